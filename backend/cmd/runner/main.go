@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/runner"
+	"Vortexia/pkg/logger"
+)
+
+// 远程执行器：向服务端注册后领取构建在本机执行
+func main() {
+	// 初始化日志
+	logger := logger.New()
+	defer logger.Sync()
+
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	// 注册执行器或读取已保存的令牌
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	r, err := runner.New(ctx, cfg)
+	cancel()
+	if err != nil {
+		log.Fatal("Failed to start runner:", err)
+	}
+
+	r.Start()
+
+	// 等待中断信号
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	logger.Info("Shutting down runner...")
+
+	// 等待执行中的构建结束，超时后退出，未完成的构建在租约过期后重新投递
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := r.Stop(ctx); err != nil {
+		logger.Warn("Runner did not stop in time")
+	}
+
+	logger.Info("Runner exiting")
+}
@@ -13,10 +13,14 @@ import (
 	"Vortexia/internal/api/routes"
 	"Vortexia/internal/config"
 	"Vortexia/internal/repository"
+	"Vortexia/internal/secrets"
 	"Vortexia/internal/service"
+	"Vortexia/internal/storage"
+	"Vortexia/internal/worker"
 	"Vortexia/pkg/logger"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // @title Vortexia API
@@ -56,14 +60,48 @@ func main() {
 	// 初始化仓库层
 	repos := repository.NewRepositories(db, redisClient)
 
+	// 初始化密钥加密使用的主密钥
+	keyring, err := secrets.NewKeyring(cfg.Secrets.MasterKeys)
+	if err != nil {
+		log.Fatal("Failed to load secrets master keys:", err)
+	}
+
+	// 初始化制品和依赖缓存的存储
+	objectStorage, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+	artifacts := storage.NewArtifactStore(repos.Artifact, objectStorage, cfg.Artifacts)
+	caches := storage.NewCacheStore(repos.Cache, objectStorage, cfg.Cache)
+
 	// 初始化服务层
-	services := service.NewServices(repos)
+	services := service.NewServices(repos, cfg, keyring, artifacts, caches)
+
+	// 轮换主密钥后用新的主密钥重新加密已保存的密钥
+	if rotated, err := services.Secret.RotateKeys(); err != nil {
+		logger.Error("Failed to rotate secrets", zap.Error(err))
+	} else if rotated > 0 {
+		logger.Info("Re-encrypted secrets with the current master key", zap.Int("count", rotated))
+	}
+	if rotated, err := services.Webhook.RotateKeys(); err != nil {
+		logger.Error("Failed to rotate webhook secrets", zap.Error(err))
+	} else if rotated > 0 {
+		logger.Info("Re-encrypted webhook secrets with the current master key", zap.Int("count", rotated))
+	}
+
+	// 启动构建工作池
+	pool := worker.NewPool(repos.Queue, repos.Cancels, services.Build, cfg.Worker)
+	pool.Start()
+
+	// 启动过期制品清理
+	cleaner := worker.NewArtifactCleaner(services.Artifact, cfg.Artifacts)
+	cleaner.Start()
 
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 
 	// 初始化路由
-	router := routes.SetupRoutes(services, logger)
+	router := routes.SetupRoutes(services, cfg, logger)
 
 	// 创建HTTP服务器
 	srv := &http.Server{
@@ -94,5 +132,11 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// 等待执行中的构建结束，未完成的构建会在租约过期后重新投递
+	if err := pool.Stop(ctx); err != nil {
+		logger.Warn("Build workers did not stop in time")
+	}
+	cleaner.Stop()
+
 	logger.Info("Server exiting")
 }
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+
+	"Vortexia/internal/middleware"
+	"Vortexia/internal/model"
+	"Vortexia/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArtifactHandler struct {
+	artifactService service.ArtifactService
+}
+
+// NewArtifactHandler 创建制品处理器
+func NewArtifactHandler(artifactService service.ArtifactService) *ArtifactHandler {
+	return &ArtifactHandler{artifactService: artifactService}
+}
+
+// ListByBuild 获取构建的制品
+// @Summary 获取构建的制品
+// @Description 获取构建各作业上传的制品，包括过期时间。只有构建所属项目的所有者和管理员可以获取
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Success 200 {object} model.APIResponse{data=[]model.Artifact}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/artifacts [get]
+func (h *ArtifactHandler) ListByBuild(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	artifacts, err := h.artifactService.ListByBuild(id, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if artifacts == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "构建不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "获取成功",
+		Data:    artifacts,
+	})
+}
+
+// Download 下载制品
+// @Summary 下载制品
+// @Description 以附件形式流式返回制品文件，响应头 X-Checksum-Sha256 为文件的SHA-256。
+// @Description 只有构建所属项目的所有者和管理员可以下载
+// @Tags 构建
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Param artifact_id path int true "制品ID"
+// @Param ticket query string false "流式请求票据，浏览器无法设置请求头时使用，只能使用一次"
+// @Success 200 {file} binary
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Failure 410 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/artifacts/{artifact_id}/download [get]
+func (h *ArtifactHandler) Download(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	artifactID, err := strconv.Atoi(c.Param("artifact_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的制品ID",
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	artifact, content, err := h.artifactService.Open(c.Request.Context(), id, artifactID, user)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrArtifactExpired) {
+			status = http.StatusGone
+		}
+		c.JSON(status, model.APIResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if artifact == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "制品不存在",
+		})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, artifact.Size, "application/octet-stream", content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(artifact.Path)}),
+		"X-Checksum-Sha256":   artifact.Checksum,
+	})
+}
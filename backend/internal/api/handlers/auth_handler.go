@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"Vortexia/internal/middleware"
 	"Vortexia/internal/model"
 	"Vortexia/internal/service"
 
@@ -55,3 +56,39 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		Data:    response,
 	})
 }
+
+// StreamTicket 签发流式请求票据
+// @Summary 签发流式请求票据
+// @Description 签发只能使用一次的短期票据，浏览器的WebSocket/EventSource和下载链接无法设置请求头时，通过 ticket 查询参数代替令牌认证
+// @Tags 认证
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.APIResponse{data=model.StreamTicketResponse}
+// @Failure 401 {object} model.APIResponse
+// @Failure 500 {object} model.APIResponse
+// @Router /api/v1/auth/stream-ticket [post]
+func (h *AuthHandler) StreamTicket(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	ticket, err := h.authService.IssueStreamTicket(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "签发成功",
+		Data:    ticket,
+	})
+}
@@ -1,78 +1,714 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"Vortexia/internal/middleware"
 	"Vortexia/internal/model"
 	"Vortexia/internal/service"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
 )
 
+// SSE心跳间隔，需小于反向代理的读超时
+const sseHeartbeatInterval = 15 * time.Second
+
 type BuildHandler struct {
-	buildService service.BuildService
+	buildService   service.BuildService
+	allowedOrigins []string // 允许建立日志WebSocket连接的页面来源，为空时只允许同源页面
 }
 
 // NewBuildHandler 创建构建处理器
-func NewBuildHandler(buildService service.BuildService) *BuildHandler {
-	return &BuildHandler{buildService: buildService}
+func NewBuildHandler(buildService service.BuildService, allowedOrigins []string) *BuildHandler {
+	return &BuildHandler{buildService: buildService, allowedOrigins: allowedOrigins}
 }
 
 // List 获取构建列表
+// @Summary 获取构建列表
+// @Description 获取构建列表
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页大小" default(20)
+// @Success 200 {object} model.APIResponse{data=model.PaginationResponse}
+// @Router /api/v1/builds [get]
 func (h *BuildHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.buildService.List(page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
-		Data:    []interface{}{},
+		Message: "获取成功",
+		Data:    result,
 	})
 }
 
 // Create 创建构建
+// @Summary 触发构建
+// @Description 为流水线创建构建并加入构建队列
+// @Tags 构建
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.TriggerBuildRequest true "触发构建请求"
+// @Success 201 {object} model.APIResponse{data=model.Build}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Router /api/v1/builds [post]
 func (h *BuildHandler) Create(c *gin.Context) {
-	c.JSON(http.StatusOK, model.APIResponse{
-		Code:    http.StatusOK,
-		Message: "功能开发中",
+	var req model.TriggerBuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	build, err := h.buildService.Create(&req, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "构建已触发",
+		Data:    build,
 	})
 }
 
 // GetByID 根据ID获取构建
+// @Summary 根据ID获取构建
+// @Description 根据ID获取构建详情
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Success 200 {object} model.APIResponse{data=model.Build}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/builds/{id} [get]
 func (h *BuildHandler) GetByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	build, err := h.buildService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if build == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "构建不存在",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
+		Message: "获取成功",
+		Data:    build,
 	})
 }
 
 // UpdateStatus 更新构建状态
+// @Summary 更新构建状态
+// @Description 更新构建状态
+// @Tags 构建
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Param request body model.UpdateBuildStatusRequest true "构建状态"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/status [put]
 func (h *BuildHandler) UpdateStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	var req model.UpdateBuildStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Status == model.BuildStatusCanceled {
+		// 取消需要终止执行中的步骤，不能只修改状态
+		h.cancel(c, id)
+		return
+	}
+
+	if err := h.buildService.UpdateStatus(id, req.Status); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
+		Message: "更新成功",
+	})
+}
+
+// Cancel 取消构建
+// @Summary 取消构建
+// @Description 取消等待中或执行中的构建，执行中的步骤会被终止，其余步骤标记为跳过
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Success 200 {object} model.APIResponse{data=model.Build}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/cancel [post]
+func (h *BuildHandler) Cancel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	h.cancel(c, id)
+}
+
+func (h *BuildHandler) cancel(c *gin.Context, id int) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	build, err := h.buildService.Cancel(c.Request.Context(), id, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if build == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "构建不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "构建已取消",
+		Data:    build,
+	})
+}
+
+// Rerun 重新执行构建
+// @Summary 重新执行构建
+// @Description 以原构建的流水线、分支、提交和配置快照创建新构建。mode=failed 时复用原构建中成功步骤的结果，只执行失败和跳过的步骤
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Param mode query string false "重新执行方式" Enums(all, failed) default(all)
+// @Success 201 {object} model.APIResponse{data=model.Build}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/rerun [post]
+func (h *BuildHandler) Rerun(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	var req model.RerunBuildRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	build, err := h.buildService.Rerun(id, req.Mode, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if build == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "构建不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "构建已触发",
+		Data:    build,
 	})
 }
 
 // GetSteps 获取构建步骤
+// @Summary 获取构建步骤
+// @Description 获取构建的全部步骤及每个步骤的执行记录，步骤日志通过日志接口读取
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Success 200 {object} model.APIResponse{data=[]model.BuildStep}
+// @Failure 400 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/steps [get]
 func (h *BuildHandler) GetSteps(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	steps, err := h.buildService.GetSteps(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "获取成功",
+		Data:    steps,
+	})
+}
+
+// GetGraph 获取构建的作业依赖图
+// @Summary 获取构建的作业依赖图
+// @Description 获取构建的阶段、作业（包含各自的步骤）以及作业之间的依赖关系，用于绘制流水线图
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Success 200 {object} model.APIResponse{data=model.BuildGraph}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/graph [get]
+func (h *BuildHandler) GetGraph(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	graph, err := h.buildService.GetGraph(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if graph == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "构建不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "获取成功",
+		Data:    graph,
+	})
+}
+
+// GetStepLog 读取构建步骤日志
+// @Summary 读取构建步骤日志
+// @Description 按字节范围（offset、limit）或行范围（line、lines）读取步骤日志，指定 line 时按行读取
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Param step_id path int true "步骤ID"
+// @Param offset query int false "起始字节偏移" default(0)
+// @Param limit query int false "读取字节数" default(65536)
+// @Param line query int false "起始行号（从0开始）"
+// @Param lines query int false "读取行数" default(500)
+// @Param attempt query int false "只读取第几次执行的输出，偏移量和行号相对于该次执行"
+// @Success 200 {object} model.APIResponse{data=model.StepLog}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/steps/{step_id}/log [get]
+func (h *BuildHandler) GetStepLog(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return
+	}
+
+	stepID, err := strconv.Atoi(c.Param("step_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的步骤ID",
+		})
+		return
+	}
+
+	attempt, err := strconv.Atoi(c.DefaultQuery("attempt", "0"))
+	if err != nil || attempt < 0 {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的执行次数",
+		})
+		return
+	}
+
+	var log *model.StepLog
+	if line, ok := c.GetQuery("line"); ok {
+		from, convErr := strconv.Atoi(line)
+		if convErr != nil || from < 0 {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Code:    http.StatusBadRequest,
+				Message: "无效的行号",
+			})
+			return
+		}
+		lines, _ := strconv.Atoi(c.Query("lines"))
+		log, err = h.buildService.GetStepLogLines(id, stepID, attempt, from, lines)
+	} else {
+		offset, convErr := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+		if convErr != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Code:    http.StatusBadRequest,
+				Message: "无效的偏移量",
+			})
+			return
+		}
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		log, err = h.buildService.GetStepLogBytes(id, stepID, attempt, offset, limit)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if log == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "构建步骤不存在",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
-		Data:    []interface{}{},
+		Message: "获取成功",
+		Data:    log,
 	})
 }
 
 // GetByPipeline 根据流水线获取构建
+// @Summary 获取流水线的构建
+// @Description 获取指定流水线的构建列表
+// @Tags 构建
+// @Produce json
+// @Security ApiKeyAuth
+// @Param pipeline_id path int true "流水线ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页大小" default(20)
+// @Success 200 {object} model.APIResponse{data=model.PaginationResponse}
+// @Failure 400 {object} model.APIResponse
+// @Router /api/v1/builds/pipeline/{pipeline_id} [get]
 func (h *BuildHandler) GetByPipeline(c *gin.Context) {
+	pipelineID, err := strconv.Atoi(c.Param("pipeline_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的流水线ID",
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.buildService.GetByPipeline(pipelineID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
-		Data:    []interface{}{},
+		Message: "获取成功",
+		Data:    result,
 	})
 }
 
 // WatchLogs WebSocket日志流
+// @Summary 实时构建日志（WebSocket）
+// @Description 升级为WebSocket连接，先回放已有日志，再实时推送 step_start/log/step_end 事件，构建结束后发送 build_end 并关闭连接
+// @Tags 构建
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Param after query int false "只推送序号大于该值的事件，用于断线续传"
+// @Param ticket query string false "流式请求票据，浏览器无法设置请求头时使用，只能使用一次"
+// @Success 101 {object} model.LogEvent
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/ws/builds/{id}/logs [get]
 func (h *BuildHandler) WatchLogs(c *gin.Context) {
-	c.JSON(http.StatusOK, model.APIResponse{
-		Code:    http.StatusOK,
-		Message: "WebSocket功能开发中",
+	build, ok := h.getBuild(c)
+	if !ok {
+		return
+	}
+	after, _ := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+
+	server := websocket.Server{
+		// CORS不适用于WebSocket握手，需要校验Origin，否则任意网站都能以用户的身份打开连接
+		Handshake: func(_ *websocket.Config, req *http.Request) error {
+			return checkOrigin(req, h.allowedOrigins)
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+
+			// 客户端不发送数据，读取失败说明连接已断开
+			go func() {
+				var msg string
+				for websocket.Message.Receive(ws, &msg) == nil {
+				}
+				cancel()
+			}()
+
+			events, err := h.buildService.WatchLogs(ctx, build.ID, after)
+			if err != nil {
+				_ = websocket.JSON.Send(ws, model.APIResponse{
+					Code:    http.StatusInternalServerError,
+					Message: err.Error(),
+				})
+				return
+			}
+
+			for event := range events {
+				if err := websocket.JSON.Send(ws, event); err != nil {
+					return
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// checkOrigin 校验WebSocket握手请求的来源：没有 Origin 的非浏览器客户端允许连接，
+// 浏览器页面的来源需在 allowed 中，未配置时需与请求的主机相同
+func checkOrigin(req *http.Request, allowed []string) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		if err == nil && strings.EqualFold(u.Host, req.Host) {
+			return nil
+		}
+	}
+	for _, o := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+// StreamLogs SSE日志流
+// @Summary 实时构建日志（SSE）
+// @Description 以Server-Sent Events推送构建日志，适用于不支持WebSocket的网络环境。事件ID为日志序号，重连时通过Last-Event-ID续传
+// @Tags 构建
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param id path int true "构建ID"
+// @Param Last-Event-ID header int false "最后收到的事件ID"
+// @Param after query int false "只推送序号大于该值的事件，未提供Last-Event-ID时使用"
+// @Param ticket query string false "流式请求票据，浏览器无法设置请求头时使用，只能使用一次"
+// @Success 200 {object} model.LogEvent
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/builds/{id}/logs/stream [get]
+func (h *BuildHandler) StreamLogs(c *gin.Context) {
+	build, ok := h.getBuild(c)
+	if !ok {
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.DefaultQuery("after", "0")
+	}
+	after, _ := strconv.ParseInt(lastEventID, 10, 64)
+
+	events, err := h.buildService.WatchLogs(c.Request.Context(), build.ID, after)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭Nginx缓冲
+
+	// 定期发送注释行，防止代理因空闲断开连接
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			id := ""
+			if event.Seq > 0 {
+				id = strconv.FormatInt(event.Seq, 10)
+			}
+			c.Render(-1, sse.Event{Id: id, Event: event.Type, Data: event})
+			return true
+		case <-heartbeat.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+			return true
+		}
 	})
 }
+
+// getBuild 解析路径中的构建ID并获取构建，失败时已写入响应
+func (h *BuildHandler) getBuild(c *gin.Context) (*model.Build, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的构建ID",
+		})
+		return nil, false
+	}
+
+	build, err := h.buildService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+
+	if build == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "构建不存在",
+		})
+		return nil, false
+	}
+
+	return build, true
+}
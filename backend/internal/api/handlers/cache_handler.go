@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"Vortexia/internal/middleware"
+	"Vortexia/internal/model"
+	"Vortexia/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CacheHandler struct {
+	cacheService service.CacheService
+}
+
+// NewCacheHandler 创建依赖缓存处理器
+func NewCacheHandler(cacheService service.CacheService) *CacheHandler {
+	return &CacheHandler{cacheService: cacheService}
+}
+
+// ListByProject 获取项目的缓存
+// @Summary 获取项目的缓存
+// @Description 获取项目的依赖缓存，按最近使用时间倒序
+// @Tags 缓存
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "项目ID"
+// @Success 200 {object} model.APIResponse{data=[]model.Cache}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/projects/{id}/caches [get]
+func (h *CacheHandler) ListByProject(c *gin.Context) {
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	caches, err := h.cacheService.ListByProject(id, user)
+	h.respondList(c, caches, err, "获取成功")
+}
+
+// Purge 清空项目的缓存
+// @Summary 清空项目的缓存
+// @Description 删除项目的全部依赖缓存，返回删除的缓存
+// @Tags 缓存
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "项目ID"
+// @Success 200 {object} model.APIResponse{data=[]model.Cache}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/projects/{id}/caches [delete]
+func (h *CacheHandler) Purge(c *gin.Context) {
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	caches, err := h.cacheService.Purge(c.Request.Context(), id, user)
+	h.respondList(c, caches, err, "缓存已清空")
+}
+
+// Delete 删除缓存
+// @Summary 删除缓存
+// @Description 删除项目的一个依赖缓存
+// @Tags 缓存
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "项目ID"
+// @Param cache_id path int true "缓存ID"
+// @Success 200 {object} model.APIResponse{data=model.Cache}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/projects/{id}/caches/{cache_id} [delete]
+func (h *CacheHandler) Delete(c *gin.Context) {
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+
+	cacheID, err := strconv.Atoi(c.Param("cache_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的缓存ID",
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	cache, err := h.cacheService.Delete(c.Request.Context(), id, cacheID, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if cache == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "缓存不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "缓存已删除",
+		Data:    cache,
+	})
+}
+
+func (h *CacheHandler) respondList(c *gin.Context, caches []*model.Cache, err error, message string) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if caches == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "项目不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: message,
+		Data:    caches,
+	})
+}
+
+// parseProjectID 解析路径中的项目ID，无效时写入错误响应
+func parseProjectID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的项目ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
 	"Vortexia/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -19,51 +22,242 @@ func NewPipelineHandler(pipelineService service.PipelineService) *PipelineHandle
 }
 
 // List 获取流水线列表
+// @Summary 获取流水线列表
+// @Description 获取流水线列表
+// @Tags 流水线
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页大小" default(20)
+// @Success 200 {object} model.APIResponse{data=model.PaginationResponse}
+// @Router /api/v1/pipelines [get]
 func (h *PipelineHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.pipelineService.List(page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
-		Data:    []interface{}{},
+		Message: "获取成功",
+		Data:    result,
 	})
 }
 
 // Create 创建流水线
+// @Summary 创建流水线
+// @Description 创建新流水线，配置无效时返回全部错误及其行列位置
+// @Tags 流水线
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.CreatePipelineRequest true "创建流水线请求"
+// @Success 201 {object} model.APIResponse{data=model.Pipeline}
+// @Failure 400 {object} model.APIResponse{data=[]pipeline.ValidationError}
+// @Router /api/v1/pipelines [post]
 func (h *PipelineHandler) Create(c *gin.Context) {
-	c.JSON(http.StatusOK, model.APIResponse{
-		Code:    http.StatusOK,
-		Message: "功能开发中",
+	var req model.CreatePipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	p, err := h.pipelineService.Create(&req)
+	if err != nil {
+		writePipelineError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "创建成功",
+		Data:    p,
 	})
 }
 
 // GetByID 根据ID获取流水线
+// @Summary 根据ID获取流水线
+// @Description 根据ID获取流水线详情
+// @Tags 流水线
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "流水线ID"
+// @Success 200 {object} model.APIResponse{data=model.Pipeline}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/pipelines/{id} [get]
 func (h *PipelineHandler) GetByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的流水线ID",
+		})
+		return
+	}
+
+	p, err := h.pipelineService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if p == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "流水线不存在",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
+		Message: "获取成功",
+		Data:    p,
 	})
 }
 
 // Update 更新流水线
+// @Summary 更新流水线
+// @Description 更新流水线信息，配置无效时返回全部错误及其行列位置
+// @Tags 流水线
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "流水线ID"
+// @Param request body model.Pipeline true "流水线信息"
+// @Success 200 {object} model.APIResponse{data=model.Pipeline}
+// @Failure 400 {object} model.APIResponse{data=[]pipeline.ValidationError}
+// @Router /api/v1/pipelines/{id} [put]
 func (h *PipelineHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的流水线ID",
+		})
+		return
+	}
+
+	var req model.Pipeline
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	req.ID = id
+	if err := h.pipelineService.Update(&req); err != nil {
+		writePipelineError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
+		Message: "更新成功",
+		Data:    req,
 	})
 }
 
 // Delete 删除流水线
+// @Summary 删除流水线
+// @Description 删除流水线
+// @Tags 流水线
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "流水线ID"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Router /api/v1/pipelines/{id} [delete]
 func (h *PipelineHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的流水线ID",
+		})
+		return
+	}
+
+	if err := h.pipelineService.Delete(id); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
+		Message: "删除成功",
 	})
 }
 
 // GetByProject 根据项目获取流水线
+// @Summary 获取项目的流水线
+// @Description 获取指定项目下的流水线列表
+// @Tags 流水线
+// @Produce json
+// @Security ApiKeyAuth
+// @Param project_id path int true "项目ID"
+// @Success 200 {object} model.APIResponse{data=[]model.Pipeline}
+// @Failure 400 {object} model.APIResponse
+// @Router /api/v1/pipelines/project/{project_id} [get]
 func (h *PipelineHandler) GetByProject(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的项目ID",
+		})
+		return
+	}
+
+	pipelines, err := h.pipelineService.GetByProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    http.StatusOK,
-		Message: "功能开发中",
-		Data:    []interface{}{},
+		Message: "获取成功",
+		Data:    pipelines,
+	})
+}
+
+// writePipelineError 输出流水线相关错误，配置校验错误附带完整的错误列表
+func writePipelineError(c *gin.Context, err error) {
+	var verrs pipeline.ValidationErrors
+	if errors.As(err, &verrs) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "流水线配置无效",
+			Data:    verrs,
+		})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, model.APIResponse{
+		Code:    http.StatusBadRequest,
+		Message: err.Error(),
 	})
 }
@@ -0,0 +1,954 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Vortexia/internal/middleware"
+	"Vortexia/internal/model"
+	"Vortexia/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRunnerLogChunk 执行器单次上报的步骤日志上限，执行器每秒上报一次，正常不会超过
+const maxRunnerLogChunk = 4 * 1024 * 1024
+
+type RunnerHandler struct {
+	runnerService service.RunnerService
+}
+
+// NewRunnerHandler 创建执行器处理器
+func NewRunnerHandler(runnerService service.RunnerService) *RunnerHandler {
+	return &RunnerHandler{runnerService: runnerService}
+}
+
+// List 获取执行器列表
+// @Summary 获取执行器列表
+// @Description 获取已注册的远程执行器及其在线状态
+// @Tags 执行器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页大小" default(20)
+// @Success 200 {object} model.APIResponse{data=model.PaginationResponse}
+// @Router /api/v1/runners [get]
+func (h *RunnerHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.runnerService.List(page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "获取成功",
+		Data:    result,
+	})
+}
+
+// Pause 暂停执行器
+// @Summary 暂停执行器
+// @Description 暂停的执行器执行完当前构建后不再领取新构建
+// @Tags 执行器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "执行器ID"
+// @Success 200 {object} model.APIResponse{data=model.Runner}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/runners/{id}/pause [post]
+func (h *RunnerHandler) Pause(c *gin.Context) {
+	h.setPaused(c, true, "执行器已暂停")
+}
+
+// Resume 恢复执行器
+// @Summary 恢复执行器
+// @Description 恢复被暂停的执行器
+// @Tags 执行器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "执行器ID"
+// @Success 200 {object} model.APIResponse{data=model.Runner}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/runners/{id}/resume [post]
+func (h *RunnerHandler) Resume(c *gin.Context) {
+	h.setPaused(c, false, "执行器已恢复")
+}
+
+func (h *RunnerHandler) setPaused(c *gin.Context, paused bool, message string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的执行器ID",
+		})
+		return
+	}
+
+	runner, err := h.runnerService.SetPaused(id, paused)
+	h.respondRunner(c, runner, err, message)
+}
+
+// Revoke 吊销执行器
+// @Summary 吊销执行器
+// @Description 吊销后执行器令牌立即失效，其执行中的构建在租约过期后重新投递
+// @Tags 执行器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "执行器ID"
+// @Success 200 {object} model.APIResponse{data=model.Runner}
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/runners/{id}/revoke [post]
+func (h *RunnerHandler) Revoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的执行器ID",
+		})
+		return
+	}
+
+	runner, err := h.runnerService.Revoke(id)
+	h.respondRunner(c, runner, err, "执行器已吊销")
+}
+
+func (h *RunnerHandler) respondRunner(c *gin.Context, runner *model.Runner, err error, message string) {
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if runner == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "执行器不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: message,
+		Data:    runner,
+	})
+}
+
+// Register 注册执行器
+// @Summary 注册执行器
+// @Description 使用服务端配置的注册令牌注册执行器，返回的执行器令牌只出现这一次
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Param request body model.RegisterRunnerRequest true "注册请求"
+// @Success 201 {object} model.APIResponse{data=model.RegisterRunnerResponse}
+// @Failure 400 {object} model.APIResponse
+// @Router /api/v1/runners/register [post]
+func (h *RunnerHandler) Register(c *gin.Context) {
+	var req model.RegisterRunnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.runnerService.Register(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "注册成功",
+		Data:    resp,
+	})
+}
+
+// Heartbeat 执行器心跳
+// @Summary 执行器心跳
+// @Description 上报执行器在线、当前标签和正在执行的构建，为这些构建续租，返回需要终止的构建
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param request body model.RunnerHeartbeatRequest true "心跳请求"
+// @Success 200 {object} model.APIResponse{data=model.RunnerHeartbeatResponse}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Router /api/v1/runners/heartbeat [post]
+func (h *RunnerHandler) Heartbeat(c *gin.Context) {
+	runner, ok := currentRunner(c)
+	if !ok {
+		return
+	}
+
+	var req model.RunnerHeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.runnerService.Heartbeat(c.Request.Context(), runner, &req)
+	if err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "ok",
+		Data:    resp,
+	})
+}
+
+// RequestJob 领取构建
+// @Summary 领取构建
+// @Description 长轮询领取一个待执行的构建，等待期间没有构建时返回204
+// @Tags 执行器API
+// @Produce json
+// @Security RunnerToken
+// @Success 200 {object} model.APIResponse{data=model.RunnerJob}
+// @Success 204
+// @Failure 401 {object} model.APIResponse
+// @Router /api/v1/runners/jobs [get]
+func (h *RunnerHandler) RequestJob(c *gin.Context) {
+	runner, ok := currentRunner(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.runnerService.RequestJob(c.Request.Context(), runner)
+	if err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	if job == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "ok",
+		Data:    job,
+	})
+}
+
+// GetJobStatus 获取构建状态
+// @Summary 获取构建状态
+// @Description 执行器在步骤之间查询构建状态，以发现丢失了取消通知的构建
+// @Tags 执行器API
+// @Produce json
+// @Security RunnerToken
+// @Param id path int true "构建ID"
+// @Success 200 {object} model.APIResponse{data=model.RunnerBuildStatus}
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/jobs/{id}/status [get]
+func (h *RunnerHandler) GetJobStatus(c *gin.Context) {
+	runner, id, ok := runnerAndID(c, "id", "无效的构建ID")
+	if !ok {
+		return
+	}
+
+	status, err := h.runnerService.GetJobStatus(runner, id)
+	if err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "ok",
+		Data:    model.RunnerBuildStatus{Status: status},
+	})
+}
+
+// UpdateCommit 上报检出的提交
+// @Summary 上报检出的提交
+// @Description 构建未指定提交时，执行器检出分支的最新提交后上报
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param id path int true "构建ID"
+// @Param request body model.RunnerCommitUpdate true "提交"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/jobs/{id}/commit [put]
+func (h *RunnerHandler) UpdateCommit(c *gin.Context) {
+	runner, id, ok := runnerAndID(c, "id", "无效的构建ID")
+	if !ok {
+		return
+	}
+
+	var req model.RunnerCommitUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.runnerService.UpdateCommit(runner, id, req.Commit); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "更新成功",
+	})
+}
+
+// FinishJob 结束构建
+// @Summary 结束构建
+// @Description 上报构建的最终状态，构建移出构建队列
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param id path int true "构建ID"
+// @Param request body model.RunnerBuildStatus true "最终状态"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/jobs/{id}/finish [post]
+func (h *RunnerHandler) FinishJob(c *gin.Context) {
+	runner, id, ok := runnerAndID(c, "id", "无效的构建ID")
+	if !ok {
+		return
+	}
+
+	var req model.RunnerBuildStatus
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.runnerService.FinishJob(c.Request.Context(), runner, id, req.Status); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "构建已结束",
+	})
+}
+
+// PublishEvents 上报日志事件
+// @Summary 上报日志事件
+// @Description 批量上报步骤开始、输出行和步骤结束等事件，转发给实时日志订阅者
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param request body []model.LogEvent true "日志事件"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/events [post]
+func (h *RunnerHandler) PublishEvents(c *gin.Context) {
+	runner, ok := currentRunner(c)
+	if !ok {
+		return
+	}
+
+	var events []*model.LogEvent
+	if err := c.ShouldBindJSON(&events); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.runnerService.PublishEvents(c.Request.Context(), runner, events); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "ok",
+	})
+}
+
+// UpdateBuildJob 更新构建作业
+// @Summary 更新构建作业
+// @Description 上报构建作业的状态
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param job_id path int true "作业ID"
+// @Param request body model.RunnerBuildJobUpdate true "作业状态"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/build-jobs/{job_id} [put]
+func (h *RunnerHandler) UpdateBuildJob(c *gin.Context) {
+	runner, jobID, ok := runnerAndID(c, "job_id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	var req model.RunnerBuildJobUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.runnerService.UpdateBuildJob(runner, jobID, req.Status); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "更新成功",
+	})
+}
+
+// UploadArtifact 上传制品
+// @Summary 上传制品
+// @Description 请求体为制品文件的原始内容，必须带有 Content-Length，同一作业中相同路径的制品被覆盖
+// @Tags 执行器API
+// @Accept octet-stream
+// @Produce json
+// @Security RunnerToken
+// @Param job_id path int true "作业ID"
+// @Param path query string true "文件在工作目录中的相对路径"
+// @Param mode query string false "文件的权限位（八进制），未指定时为 644"
+// @Param expires_at query string false "过期时间（RFC3339），未指定时使用服务端的默认保存时长"
+// @Success 201 {object} model.APIResponse{data=model.Artifact}
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Failure 411 {object} model.APIResponse
+// @Router /api/v1/runners/build-jobs/{job_id}/artifacts [post]
+func (h *RunnerHandler) UploadArtifact(c *gin.Context) {
+	runner, jobID, ok := runnerAndID(c, "job_id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	artifact := &model.Artifact{JobID: jobID, Path: c.Query("path")}
+	if value := c.Query("mode"); value != "" {
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Code:    http.StatusBadRequest,
+				Message: "无效的文件权限",
+			})
+			return
+		}
+		artifact.Mode = uint32(mode)
+	}
+	if value := c.Query("expires_at"); value != "" {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Code:    http.StatusBadRequest,
+				Message: "无效的过期时间",
+			})
+			return
+		}
+		artifact.ExpiresAt = &expiresAt
+	}
+
+	if c.Request.ContentLength < 0 {
+		c.JSON(http.StatusLengthRequired, model.APIResponse{
+			Code:    http.StatusLengthRequired,
+			Message: "缺少 Content-Length",
+		})
+		return
+	}
+
+	if err := h.runnerService.UploadArtifact(c.Request.Context(), runner, artifact, c.Request.Body, c.Request.ContentLength); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "上传成功",
+		Data:    artifact,
+	})
+}
+
+// DependencyArtifacts 获取上游作业的制品
+// @Summary 获取上游作业的制品
+// @Description 获取作业所在构建中上游作业的制品，上游作业的步骤均复用了原构建的结果时返回原构建中的制品
+// @Tags 执行器API
+// @Produce json
+// @Security RunnerToken
+// @Param job_id path int true "作业ID"
+// @Param job query string true "上游作业的名称"
+// @Success 200 {object} model.APIResponse{data=[]model.Artifact}
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/build-jobs/{job_id}/dependencies [get]
+func (h *RunnerHandler) DependencyArtifacts(c *gin.Context) {
+	runner, jobID, ok := runnerAndID(c, "job_id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	upstream := c.Query("job")
+	if upstream == "" {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "缺少上游作业的名称",
+		})
+		return
+	}
+
+	artifacts, err := h.runnerService.DependencyArtifacts(c.Request.Context(), runner, jobID, upstream)
+	if err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "获取成功",
+		Data:    artifacts,
+	})
+}
+
+// DownloadDependencyArtifact 下载上游作业的制品
+// @Summary 下载上游作业的制品
+// @Description 流式返回上游作业的制品文件，响应头 X-Checksum-Sha256 为文件的SHA-256
+// @Tags 执行器API
+// @Produce octet-stream
+// @Security RunnerToken
+// @Param job_id path int true "作业ID"
+// @Param artifact_id path int true "制品ID"
+// @Success 200 {file} binary
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Failure 410 {object} model.APIResponse
+// @Router /api/v1/runners/build-jobs/{job_id}/dependencies/{artifact_id} [get]
+func (h *RunnerHandler) DownloadDependencyArtifact(c *gin.Context) {
+	runner, jobID, ok := runnerAndID(c, "job_id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	artifactID, err := strconv.Atoi(c.Param("artifact_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的制品ID",
+		})
+		return
+	}
+
+	artifact, content, err := h.runnerService.OpenDependencyArtifact(c.Request.Context(), runner, jobID, artifactID)
+	if errors.Is(err, service.ErrArtifactExpired) {
+		c.JSON(http.StatusGone, model.APIResponse{
+			Code:    http.StatusGone,
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	if artifact == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "制品不存在",
+		})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, artifact.Size, "application/octet-stream", content, map[string]string{
+		"X-Checksum-Sha256": artifact.Checksum,
+	})
+}
+
+// FindCache 查找缓存
+// @Summary 查找缓存
+// @Description 在作业所属的项目中按 key 精确匹配、按 restore_keys 依次前缀匹配查找缓存，未命中时返回204
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param job_id path int true "作业ID"
+// @Param request body model.RunnerCacheQuery true "缓存键"
+// @Success 200 {object} model.APIResponse{data=model.Cache}
+// @Success 204
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/build-jobs/{job_id}/caches/restore [post]
+func (h *RunnerHandler) FindCache(c *gin.Context) {
+	runner, jobID, ok := runnerAndID(c, "job_id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	var req model.RunnerCacheQuery
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	cache, err := h.runnerService.FindCache(runner, jobID, &req)
+	if err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	if cache == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "获取成功",
+		Data:    cache,
+	})
+}
+
+// DownloadCache 下载缓存
+// @Summary 下载缓存
+// @Description 流式返回缓存的 tar.gz 压缩包
+// @Tags 执行器API
+// @Produce octet-stream
+// @Security RunnerToken
+// @Param job_id path int true "作业ID"
+// @Param cache_id path int true "缓存ID"
+// @Success 200 {file} binary
+// @Failure 400 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/build-jobs/{job_id}/caches/{cache_id} [get]
+func (h *RunnerHandler) DownloadCache(c *gin.Context) {
+	runner, jobID, ok := runnerAndID(c, "job_id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	cacheID, err := strconv.Atoi(c.Param("cache_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的缓存ID",
+		})
+		return
+	}
+
+	cache, content, err := h.runnerService.OpenCache(c.Request.Context(), runner, jobID, cacheID)
+	if err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	if cache == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "缓存不存在",
+		})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, cache.Size, "application/gzip", content, map[string]string{
+		"X-Checksum-Sha256": cache.Checksum,
+	})
+}
+
+// SaveCache 保存缓存
+// @Summary 保存缓存
+// @Description 请求体为缓存的 tar.gz 压缩包，必须带有 Content-Length，项目中相同缓存键的缓存被覆盖
+// @Tags 执行器API
+// @Accept octet-stream
+// @Produce json
+// @Security RunnerToken
+// @Param job_id path int true "作业ID"
+// @Param key query string true "缓存键"
+// @Success 201 {object} model.APIResponse{data=model.Cache}
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Failure 411 {object} model.APIResponse
+// @Router /api/v1/runners/build-jobs/{job_id}/caches [post]
+func (h *RunnerHandler) SaveCache(c *gin.Context) {
+	runner, jobID, ok := runnerAndID(c, "job_id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	if c.Request.ContentLength < 0 {
+		c.JSON(http.StatusLengthRequired, model.APIResponse{
+			Code:    http.StatusLengthRequired,
+			Message: "缺少 Content-Length",
+		})
+		return
+	}
+
+	cache := &model.Cache{Key: c.Query("key")}
+	if err := h.runnerService.SaveCache(c.Request.Context(), runner, jobID, cache, c.Request.Body, c.Request.ContentLength); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "保存成功",
+		Data:    cache,
+	})
+}
+
+// UpdateStep 更新步骤
+// @Summary 更新步骤
+// @Description 上报步骤的状态、退出码或复用的原步骤
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param step_id path int true "步骤ID"
+// @Param request body model.RunnerStepUpdate true "步骤更新"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/steps/{step_id} [put]
+func (h *RunnerHandler) UpdateStep(c *gin.Context) {
+	runner, stepID, ok := runnerAndID(c, "step_id", "无效的步骤ID")
+	if !ok {
+		return
+	}
+
+	var req model.RunnerStepUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.runnerService.UpdateStep(runner, stepID, &req); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "更新成功",
+	})
+}
+
+// AppendStepLog 追加步骤日志
+// @Summary 追加步骤日志
+// @Description 请求体为原始的步骤输出，追加到步骤日志末尾
+// @Tags 执行器API
+// @Accept octet-stream
+// @Produce json
+// @Security RunnerToken
+// @Param step_id path int true "步骤ID"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/steps/{step_id}/log [post]
+func (h *RunnerHandler) AppendStepLog(c *gin.Context) {
+	runner, stepID, ok := runnerAndID(c, "step_id", "无效的步骤ID")
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRunnerLogChunk))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "读取日志失败: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.runnerService.AppendStepLog(runner, stepID, data); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "ok",
+	})
+}
+
+// CreateStepAttempt 创建步骤执行记录
+// @Summary 创建步骤执行记录
+// @Description 步骤每次开始执行（包括重试）时创建执行记录
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param step_id path int true "步骤ID"
+// @Param request body model.BuildStepAttempt true "执行记录"
+// @Success 201 {object} model.APIResponse{data=model.BuildStepAttempt}
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/steps/{step_id}/attempts [post]
+func (h *RunnerHandler) CreateStepAttempt(c *gin.Context) {
+	runner, stepID, ok := runnerAndID(c, "step_id", "无效的步骤ID")
+	if !ok {
+		return
+	}
+
+	var attempt model.BuildStepAttempt
+	if err := c.ShouldBindJSON(&attempt); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	attempt.StepID = stepID
+
+	if err := h.runnerService.CreateStepAttempt(runner, &attempt); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "创建成功",
+		Data:    attempt,
+	})
+}
+
+// FinishStepAttempt 写入步骤执行结果
+// @Summary 写入步骤执行结果
+// @Description 步骤的一次执行结束后写入状态、退出码和输出范围
+// @Tags 执行器API
+// @Accept json
+// @Produce json
+// @Security RunnerToken
+// @Param step_id path int true "步骤ID"
+// @Param attempt path int true "第几次执行"
+// @Param request body model.BuildStepAttempt true "执行记录"
+// @Success 200 {object} model.APIResponse
+// @Failure 400 {object} model.APIResponse
+// @Failure 409 {object} model.APIResponse
+// @Router /api/v1/runners/steps/{step_id}/attempts/{attempt} [put]
+func (h *RunnerHandler) FinishStepAttempt(c *gin.Context) {
+	runner, stepID, ok := runnerAndID(c, "step_id", "无效的步骤ID")
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(c.Param("attempt"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的执行次数",
+		})
+		return
+	}
+
+	var attempt model.BuildStepAttempt
+	if err := c.ShouldBindJSON(&attempt); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	attempt.StepID = stepID
+	attempt.Attempt = n
+
+	if err := h.runnerService.FinishStepAttempt(runner, &attempt); err != nil {
+		respondRunnerAPIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "更新成功",
+	})
+}
+
+// currentRunner 获取发起请求的执行器，不存在时写入错误响应
+func currentRunner(c *gin.Context) (*model.Runner, bool) {
+	runner, exists := middleware.GetCurrentRunner(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "执行器信息不存在",
+		})
+		return nil, false
+	}
+	return runner, true
+}
+
+// runnerAndID 获取发起请求的执行器和路径中的ID，失败时写入错误响应
+func runnerAndID(c *gin.Context, param, invalidMessage string) (*model.Runner, int, bool) {
+	runner, ok := currentRunner(c)
+	if !ok {
+		return nil, 0, false
+	}
+
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: invalidMessage,
+		})
+		return nil, 0, false
+	}
+	return runner, id, true
+}
+
+// respondRunnerAPIError 构建已不再分配给执行器时返回409，执行器据此放弃该构建
+func respondRunnerAPIError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, service.ErrJobNotAssigned) {
+		status = http.StatusConflict
+	}
+	c.JSON(status, model.APIResponse{
+		Code:    status,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"Vortexia/internal/middleware"
+	"Vortexia/internal/model"
+	"Vortexia/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SecretHandler struct {
+	secretService service.SecretService
+}
+
+// NewSecretHandler 创建密钥处理器
+func NewSecretHandler(secretService service.SecretService) *SecretHandler {
+	return &SecretHandler{secretService: secretService}
+}
+
+// ListByProject 获取项目的密钥
+// @Summary 获取项目的密钥
+// @Description 获取项目的密钥名称，不返回密钥的值
+// @Tags 密钥
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "项目ID"
+// @Success 200 {object} model.APIResponse{data=[]model.Secret}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/projects/{id}/secrets [get]
+func (h *SecretHandler) ListByProject(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的项目ID",
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	secrets, err := h.secretService.ListByProject(id, user)
+	h.respondList(c, secrets, err, "项目不存在")
+}
+
+// ListByPipeline 获取流水线的密钥
+// @Summary 获取流水线的密钥
+// @Description 获取流水线的密钥名称，不返回密钥的值，也不包括流水线所属项目的密钥
+// @Tags 密钥
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "流水线ID"
+// @Success 200 {object} model.APIResponse{data=[]model.Secret}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/pipelines/{id}/secrets [get]
+func (h *SecretHandler) ListByPipeline(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的流水线ID",
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	secrets, err := h.secretService.ListByPipeline(id, user)
+	h.respondList(c, secrets, err, "流水线不存在")
+}
+
+func (h *SecretHandler) respondList(c *gin.Context, secrets []*model.Secret, err error, notFound string) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if secrets == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: notFound,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "获取成功",
+		Data:    secrets,
+	})
+}
+
+// CreateForProject 创建项目的密钥
+// @Summary 创建项目的密钥
+// @Description 创建注入项目全部构建的密钥，值加密保存且不会再返回
+// @Tags 密钥
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "项目ID"
+// @Param request body model.CreateSecretRequest true "创建密钥请求"
+// @Success 201 {object} model.APIResponse{data=model.Secret}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/projects/{id}/secrets [post]
+func (h *SecretHandler) CreateForProject(c *gin.Context) {
+	h.create(c, "无效的项目ID", "项目不存在", h.secretService.CreateForProject)
+}
+
+// CreateForPipeline 创建流水线的密钥
+// @Summary 创建流水线的密钥
+// @Description 创建只注入该流水线构建的密钥，覆盖同名的项目密钥，值加密保存且不会再返回
+// @Tags 密钥
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "流水线ID"
+// @Param request body model.CreateSecretRequest true "创建密钥请求"
+// @Success 201 {object} model.APIResponse{data=model.Secret}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/pipelines/{id}/secrets [post]
+func (h *SecretHandler) CreateForPipeline(c *gin.Context) {
+	h.create(c, "无效的流水线ID", "流水线不存在", h.secretService.CreateForPipeline)
+}
+
+func (h *SecretHandler) create(c *gin.Context, invalidID, notFound string,
+	create func(int, *model.CreateSecretRequest, *model.User) (*model.Secret, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: invalidID,
+		})
+		return
+	}
+
+	var req model.CreateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	secret, err := create(id, &req, user)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if secret == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: notFound,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "密钥已创建",
+		Data:    secret,
+	})
+}
+
+// Update 修改密钥的值
+// @Summary 修改密钥的值
+// @Description 用新的值替换密钥，之后开始的构建使用新的值
+// @Tags 密钥
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "密钥ID"
+// @Param request body model.UpdateSecretRequest true "修改密钥请求"
+// @Success 200 {object} model.APIResponse{data=model.Secret}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/secrets/{id} [put]
+func (h *SecretHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的密钥ID",
+		})
+		return
+	}
+
+	var req model.UpdateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	secret, err := h.secretService.Update(id, &req, user)
+	h.respondSecret(c, secret, err, "密钥已更新")
+}
+
+// Delete 删除密钥
+// @Summary 删除密钥
+// @Description 删除密钥，之后开始的构建不再注入该密钥
+// @Tags 密钥
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "密钥ID"
+// @Success 200 {object} model.APIResponse{data=model.Secret}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/secrets/{id} [delete]
+func (h *SecretHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的密钥ID",
+		})
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	secret, err := h.secretService.Delete(id, user)
+	h.respondSecret(c, secret, err, "密钥已删除")
+}
+
+func (h *SecretHandler) respondSecret(c *gin.Context, secret *model.Secret, err error, message string) {
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if secret == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "密钥不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: message,
+		Data:    secret,
+	})
+}
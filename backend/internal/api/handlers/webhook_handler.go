@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"Vortexia/internal/middleware"
+	"Vortexia/internal/model"
+	"Vortexia/internal/service"
+	"Vortexia/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService service.WebhookService
+}
+
+// NewWebhookHandler 创建 webhook 处理器
+func NewWebhookHandler(webhookService service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// Get 获取项目的 webhook
+// @Summary 获取项目的 webhook
+// @Description 获取项目 webhook 的地址和生成时间，不返回密钥。只有项目的所有者和管理员可以获取
+// @Tags Webhook
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "项目ID"
+// @Success 200 {object} model.APIResponse{data=model.ProjectWebhook}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/projects/{id}/webhook [get]
+func (h *WebhookHandler) Get(c *gin.Context) {
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	hook, err := h.webhookService.Get(id, user)
+	h.respondWebhook(c, hook, err, "获取成功")
+}
+
+// Rotate 生成项目的 webhook 密钥
+// @Summary 生成项目的 webhook 密钥
+// @Description 生成新的 webhook 密钥并替换已有的密钥，密钥只返回这一次，需填写到代码托管平台的 webhook 配置中。
+// @Description 只有项目的所有者和管理员可以生成
+// @Tags Webhook
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "项目ID"
+// @Success 201 {object} model.APIResponse{data=model.RotateWebhookResponse}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/projects/{id}/webhook [post]
+func (h *WebhookHandler) Rotate(c *gin.Context) {
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	resp, err := h.webhookService.Rotate(id, user)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if resp == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "项目不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{
+		Code:    http.StatusCreated,
+		Message: "webhook 密钥已生成",
+		Data:    resp,
+	})
+}
+
+// Delete 删除项目的 webhook
+// @Summary 删除项目的 webhook
+// @Description 删除项目的 webhook 密钥，之后的推送事件不再触发构建。只有项目的所有者和管理员可以删除
+// @Tags Webhook
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "项目ID"
+// @Success 200 {object} model.APIResponse{data=model.ProjectWebhook}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Router /api/v1/projects/{id}/webhook [delete]
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, model.APIResponse{
+			Code:    http.StatusUnauthorized,
+			Message: "用户信息不存在",
+		})
+		return
+	}
+
+	hook, err := h.webhookService.Delete(id, user)
+	h.respondWebhook(c, hook, err, "webhook 已删除")
+}
+
+// Receive 接收代码托管平台的推送事件
+// @Summary 接收推送事件
+// @Description 接收 GitHub、GitLab 或 Gitea 的推送事件，校验签名后为触发规则匹配的流水线创建构建。
+// @Description GitHub 和 Gitea 使用 webhook 密钥签名请求体，GitLab 在 X-Gitlab-Token 中携带密钥
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param provider path string true "代码托管平台" Enums(github, gitlab, gitea)
+// @Param project_id path int true "项目ID"
+// @Success 200 {object} model.APIResponse{data=model.WebhookResult}
+// @Failure 400 {object} model.APIResponse
+// @Failure 401 {object} model.APIResponse
+// @Failure 404 {object} model.APIResponse
+// @Failure 413 {object} model.APIResponse
+// @Router /api/v1/hooks/{provider}/{project_id} [post]
+func (h *WebhookHandler) Receive(c *gin.Context) {
+	projectID, err := strconv.Atoi(c.Param("project_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    http.StatusBadRequest,
+			Message: "无效的项目ID",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, webhook.MaxPayloadSize))
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, model.APIResponse{
+			Code:    status,
+			Message: "读取请求失败: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := h.webhookService.Receive(c.Param("provider"), projectID, c.Request.Header, body)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, webhook.ErrUnknownProvider):
+			status = http.StatusNotFound
+		case errors.Is(err, webhook.ErrInvalidSignature):
+			status = http.StatusUnauthorized
+		case errors.Is(err, webhook.ErrInvalidPayload):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, model.APIResponse{
+			Code:    status,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if result == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "项目不存在或未配置 webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: "处理成功",
+		Data:    result,
+	})
+}
+
+func (h *WebhookHandler) respondWebhook(c *gin.Context, hook *model.ProjectWebhook, err error, message string) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if hook == nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:    http.StatusNotFound,
+			Message: "项目不存在或未配置 webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    http.StatusOK,
+		Message: message,
+		Data:    hook,
+	})
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"Vortexia/internal/api/handlers"
+	"Vortexia/internal/config"
 	"Vortexia/internal/middleware"
 	"Vortexia/internal/service"
 
@@ -12,7 +13,7 @@ import (
 )
 
 // SetupRoutes 配置路由
-func SetupRoutes(services *service.Services, logger *zap.Logger) *gin.Engine {
+func SetupRoutes(services *service.Services, cfg *config.Config, logger *zap.Logger) *gin.Engine {
 	r := gin.New()
 
 	// 中间件
@@ -25,7 +26,12 @@ func SetupRoutes(services *service.Services, logger *zap.Logger) *gin.Engine {
 	userHandler := handlers.NewUserHandler(services.User)
 	projectHandler := handlers.NewProjectHandler(services.Project)
 	pipelineHandler := handlers.NewPipelineHandler(services.Pipeline)
-	buildHandler := handlers.NewBuildHandler(services.Build)
+	buildHandler := handlers.NewBuildHandler(services.Build, cfg.Server.AllowedOrigins)
+	runnerHandler := handlers.NewRunnerHandler(services.Runner)
+	secretHandler := handlers.NewSecretHandler(services.Secret)
+	artifactHandler := handlers.NewArtifactHandler(services.Artifact)
+	cacheHandler := handlers.NewCacheHandler(services.Cache)
+	webhookHandler := handlers.NewWebhookHandler(services.Webhook)
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
@@ -44,10 +50,45 @@ func SetupRoutes(services *service.Services, logger *zap.Logger) *gin.Engine {
 		auth.POST("/login", authHandler.Login)
 	}
 
+	// 代码托管平台的推送事件（使用项目的 webhook 密钥校验签名）
+	hooks := api.Group("/hooks")
+	{
+		hooks.POST("/:provider/:project_id", webhookHandler.Receive)
+	}
+
+	// 远程执行器API（注册使用注册令牌，其余使用执行器令牌）
+	runnerAPI := api.Group("/runners")
+	{
+		runnerAPI.POST("/register", runnerHandler.Register)
+	}
+	runnerAPI.Use(middleware.RunnerAuth(services.Runner))
+	{
+		runnerAPI.POST("/heartbeat", runnerHandler.Heartbeat)
+		runnerAPI.GET("/jobs", runnerHandler.RequestJob)
+		runnerAPI.GET("/jobs/:id/status", runnerHandler.GetJobStatus)
+		runnerAPI.PUT("/jobs/:id/commit", runnerHandler.UpdateCommit)
+		runnerAPI.POST("/jobs/:id/finish", runnerHandler.FinishJob)
+		runnerAPI.POST("/events", runnerHandler.PublishEvents)
+		runnerAPI.PUT("/build-jobs/:job_id", runnerHandler.UpdateBuildJob)
+		runnerAPI.POST("/build-jobs/:job_id/artifacts", runnerHandler.UploadArtifact)
+		runnerAPI.GET("/build-jobs/:job_id/dependencies", runnerHandler.DependencyArtifacts)
+		runnerAPI.GET("/build-jobs/:job_id/dependencies/:artifact_id", runnerHandler.DownloadDependencyArtifact)
+		runnerAPI.POST("/build-jobs/:job_id/caches", runnerHandler.SaveCache)
+		runnerAPI.POST("/build-jobs/:job_id/caches/restore", runnerHandler.FindCache)
+		runnerAPI.GET("/build-jobs/:job_id/caches/:cache_id", runnerHandler.DownloadCache)
+		runnerAPI.PUT("/steps/:step_id", runnerHandler.UpdateStep)
+		runnerAPI.POST("/steps/:step_id/log", runnerHandler.AppendStepLog)
+		runnerAPI.POST("/steps/:step_id/attempts", runnerHandler.CreateStepAttempt)
+		runnerAPI.PUT("/steps/:step_id/attempts/:attempt", runnerHandler.FinishStepAttempt)
+	}
+
 	// 需要认证的路由
 	protected := api.Group("/")
 	protected.Use(middleware.JWTAuth(services.Auth))
 
+	// 签发实时日志和制品下载使用的流式请求票据
+	protected.POST("/auth/stream-ticket", authHandler.StreamTicket)
+
 	// 用户管理路由
 	users := protected.Group("/users")
 	{
@@ -69,6 +110,14 @@ func SetupRoutes(services *service.Services, logger *zap.Logger) *gin.Engine {
 		projects.PUT("/:id", projectHandler.Update)
 		projects.DELETE("/:id", projectHandler.Delete)
 		projects.GET("/my", projectHandler.GetMyProjects)
+		projects.GET("/:id/secrets", secretHandler.ListByProject)
+		projects.POST("/:id/secrets", secretHandler.CreateForProject)
+		projects.GET("/:id/caches", cacheHandler.ListByProject)
+		projects.DELETE("/:id/caches", cacheHandler.Purge)
+		projects.DELETE("/:id/caches/:cache_id", cacheHandler.Delete)
+		projects.GET("/:id/webhook", webhookHandler.Get)
+		projects.POST("/:id/webhook", webhookHandler.Rotate)
+		projects.DELETE("/:id/webhook", webhookHandler.Delete)
 	}
 
 	// 流水线管理路由
@@ -80,6 +129,15 @@ func SetupRoutes(services *service.Services, logger *zap.Logger) *gin.Engine {
 		pipelines.PUT("/:id", pipelineHandler.Update)
 		pipelines.DELETE("/:id", pipelineHandler.Delete)
 		pipelines.GET("/project/:project_id", pipelineHandler.GetByProject)
+		pipelines.GET("/:id/secrets", secretHandler.ListByPipeline)
+		pipelines.POST("/:id/secrets", secretHandler.CreateForPipeline)
+	}
+
+	// 密钥管理路由，密钥的值只能写入
+	secrets := protected.Group("/secrets")
+	{
+		secrets.PUT("/:id", secretHandler.Update)
+		secrets.DELETE("/:id", secretHandler.Delete)
 	}
 
 	// 构建管理路由
@@ -89,14 +147,32 @@ func SetupRoutes(services *service.Services, logger *zap.Logger) *gin.Engine {
 		builds.POST("/", buildHandler.Create)
 		builds.GET("/:id", buildHandler.GetByID)
 		builds.PUT("/:id/status", buildHandler.UpdateStatus)
+		builds.POST("/:id/cancel", buildHandler.Cancel)
+		builds.POST("/:id/rerun", buildHandler.Rerun)
 		builds.GET("/:id/steps", buildHandler.GetSteps)
+		builds.GET("/:id/graph", buildHandler.GetGraph)
+		builds.GET("/:id/steps/:step_id/log", buildHandler.GetStepLog)
+		builds.GET("/:id/artifacts", artifactHandler.ListByBuild)
 		builds.GET("/pipeline/:pipeline_id", buildHandler.GetByPipeline)
 	}
 
-	// WebSocket路由（实时日志）
-	ws := protected.Group("/ws")
+	// 执行器管理路由
+	runners := protected.Group("/runners")
+	{
+		runners.GET("/", middleware.AdminRequired(), runnerHandler.List)
+		runners.POST("/:id/pause", middleware.AdminRequired(), runnerHandler.Pause)
+		runners.POST("/:id/resume", middleware.AdminRequired(), runnerHandler.Resume)
+		runners.POST("/:id/revoke", middleware.AdminRequired(), runnerHandler.Revoke)
+	}
+
+	// 浏览器无法设置请求头的路由，允许通过 ticket 查询参数传递流式请求票据认证
+	streaming := api.Group("/")
+	streaming.Use(middleware.StreamTicketAuth(services.Auth))
 	{
-		ws.GET("/builds/:id/logs", buildHandler.WatchLogs)
+		streaming.GET("/builds/:id/logs/stream", buildHandler.StreamLogs)
+		streaming.GET("/builds/:id/artifacts/:artifact_id/download", artifactHandler.Download)
+		// WebSocket路由（实时日志）
+		streaming.GET("/ws/builds/:id/logs", buildHandler.WatchLogs)
 	}
 
 	return r
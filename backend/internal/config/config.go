@@ -1,22 +1,35 @@
 package config
 
 import (
+	"encoding/base64"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	JWT       JWTConfig
+	Worker    WorkerConfig
+	Log       LogConfig
+	Executor  ExecutorConfig
+	Runner    RunnerConfig
+	Secrets   SecretsConfig
+	Storage   StorageConfig
+	Artifacts ArtifactsConfig
+	Cache     CacheConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port           string
+	Mode           string
+	AllowedOrigins []string // 允许建立实时日志WebSocket连接的页面来源，如 https://ci.example.com，为空时只允许同源页面
 }
 
 type DatabaseConfig struct {
@@ -40,14 +53,92 @@ type JWTConfig struct {
 	Expire int
 }
 
+type LogConfig struct {
+	MaxStepBytes int64 // 单个步骤日志的最大字节数，超出部分截断，0表示不限制
+}
+
+type ExecutorConfig struct {
+	Type               string // 执行器类型：local 在本机进程中执行，docker 在容器中执行
+	WorkspaceRoot      string // 构建工作空间的根目录
+	WorkspaceRetention int    // 构建结束后保留工作空间的时长（小时），0表示立即删除
+	Timestamps         bool   // 是否在步骤输出的每一行前添加时间戳
+	MaxParallelJobs    int    // 一个构建中同时执行的作业数上限
+	Docker             DockerConfig
+}
+
+type DockerConfig struct {
+	Host              string  // Docker守护进程地址，如 unix:///var/run/docker.sock、tcp://127.0.0.1:2375
+	DefaultImage      string  // 流水线和步骤都未指定镜像时使用的镜像
+	PullPolicy        string  // 镜像拉取策略：always、if-not-present、never
+	CPUs              float64 // 每个步骤容器可使用的CPU核数，0表示不限制
+	MemoryMB          int     // 每个步骤容器可使用的内存（MB），0表示不限制
+	WorkspaceHostRoot string  // 工作空间根目录在Docker宿主机上的路径，后端运行在容器中时需要配置
+}
+
+type WorkerConfig struct {
+	Concurrency       int      // 并发执行的构建数，0表示不在本进程执行构建
+	VisibilityTimeout int      // 构建租约时长（秒），工作进程失联超过该时长后构建被重新投递
+	MaxDeliveries     int      // 构建最多被投递的次数，超过后直接标记为失败
+	CancelGracePeriod int      // 取消构建时发送SIGTERM后等待进程退出的时长（秒），超时后发送SIGKILL
+	Labels            []string // 本进程作为执行器的标签，只执行 runs_on 被这些标签满足的构建
+}
+
+type RunnerConfig struct {
+	RegistrationToken string // 执行器注册令牌，服务端未配置时不允许注册执行器
+
+	// 以下为执行器进程（cmd/runner）的配置
+	ServerURL         string   // 服务端地址
+	Name              string   // 注册时使用的名称
+	Labels            []string // 注册时上报的标签
+	TokenFile         string   // 保存注册后获得的执行器令牌，重启后不再重新注册
+	Concurrency       int      // 并发执行的构建数
+	HeartbeatInterval int      // 心跳间隔（秒），需小于服务端的构建租约时长
+}
+
+type SecretsConfig struct {
+	MasterKeys []MasterKey // 加密密钥的主密钥，第一个用于加密，其余只用于解密轮换前保存的密钥
+}
+
+type StorageConfig struct {
+	Type      string // 存储类型：local 保存在本机目录，s3 保存在S3兼容的对象存储
+	LocalRoot string // local 存储的根目录
+	S3        S3Config
+}
+
+type S3Config struct {
+	Endpoint  string // 对象存储地址，如 https://s3.amazonaws.com、http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // 使用 endpoint/bucket/key 形式的地址，MinIO 等自建存储通常需要开启
+}
+
+type ArtifactsConfig struct {
+	ExpireDays      int // 流水线未配置 expire_in 时制品的保存天数，0表示永久保存
+	MaxSizeMB       int // 单个制品文件的大小上限（MB）
+	CleanupInterval int // 删除过期制品的间隔（分钟）
+}
+
+type CacheConfig struct {
+	MaxSizeMB int // 每个项目的依赖缓存总大小上限（MB），超出后删除最久未使用的缓存
+}
+
+// MasterKey 带标识的AES-256主密钥，密钥记录保存加密时使用的主密钥标识
+type MasterKey struct {
+	ID  string
+	Key []byte
+}
+
 func Load() (*Config, error) {
 	// 加载.env文件（如果存在）
 	_ = godotenv.Load()
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Mode: getEnv("GIN_MODE", "release"),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			Mode:           getEnv("GIN_MODE", "release"),
+			AllowedOrigins: getEnvAsSlice("WS_ALLOWED_ORIGINS", nil),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -67,11 +158,97 @@ func Load() (*Config, error) {
 			Secret: getEnv("JWT_SECRET", "vortexia-secret-key"),
 			Expire: getEnvAsInt("JWT_EXPIRE", 7200), // 2小时
 		},
+		Worker: WorkerConfig{
+			Concurrency:       getEnvAsInt("WORKER_CONCURRENCY", 1),
+			VisibilityTimeout: getEnvAsInt("WORKER_VISIBILITY_TIMEOUT", 60),
+			MaxDeliveries:     getEnvAsInt("WORKER_MAX_DELIVERIES", 3),
+			CancelGracePeriod: getEnvAsInt("WORKER_CANCEL_GRACE_PERIOD", 10),
+			Labels:            getEnvAsSlice("WORKER_LABELS", nil),
+		},
+		Log: LogConfig{
+			MaxStepBytes: int64(getEnvAsInt("LOG_MAX_STEP_BYTES", 10*1024*1024)), // 10MB
+		},
+		Executor: ExecutorConfig{
+			Type:               getEnv("EXECUTOR_TYPE", "local"),
+			WorkspaceRoot:      getEnv("EXECUTOR_WORKSPACE_ROOT", filepath.Join(os.TempDir(), "vortexia", "workspaces")),
+			WorkspaceRetention: getEnvAsInt("EXECUTOR_WORKSPACE_RETENTION", 0),
+			Timestamps:         getEnvAsBool("EXECUTOR_TIMESTAMPS", true),
+			MaxParallelJobs:    getEnvAsInt("EXECUTOR_MAX_PARALLEL_JOBS", 4),
+			Docker: DockerConfig{
+				Host:              getEnv("DOCKER_HOST", "unix:///var/run/docker.sock"),
+				DefaultImage:      getEnv("DOCKER_DEFAULT_IMAGE", "alpine:3"),
+				PullPolicy:        getEnv("DOCKER_PULL_POLICY", "if-not-present"),
+				CPUs:              getEnvAsFloat("DOCKER_CPUS", 1),
+				MemoryMB:          getEnvAsInt("DOCKER_MEMORY_MB", 1024),
+				WorkspaceHostRoot: getEnv("DOCKER_WORKSPACE_HOST_ROOT", ""),
+			},
+		},
+		Runner: RunnerConfig{
+			RegistrationToken: getEnv("RUNNER_REGISTRATION_TOKEN", ""),
+			ServerURL:         getEnv("RUNNER_SERVER_URL", "http://localhost:8080"),
+			Name:              getEnv("RUNNER_NAME", hostname()),
+			Labels:            getEnvAsSlice("RUNNER_LABELS", nil),
+			TokenFile:         getEnv("RUNNER_TOKEN_FILE", ".runner-token"),
+			Concurrency:       getEnvAsInt("RUNNER_CONCURRENCY", 1),
+			HeartbeatInterval: getEnvAsInt("RUNNER_HEARTBEAT_INTERVAL", 10),
+		},
+		Storage: StorageConfig{
+			Type:      getEnv("STORAGE_TYPE", "local"),
+			LocalRoot: getEnv("STORAGE_LOCAL_ROOT", filepath.Join(os.TempDir(), "vortexia", "storage")),
+			S3: S3Config{
+				Endpoint:  getEnv("STORAGE_S3_ENDPOINT", ""),
+				Region:    getEnv("STORAGE_S3_REGION", "us-east-1"),
+				Bucket:    getEnv("STORAGE_S3_BUCKET", ""),
+				AccessKey: getEnv("STORAGE_S3_ACCESS_KEY", ""),
+				SecretKey: getEnv("STORAGE_S3_SECRET_KEY", ""),
+				PathStyle: getEnvAsBool("STORAGE_S3_PATH_STYLE", true),
+			},
+		},
+		Artifacts: ArtifactsConfig{
+			ExpireDays:      getEnvAsInt("ARTIFACTS_EXPIRE_DAYS", 30),
+			MaxSizeMB:       getEnvAsInt("ARTIFACTS_MAX_SIZE_MB", 1024),
+			CleanupInterval: getEnvAsInt("ARTIFACTS_CLEANUP_INTERVAL", 60),
+		},
+		Cache: CacheConfig{
+			MaxSizeMB: getEnvAsInt("CACHE_MAX_SIZE_MB", 2048),
+		},
 	}
 
+	masterKeys, err := parseMasterKeys(getEnvAsSlice("SECRETS_MASTER_KEYS", nil))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Secrets.MasterKeys = masterKeys
+
 	return cfg, nil
 }
 
+// parseMasterKeys 解析 id:base64 形式的主密钥，密钥解码后必须为32字节
+func parseMasterKeys(items []string) ([]MasterKey, error) {
+	keys := make([]MasterKey, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid SECRETS_MASTER_KEYS entry %q: expected id:base64-key", item)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate master key id %q in SECRETS_MASTER_KEYS", id)
+		}
+		seen[id] = true
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode master key %q: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %q must be 32 bytes, got %d", id, len(key))
+		}
+		keys = append(keys, MasterKey{ID: id, Key: key})
+	}
+	return keys, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -87,3 +264,45 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsSlice 读取逗号分隔的列表，忽略空白项
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "runner"
+	}
+	return name
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+)
+
+// artifactsStepName 上传制品步骤的名称
+const artifactsStepName = "artifacts"
+
+// artifactsStepOrder 上传制品步骤排在流水线定义的所有步骤之后
+const artifactsStepOrder = math.MaxInt32
+
+// maxArtifactFiles 一个作业最多上传的制品文件数，避免误配置的路径上传整个依赖目录
+const maxArtifactFiles = 1000
+
+// createArtifactsStep 为配置了制品的作业创建上传制品步骤，未配置时返回 nil
+func (e *Engine) createArtifactsStep(build *model.Build, job *model.BuildJob, spec *pipeline.Job) (*model.BuildStep, error) {
+	if spec.Artifacts == nil {
+		return nil, nil
+	}
+
+	step := &model.BuildStep{
+		BuildID:   build.ID,
+		JobID:     &job.ID,
+		Name:      artifactsStepName,
+		Command:   "upload " + strings.Join(spec.Artifacts.Paths, " "),
+		Status:    model.StepStatusPending,
+		StartedAt: time.Now(),
+		StepOrder: artifactsStepOrder,
+	}
+	if err := e.buildRepo.CreateStep(step); err != nil {
+		return nil, err
+	}
+	return step, nil
+}
+
+// runArtifacts 按作业的状态和 when 配置上传工作目录中与制品路径匹配的文件，返回作业的最终状态。
+// 构建被取消或超时后不上传；上传失败时成功的作业变为失败
+func (e *Engine) runArtifacts(ctx context.Context, j *jobRun, step *model.BuildStep, status string, reusedOnly bool) (string, error) {
+	spec := j.spec.Artifacts
+
+	if status == model.JobStatusSuccess || status == model.JobStatusFailed {
+		interrupted, err := e.interrupted(ctx, j.Build.ID)
+		if err != nil {
+			return "", err
+		}
+		if interrupted != "" {
+			status = interrupted
+		}
+	}
+
+	reason := ""
+	switch {
+	case status == model.JobStatusCanceled || status == model.JobStatusTimedOut:
+	case !spec.Upload(status == model.JobStatusSuccess):
+		reason = fmt.Sprintf("作业状态为 %s，不满足 when: %s，跳过\n", status, spec.WhenOr())
+	case reusedOnly:
+		reason = fmt.Sprintf("作业的步骤均复用了构建 #%d 的结果，制品见该构建，跳过\n", *j.Build.RerunOf)
+	default:
+		stepStatus, err := e.uploadArtifacts(ctx, j, step)
+		if err != nil {
+			return "", err
+		}
+		if next := jobStatusOf(stepStatus); next != model.JobStatusSuccess {
+			status = next
+		}
+		return status, nil
+	}
+
+	return status, e.skipStep(ctx, j, step, reason)
+}
+
+// uploadArtifacts 执行上传制品步骤，返回步骤状态。某个文件上传失败时继续上传其余文件，步骤失败
+func (e *Engine) uploadArtifacts(ctx context.Context, j *jobRun, step *model.BuildStep) (string, error) {
+	spec := j.spec.Artifacts
+	out, err := e.startStep(ctx, j, step)
+	if err != nil {
+		return "", err
+	}
+
+	var expiresAt *time.Time
+	if spec.ExpireIn != nil {
+		t := time.Now().Add(spec.ExpireIn.Duration)
+		expiresAt = &t
+	}
+
+	status := model.StepStatusSuccess
+	files, err := collectArtifacts(j.ws.Dir(), spec)
+	switch {
+	case err != nil:
+		status = model.StepStatusFailed
+		out.WriteString(fmt.Sprintf("查找制品失败: %v\n", err))
+	case len(files) == 0:
+		out.WriteString(fmt.Sprintf("工作目录中没有与 %s 匹配的文件\n", strings.Join(spec.Paths, " ")))
+	}
+
+	uploaded := 0
+	for _, name := range files {
+		if interrupted := interruptStatus(ctx); interrupted != "" {
+			status = interrupted
+			out.WriteString(interruptMessage(ctx, j.def, nil))
+			break
+		}
+
+		artifact := &model.Artifact{BuildID: j.Build.ID, JobID: j.job.ID, Path: name, ExpiresAt: expiresAt}
+		if err := e.uploadArtifact(ctx, j, artifact); err != nil {
+			status = model.StepStatusFailed
+			out.WriteString(fmt.Sprintf("上传 %s 失败: %v\n", name, err))
+			continue
+		}
+		uploaded++
+		out.WriteString(fmt.Sprintf("已上传 %s（%d 字节，sha256:%s）\n", name, artifact.Size, artifact.Checksum))
+	}
+	if len(files) > 0 {
+		out.WriteString(fmt.Sprintf("共上传 %d/%d 个文件\n", uploaded, len(files)))
+	}
+
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return status, e.endStep(ctx, j, step, status)
+}
+
+// uploadArtifact 上传工作目录中的一个制品文件
+func (e *Engine) uploadArtifact(ctx context.Context, j *jobRun, artifact *model.Artifact) error {
+	f, err := os.Open(filepath.Join(j.ws.Dir(), filepath.FromSlash(artifact.Path)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	artifact.Mode = uint32(info.Mode().Perm())
+	return e.reporter.UploadArtifact(ctx, artifact, f, info.Size())
+}
+
+// collectArtifacts 返回工作目录中与制品路径匹配的普通文件，以 / 分隔的相对路径按字典序排列。
+// 不跟随符号链接，避免上传工作目录之外的文件
+func collectArtifacts(dir string, spec *pipeline.Artifacts) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !spec.Matches(name) {
+			return nil
+		}
+		if len(files) >= maxArtifactFiles {
+			return fmt.Errorf("匹配的文件超过 %d 个，请缩小制品路径的范围", maxArtifactFiles)
+		}
+		files = append(files, name)
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,477 @@
+package engine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+)
+
+// 恢复和保存缓存步骤的名称
+const (
+	cacheRestoreStepName = "restore cache"
+	cacheSaveStepName    = "save cache"
+)
+
+// 恢复缓存步骤排在代码检出和下载上游作业的制品之后、流水线定义的步骤之前，保存缓存步骤排在上传制品之前
+const (
+	cacheRestoreStepOrder = -1
+	cacheSaveStepOrder    = math.MaxInt32 - 1
+)
+
+// maxCacheKeyLength 缓存键的最大长度
+const maxCacheKeyLength = 255
+
+// jobCache 作业恢复缓存的结果，保存缓存时使用
+type jobCache struct {
+	key string // 求值后的缓存键，为空时不保存缓存
+	hit bool   // 缓存键精确命中，缓存内容未变，无需保存
+}
+
+// createCacheSteps 为配置了缓存的作业创建恢复和保存缓存步骤，未配置时返回 nil
+func (e *Engine) createCacheSteps(build *model.Build, job *model.BuildJob, spec *pipeline.Job) (*model.BuildStep, *model.BuildStep, error) {
+	if spec.Cache == nil {
+		return nil, nil, nil
+	}
+
+	restore := &model.BuildStep{
+		BuildID:   build.ID,
+		JobID:     &job.ID,
+		Name:      cacheRestoreStepName,
+		Command:   "restore " + spec.Cache.Key.String(),
+		Status:    model.StepStatusPending,
+		StartedAt: time.Now(),
+		StepOrder: cacheRestoreStepOrder,
+	}
+	if err := e.buildRepo.CreateStep(restore); err != nil {
+		return nil, nil, err
+	}
+
+	save := &model.BuildStep{
+		BuildID:   build.ID,
+		JobID:     &job.ID,
+		Name:      cacheSaveStepName,
+		Command:   "save " + strings.Join(spec.Cache.Paths, " "),
+		Status:    model.StepStatusPending,
+		StartedAt: time.Now(),
+		StepOrder: cacheSaveStepOrder,
+	}
+	if err := e.buildRepo.CreateStep(save); err != nil {
+		return nil, nil, err
+	}
+	return restore, save, nil
+}
+
+// runRestoreCache 按缓存键恢复缓存到工作目录，返回步骤状态和恢复的结果。缓存只用于加速构建，
+// 缓存键求值失败、未命中或恢复失败时步骤仍然成功，作业按未恢复缓存继续执行
+func (e *Engine) runRestoreCache(ctx context.Context, j *jobRun, step *model.BuildStep) (string, *jobCache, error) {
+	spec := j.spec.Cache
+	out, err := e.startStep(ctx, j, step)
+	if err != nil {
+		return "", nil, err
+	}
+
+	result := &jobCache{}
+	key, restoreKeys, err := cacheKeys(j, spec)
+	switch {
+	case err != nil:
+		out.WriteString(fmt.Sprintf("缓存键求值失败: %v\n", err))
+	case key == "":
+		out.WriteString(fmt.Sprintf("缓存键 %s 的值为空，不使用缓存\n", spec.Key))
+	case len(key) > maxCacheKeyLength:
+		out.WriteString(fmt.Sprintf("缓存键 %q 超过%d个字符，不使用缓存\n", key, maxCacheKeyLength))
+	default:
+		result.key = key
+		result.hit, err = e.restoreCache(ctx, j, key, restoreKeys, out)
+		if err != nil {
+			out.WriteString(fmt.Sprintf("恢复缓存失败: %v\n", err))
+		}
+	}
+
+	status := model.StepStatusSuccess
+	if interrupted := interruptStatus(ctx); interrupted != "" {
+		status = interrupted
+		out.WriteString(interruptMessage(ctx, j.def, nil))
+	}
+
+	if err := out.Close(); err != nil {
+		return "", nil, err
+	}
+	return status, result, e.endStep(ctx, j, step, status)
+}
+
+// restoreCache 查找并解压缓存，返回缓存键是否精确命中
+func (e *Engine) restoreCache(ctx context.Context, j *jobRun, key string, restoreKeys []string, out io.Writer) (bool, error) {
+	cache := &model.Cache{ProjectID: j.ProjectID, Key: key}
+	content, err := e.reporter.RestoreCache(ctx, j.job.ID, cache, restoreKeys)
+	if err != nil {
+		return false, err
+	}
+	if content == nil {
+		fmt.Fprintf(out, "未找到缓存 %s\n", key)
+		return false, nil
+	}
+	defer content.Close()
+
+	files, err := extractCache(j.ws.Dir(), content)
+	if err != nil {
+		return false, err
+	}
+	if cache.Key == key {
+		fmt.Fprintf(out, "从缓存 %s 恢复了 %d 个文件（%d 字节）\n", cache.Key, files, cache.Size)
+		return true, nil
+	}
+	fmt.Fprintf(out, "未找到缓存 %s，从备用缓存键匹配的缓存 %s 恢复了 %d 个文件（%d 字节）\n", key, cache.Key, files, cache.Size)
+	return false, nil
+}
+
+// runSaveCache 作业成功且缓存键未精确命中时将与缓存路径匹配的文件打包保存。
+// 保存失败不影响作业的结果
+func (e *Engine) runSaveCache(ctx context.Context, j *jobRun, step *model.BuildStep, cache *jobCache, status string, reusedOnly bool) (string, error) {
+	if status == model.JobStatusSuccess {
+		interrupted, err := e.interrupted(ctx, j.Build.ID)
+		if err != nil {
+			return "", err
+		}
+		if interrupted != "" {
+			status = interrupted
+		}
+	}
+
+	reason := ""
+	switch {
+	case status == model.JobStatusCanceled || status == model.JobStatusTimedOut:
+	case status != model.JobStatusSuccess:
+		reason = fmt.Sprintf("作业状态为 %s，不保存缓存\n", status)
+	case cache == nil || cache.key == "":
+		reason = "未能确定缓存键，不保存缓存\n"
+	case cache.hit:
+		reason = fmt.Sprintf("缓存 %s 已存在，无需保存\n", cache.key)
+	case reusedOnly:
+		reason = fmt.Sprintf("作业的步骤均复用了构建 #%d 的结果，不保存缓存\n", *j.Build.RerunOf)
+	default:
+		return status, e.saveCache(ctx, j, step, cache.key)
+	}
+
+	return status, e.skipStep(ctx, j, step, reason)
+}
+
+// saveCache 执行保存缓存步骤
+func (e *Engine) saveCache(ctx context.Context, j *jobRun, step *model.BuildStep, key string) error {
+	out, err := e.startStep(ctx, j, step)
+	if err != nil {
+		return err
+	}
+
+	status := model.StepStatusSuccess
+	files, size, err := e.uploadCache(ctx, j, key)
+	interrupted := interruptStatus(ctx)
+	switch {
+	case interrupted != "":
+		status = interrupted
+		out.WriteString(interruptMessage(ctx, j.def, nil))
+	case err != nil:
+		out.WriteString(fmt.Sprintf("保存缓存失败，不影响作业的结果: %v\n", err))
+	case files == 0:
+		out.WriteString(fmt.Sprintf("工作目录中没有与 %s 匹配的文件，不保存缓存\n", strings.Join(j.spec.Cache.Paths, " ")))
+	default:
+		out.WriteString(fmt.Sprintf("已保存缓存 %s（%d 个文件，%d 字节）\n", key, files, size))
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return e.endStep(ctx, j, step, status)
+}
+
+// uploadCache 将与缓存路径匹配的文件打包到临时文件后上传，返回打包的文件数和压缩包大小。
+// 没有匹配的文件时不上传
+func (e *Engine) uploadCache(ctx context.Context, j *jobRun, key string) (int, int64, error) {
+	f, err := os.CreateTemp("", "vortexia-cache-*.tar.gz")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create cache archive: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	files, err := archiveCache(j.ws.Dir(), j.spec.Cache, f)
+	if err != nil || files == 0 {
+		return 0, 0, err
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	cache := &model.Cache{ProjectID: j.ProjectID, Key: key, BuildID: &j.Build.ID}
+	if err := e.reporter.SaveCache(ctx, j.job.ID, cache, f, size); err != nil {
+		return 0, 0, err
+	}
+	return files, size, nil
+}
+
+// cacheKeys 对缓存键和备用缓存键求值，值为空的备用缓存键被忽略
+func cacheKeys(j *jobRun, spec *pipeline.Cache) (string, []string, error) {
+	ctx := exprContext(j, &pipeline.Step{}, nil, false)
+	ctx.HashFiles = func(patterns []string) (string, error) {
+		return hashFiles(j.ws.Dir(), patterns)
+	}
+
+	key, err := spec.Key.Render(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	var restoreKeys []string
+	for _, t := range spec.RestoreKeys {
+		restoreKey, err := t.Render(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		if restoreKey != "" {
+			restoreKeys = append(restoreKeys, restoreKey)
+		}
+	}
+	return key, restoreKeys, nil
+}
+
+// hashFiles 计算工作目录中与路径匹配的普通文件的摘要：按路径顺序对各文件内容的SHA-256再做SHA-256。
+// 跳过 .git 目录，没有匹配的文件时返回空字符串
+func hashFiles(dir string, patterns []string) (string, error) {
+	for _, pattern := range patterns {
+		clean := path.Clean(pattern)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return "", fmt.Errorf("路径 %q 必须是工作目录中的相对路径", pattern)
+		}
+	}
+
+	sum := sha256.New()
+	matched := 0
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		for _, pattern := range patterns {
+			if !pipeline.MatchFile(pattern, name) {
+				continue
+			}
+			fileSum, err := hashFile(p)
+			if err != nil {
+				return err
+			}
+			sum.Write(fileSum)
+			matched++
+			break
+		}
+		return nil
+	})
+	if err != nil || matched == 0 {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func hashFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// archiveCache 将工作目录中与缓存路径匹配的普通文件和符号链接写入 tar.gz，返回写入的数量。
+// 符号链接按链接本身保存，不跟随，node_modules/.bin 等目录依赖它们
+func archiveCache(dir string, spec *pipeline.Cache, w io.Writer) (int, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	files := 0
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !spec.Matches(name) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		hdr.Uname, hdr.Gname = "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		files++
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	return files, gz.Close()
+}
+
+// extractCache 将 tar.gz 中的普通文件、目录和符号链接解压到工作目录，返回解压的文件数。
+// 路径不能指向工作目录之外，也不能经过已存在的符号链接，避免通过缓存写入工作目录之外的文件
+func extractCache(dir string, r io.Reader) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer gz.Close()
+
+	files := 0
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+
+		name := path.Clean(hdr.Name)
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, "\\") {
+			return files, fmt.Errorf("缓存中的路径 %q 无效", hdr.Name)
+		}
+		if err := mkdirInside(dir, path.Dir(name)); err != nil {
+			return files, err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := mkdirInside(dir, name); err != nil {
+				return files, err
+			}
+			continue
+		case tar.TypeReg, tar.TypeSymlink:
+		default:
+			continue
+		}
+
+		// 替换已存在的文件或符号链接本身，不写入符号链接指向的文件
+		if info, err := os.Lstat(target); err == nil {
+			if info.IsDir() {
+				return files, fmt.Errorf("缓存中的文件 %s 与工作目录中的目录冲突", name)
+			}
+			if err := os.Remove(target); err != nil {
+				return files, err
+			}
+		}
+
+		if hdr.Typeflag == tar.TypeSymlink {
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return files, err
+			}
+		} else if err := writeFile(target, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+			return files, err
+		}
+		files++
+	}
+}
+
+// mkdirInside 逐级创建工作目录中的目录 name，已存在的各级必须是目录而不是符号链接
+func mkdirInside(dir, name string) error {
+	if name == "." {
+		return nil
+	}
+	p := dir
+	for _, segment := range strings.Split(name, "/") {
+		p = filepath.Join(p, segment)
+		info, err := os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			if err := os.Mkdir(p, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("路径 %s 经过工作目录中的文件或符号链接 %s", name, segment)
+		}
+	}
+	return nil
+}
+
+func writeFile(name string, r io.Reader, perm fs.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"Vortexia/internal/executor"
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+)
+
+// checkoutStepName 代码检出步骤的名称
+const checkoutStepName = "checkout"
+
+// checkoutStepOrder 代码检出步骤排在流水线定义的所有步骤和恢复缓存步骤之前，
+// 使用负数以保持定义中步骤的顺序号不变（只重新执行失败步骤时按顺序号匹配原步骤）
+const checkoutStepOrder = math.MinInt32
+
+// createCheckoutStep 为作业创建代码检出步骤，项目未配置仓库或流水线关闭检出时返回 nil
+func (e *Engine) createCheckoutStep(build *model.Build, job *model.BuildJob, def *pipeline.Definition, project *model.Project) (*model.BuildStep, error) {
+	if project.RepoURL == "" || !def.Checkout.Enabled() {
+		return nil, nil
+	}
+
+	step := &model.BuildStep{
+		BuildID:   build.ID,
+		JobID:     &job.ID,
+		Name:      checkoutStepName,
+		Command:   "git checkout",
+		Status:    model.StepStatusPending,
+		StartedAt: time.Now(),
+		StepOrder: checkoutStepOrder,
+	}
+	if err := e.buildRepo.CreateStep(step); err != nil {
+		return nil, err
+	}
+	return step, nil
+}
+
+// runCheckout 将项目仓库检出到作业的工作目录。构建未指定提交时第一个检出的作业检出分支的最新提交，
+// 并将其写回构建，其余作业、之后的步骤和重新执行的构建都使用这个提交
+func (e *Engine) runCheckout(ctx context.Context, j *jobRun, step *model.BuildStep) (string, error) {
+	build, def := j.Build, j.def
+	out, err := e.startStep(ctx, j, step)
+	if err != nil {
+		return "", err
+	}
+
+	branch := build.Branch
+	if branch == "" && build.Tag == "" {
+		branch = j.DefaultBranch
+	}
+
+	status := model.StepStatusSuccess
+	sha, changed, err := j.checkout(ctx, j.ws, &executor.Checkout{
+		RepoURL:    j.RepoURL,
+		Branch:     branch,
+		Tag:        build.Tag,
+		Depth:      def.Checkout.CloneDepth(),
+		Submodules: def.Checkout.HasSubmodules(),
+		Output:     out,
+	})
+	if interrupted := interruptStatus(ctx); interrupted != "" && err != nil {
+		status = interrupted
+		out.WriteString(interruptMessage(ctx, def, nil))
+	} else if err != nil {
+		status = model.StepStatusFailed
+		out.WriteString(fmt.Sprintf("\n代码检出失败: %v\n", err))
+	} else {
+		out.WriteString(fmt.Sprintf("检出提交 %s\n", sha))
+	}
+
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+
+	if status == model.StepStatusSuccess && changed {
+		if err := e.reporter.UpdateCommit(build.ID, sha); err != nil {
+			return "", err
+		}
+	}
+
+	return status, e.endStep(ctx, j, step, status)
+}
+
+// checkout 检出构建的提交，返回检出的提交以及构建的提交是否因此改变。
+// 提交尚未确定时持有锁检出，其余作业等待后检出同一个提交，避免分支在检出期间更新导致各作业检出不同的提交
+func (b *buildRun) checkout(ctx context.Context, ws executor.Workspace, c *executor.Checkout) (string, bool, error) {
+	b.mu.Lock()
+	if b.commit != "" {
+		c.Commit = b.commit
+		b.mu.Unlock()
+
+		sha, err := ws.Checkout(ctx, c)
+		if err != nil {
+			return "", false, err
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		// 指定的提交可能是缩写，记录完整的提交
+		changed := b.commit != sha
+		b.commit = sha
+		return sha, changed, nil
+	}
+	defer b.mu.Unlock()
+
+	sha, err := ws.Checkout(ctx, c)
+	if err != nil {
+		return "", false, err
+	}
+	b.commit = sha
+	return sha, true, nil
+}
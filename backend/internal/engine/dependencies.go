@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+)
+
+// dependenciesStepName 下载上游作业制品步骤的名称
+const dependenciesStepName = "dependencies"
+
+// dependenciesStepOrder 下载上游作业制品步骤排在代码检出之后、恢复缓存之前
+const dependenciesStepOrder = math.MinInt32 + 1
+
+// createDependenciesStep 为需要获取上游作业制品的作业创建下载制品步骤，不需要时返回 nil
+func (e *Engine) createDependenciesStep(build *model.Build, job *model.BuildJob, spec *pipeline.Job) (*model.BuildStep, error) {
+	if len(spec.ArtifactsFrom) == 0 {
+		return nil, nil
+	}
+
+	step := &model.BuildStep{
+		BuildID:   build.ID,
+		JobID:     &job.ID,
+		Name:      dependenciesStepName,
+		Command:   "download " + strings.Join(spec.ArtifactsFrom, ", "),
+		Status:    model.StepStatusPending,
+		StartedAt: time.Now(),
+		StepOrder: dependenciesStepOrder,
+	}
+	if err := e.buildRepo.CreateStep(step); err != nil {
+		return nil, err
+	}
+	return step, nil
+}
+
+// runDependencies 将上游作业的制品下载到工作目录，返回步骤状态。
+// 上游作业没有制品、制品已过期或下载失败时步骤失败，作业的步骤不再执行
+func (e *Engine) runDependencies(ctx context.Context, j *jobRun, step *model.BuildStep) (string, error) {
+	out, err := e.startStep(ctx, j, step)
+	if err != nil {
+		return "", err
+	}
+
+	status := model.StepStatusSuccess
+	files := 0
+	for _, upstream := range j.spec.ArtifactsFrom {
+		n, err := e.downloadDependency(ctx, j, upstream, out)
+		files += n
+		if interrupted := interruptStatus(ctx); interrupted != "" {
+			status = interrupted
+			out.WriteString(interruptMessage(ctx, j.def, nil))
+			break
+		}
+		if err != nil {
+			status = model.StepStatusFailed
+			out.WriteString(err.Error() + "\n")
+			break
+		}
+	}
+	if status == model.StepStatusSuccess {
+		out.WriteString(fmt.Sprintf("共下载 %d 个文件\n", files))
+	}
+
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return status, e.endStep(ctx, j, step, status)
+}
+
+// downloadDependency 下载上游作业 upstream 的全部制品，返回下载的文件数。
+// 下载前先检查全部制品是否过期，避免下载了部分文件后才失败
+func (e *Engine) downloadDependency(ctx context.Context, j *jobRun, upstream string, out io.Writer) (int, error) {
+	artifacts, err := e.reporter.DependencyArtifacts(ctx, j.job.ID, upstream)
+	if err != nil {
+		return 0, fmt.Errorf("获取作业 %s 的制品失败: %v", upstream, err)
+	}
+	if len(artifacts) == 0 {
+		return 0, fmt.Errorf("作业 %s 没有可下载的制品：制品路径没有匹配的文件，或制品已过期被删除", upstream)
+	}
+
+	now := time.Now()
+	for _, artifact := range artifacts {
+		if artifact.ExpiresAt != nil && !artifact.ExpiresAt.After(now) {
+			return 0, fmt.Errorf("作业 %s 的制品 %s 已于 %s 过期", upstream, artifact.Path, artifact.ExpiresAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+	if artifacts[0].BuildID != j.Build.ID {
+		fmt.Fprintf(out, "作业 %s 的步骤复用了构建 #%d 的结果，下载该构建中的制品\n", upstream, artifacts[0].BuildID)
+	}
+
+	for i, artifact := range artifacts {
+		if interruptStatus(ctx) != "" {
+			return i, nil
+		}
+		if err := e.downloadArtifact(ctx, j, artifact); err != nil {
+			return i, fmt.Errorf("下载作业 %s 的制品 %s 失败: %v", upstream, artifact.Path, err)
+		}
+		fmt.Fprintf(out, "已下载作业 %s 的制品 %s（%d 字节）\n", upstream, artifact.Path, artifact.Size)
+	}
+	return len(artifacts), nil
+}
+
+// downloadArtifact 将制品文件写入工作目录中的相同路径，替换已存在的文件，并校验内容的SHA-256
+func (e *Engine) downloadArtifact(ctx context.Context, j *jobRun, artifact *model.Artifact) error {
+	name := path.Clean(artifact.Path)
+	if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, "\\") {
+		return fmt.Errorf("无效的制品路径 %q", artifact.Path)
+	}
+
+	dir := j.ws.Dir()
+	if err := mkdirInside(dir, path.Dir(name)); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if info, err := os.Lstat(target); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("与工作目录中的目录 %s 冲突", name)
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+
+	content, err := e.reporter.DownloadArtifact(ctx, j.job.ID, artifact)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	hash := sha256.New()
+	if err := writeFile(target, io.TeeReader(content, hash), fs.FileMode(artifact.Mode).Perm()); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); artifact.Checksum != "" && sum != artifact.Checksum {
+		return fmt.Errorf("文件内容的SHA-256 %s 与制品记录的 %s 不一致", sum, artifact.Checksum)
+	}
+	return nil
+}
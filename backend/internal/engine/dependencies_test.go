@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"Vortexia/internal/model"
+)
+
+func TestRunDependencyArtifacts(t *testing.T) {
+	cfg := `stages:
+  - name: build
+    artifacts:
+      paths: [bin/]
+    steps: [{name: compile, run: %s}]
+  - name: test
+    dependencies: [build]
+    steps: [{name: run, run: cat bin/app}]
+`
+	tests := []struct {
+		name       string
+		build      string // build 作业的脚本
+		corrupt    bool
+		wantStatus string
+		wantLog    []string // 下载制品步骤的日志
+	}{
+		{
+			name:       "downloaded",
+			build:      "write bin/app hello",
+			wantStatus: model.JobStatusSuccess,
+			wantLog:    []string{"已下载作业 build 的制品 bin/app（5 字节）", "共下载 1 个文件"},
+		},
+		{
+			name:       "no artifacts",
+			build:      "make",
+			wantStatus: model.JobStatusFailed,
+			wantLog:    []string{"作业 build 没有可下载的制品"},
+		},
+		{
+			name:       "checksum mismatch",
+			build:      "write bin/app hello",
+			corrupt:    true,
+			wantStatus: model.JobStatusFailed,
+			wantLog:    []string{"SHA-256"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, 2)
+			e.reporter.corrupt = tt.corrupt
+			jobs := e.run(t, strings.Replace(cfg, "%s", tt.build, 1))
+
+			if got := e.reporter.lastJobStatus(jobs["build"].ID); got != model.JobStatusSuccess {
+				t.Fatalf("build job status = %q, want success", got)
+			}
+			test := jobs["test"]
+			if got := e.reporter.lastJobStatus(test.ID); got != tt.wantStatus {
+				t.Errorf("test job status = %q, want %q", got, tt.wantStatus)
+			}
+
+			// 下载制品的步骤在作业的其他步骤之前执行
+			download, run := test.Steps[0], test.Steps[len(test.Steps)-1]
+			log := e.reporter.log(download.ID)
+			for _, want := range tt.wantLog {
+				if !strings.Contains(log, want) {
+					t.Errorf("download log = %q, want %q", log, want)
+				}
+			}
+
+			if tt.wantStatus == model.JobStatusSuccess {
+				// 制品写入下游作业的工作目录
+				if got := strings.TrimSpace(e.reporter.log(run.ID)); got != "hello" {
+					t.Errorf("run log = %q, want the downloaded file", got)
+				}
+				return
+			}
+			if got := e.reporter.stepStatus[download.ID]; got != model.StepStatusFailed {
+				t.Errorf("download step status = %q, want failed", got)
+			}
+			if got := e.reporter.stepStatus[run.ID]; got != model.StepStatusSkipped {
+				t.Errorf("run step status = %q, want skipped", got)
+			}
+			if e.reporter.buildStatus != model.BuildStatusFailed {
+				t.Errorf("build status = %q, want failed", e.reporter.buildStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,964 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/executor"
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/secrets"
+	"Vortexia/internal/storage"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 构建中断的原因，通过 context.Cause 区分
+var (
+	errBuildCanceled = errors.New("build canceled")
+	errBuildTimeout  = errors.New("build timed out")
+	errStepTimeout   = errors.New("step timed out")
+	errFailFast      = errors.New("matrix job failed")
+)
+
+// Engine 构建执行引擎，按流水线定义的依赖关系执行构建作业，作业内依次执行步骤
+type Engine struct {
+	reporter Reporter
+	executor executor.Executor
+	cfg      *config.Config
+
+	// 准备构建只在服务端进行，远程执行器的引擎没有这些仓库
+	buildRepo    repository.BuildRepository
+	pipelineRepo repository.PipelineRepository
+	projectRepo  repository.ProjectRepository
+	secretRepo   repository.SecretRepository
+	keyring      *secrets.Keyring
+	artifacts    *storage.ArtifactStore
+	caches       *storage.CacheStore
+
+	mu      sync.Mutex
+	running map[int]context.CancelCauseFunc // 本进程正在执行的构建
+}
+
+// New 创建在服务端执行构建的引擎
+func New(repos *repository.Repositories, cfg *config.Config, keyring *secrets.Keyring, artifacts *storage.ArtifactStore, caches *storage.CacheStore) *Engine {
+	e := NewRemote(NewReporter(repos, artifacts, caches), cfg)
+	e.buildRepo = repos.Build
+	e.pipelineRepo = repos.Pipeline
+	e.projectRepo = repos.Project
+	e.secretRepo = repos.Secret
+	e.keyring = keyring
+	e.artifacts = artifacts
+	e.caches = caches
+	return e
+}
+
+// NewRemote 创建远程执行器使用的引擎，只能通过 Run 执行服务端准备好的构建
+func NewRemote(reporter Reporter, cfg *config.Config) *Engine {
+	return &Engine{
+		reporter: reporter,
+		executor: executor.New(cfg),
+		cfg:      cfg,
+		running:  make(map[int]context.CancelCauseFunc),
+	}
+}
+
+// Abort 终止本进程中正在执行的构建，构建不在本进程执行时返回 false
+func (e *Engine) Abort(buildID int) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cancel, ok := e.running[buildID]
+	if ok {
+		cancel(errBuildCanceled)
+	}
+	return ok
+}
+
+// Running 返回本进程正在执行的构建
+func (e *Engine) Running() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ids := make([]int, 0, len(e.running))
+	for id := range e.running {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// track 登记正在执行的构建，返回的 ctx 在构建被 Abort 时取消
+func (e *Engine) track(ctx context.Context, buildID int) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	e.mu.Lock()
+	e.running[buildID] = cancel
+	e.mu.Unlock()
+
+	return ctx, func() {
+		e.mu.Lock()
+		delete(e.running, buildID)
+		e.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// Execute 在本进程中准备并执行构建。构建与步骤的结果写回数据库，
+// 返回的错误仅表示引擎本身无法继续（如数据库故障），而不是构建失败。
+func (e *Engine) Execute(ctx context.Context, buildID int) error {
+	// 先登记再读取状态，避免错过读取状态之后到达的取消信号
+	ctx, untrack := e.track(ctx, buildID)
+	defer untrack()
+
+	job, err := e.Prepare(buildID, nil)
+	if err != nil || job == nil {
+		return err
+	}
+	return e.run(ctx, job)
+}
+
+// Run 执行服务端准备好的构建，结果通过上报器写回。
+// 返回的错误表示上报失败等引擎本身的问题，而不是构建失败。
+func (e *Engine) Run(ctx context.Context, job *model.RunnerJob) error {
+	ctx, untrack := e.track(ctx, job.Build.ID)
+	defer untrack()
+
+	return e.run(ctx, job)
+}
+
+// Prepare 将构建标记为执行中并创建作业和步骤记录，返回执行构建所需的信息。
+// runnerID 为领取构建的远程执行器，在服务端执行时为 nil。
+// 构建已结束，或配置无效而被直接标记为失败时返回 nil。
+func (e *Engine) Prepare(buildID int, runnerID *int) (*model.RunnerJob, error) {
+	ctx := context.Background()
+
+	build, err := e.buildRepo.GetByID(buildID)
+	if err != nil {
+		return nil, err
+	}
+	if build == nil {
+		return nil, fmt.Errorf("build %d not found", buildID)
+	}
+
+	switch build.Status {
+	case model.BuildStatusPending:
+	case model.BuildStatusRunning:
+		// 上一次执行被中断（如工作进程崩溃或执行器失联），清理已创建的作业和步骤后从头执行
+		logger.Warn("Restarting interrupted build", zap.Int("build_id", build.ID))
+		// 制品记录随作业删除，需先删除制品文件
+		if err := e.artifacts.DeleteByBuild(ctx, build.ID); err != nil {
+			return nil, err
+		}
+		if err := e.buildRepo.DeleteStepsByBuild(build.ID); err != nil {
+			return nil, err
+		}
+		if err := e.buildRepo.DeleteJobsByBuild(build.ID); err != nil {
+			return nil, err
+		}
+	default:
+		// 已结束的构建无需再执行
+		return nil, nil
+	}
+
+	p, err := e.pipelineRepo.GetByID(build.PipelineID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("pipeline %d of build %d not found", build.PipelineID, buildID)
+	}
+
+	project, err := e.projectRepo.GetByID(p.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, fmt.Errorf("project %d of build %d not found", p.ProjectID, buildID)
+	}
+
+	if err := e.buildRepo.UpdateStatus(build.ID, model.BuildStatusRunning); err != nil {
+		return nil, err
+	}
+	if err := e.buildRepo.SetRunner(build.ID, runnerID); err != nil {
+		return nil, err
+	}
+	build.Status = model.BuildStatusRunning
+	build.RunnerID = runnerID
+
+	config := build.Config
+	if config == "" {
+		// 早期的构建没有配置快照
+		config = p.Config
+	}
+
+	def, err := pipeline.Parse(config)
+	if err != nil {
+		// 配置在保存时已校验，这里失败说明配置被绕过校验修改过
+		logger.Error("Invalid pipeline config",
+			zap.Int("build_id", build.ID),
+			zap.Int("pipeline_id", p.ID),
+			zap.Error(err),
+		)
+		return nil, e.finish(ctx, build, model.BuildStatusFailed)
+	}
+
+	buildSecrets, err := e.buildSecrets(project.ID, p.ID)
+	if err != nil {
+		// 密钥无法解密（如主密钥已从配置中移除），构建无法按预期执行
+		logger.Error("Cannot decrypt build secrets", zap.Int("build_id", build.ID), zap.Error(err))
+		return nil, e.finish(ctx, build, model.BuildStatusFailed)
+	}
+
+	jobs, err := e.createJobs(build, def, project)
+	if err != nil {
+		_ = e.finish(ctx, build, model.BuildStatusFailed)
+		return nil, err
+	}
+
+	reusable, err := e.reusableSteps(build)
+	if err != nil {
+		_ = e.finish(ctx, build, model.BuildStatusFailed)
+		return nil, err
+	}
+
+	return &model.RunnerJob{
+		Build:         build,
+		ProjectID:     project.ID,
+		Config:        config,
+		RepoURL:       project.RepoURL,
+		DefaultBranch: project.Branch,
+		Jobs:          jobs,
+		Reusable:      reusable,
+		Secrets:       buildSecrets,
+	}, nil
+}
+
+// buildSecrets 解密流水线的构建可以使用的密钥，流水线的密钥覆盖同名的项目密钥
+func (e *Engine) buildSecrets(projectID, pipelineID int) (map[string]string, error) {
+	stored, err := e.secretRepo.GetForBuild(projectID, pipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(stored))
+	for _, secret := range stored {
+		value, err := e.keyring.Open(secret)
+		if err != nil {
+			return nil, err
+		}
+		values[secret.Name] = value
+	}
+	return values, nil
+}
+
+// run 按依赖关系执行构建的作业，写入构建的最终状态
+func (e *Engine) run(ctx context.Context, job *model.RunnerJob) error {
+	build := job.Build
+
+	def, err := pipeline.Parse(job.Config)
+	if err == nil {
+		err = checkPrepared(def, job)
+	}
+	if err != nil {
+		// 服务端准备构建时已解析过配置，远程执行器的版本与服务端不一致时可能失败
+		logger.Error("Cannot run prepared build", zap.Int("build_id", build.ID), zap.Error(err))
+		return e.finish(ctx, build, model.BuildStatusFailed)
+	}
+
+	if def.Timeout != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, def.Timeout.Duration, errBuildTimeout)
+		defer cancel()
+	}
+
+	values := make([]string, 0, len(job.Secrets))
+	for _, value := range job.Secrets {
+		values = append(values, value)
+	}
+	run := &buildRun{RunnerJob: job, def: def, commit: build.Commit, redactor: secrets.NewRedactor(values)}
+
+	status, err := e.runGraph(ctx, run)
+	if err != nil {
+		_ = e.finish(ctx, build, model.BuildStatusFailed)
+		return err
+	}
+
+	return e.finish(ctx, build, status)
+}
+
+// checkPrepared 检查服务端准备的作业和步骤与流水线定义是否一致
+func checkPrepared(def *pipeline.Definition, job *model.RunnerJob) error {
+	specs := def.Jobs()
+	if len(specs) != len(job.Jobs) {
+		return fmt.Errorf("pipeline defines %d jobs but %d were prepared", len(specs), len(job.Jobs))
+	}
+	for i, spec := range specs {
+		if steps := splitSteps(job.Jobs[i]).steps; spec.Name != job.Jobs[i].Name || len(spec.Steps) != len(steps) {
+			return fmt.Errorf("job %q does not match the prepared job %q", spec.Name, job.Jobs[i].Name)
+		}
+	}
+	return nil
+}
+
+// finish 写入构建的最终状态并通知日志订阅者构建结束
+func (e *Engine) finish(ctx context.Context, build *model.Build, status string) error {
+	if err := e.reporter.FinishBuild(build.ID, status); err != nil {
+		return err
+	}
+
+	e.publish(ctx, &model.LogEvent{
+		Type:    model.LogEventBuildEnd,
+		BuildID: build.ID,
+		Status:  status,
+	})
+	return nil
+}
+
+// publish 发布日志事件，发布失败只影响实时日志，不影响构建本身
+func (e *Engine) publish(ctx context.Context, event *model.LogEvent) {
+	event.Time = time.Now()
+	// 构建被取消后仍需发布步骤和构建结束事件
+	if err := e.reporter.Publish(context.WithoutCancel(ctx), event); err != nil {
+		logger.Warn("Failed to publish log event",
+			zap.Int("build_id", event.BuildID),
+			zap.String("type", event.Type),
+			zap.Error(err),
+		)
+	}
+}
+
+// skipStep 将步骤标记为跳过，跳过原因写入步骤日志
+func (e *Engine) skipStep(ctx context.Context, j *jobRun, step *model.BuildStep, reason string) error {
+	if reason != "" {
+		if err := e.reporter.AppendLog(step.ID, []byte(reason)); err != nil {
+			return err
+		}
+	}
+	if err := e.reporter.UpdateStepStatus(step.ID, model.StepStatusSkipped); err != nil {
+		return err
+	}
+
+	event := j.event(model.LogEventStepEnd, step)
+	event.Status = model.StepStatusSkipped
+	e.publish(ctx, event)
+	return nil
+}
+
+// reusableSteps 返回只重新执行失败步骤时可复用的原构建步骤，按作业名称和步骤顺序索引。
+// 早期的构建没有作业，其步骤不可复用
+func (e *Engine) reusableSteps(build *model.Build) (map[string]map[int]*model.BuildStep, error) {
+	if build.RerunOf == nil || build.RerunMode != model.RerunModeFailed {
+		return nil, nil
+	}
+
+	jobs, err := e.buildRepo.GetJobsByBuild(*build.RerunOf)
+	if err != nil {
+		return nil, err
+	}
+	jobNames := make(map[int]string, len(jobs))
+	for _, job := range jobs {
+		jobNames[job.ID] = job.Name
+	}
+
+	steps, err := e.buildRepo.GetStepsByBuild(*build.RerunOf)
+	if err != nil {
+		return nil, err
+	}
+
+	reusable := make(map[string]map[int]*model.BuildStep)
+	for _, step := range steps {
+		if step.Status != model.StepStatusSuccess || step.JobID == nil {
+			continue
+		}
+		name, ok := jobNames[*step.JobID]
+		if !ok {
+			continue
+		}
+		if reusable[name] == nil {
+			reusable[name] = make(map[int]*model.BuildStep)
+		}
+		reusable[name][step.StepOrder] = step
+	}
+	return reusable, nil
+}
+
+// reuseStep 复用原构建中成功步骤的结果，不再执行
+func (e *Engine) reuseStep(ctx context.Context, j *jobRun, step, orig *model.BuildStep) error {
+	msg := fmt.Sprintf("复用构建 #%d 中该步骤的成功结果，跳过执行\n", orig.BuildID)
+	if err := e.reporter.AppendLog(step.ID, []byte(msg)); err != nil {
+		return err
+	}
+
+	// 复用的步骤记录原步骤，原步骤本身也是复用的结果时指向最初执行的步骤
+	from := orig.ID
+	if orig.ReusedFrom != nil {
+		from = *orig.ReusedFrom
+	}
+	if err := e.reporter.ReuseStep(step.ID, from); err != nil {
+		return err
+	}
+
+	event := j.event(model.LogEventStepEnd, step)
+	event.Status = model.StepStatusSuccess
+	e.publish(ctx, event)
+	return nil
+}
+
+// createJobs 按定义顺序为构建创建作业记录及各作业的步骤记录
+func (e *Engine) createJobs(build *model.Build, def *pipeline.Definition, project *model.Project) ([]*model.BuildJob, error) {
+	var jobs []*model.BuildJob
+	for i, spec := range def.Jobs() {
+		job := &model.BuildJob{
+			BuildID:  build.ID,
+			Name:     spec.Name,
+			Stage:    spec.Stage,
+			Status:   model.JobStatusPending,
+			Needs:    spec.DependsOn,
+			MatrixOf: spec.MatrixOf,
+			Matrix:   make([]string, 0, len(spec.MatrixValues)),
+			JobOrder: i,
+		}
+		for _, mv := range spec.MatrixValues {
+			job.Matrix = append(job.Matrix, mv.Name+"="+mv.Value)
+		}
+		if err := e.buildRepo.CreateJob(job); err != nil {
+			return nil, err
+		}
+
+		checkout, err := e.createCheckoutStep(build, job, def, project)
+		if err != nil {
+			return nil, err
+		}
+		if checkout != nil {
+			job.Steps = append(job.Steps, checkout)
+		}
+
+		dependencies, err := e.createDependenciesStep(build, job, spec)
+		if err != nil {
+			return nil, err
+		}
+		if dependencies != nil {
+			job.Steps = append(job.Steps, dependencies)
+		}
+
+		restoreCache, saveCache, err := e.createCacheSteps(build, job, spec)
+		if err != nil {
+			return nil, err
+		}
+		if restoreCache != nil {
+			job.Steps = append(job.Steps, restoreCache)
+		}
+
+		steps, err := e.createSteps(build, job, spec)
+		if err != nil {
+			return nil, err
+		}
+		job.Steps = append(job.Steps, steps...)
+
+		if saveCache != nil {
+			job.Steps = append(job.Steps, saveCache)
+		}
+
+		artifacts, err := e.createArtifactsStep(build, job, spec)
+		if err != nil {
+			return nil, err
+		}
+		if artifacts != nil {
+			job.Steps = append(job.Steps, artifacts)
+		}
+
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// createSteps 按定义顺序为作业创建步骤记录
+func (e *Engine) createSteps(build *model.Build, job *model.BuildJob, spec *pipeline.Job) ([]*model.BuildStep, error) {
+	var steps []*model.BuildStep
+	for i, stepSpec := range spec.Steps {
+		step := &model.BuildStep{
+			BuildID:   build.ID,
+			JobID:     &job.ID,
+			Name:      stepSpec.Name,
+			Command:   stepSpec.Run,
+			Status:    model.StepStatusPending,
+			StartedAt: time.Now(),
+			StepOrder: i,
+		}
+		if err := e.buildRepo.CreateStep(step); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// interrupted 判断构建是否已被取消或超时，返回对应的构建状态，未中断时返回空字符串。
+// 取消信号可能丢失，因此同时检查数据库中的状态
+func (e *Engine) interrupted(ctx context.Context, buildID int) (string, error) {
+	switch context.Cause(ctx) {
+	case nil:
+	case errBuildTimeout:
+		return model.BuildStatusTimedOut, nil
+	default:
+		return model.BuildStatusCanceled, nil
+	}
+
+	status, err := e.reporter.BuildStatus(buildID)
+	if err != nil {
+		return "", err
+	}
+	if status == model.BuildStatusCanceled {
+		return model.BuildStatusCanceled, nil
+	}
+	return "", nil
+}
+
+// runSteps 检出代码、下载上游作业的制品、恢复缓存后依次执行作业的步骤，
+// 检出、下载制品或某一步失败、构建被取消、超时后其余步骤标记为跳过，
+// 最后按配置保存缓存、上传制品，返回作业的最终状态
+func (e *Engine) runSteps(ctx context.Context, j *jobRun) (string, error) {
+	build := j.Build
+	split := splitSteps(j.job)
+	checkout, steps := split.checkout, split.steps
+	reused, ran := 0, 0
+
+	status := model.JobStatusSuccess
+	// 作业中已结束步骤的名称到状态，供条件表达式读取
+	results := make(map[string]string, len(j.job.Steps))
+
+	if checkout != nil {
+		interrupted, err := e.interrupted(ctx, build.ID)
+		if err != nil {
+			return "", err
+		}
+		if interrupted != "" {
+			status = interrupted
+			if err := e.skipStep(ctx, j, checkout, ""); err != nil {
+				return "", err
+			}
+			results[checkout.Name] = model.StepStatusSkipped
+		} else {
+			stepStatus, err := e.runCheckout(ctx, j, checkout)
+			if err != nil {
+				return "", err
+			}
+			status = jobStatusOf(stepStatus)
+			results[checkout.Name] = stepStatus
+		}
+	}
+
+	if deps := split.dependencies; deps != nil {
+		if status != model.JobStatusSuccess {
+			if err := e.skipStep(ctx, j, deps, ""); err != nil {
+				return "", err
+			}
+			results[deps.Name] = model.StepStatusSkipped
+		} else {
+			stepStatus, err := e.runDependencies(ctx, j, deps)
+			if err != nil {
+				return "", err
+			}
+			status = jobStatusOf(stepStatus)
+			results[deps.Name] = stepStatus
+		}
+	}
+
+	var cache *jobCache
+	if restore := split.restoreCache; restore != nil {
+		if status != model.JobStatusSuccess {
+			if err := e.skipStep(ctx, j, restore, ""); err != nil {
+				return "", err
+			}
+			results[restore.Name] = model.StepStatusSkipped
+		} else {
+			stepStatus, restored, err := e.runRestoreCache(ctx, j, restore)
+			if err != nil {
+				return "", err
+			}
+			cache = restored
+			status = jobStatusOf(stepStatus)
+			results[restore.Name] = stepStatus
+		}
+	}
+
+	for i, spec := range j.spec.Steps {
+		step := steps[i]
+
+		if status == model.JobStatusSuccess || status == model.JobStatusFailed {
+			interrupted, err := e.interrupted(ctx, build.ID)
+			if err != nil {
+				return "", err
+			}
+			if interrupted != "" {
+				status = interrupted
+			}
+		}
+
+		// 构建被取消或超时后不再执行任何步骤，包括 always() 的步骤
+		if status == model.JobStatusCanceled || status == model.JobStatusTimedOut {
+			if err := e.skipStep(ctx, j, step, ""); err != nil {
+				return "", err
+			}
+			results[step.Name] = model.StepStatusSkipped
+			continue
+		}
+
+		if !spec.If.Eval(exprContext(j, spec, results, status != model.JobStatusSuccess)) {
+			var reason string
+			if spec.If != nil {
+				reason = fmt.Sprintf("条件 %s 不满足，跳过\n", spec.If)
+			}
+			if err := e.skipStep(ctx, j, step, reason); err != nil {
+				return "", err
+			}
+			results[step.Name] = model.StepStatusSkipped
+			continue
+		}
+
+		if !spec.When.Matches(build.Branch) {
+			reason := fmt.Sprintf("分支 %s 不满足执行条件，跳过\n", build.Branch)
+			if err := e.skipStep(ctx, j, step, reason); err != nil {
+				return "", err
+			}
+			results[step.Name] = model.StepStatusSkipped
+			continue
+		}
+
+		if orig := j.Reusable[j.job.Name][step.StepOrder]; orig != nil && orig.Name == step.Name {
+			if err := e.reuseStep(ctx, j, step, orig); err != nil {
+				return "", err
+			}
+			results[step.Name] = model.StepStatusSuccess
+			reused++
+			continue
+		}
+
+		stepStatus, err := e.runStep(ctx, j, spec, step)
+		if err != nil {
+			return "", err
+		}
+		ran++
+		results[step.Name] = stepStatus
+		// 失败后按条件执行的步骤成功时，作业仍为失败
+		if next := jobStatusOf(stepStatus); next != model.JobStatusSuccess {
+			status = next
+		}
+	}
+
+	reusedOnly := reused > 0 && ran == 0
+	if split.saveCache != nil {
+		var err error
+		if status, err = e.runSaveCache(ctx, j, split.saveCache, cache, status, reusedOnly); err != nil {
+			return "", err
+		}
+	}
+	if split.artifacts != nil {
+		return e.runArtifacts(ctx, j, split.artifacts, status, reusedOnly)
+	}
+	return status, nil
+}
+
+// jobSteps 作业的步骤按用途分组，未创建的步骤为 nil
+type jobSteps struct {
+	checkout     *model.BuildStep   // 代码检出
+	dependencies *model.BuildStep   // 下载上游作业的制品
+	restoreCache *model.BuildStep   // 恢复缓存
+	steps        []*model.BuildStep // 流水线定义中的步骤
+	saveCache    *model.BuildStep   // 保存缓存
+	artifacts    *model.BuildStep   // 上传制品
+}
+
+// splitSteps 按步骤顺序号将作业的步骤分组
+func splitSteps(job *model.BuildJob) jobSteps {
+	var s jobSteps
+	for _, step := range job.Steps {
+		switch step.StepOrder {
+		case checkoutStepOrder:
+			s.checkout = step
+		case dependenciesStepOrder:
+			s.dependencies = step
+		case cacheRestoreStepOrder:
+			s.restoreCache = step
+		case cacheSaveStepOrder:
+			s.saveCache = step
+		case artifactsStepOrder:
+			s.artifacts = step
+		default:
+			s.steps = append(s.steps, step)
+		}
+	}
+	return s
+}
+
+// jobStatusOf 返回步骤结束后作业应处的状态
+func jobStatusOf(stepStatus string) string {
+	switch stepStatus {
+	case model.StepStatusSuccess:
+		return model.JobStatusSuccess
+	case model.StepStatusCanceled:
+		return model.JobStatusCanceled
+	case model.StepStatusTimedOut:
+		return model.JobStatusTimedOut
+	default:
+		return model.JobStatusFailed
+	}
+}
+
+// runStep 执行单个步骤，失败时按重试策略重新执行
+func (e *Engine) runStep(ctx context.Context, j *jobRun, spec *pipeline.Step, step *model.BuildStep) (string, error) {
+	out, err := e.startStep(ctx, j, step)
+	if err != nil {
+		return "", err
+	}
+
+	maxAttempts := spec.Retry.Attempts()
+	var status string
+	exitCode := -1
+	for attempt := 1; ; attempt++ {
+		status, exitCode, err = e.runAttempt(ctx, j, spec, step, out, attempt)
+		if err != nil {
+			_ = out.Close()
+			return "", err
+		}
+		if status != model.StepStatusFailed || attempt >= maxAttempts || !spec.Retry.ShouldRetry(exitCode) {
+			break
+		}
+
+		delay := spec.Retry.Delay(attempt)
+		out.WriteString(fmt.Sprintf("将在 %s 后进行第 %d/%d 次执行\n", delay, attempt+1, maxAttempts))
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		if interrupted := interruptStatus(ctx); interrupted != "" {
+			status = interrupted
+			out.WriteString(interruptMessage(ctx, j.def, spec))
+			break
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+
+	if exitCode >= 0 {
+		if err := e.reporter.SetStepExitCode(step.ID, exitCode); err != nil {
+			return "", err
+		}
+	}
+	return status, e.endStep(ctx, j, step, status)
+}
+
+// startStep 将步骤标记为执行中，返回步骤的输出。
+// 输出在执行期间定期写入日志存储，并逐行发布给实时日志订阅者
+func (e *Engine) startStep(ctx context.Context, j *jobRun, step *model.BuildStep) (*stepOutput, error) {
+	if err := e.reporter.UpdateStepStatus(step.ID, model.StepStatusRunning); err != nil {
+		return nil, err
+	}
+
+	e.publish(ctx, j.event(model.LogEventStepStart, step))
+
+	out := newStepOutput(
+		e.cfg.Log.MaxStepBytes,
+		j.redactor,
+		func(data []byte) error {
+			return e.reporter.AppendLog(step.ID, data)
+		},
+		func(line string) {
+			e.publish(ctx, &model.LogEvent{
+				Type:    model.LogEventLog,
+				BuildID: j.Build.ID,
+				StepID:  step.ID,
+				Line:    line,
+			})
+		},
+	)
+	return out, nil
+}
+
+// endStep 写入步骤的最终状态并通知日志订阅者步骤结束
+func (e *Engine) endStep(ctx context.Context, j *jobRun, step *model.BuildStep, status string) error {
+	if err := e.reporter.UpdateStepStatus(step.ID, status); err != nil {
+		return err
+	}
+
+	event := j.event(model.LogEventStepEnd, step)
+	event.Status = status
+	e.publish(ctx, event)
+	return nil
+}
+
+// runAttempt 执行一次步骤命令并记录执行结果，返回步骤状态和命令退出码
+func (e *Engine) runAttempt(ctx context.Context, j *jobRun, spec *pipeline.Step, step *model.BuildStep, out *stepOutput, n int) (string, int, error) {
+	offset, line := out.Mark()
+	attempt := &model.BuildStepAttempt{
+		StepID:    step.ID,
+		Attempt:   n,
+		Status:    model.StepStatusRunning,
+		LogOffset: offset,
+		LogLine:   line,
+		StartedAt: time.Now(),
+	}
+	if err := e.reporter.CreateStepAttempt(attempt); err != nil {
+		return "", 0, err
+	}
+
+	// 超时时间对每次执行单独计算
+	attemptCtx := ctx
+	if spec.Timeout != nil {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeoutCause(ctx, spec.Timeout.Duration, errStepTimeout)
+		defer cancel()
+	}
+
+	status := model.StepStatusSuccess
+	result, err := j.ws.Run(attemptCtx, &executor.Command{
+		Script: spec.Run,
+		Image:  spec.ImageOr(j.def.Image),
+		Env:    stepEnv(j, spec),
+		Output: out,
+	})
+	if interrupted := interruptStatus(attemptCtx); interrupted != "" && (err != nil || result.ExitCode != 0) {
+		status = interrupted
+		out.WriteString(interruptMessage(attemptCtx, j.def, spec))
+	} else if err != nil {
+		status = model.StepStatusFailed
+		out.WriteString(fmt.Sprintf("\n命令执行失败: %v\n", err))
+	} else if result.ExitCode != 0 {
+		status = model.StepStatusFailed
+		out.WriteString(fmt.Sprintf("\n命令退出码: %d\n", result.ExitCode))
+	}
+
+	if result != nil && result.ExitCode >= 0 {
+		exitCode := result.ExitCode
+		attempt.ExitCode = &exitCode
+	}
+
+	end, endLine := out.Mark()
+	now := time.Now()
+	attempt.Status = status
+	attempt.LogBytes = end - offset
+	attempt.LogLines = endLine - line
+	attempt.FinishedAt = &now
+	if err := e.reporter.FinishStepAttempt(attempt); err != nil {
+		return "", 0, err
+	}
+
+	exitCode := -1
+	if attempt.ExitCode != nil {
+		exitCode = *attempt.ExitCode
+	}
+	return status, exitCode, nil
+}
+
+// interruptStatus 根据 ctx 结束的原因返回步骤状态，ctx 未结束时返回空字符串
+func interruptStatus(ctx context.Context) string {
+	switch context.Cause(ctx) {
+	case nil:
+		return ""
+	case errStepTimeout, errBuildTimeout:
+		return model.StepStatusTimedOut
+	default:
+		return model.StepStatusCanceled
+	}
+}
+
+// interruptMessage 返回写入步骤日志的中断说明
+func interruptMessage(ctx context.Context, def *pipeline.Definition, spec *pipeline.Step) string {
+	switch context.Cause(ctx) {
+	case errStepTimeout:
+		return fmt.Sprintf("\n步骤执行超过 %s，已终止\n", spec.Timeout.Duration)
+	case errBuildTimeout:
+		return fmt.Sprintf("\n构建执行超过 %s，已终止\n", def.Timeout.Duration)
+	case errFailFast:
+		return "\n同一矩阵中的其他作业失败，已终止\n"
+	default:
+		return "\n构建已取消\n"
+	}
+}
+
+// exprContext 返回步骤条件表达式求值时的构建上下文，failed 表示作业中之前的步骤已失败
+func exprContext(j *jobRun, spec *pipeline.Step, results map[string]string, failed bool) *pipeline.ExprContext {
+	trigger := "manual"
+	switch {
+	case j.Build.RerunOf != nil:
+		trigger = "rerun"
+	case j.Build.Event != "":
+		trigger = j.Build.Event
+	}
+
+	env := make(map[string]string, len(j.def.Env)+len(spec.Env))
+	for k, v := range j.def.Env {
+		env[k] = v
+	}
+	for k, v := range spec.Env {
+		env[k] = v
+	}
+
+	matrix := make(map[string]string, len(j.spec.MatrixValues))
+	for _, mv := range j.spec.MatrixValues {
+		matrix[mv.Name] = mv.Value
+	}
+
+	params := make(map[string]string, len(j.Build.Parameters))
+	for _, param := range j.Build.Parameters {
+		name, value := pipeline.SplitParameter(param)
+		params[name] = value
+	}
+
+	return &pipeline.ExprContext{
+		Branch:  j.Build.Branch,
+		Tag:     j.Build.Tag,
+		Commit:  j.Commit(),
+		Trigger: trigger,
+		Env:     env,
+		Matrix:  matrix,
+		Params:  params,
+		Steps:   results,
+		Failed:  failed,
+	}
+}
+
+// stepEnv 组装步骤的环境变量，步骤级配置覆盖流水线级配置。
+// 矩阵变量以 MATRIX_ 加大写的变量名传入，如 MATRIX_GO
+func stepEnv(j *jobRun, spec *pipeline.Step) []string {
+	build := j.Build
+	env := []string{
+		"CI=true",
+		"VORTEXIA=true",
+		"VORTEXIA_BUILD_ID=" + strconv.Itoa(build.ID),
+		"VORTEXIA_PIPELINE_ID=" + strconv.Itoa(build.PipelineID),
+		"VORTEXIA_BRANCH=" + build.Branch,
+		"VORTEXIA_TAG=" + build.Tag,
+		"VORTEXIA_COMMIT=" + j.Commit(),
+		"VORTEXIA_STAGE=" + j.job.Stage,
+		"VORTEXIA_JOB=" + j.job.Name,
+	}
+	for _, mv := range j.spec.MatrixValues {
+		env = append(env, "MATRIX_"+strings.ToUpper(mv.Name)+"="+mv.Value)
+	}
+	for _, param := range build.Parameters {
+		name, value := pipeline.SplitParameter(param)
+		env = append(env, pipeline.ParamEnvName(name)+"="+value)
+	}
+	// 密钥排在流水线的环境变量之前，配置中同名的环境变量会覆盖密钥
+	for k, v := range j.Secrets {
+		env = append(env, k+"="+v)
+	}
+	for k, v := range j.def.Env {
+		env = append(env, k+"="+v)
+	}
+	for k, v := range spec.Env {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
@@ -0,0 +1,644 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/executor"
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+	"Vortexia/internal/repository"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// fakeBuildRepo 为作业和步骤分配ID的构建仓库，只用于创建构建的作业和步骤
+type fakeBuildRepo struct {
+	repository.BuildRepository
+	mu     sync.Mutex
+	nextID int
+	jobs   map[int][]*model.BuildJob
+	steps  map[int][]*model.BuildStep
+}
+
+func newFakeBuildRepo() *fakeBuildRepo {
+	return &fakeBuildRepo{jobs: make(map[int][]*model.BuildJob), steps: make(map[int][]*model.BuildStep)}
+}
+
+func (r *fakeBuildRepo) CreateJob(job *model.BuildJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	job.ID = r.nextID
+	r.jobs[job.BuildID] = append(r.jobs[job.BuildID], job)
+	return nil
+}
+
+func (r *fakeBuildRepo) CreateStep(step *model.BuildStep) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	step.ID = r.nextID
+	r.steps[step.BuildID] = append(r.steps[step.BuildID], step)
+	return nil
+}
+
+func (r *fakeBuildRepo) GetJobsByBuild(buildID int) ([]*model.BuildJob, error) {
+	return r.jobs[buildID], nil
+}
+
+func (r *fakeBuildRepo) GetStepsByBuild(buildID int) ([]*model.BuildStep, error) {
+	return r.steps[buildID], nil
+}
+
+// fakeReporter 在内存中记录上报的状态、日志和事件
+type fakeReporter struct {
+	mu          sync.Mutex
+	jobStatus   map[int][]string // 作业依次上报的状态
+	stepStatus  map[int]string
+	exitCodes   map[int]int
+	reused      map[int]int
+	attempts    map[int][]*model.BuildStepAttempt
+	logs        map[int]*bytes.Buffer
+	events      []*model.LogEvent
+	buildStatus string // FinishBuild 写入的最终状态
+	canceled    bool   // BuildStatus 返回已取消，模拟丢失的取消信号
+
+	repo      *fakeBuildRepo            // 按名称查找上游作业
+	artifacts map[int][]*model.Artifact // 作业上传的制品
+	contents  map[int][]byte            // 制品ID到文件内容
+	corrupt   bool                      // 下载的制品内容与上传时不同
+}
+
+func newFakeReporter() *fakeReporter {
+	return &fakeReporter{
+		jobStatus:  make(map[int][]string),
+		stepStatus: make(map[int]string),
+		exitCodes:  make(map[int]int),
+		reused:     make(map[int]int),
+		attempts:   make(map[int][]*model.BuildStepAttempt),
+		logs:       make(map[int]*bytes.Buffer),
+		artifacts:  make(map[int][]*model.Artifact),
+		contents:   make(map[int][]byte),
+	}
+}
+
+func (r *fakeReporter) UpdateJobStatus(jobID int, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobStatus[jobID] = append(r.jobStatus[jobID], status)
+	return nil
+}
+
+func (r *fakeReporter) UpdateStepStatus(stepID int, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stepStatus[stepID] = status
+	return nil
+}
+
+func (r *fakeReporter) SetStepExitCode(stepID int, exitCode int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exitCodes[stepID] = exitCode
+	return nil
+}
+
+func (r *fakeReporter) ReuseStep(stepID int, fromStepID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reused[stepID] = fromStepID
+	r.stepStatus[stepID] = model.StepStatusSuccess
+	return nil
+}
+
+func (r *fakeReporter) CreateStepAttempt(attempt *model.BuildStepAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts[attempt.StepID] = append(r.attempts[attempt.StepID], attempt)
+	return nil
+}
+
+func (r *fakeReporter) FinishStepAttempt(attempt *model.BuildStepAttempt) error { return nil }
+
+func (r *fakeReporter) UpdateCommit(buildID int, commit string) error { return nil }
+
+func (r *fakeReporter) AppendLog(stepID int, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.logs[stepID] == nil {
+		r.logs[stepID] = &bytes.Buffer{}
+	}
+	r.logs[stepID].Write(data)
+	return nil
+}
+
+func (r *fakeReporter) Publish(ctx context.Context, event *model.LogEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *fakeReporter) UploadArtifact(ctx context.Context, artifact *model.Artifact, content io.ReadSeeker, size int64) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	artifact.ID = len(r.contents) + 1
+	artifact.Size = size
+	artifact.Checksum = hex.EncodeToString(sum[:])
+	r.contents[artifact.ID] = data
+	r.artifacts[artifact.JobID] = append(r.artifacts[artifact.JobID], artifact)
+	return nil
+}
+
+func (r *fakeReporter) DependencyArtifacts(ctx context.Context, jobID int, upstream string) ([]*model.Artifact, error) {
+	r.repo.mu.Lock()
+	defer r.repo.mu.Unlock()
+	for _, jobs := range r.repo.jobs {
+		for _, job := range jobs {
+			if job.ID != jobID {
+				continue
+			}
+			for _, other := range jobs {
+				if other.Name == upstream {
+					r.mu.Lock()
+					defer r.mu.Unlock()
+					return r.artifacts[other.ID], nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("job %s not found", upstream)
+}
+
+func (r *fakeReporter) DownloadArtifact(ctx context.Context, jobID int, artifact *model.Artifact) (io.ReadCloser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.contents[artifact.ID]
+	if !ok {
+		return nil, fmt.Errorf("artifact %d not found", artifact.ID)
+	}
+	if r.corrupt {
+		data = append([]byte("corrupted "), data...)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (r *fakeReporter) RestoreCache(ctx context.Context, jobID int, cache *model.Cache, restoreKeys []string) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func (r *fakeReporter) SaveCache(ctx context.Context, jobID int, cache *model.Cache, content io.ReadSeeker, size int64) error {
+	return nil
+}
+
+func (r *fakeReporter) BuildStatus(buildID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.canceled {
+		return model.BuildStatusCanceled, nil
+	}
+	return model.BuildStatusRunning, nil
+}
+
+func (r *fakeReporter) FinishBuild(buildID int, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buildStatus = status
+	return nil
+}
+
+// lastJobStatus 返回作业最后上报的状态
+func (r *fakeReporter) lastJobStatus(jobID int) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := r.jobStatus[jobID]
+	if len(statuses) == 0 {
+		return ""
+	}
+	return statuses[len(statuses)-1]
+}
+
+// log 返回步骤的日志
+func (r *fakeReporter) log(stepID int) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.logs[stepID] == nil {
+		return ""
+	}
+	return r.logs[stepID].String()
+}
+
+// fakeExecutor 不执行真实命令的执行器，按脚本内容模拟步骤的结果：
+//
+//	exit N             以退出码 N 结束
+//	sleep              等待一小段时间后成功
+//	wait               一直等待到 ctx 结束，模拟被终止的命令
+//	write PATH CONTENT 将 CONTENT 写入工作目录中的文件 PATH
+//	cat PATH           输出工作目录中的文件 PATH，文件不存在时失败
+//
+// 其余脚本输出脚本内容后成功。同时记录命令的开始和结束顺序以及同时执行的命令数
+type fakeExecutor struct {
+	t *testing.T
+	// run 非 nil 时代替按脚本内容模拟的结果
+	run func(ctx context.Context, job *model.BuildJob, cmd *executor.Command) (*executor.Result, error)
+
+	mu         sync.Mutex
+	trace      []string // "start <作业>"、"end <作业>"
+	running    int
+	maxRunning int
+}
+
+func (e *fakeExecutor) Prepare(ctx context.Context, build *model.Build, job *model.BuildJob) (executor.Workspace, error) {
+	return &fakeWorkspace{executor: e, job: job, dir: e.t.TempDir()}, nil
+}
+
+func (e *fakeExecutor) record(entry string, delta int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.trace = append(e.trace, entry)
+	e.running += delta
+	if e.running > e.maxRunning {
+		e.maxRunning = e.running
+	}
+}
+
+// index 返回 entry 在执行顺序中第一次出现的位置，不存在时为-1
+func (e *fakeExecutor) index(entry string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, got := range e.trace {
+		if got == entry {
+			return i
+		}
+	}
+	return -1
+}
+
+type fakeWorkspace struct {
+	executor *fakeExecutor
+	job      *model.BuildJob
+	dir      string
+}
+
+func (w *fakeWorkspace) Dir() string { return w.dir }
+
+func (w *fakeWorkspace) Run(ctx context.Context, cmd *executor.Command) (*executor.Result, error) {
+	w.executor.record("start "+w.job.Name, 1)
+	defer w.executor.record("end "+w.job.Name, -1)
+
+	if w.executor.run != nil {
+		return w.executor.run(ctx, w.job, cmd)
+	}
+	return w.simulate(ctx, cmd)
+}
+
+func (w *fakeWorkspace) Checkout(ctx context.Context, c *executor.Checkout) (string, error) {
+	return "", fmt.Errorf("checkout is not supported")
+}
+
+func (w *fakeWorkspace) Close() error { return nil }
+
+// simulate 按脚本内容模拟命令的结果
+func (w *fakeWorkspace) simulate(ctx context.Context, cmd *executor.Command) (*executor.Result, error) {
+	switch script := strings.TrimSpace(cmd.Script); {
+	case strings.HasPrefix(script, "write "):
+		name, content, _ := strings.Cut(strings.TrimPrefix(script, "write "), " ")
+		target := filepath.Join(w.dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, err
+		}
+		return &executor.Result{ExitCode: 0}, os.WriteFile(target, []byte(content), 0o644)
+	case strings.HasPrefix(script, "cat "):
+		data, err := os.ReadFile(filepath.Join(w.dir, filepath.FromSlash(strings.TrimPrefix(script, "cat "))))
+		if err != nil {
+			fmt.Fprintln(cmd.Output, err)
+			return &executor.Result{ExitCode: 1}, nil
+		}
+		cmd.Output.Write(data)
+		return &executor.Result{ExitCode: 0}, nil
+	case strings.HasPrefix(script, "exit "):
+		code, err := strconv.Atoi(strings.TrimPrefix(script, "exit "))
+		if err != nil {
+			return nil, err
+		}
+		return &executor.Result{ExitCode: code}, nil
+	case script == "sleep":
+		select {
+		case <-ctx.Done():
+			return &executor.Result{ExitCode: -1}, nil
+		case <-time.After(20 * time.Millisecond):
+			return &executor.Result{ExitCode: 0}, nil
+		}
+	case script == "wait":
+		<-ctx.Done()
+		return &executor.Result{ExitCode: -1}, nil
+	default:
+		fmt.Fprintln(cmd.Output, script)
+		return &executor.Result{ExitCode: 0}, nil
+	}
+}
+
+// testEngine 使用 fake 上报器和执行器的引擎
+type testEngine struct {
+	*Engine
+	reporter *fakeReporter
+	executor *fakeExecutor
+	repo     *fakeBuildRepo
+}
+
+func newTestEngine(t *testing.T, maxParallelJobs int) *testEngine {
+	t.Helper()
+	reporter := newFakeReporter()
+	exec := &fakeExecutor{t: t}
+	repo := newFakeBuildRepo()
+	reporter.repo = repo
+
+	cfg := &config.Config{}
+	cfg.Executor.MaxParallelJobs = maxParallelJobs
+	e := NewRemote(reporter, cfg)
+	e.executor = exec
+	e.buildRepo = repo
+	return &testEngine{Engine: e, reporter: reporter, executor: exec, repo: repo}
+}
+
+// prepare 按流水线配置创建构建的作业和步骤，项目未配置仓库，作业不检出代码
+func (e *testEngine) prepare(t *testing.T, build *model.Build, cfg string) *model.RunnerJob {
+	t.Helper()
+	def, err := pipeline.Parse(cfg)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	jobs, err := e.createJobs(build, def, &model.Project{ID: 1})
+	if err != nil {
+		t.Fatalf("createJobs() error = %v", err)
+	}
+	reusable, err := e.reusableSteps(build)
+	if err != nil {
+		t.Fatalf("reusableSteps() error = %v", err)
+	}
+	return &model.RunnerJob{Build: build, ProjectID: 1, Config: cfg, Jobs: jobs, Reusable: reusable}
+}
+
+// run 创建并执行构建，返回构建的作业
+func (e *testEngine) run(t *testing.T, cfg string) map[string]*model.BuildJob {
+	t.Helper()
+	job := e.prepare(t, &model.Build{ID: 1, PipelineID: 1, Branch: "main", Status: model.BuildStatusRunning}, cfg)
+	if err := e.Run(context.Background(), job); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return jobsByName(job.Jobs)
+}
+
+func jobsByName(jobs []*model.BuildJob) map[string]*model.BuildJob {
+	byName := make(map[string]*model.BuildJob, len(jobs))
+	for _, job := range jobs {
+		byName[job.Name] = job
+	}
+	return byName
+}
+
+func TestRunStepTimeout(t *testing.T) {
+	e := newTestEngine(t, 1)
+	jobs := e.run(t, `stages:
+  - name: test
+    steps:
+      - {name: hang, run: wait, timeout: 30ms}
+      - {name: after, run: make}
+`)
+
+	steps := jobs["test"].Steps
+	if got := e.reporter.stepStatus[steps[0].ID]; got != model.StepStatusTimedOut {
+		t.Errorf("step status = %q, want timed_out", got)
+	}
+	if log := e.reporter.log(steps[0].ID); !strings.Contains(log, "步骤执行超过 30ms，已终止") {
+		t.Errorf("step log = %q, want the timeout message", log)
+	}
+	if got := e.reporter.stepStatus[steps[1].ID]; got != model.StepStatusSkipped {
+		t.Errorf("step after the timeout status = %q, want skipped", got)
+	}
+	if got := e.reporter.lastJobStatus(jobs["test"].ID); got != model.JobStatusTimedOut {
+		t.Errorf("job status = %q, want timed_out", got)
+	}
+	if e.reporter.buildStatus != model.BuildStatusTimedOut {
+		t.Errorf("build status = %q, want timed_out", e.reporter.buildStatus)
+	}
+}
+
+func TestRunBuildTimeout(t *testing.T) {
+	e := newTestEngine(t, 1)
+	start := time.Now()
+	jobs := e.run(t, `timeout: 50ms
+stages:
+  - name: build
+    steps: [{name: hang, run: wait}]
+  - name: test
+    steps: [{name: unit, run: make test}]
+`)
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("build ran for %v after the timeout", elapsed)
+	}
+	if log := e.reporter.log(jobs["build"].Steps[0].ID); !strings.Contains(log, "构建执行超过 50ms，已终止") {
+		t.Errorf("step log = %q, want the timeout message", log)
+	}
+	if got := e.reporter.lastJobStatus(jobs["build"].ID); got != model.JobStatusTimedOut {
+		t.Errorf("job build status = %q, want timed_out", got)
+	}
+	if got := e.reporter.lastJobStatus(jobs["test"].ID); got != model.JobStatusSkipped {
+		t.Errorf("job test status = %q, want skipped", got)
+	}
+	if e.reporter.buildStatus != model.BuildStatusTimedOut {
+		t.Errorf("build status = %q, want timed_out", e.reporter.buildStatus)
+	}
+}
+
+func TestRunStepRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		retry        string
+		exitCodes    []int // 每次执行的退出码，之后的执行成功
+		wantStatus   string
+		wantAttempts int
+		wantExitCode int
+		wantLog      string
+	}{
+		{
+			name:         "succeeds on retry",
+			retry:        "{max_attempts: 3}",
+			exitCodes:    []int{1, 1},
+			wantStatus:   model.StepStatusSuccess,
+			wantAttempts: 3,
+			wantLog:      "将在 0s 后进行第 3/3 次执行",
+		},
+		{
+			name:         "attempts exhausted",
+			retry:        "{max_attempts: 2, backoff: 10ms}",
+			exitCodes:    []int{3, 4, 5},
+			wantStatus:   model.StepStatusFailed,
+			wantAttempts: 2,
+			wantExitCode: 4,
+			wantLog:      "将在 10ms 后进行第 2/2 次执行",
+		},
+		{
+			name:         "matching exit code",
+			retry:        "{max_attempts: 2, on_exit_codes: [75]}",
+			exitCodes:    []int{75},
+			wantStatus:   model.StepStatusSuccess,
+			wantAttempts: 2,
+		},
+		{
+			name:         "other exit code",
+			retry:        "{max_attempts: 2, on_exit_codes: [75]}",
+			exitCodes:    []int{1},
+			wantStatus:   model.StepStatusFailed,
+			wantAttempts: 1,
+			wantExitCode: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, 1)
+			runs := 0
+			e.executor.run = func(ctx context.Context, job *model.BuildJob, cmd *executor.Command) (*executor.Result, error) {
+				runs++
+				if runs <= len(tt.exitCodes) {
+					return &executor.Result{ExitCode: tt.exitCodes[runs-1]}, nil
+				}
+				return &executor.Result{ExitCode: 0}, nil
+			}
+			jobs := e.run(t, `stages:
+  - name: test
+    steps:
+      - {name: flaky, run: make test, retry: `+tt.retry+`}
+`)
+
+			step := jobs["test"].Steps[0]
+			if got := e.reporter.stepStatus[step.ID]; got != tt.wantStatus {
+				t.Errorf("step status = %q, want %q", got, tt.wantStatus)
+			}
+			attempts := e.reporter.attempts[step.ID]
+			if len(attempts) != tt.wantAttempts || runs != tt.wantAttempts {
+				t.Fatalf("attempts = %d, runs = %d, want %d", len(attempts), runs, tt.wantAttempts)
+			}
+			for i, attempt := range attempts {
+				if attempt.Attempt != i+1 {
+					t.Errorf("attempt %d numbered %d", i+1, attempt.Attempt)
+				}
+			}
+			if got := e.reporter.exitCodes[step.ID]; got != tt.wantExitCode {
+				t.Errorf("exit code = %d, want %d", got, tt.wantExitCode)
+			}
+			if log := e.reporter.log(step.ID); !strings.Contains(log, tt.wantLog) {
+				t.Errorf("step log = %q, want %q", log, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestRunStepRetryNotAfterTimeout(t *testing.T) {
+	e := newTestEngine(t, 1)
+	jobs := e.run(t, `stages:
+  - name: test
+    steps:
+      - {name: hang, run: wait, timeout: 20ms, retry: {max_attempts: 3}}
+`)
+
+	// 超时不是失败，不重试
+	step := jobs["test"].Steps[0]
+	if got := e.reporter.stepStatus[step.ID]; got != model.StepStatusTimedOut {
+		t.Errorf("step status = %q, want timed_out", got)
+	}
+	if got := len(e.reporter.attempts[step.ID]); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
+
+func TestRunRerunFailedReusesSteps(t *testing.T) {
+	cfg := `stages:
+  - name: build
+    steps:
+      - {name: compile, run: make}
+  - name: test
+    steps:
+      - {name: unit, run: make test}
+      - {name: e2e, run: make e2e}
+`
+	e := newTestEngine(t, 1)
+	// 原构建中 e2e 失败，compile 复用了更早的构建 #1 中的结果
+	orig := e.prepare(t, &model.Build{ID: 2, PipelineID: 1, Status: model.BuildStatusRunning}, cfg)
+	origJobs := jobsByName(orig.Jobs)
+	compile, unit, e2e := origJobs["build"].Steps[0], origJobs["test"].Steps[0], origJobs["test"].Steps[1]
+	first := 1
+	compile.Status, compile.ReusedFrom = model.StepStatusSuccess, &first
+	unit.Status = model.StepStatusSuccess
+	e2e.Status = model.StepStatusFailed
+
+	tests := []struct {
+		name       string
+		mode       string
+		wantReused map[string]int // 复用的步骤到原步骤的ID
+		wantRuns   int
+	}{
+		{name: "failed", mode: model.RerunModeFailed, wantReused: map[string]int{"compile": first, "unit": unit.ID}, wantRuns: 1},
+		{name: "all", mode: model.RerunModeAll, wantReused: map[string]int{}, wantRuns: 3},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build := &model.Build{ID: 3 + i, PipelineID: 1, Status: model.BuildStatusRunning, RerunOf: &orig.Build.ID, RerunMode: tt.mode}
+			job := e.prepare(t, build, cfg)
+			before := len(e.executor.trace)
+			if err := e.Run(context.Background(), job); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if runs := (len(e.executor.trace) - before) / 2; runs != tt.wantRuns {
+				t.Errorf("ran %d steps, want %d", runs, tt.wantRuns)
+			}
+
+			for _, j := range job.Jobs {
+				for _, step := range j.Steps {
+					from, reused := e.reporter.reused[step.ID]
+					want, wantReused := tt.wantReused[step.Name]
+					if reused != wantReused || from != want {
+						t.Errorf("step %s reused from %d (%v), want %d (%v)", step.Name, from, reused, want, wantReused)
+					}
+					if got := e.reporter.stepStatus[step.ID]; got != model.StepStatusSuccess {
+						t.Errorf("step %s status = %q, want success", step.Name, got)
+					}
+					if reused && !strings.Contains(e.reporter.log(step.ID), "复用构建 #2 中该步骤的成功结果") {
+						t.Errorf("step %s log = %q, want the reuse message", step.Name, e.reporter.log(step.ID))
+					}
+				}
+			}
+			if e.reporter.buildStatus != model.BuildStatusSuccess {
+				t.Errorf("build status = %q, want success", e.reporter.buildStatus)
+			}
+		})
+	}
+}
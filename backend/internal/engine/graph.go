@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"Vortexia/internal/executor"
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+	"Vortexia/internal/secrets"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// buildRun 正在执行的构建，由并行执行的作业共享
+type buildRun struct {
+	*model.RunnerJob
+	def      *pipeline.Definition
+	redactor *secrets.Redactor // 替换步骤输出中的密钥
+
+	mu     sync.Mutex
+	commit string // 构建检出的提交，第一个检出代码的作业确定后其余作业检出同一个提交
+}
+
+// Commit 返回构建检出的提交，尚未检出时为创建构建时指定的提交
+func (b *buildRun) Commit() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.commit
+}
+
+// jobRun 正在执行的作业
+type jobRun struct {
+	*buildRun
+	job  *model.BuildJob
+	spec *pipeline.Job
+	ws   executor.Workspace
+}
+
+// event 创建作业或作业中步骤的日志事件，step 为 nil 时为作业事件
+func (j *jobRun) event(eventType string, step *model.BuildStep) *model.LogEvent {
+	event := &model.LogEvent{
+		Type:    eventType,
+		BuildID: j.Build.ID,
+		JobID:   j.job.ID,
+		JobName: j.job.Name,
+	}
+	if step != nil {
+		event.StepID = step.ID
+		event.StepName = step.Name
+	}
+	return event
+}
+
+// jobResult 作业执行结束的结果
+type jobResult struct {
+	spec    *pipeline.Job
+	status  string
+	stopped bool // 因同一矩阵中的其他作业失败而被终止
+	err     error
+}
+
+// runGraph 按依赖关系执行作业：依赖的作业全部成功后作业才开始执行，依赖的作业未成功时作业被跳过，
+// 同时执行的作业数不超过 EXECUTOR_MAX_PARALLEL_JOBS。矩阵作业失败且矩阵开启 fail_fast 时，
+// 同一矩阵中执行中的作业被终止、尚未开始的作业被跳过。返回构建的最终状态。
+// 引擎出错后不再开始新的作业，等待执行中的作业结束后返回错误
+func (e *Engine) runGraph(ctx context.Context, b *buildRun) (string, error) {
+	specs := b.def.Jobs()
+	limit := e.cfg.Executor.MaxParallelJobs
+	if limit < 1 {
+		limit = 1
+	}
+
+	results := make(map[string]string, len(specs))
+	started := make([]bool, len(specs))
+	done := make(chan jobResult)
+	running := 0
+	var firstErr error
+
+	cancels := make(map[string]context.CancelCauseFunc) // 执行中的作业
+	failedMatrix := make(map[string]string)             // 开启 fail_fast 的矩阵中第一个失败的作业
+
+	for {
+		// 跳过的作业可能使后面的作业满足条件，直到没有新的作业可以开始
+		for progress := true; progress && firstErr == nil; {
+			progress = false
+			for i, spec := range specs {
+				if started[i] {
+					continue
+				}
+				ready, failed := dependencies(spec, results)
+				if !ready || (failed == "" && running >= limit) {
+					continue
+				}
+
+				started[i] = true
+				j := &jobRun{buildRun: b, job: b.Jobs[i], spec: spec}
+				reason := ""
+				if failed != "" {
+					reason = fmt.Sprintf("依赖的作业 %s 未成功，跳过\n", failed)
+				} else if sibling, ok := failedMatrix[spec.MatrixOf]; ok && spec.MatrixOf != "" {
+					reason = fmt.Sprintf("同一矩阵中的作业 %s 失败，跳过\n", sibling)
+				}
+				if reason != "" {
+					if err := e.skipJob(ctx, j, reason); err != nil {
+						firstErr = err
+						break
+					}
+					results[spec.Name] = model.JobStatusSkipped
+					progress = true
+					continue
+				}
+
+				running++
+				jobCtx, cancel := context.WithCancelCause(ctx)
+				cancels[spec.Name] = cancel
+				go func() {
+					status, err := e.runJob(jobCtx, j)
+					stopped := context.Cause(jobCtx) == errFailFast
+					done <- jobResult{spec: j.spec, status: status, stopped: stopped, err: err}
+				}()
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+		result := <-done
+		running--
+		spec := result.spec
+		cancels[spec.Name](nil)
+		delete(cancels, spec.Name)
+
+		results[spec.Name] = result.status
+		if result.stopped && result.status == model.JobStatusCanceled {
+			// 被终止的作业记为取消，但构建状态由失败的作业决定，而不是视为构建被取消
+			results[spec.Name] = model.JobStatusFailed
+		}
+		if result.err != nil && firstErr == nil {
+			firstErr = result.err
+		}
+
+		if (result.status == model.JobStatusFailed || result.status == model.JobStatusTimedOut) &&
+			spec.MatrixOf != "" && spec.Matrix.StopOnFailure() {
+			if _, ok := failedMatrix[spec.MatrixOf]; !ok {
+				failedMatrix[spec.MatrixOf] = spec.Name
+				for _, other := range specs {
+					if cancel, ok := cancels[other.Name]; ok && other.MatrixOf == spec.MatrixOf {
+						cancel(errFailFast)
+					}
+				}
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return buildStatusOf(results), nil
+}
+
+// dependencies 判断作业依赖的作业是否都已结束，并返回第一个未成功的依赖作业
+func dependencies(spec *pipeline.Job, results map[string]string) (ready bool, failed string) {
+	for _, dep := range spec.DependsOn {
+		status, ok := results[dep]
+		if !ok {
+			return false, ""
+		}
+		if status != model.JobStatusSuccess && failed == "" {
+			failed = dep
+		}
+	}
+	return true, failed
+}
+
+// buildStatusOf 根据全部作业的状态返回构建的最终状态，
+// 超时优先于取消，取消优先于失败，因依赖未成功而跳过的作业不影响构建状态
+func buildStatusOf(results map[string]string) string {
+	status := model.BuildStatusSuccess
+	rank := map[string]int{
+		model.BuildStatusSuccess:  0,
+		model.BuildStatusFailed:   1,
+		model.BuildStatusCanceled: 2,
+		model.BuildStatusTimedOut: 3,
+	}
+	for _, jobStatus := range results {
+		var s string
+		switch jobStatus {
+		case model.JobStatusSuccess, model.JobStatusSkipped:
+			continue
+		case model.JobStatusCanceled:
+			s = model.BuildStatusCanceled
+		case model.JobStatusTimedOut:
+			s = model.BuildStatusTimedOut
+		default:
+			s = model.BuildStatusFailed
+		}
+		if rank[s] > rank[status] {
+			status = s
+		}
+	}
+	return status
+}
+
+// runJob 在作业自己的工作空间中检出代码并依次执行步骤，返回作业的最终状态
+func (e *Engine) runJob(ctx context.Context, j *jobRun) (string, error) {
+	if err := e.reporter.UpdateJobStatus(j.job.ID, model.JobStatusRunning); err != nil {
+		return "", err
+	}
+	e.publish(ctx, j.event(model.LogEventJobStart, nil))
+
+	ws, err := e.executor.Prepare(ctx, j.Build, j.job)
+	if err != nil {
+		_ = e.endJob(ctx, j, model.JobStatusFailed)
+		return "", err
+	}
+	defer func() {
+		if err := ws.Close(); err != nil {
+			logger.Warn("Failed to clean workspace",
+				zap.Int("build_id", j.Build.ID),
+				zap.Int("job_id", j.job.ID),
+				zap.Error(err),
+			)
+		}
+	}()
+	j.ws = ws
+
+	status, err := e.runSteps(ctx, j)
+	if err != nil {
+		_ = e.endJob(ctx, j, model.JobStatusFailed)
+		return "", err
+	}
+	return status, e.endJob(ctx, j, status)
+}
+
+// skipJob 将作业及其全部步骤标记为跳过，跳过原因写入每个步骤的日志
+func (e *Engine) skipJob(ctx context.Context, j *jobRun, reason string) error {
+	for _, step := range j.job.Steps {
+		if err := e.skipStep(ctx, j, step, reason); err != nil {
+			return err
+		}
+	}
+	return e.endJob(ctx, j, model.JobStatusSkipped)
+}
+
+// endJob 写入作业的最终状态并通知日志订阅者作业结束
+func (e *Engine) endJob(ctx context.Context, j *jobRun, status string) error {
+	if err := e.reporter.UpdateJobStatus(j.job.ID, status); err != nil {
+		return err
+	}
+
+	event := j.event(model.LogEventJobEnd, nil)
+	event.Status = status
+	e.publish(ctx, event)
+	return nil
+}
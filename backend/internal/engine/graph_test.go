@@ -0,0 +1,269 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"Vortexia/internal/executor"
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+)
+
+func TestRunGraphOrder(t *testing.T) {
+	e := newTestEngine(t, 4)
+	jobs := e.run(t, `stages:
+  - name: build
+    jobs:
+      - name: compile
+        steps: [{name: a, run: sleep}]
+      - name: lint
+        steps: [{name: a, run: sleep}]
+  - name: test
+    jobs:
+      - name: unit
+        needs: [compile]
+        steps: [{name: a, run: sleep}]
+      - name: e2e
+        steps: [{name: a, run: sleep}]
+  - name: deploy
+    steps: [{name: a, run: sleep}]
+`)
+
+	if e.reporter.buildStatus != model.BuildStatusSuccess {
+		t.Errorf("build status = %q, want success", e.reporter.buildStatus)
+	}
+	for name, job := range jobs {
+		if got := e.reporter.lastJobStatus(job.ID); got != model.JobStatusSuccess {
+			t.Errorf("job %s status = %q, want success", name, got)
+		}
+		// 作业在依赖的作业全部结束后才开始
+		start := e.executor.index("start " + name)
+		for _, dep := range job.Needs {
+			if end := e.executor.index("end " + dep); end < 0 || end > start {
+				t.Errorf("job %s started at %d before %s ended at %d, trace = %v", name, start, dep, end, e.executor.trace)
+			}
+		}
+	}
+	// unit 只依赖 compile，可以与 lint 同时执行
+	if e.executor.maxRunning < 2 {
+		t.Errorf("max running jobs = %d, want independent jobs to run in parallel", e.executor.maxRunning)
+	}
+}
+
+func TestRunGraphSkipsDependentsOfFailedJobs(t *testing.T) {
+	e := newTestEngine(t, 4)
+	jobs := e.run(t, `stages:
+  - name: build
+    jobs:
+      - name: compile
+        steps: [{name: a, run: exit 2}]
+      - name: lint
+        steps: [{name: a, run: make lint}]
+  - name: test
+    jobs:
+      - name: unit
+        needs: [compile]
+        steps: [{name: a, run: make test}, {name: b, run: make cover}]
+      - name: docs
+        needs: [lint]
+        steps: [{name: a, run: make docs}]
+  - name: deploy
+    steps: [{name: a, run: make deploy}]
+`)
+
+	want := map[string]string{
+		"compile": model.JobStatusFailed,
+		"lint":    model.JobStatusSuccess,
+		"unit":    model.JobStatusSkipped,
+		"docs":    model.JobStatusSuccess,
+		"deploy":  model.JobStatusSkipped,
+	}
+	for name, status := range want {
+		if got := e.reporter.lastJobStatus(jobs[name].ID); got != status {
+			t.Errorf("job %s status = %q, want %q", name, got, status)
+		}
+	}
+	if e.reporter.buildStatus != model.BuildStatusFailed {
+		t.Errorf("build status = %q, want failed", e.reporter.buildStatus)
+	}
+
+	// 被跳过的作业的步骤不执行，日志中说明原因
+	if i := e.executor.index("start unit"); i >= 0 {
+		t.Errorf("skipped job unit ran, trace = %v", e.executor.trace)
+	}
+	for _, step := range jobs["unit"].Steps {
+		if got := e.reporter.stepStatus[step.ID]; got != model.StepStatusSkipped {
+			t.Errorf("step %s status = %q, want skipped", step.Name, got)
+		}
+		if log := e.reporter.log(step.ID); !strings.Contains(log, "依赖的作业 compile 未成功") {
+			t.Errorf("step %s log = %q, want the skip reason", step.Name, log)
+		}
+	}
+	// deploy 依赖上一阶段的全部作业，unit 跳过后 deploy 同样跳过
+	if log := e.reporter.log(jobs["deploy"].Steps[0].ID); !strings.Contains(log, "依赖的作业 unit 未成功") {
+		t.Errorf("deploy log = %q, want the skip reason", log)
+	}
+}
+
+func TestRunGraphMaxParallelJobs(t *testing.T) {
+	cfg := `stages:
+  - name: test
+    jobs:
+      - name: a
+        steps: [{name: s, run: sleep}]
+      - name: b
+        steps: [{name: s, run: sleep}]
+      - name: c
+        steps: [{name: s, run: sleep}]
+      - name: d
+        steps: [{name: s, run: sleep}]
+      - name: e
+        steps: [{name: s, run: sleep}]
+`
+	tests := []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{name: "limited", limit: 2, want: 2},
+		{name: "unset", limit: 0, want: 1},
+		{name: "above job count", limit: 10, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, tt.limit)
+			e.run(t, cfg)
+			if e.executor.maxRunning != tt.want {
+				t.Errorf("max running jobs = %d, want %d", e.executor.maxRunning, tt.want)
+			}
+			if e.reporter.buildStatus != model.BuildStatusSuccess {
+				t.Errorf("build status = %q, want success", e.reporter.buildStatus)
+			}
+		})
+	}
+}
+
+func TestBuildStatusOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		results map[string]string
+		want    string
+	}{
+		{name: "no jobs", results: map[string]string{}, want: model.BuildStatusSuccess},
+		{name: "all success", results: map[string]string{"a": model.JobStatusSuccess, "b": model.JobStatusSuccess}, want: model.BuildStatusSuccess},
+		{name: "skipped", results: map[string]string{"a": model.JobStatusSuccess, "b": model.JobStatusSkipped}, want: model.BuildStatusSuccess},
+		{name: "failed", results: map[string]string{"a": model.JobStatusFailed, "b": model.JobStatusSkipped}, want: model.BuildStatusFailed},
+		{name: "canceled over failed", results: map[string]string{"a": model.JobStatusFailed, "b": model.JobStatusCanceled}, want: model.BuildStatusCanceled},
+		{name: "timed out over canceled", results: map[string]string{"a": model.JobStatusTimedOut, "b": model.JobStatusCanceled, "c": model.JobStatusFailed}, want: model.BuildStatusTimedOut},
+		{name: "unknown status", results: map[string]string{"a": model.JobStatusPending}, want: model.BuildStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildStatusOf(tt.results); got != tt.want {
+				t.Errorf("buildStatusOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	results := map[string]string{"a": model.JobStatusSuccess, "b": model.JobStatusFailed, "c": model.JobStatusSkipped}
+	tests := []struct {
+		name       string
+		dependsOn  []string
+		wantReady  bool
+		wantFailed string
+	}{
+		{name: "no dependencies", wantReady: true},
+		{name: "succeeded", dependsOn: []string{"a"}, wantReady: true},
+		{name: "first failed", dependsOn: []string{"a", "c", "b"}, wantReady: true, wantFailed: "c"},
+		{name: "not finished", dependsOn: []string{"b", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, failed := dependencies(&pipeline.Job{DependsOn: tt.dependsOn}, results)
+			if ready != tt.wantReady || failed != tt.wantFailed {
+				t.Errorf("dependencies() = %v, %q, want %v, %q", ready, failed, tt.wantReady, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestRunGraphMatrixFailFast(t *testing.T) {
+	cfg := `stages:
+  - name: test
+    jobs:
+      - name: test
+        matrix:
+          v: [a, b, c]%s
+        steps: [{name: s, run: make test}]
+`
+	// a 失败，b 与 a 同时执行，c 等待 a 或 b 结束后才开始
+	run := func(ctx context.Context, job *model.BuildJob, cmd *executor.Command) (*executor.Result, error) {
+		for _, env := range cmd.Env {
+			if env == "MATRIX_V=a" {
+				time.Sleep(20 * time.Millisecond)
+				return &executor.Result{ExitCode: 1}, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return &executor.Result{ExitCode: -1}, nil
+		case <-time.After(100 * time.Millisecond):
+			return &executor.Result{ExitCode: 0}, nil
+		}
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		e := newTestEngine(t, 2)
+		e.executor.run = run
+		jobs := e.run(t, fmt.Sprintf(cfg, ""))
+
+		want := map[string]string{
+			"test (a)": model.JobStatusFailed,
+			"test (b)": model.JobStatusCanceled,
+			"test (c)": model.JobStatusSkipped,
+		}
+		for name, status := range want {
+			if got := e.reporter.lastJobStatus(jobs[name].ID); got != status {
+				t.Errorf("job %s status = %q, want %q", name, got, status)
+			}
+		}
+		// 被终止的作业不使构建变为取消
+		if e.reporter.buildStatus != model.BuildStatusFailed {
+			t.Errorf("build status = %q, want failed", e.reporter.buildStatus)
+		}
+		if log := e.reporter.log(jobs["test (b)"].Steps[0].ID); !strings.Contains(log, "同一矩阵中的其他作业失败，已终止") {
+			t.Errorf("stopped job log = %q", log)
+		}
+		if log := e.reporter.log(jobs["test (c)"].Steps[0].ID); !strings.Contains(log, "同一矩阵中的作业 test (a) 失败，跳过") {
+			t.Errorf("skipped job log = %q", log)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		e := newTestEngine(t, 2)
+		e.executor.run = run
+		jobs := e.run(t, fmt.Sprintf(cfg, "\n          fail_fast: false"))
+
+		want := map[string]string{
+			"test (a)": model.JobStatusFailed,
+			"test (b)": model.JobStatusSuccess,
+			"test (c)": model.JobStatusSuccess,
+		}
+		for name, status := range want {
+			if got := e.reporter.lastJobStatus(jobs[name].ID); got != status {
+				t.Errorf("job %s status = %q, want %q", name, got, status)
+			}
+		}
+		if e.reporter.buildStatus != model.BuildStatusFailed {
+			t.Errorf("build status = %q, want failed", e.reporter.buildStatus)
+		}
+	})
+}
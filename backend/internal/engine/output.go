@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"Vortexia/internal/secrets"
+)
+
+const (
+	outputFlushInterval = time.Second // 步骤输出写入日志存储的间隔
+	outputLineBuffer    = 1024        // 等待发布的输出行缓冲数
+)
+
+// stepOutput 收集步骤输出：先将密钥替换为 ***，再定期通过 appendLog 追加写入日志存储，
+// 并按行通过 onLine 实时发布。超过 maxBytes 的输出被截断并写入截断标记。
+type stepOutput struct {
+	mu        sync.Mutex
+	redact    *secrets.RedactStream
+	pending   bytes.Buffer // 尚未写入日志存储的输出
+	partial   []byte       // 尚未遇到换行符的残余输出
+	written   int64        // 已接收（未截断部分）的总字节数
+	lineCount int          // 已接收的完整行数
+	lastByte  byte
+	maxBytes  int64
+	truncated bool
+
+	appendLog func(data []byte) error
+	onLine    func(line string)
+	lines     chan string
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newStepOutput(maxBytes int64, redactor *secrets.Redactor, appendLog func(data []byte) error, onLine func(line string)) *stepOutput {
+	o := &stepOutput{
+		redact:    redactor.Stream(),
+		maxBytes:  maxBytes,
+		appendLog: appendLog,
+		onLine:    onLine,
+		lines:     make(chan string, outputLineBuffer),
+		done:      make(chan struct{}),
+	}
+
+	o.wg.Add(2)
+	go o.flushLoop()
+	go o.lineLoop()
+	return o
+}
+
+// Write 实现 io.Writer，stdout与stderr共用同一个实例
+func (o *stepOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.write(p)
+	return len(p), nil
+}
+
+// WriteString 追加引擎自身的提示信息
+func (o *stepOutput) WriteString(s string) {
+	_, _ = o.Write([]byte(s))
+}
+
+func (o *stepOutput) write(p []byte) {
+	if o.truncated || len(p) == 0 {
+		return
+	}
+	o.writeRedacted(o.redact.Write(p))
+}
+
+// flushRedact 写入因可能是密钥开头而暂缓输出的内容
+func (o *stepOutput) flushRedact() {
+	if !o.truncated {
+		o.writeRedacted(o.redact.Flush())
+	}
+}
+
+// writeRedacted 写入已替换密钥的内容，超过 maxBytes 时截断
+func (o *stepOutput) writeRedacted(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+
+	if o.maxBytes > 0 && o.written+int64(len(p)) > o.maxBytes {
+		p = p[:o.maxBytes-o.written]
+		o.truncated = true
+	}
+
+	o.accept(p)
+
+	if o.truncated {
+		marker := fmt.Sprintf("\n[输出超过 %d 字节，后续内容已截断]\n", o.maxBytes)
+		if o.lastByte == '\n' {
+			marker = marker[1:]
+		}
+		o.accept([]byte(marker))
+	}
+}
+
+// accept 将内容加入待写缓冲并切分出完整的行
+func (o *stepOutput) accept(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+
+	o.pending.Write(p)
+	o.written += int64(len(p))
+	o.lineCount += bytes.Count(p, []byte("\n"))
+	o.lastByte = p[len(p)-1]
+
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.lines <- string(bytes.TrimSuffix(o.partial[:i], []byte("\r")))
+		o.partial = o.partial[i+1:]
+	}
+}
+
+// Mark 以换行符结束当前输出，返回已接收的字节数和行数，用于划分每次执行的输出范围
+func (o *stepOutput) Mark() (int64, int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.flushRedact()
+	if o.written > 0 && o.lastByte != '\n' {
+		o.accept([]byte("\n"))
+	}
+	return o.written, o.lineCount
+}
+
+// Close 停止后台协程并写入剩余输出，保证日志以换行符结尾
+func (o *stepOutput) Close() error {
+	o.mu.Lock()
+	o.flushRedact()
+	if len(o.partial) > 0 {
+		o.lines <- string(o.partial)
+		o.partial = nil
+	}
+	if o.written > 0 && o.lastByte != '\n' {
+		o.pending.WriteByte('\n')
+	}
+	close(o.lines)
+	o.mu.Unlock()
+
+	close(o.done)
+	o.wg.Wait()
+
+	return o.flush()
+}
+
+// flush 将待写缓冲追加到日志存储，失败时保留内容等待下一次重试
+func (o *stepOutput) flush() error {
+	o.mu.Lock()
+	if o.pending.Len() == 0 {
+		o.mu.Unlock()
+		return nil
+	}
+	data := append([]byte(nil), o.pending.Bytes()...)
+	o.pending.Reset()
+	o.mu.Unlock()
+
+	if err := o.appendLog(data); err != nil {
+		o.mu.Lock()
+		rest := append(data, o.pending.Bytes()...)
+		o.pending.Reset()
+		o.pending.Write(rest)
+		o.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (o *stepOutput) flushLoop() {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(outputFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.done:
+			return
+		case <-ticker.C:
+			// 写入失败不影响步骤执行，下一次刷新时重试
+			_ = o.flush()
+		}
+	}
+}
+
+func (o *stepOutput) lineLoop() {
+	defer o.wg.Done()
+
+	for line := range o.lines {
+		o.onLine(line)
+	}
+}
@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/storage"
+)
+
+// Reporter 构建执行过程中的状态和日志上报接口。
+// 在服务端执行时直接写入数据库，远程执行器通过执行器API上报
+type Reporter interface {
+	UpdateJobStatus(jobID int, status string) error
+	UpdateStepStatus(stepID int, status string) error
+	SetStepExitCode(stepID int, exitCode int) error
+	ReuseStep(stepID int, fromStepID int) error
+	CreateStepAttempt(attempt *model.BuildStepAttempt) error
+	FinishStepAttempt(attempt *model.BuildStepAttempt) error
+	UpdateCommit(buildID int, commit string) error
+	AppendLog(stepID int, data []byte) error
+	Publish(ctx context.Context, event *model.LogEvent) error
+	// UploadArtifact 上传制品文件，写入制品的ID、大小和SHA-256。上传失败重试时从头读取 content
+	UploadArtifact(ctx context.Context, artifact *model.Artifact, content io.ReadSeeker, size int64) error
+	// DependencyArtifacts 获取与作业同一构建中名为 upstream 的作业上传的制品，
+	// 上游作业的步骤均复用了原构建的结果时返回原构建中该作业的制品
+	DependencyArtifacts(ctx context.Context, jobID int, upstream string) ([]*model.Artifact, error)
+	// DownloadArtifact 读取 DependencyArtifacts 返回的制品文件
+	DownloadArtifact(ctx context.Context, jobID int, artifact *model.Artifact) (io.ReadCloser, error)
+	// RestoreCache 按 cache.Key 精确匹配、按 restoreKeys 前缀匹配查找项目的缓存，
+	// 命中时将缓存记录写入 cache 并返回压缩包的内容，未命中时返回 nil
+	RestoreCache(ctx context.Context, jobID int, cache *model.Cache, restoreKeys []string) (io.ReadCloser, error)
+	// SaveCache 保存作业打包的缓存压缩包，写入缓存的ID、大小和SHA-256。上传失败重试时从头读取 content
+	SaveCache(ctx context.Context, jobID int, cache *model.Cache, content io.ReadSeeker, size int64) error
+	// BuildStatus 返回构建当前的状态，用于发现丢失了取消信号的构建
+	BuildStatus(buildID int) (string, error)
+	// FinishBuild 写入构建的最终状态
+	FinishBuild(buildID int, status string) error
+}
+
+type repoReporter struct {
+	buildRepo repository.BuildRepository
+	logs      repository.BuildLogStream
+	logStore  repository.BuildLogStore
+	artifacts *storage.ArtifactStore
+	caches    *storage.CacheStore
+}
+
+// NewReporter 创建直接写入数据库的上报器
+func NewReporter(repos *repository.Repositories, artifacts *storage.ArtifactStore, caches *storage.CacheStore) Reporter {
+	return &repoReporter{
+		buildRepo: repos.Build,
+		logs:      repos.Logs,
+		logStore:  repos.LogStore,
+		artifacts: artifacts,
+		caches:    caches,
+	}
+}
+
+// UpdateJobStatus 更新作业状态
+func (r *repoReporter) UpdateJobStatus(jobID int, status string) error {
+	return r.buildRepo.UpdateJobStatus(jobID, status)
+}
+
+// UpdateStepStatus 更新步骤状态
+func (r *repoReporter) UpdateStepStatus(stepID int, status string) error {
+	return r.buildRepo.UpdateStepStatus(stepID, status)
+}
+
+// SetStepExitCode 记录步骤命令的退出码
+func (r *repoReporter) SetStepExitCode(stepID int, exitCode int) error {
+	return r.buildRepo.SetStepExitCode(stepID, exitCode)
+}
+
+// ReuseStep 复用原步骤的结果
+func (r *repoReporter) ReuseStep(stepID int, fromStepID int) error {
+	return r.buildRepo.ReuseStep(stepID, fromStepID)
+}
+
+// CreateStepAttempt 创建步骤执行记录
+func (r *repoReporter) CreateStepAttempt(attempt *model.BuildStepAttempt) error {
+	return r.buildRepo.CreateStepAttempt(attempt)
+}
+
+// FinishStepAttempt 写入步骤执行结果
+func (r *repoReporter) FinishStepAttempt(attempt *model.BuildStepAttempt) error {
+	return r.buildRepo.FinishStepAttempt(attempt)
+}
+
+// UpdateCommit 记录构建实际检出的提交
+func (r *repoReporter) UpdateCommit(buildID int, commit string) error {
+	return r.buildRepo.UpdateCommit(buildID, commit)
+}
+
+// AppendLog 追加步骤日志
+func (r *repoReporter) AppendLog(stepID int, data []byte) error {
+	return r.logStore.Append(stepID, data)
+}
+
+// Publish 发布日志事件
+func (r *repoReporter) Publish(ctx context.Context, event *model.LogEvent) error {
+	return r.logs.Publish(ctx, event)
+}
+
+// UploadArtifact 将制品文件保存到制品存储
+func (r *repoReporter) UploadArtifact(ctx context.Context, artifact *model.Artifact, content io.ReadSeeker, size int64) error {
+	return r.artifacts.Save(ctx, artifact, content, size)
+}
+
+// DependencyArtifacts 获取上游作业的制品。只重新执行失败步骤的构建中，上游作业的步骤均复用了原构建的结果时
+// 不会上传制品，沿原构建向前查找最近一次实际执行了该作业的构建中的制品
+func (r *repoReporter) DependencyArtifacts(ctx context.Context, jobID int, upstream string) ([]*model.Artifact, error) {
+	job, err := r.buildRepo.GetJobByID(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("build job %d not found", jobID)
+	}
+
+	buildID := job.BuildID
+	for {
+		found, err := r.jobByName(buildID, upstream)
+		if err != nil || found == nil {
+			return nil, err
+		}
+		artifacts, err := r.artifacts.ListByJob(found.ID)
+		if err != nil || len(artifacts) > 0 {
+			return artifacts, err
+		}
+
+		build, err := r.buildRepo.GetByID(buildID)
+		if err != nil || build == nil || build.RerunOf == nil {
+			return artifacts, err
+		}
+		reused, err := r.reusedOnly(buildID, found.ID)
+		if err != nil || !reused {
+			return artifacts, err
+		}
+		buildID = *build.RerunOf
+	}
+}
+
+// jobByName 获取构建中指定名称的作业，不存在时返回 nil
+func (r *repoReporter) jobByName(buildID int, name string) (*model.BuildJob, error) {
+	jobs, err := r.buildRepo.GetJobsByBuild(buildID)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// reusedOnly 判断作业中流水线定义的步骤是否均复用了原构建的结果
+func (r *repoReporter) reusedOnly(buildID, jobID int) (bool, error) {
+	steps, err := r.buildRepo.GetStepsByBuild(buildID)
+	if err != nil {
+		return false, err
+	}
+	job := &model.BuildJob{ID: jobID}
+	for _, step := range steps {
+		if step.JobID != nil && *step.JobID == jobID {
+			job.Steps = append(job.Steps, step)
+		}
+	}
+
+	defined := splitSteps(job).steps
+	for _, step := range defined {
+		if step.ReusedFrom == nil {
+			return false, nil
+		}
+	}
+	return len(defined) > 0, nil
+}
+
+// DownloadArtifact 从制品存储读取制品文件
+func (r *repoReporter) DownloadArtifact(ctx context.Context, jobID int, artifact *model.Artifact) (io.ReadCloser, error) {
+	content, err := r.artifacts.Open(ctx, artifact)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, errors.New("制品文件已不存在")
+	}
+	return content, err
+}
+
+// RestoreCache 从缓存存储查找并读取项目的缓存，缓存的压缩包已不存在时视为未命中
+func (r *repoReporter) RestoreCache(ctx context.Context, jobID int, cache *model.Cache, restoreKeys []string) (io.ReadCloser, error) {
+	found, err := r.caches.Find(cache.ProjectID, cache.Key, restoreKeys)
+	if err != nil || found == nil {
+		return nil, err
+	}
+
+	content, err := r.caches.Open(ctx, found)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	*cache = *found
+	return content, nil
+}
+
+// SaveCache 将缓存压缩包保存到缓存存储
+func (r *repoReporter) SaveCache(ctx context.Context, jobID int, cache *model.Cache, content io.ReadSeeker, size int64) error {
+	return r.caches.Save(ctx, cache, content, size)
+}
+
+// BuildStatus 返回构建当前的状态
+func (r *repoReporter) BuildStatus(buildID int) (string, error) {
+	build, err := r.buildRepo.GetByID(buildID)
+	if err != nil || build == nil {
+		return "", err
+	}
+	return build.Status, nil
+}
+
+// FinishBuild 写入构建的最终状态
+func (r *repoReporter) FinishBuild(buildID int, status string) error {
+	return r.buildRepo.UpdateStatus(buildID, status)
+}
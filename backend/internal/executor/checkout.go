@@ -0,0 +1,185 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// commitPattern 提交SHA，允许缩写
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// repoSchemes 允许检出的仓库地址协议。ext:: 等协议会执行任意命令，file:// 可以读取主机上的仓库，均不允许
+var repoSchemes = map[string]bool{"http": true, "https": true, "ssh": true, "git": true}
+
+// gitAllowProtocol 限制 git 可以使用的协议，同样作用于子模块的地址
+const gitAllowProtocol = "GIT_ALLOW_PROTOCOL=http:https:ssh:git"
+
+// Checkout 代码检出参数
+type Checkout struct {
+	RepoURL    string
+	Branch     string    // 为空时检出远程仓库的默认分支
+	Tag        string    // 检出标签，优先于 Branch，检出后 HEAD 处于分离状态
+	Commit     string    // 为空时检出分支的最新提交
+	Depth      int       // 克隆深度，0 表示完整历史
+	Submodules bool      // 同时检出子模块
+	Output     io.Writer // git 的输出
+}
+
+// Checkout 在工作目录中检出代码，返回检出的提交SHA。
+// git 总是在执行构建的主机上运行，容器执行器通过挂载的工作空间访问检出的代码
+func (w *localWorkspace) Checkout(ctx context.Context, c *Checkout) (string, error) {
+	if c.Commit != "" && !commitPattern.MatchString(c.Commit) {
+		return "", fmt.Errorf("invalid commit %q", c.Commit)
+	}
+	if c.Branch != "" && !validRefName(c.Branch) {
+		return "", fmt.Errorf("invalid branch %q", c.Branch)
+	}
+	if c.Tag != "" && !validRefName(c.Tag) {
+		return "", fmt.Errorf("invalid tag %q", c.Tag)
+	}
+	if err := validateRepoURL(c.RepoURL); err != nil {
+		return "", err
+	}
+
+	out := c.Output
+	if w.executor.timestamps {
+		out = newTimestampWriter(out)
+	}
+	git := func(args ...string) error {
+		return w.git(ctx, out, nil, args...)
+	}
+
+	if err := git("init", "-q"); err != nil {
+		return "", err
+	}
+	if err := git("remote", "add", "origin", c.RepoURL); err != nil {
+		return "", err
+	}
+
+	var depth []string
+	if c.Depth > 0 {
+		depth = append(depth, "--depth="+strconv.Itoa(c.Depth))
+	}
+	fetch := func(ref string, depth ...string) error {
+		args := append([]string{"fetch", "--no-tags"}, depth...)
+		return git(append(args, "origin", ref)...)
+	}
+
+	ref := "HEAD"
+	switch {
+	case c.Tag != "":
+		ref = "+refs/tags/" + c.Tag + ":refs/tags/" + c.Tag
+	case c.Branch != "":
+		ref = "+refs/heads/" + c.Branch + ":refs/remotes/origin/" + c.Branch
+	}
+	target := "FETCH_HEAD"
+	if c.Commit != "" {
+		target = c.Commit
+		// 远程仓库不一定允许按SHA获取（缩写的SHA也无法获取），失败时获取分支或标签的完整历史
+		if len(c.Commit) < 40 || fetch(c.Commit, depth...) != nil {
+			if err := fetch(ref); err != nil {
+				return "", err
+			}
+		}
+	} else if err := fetch(ref, depth...); err != nil {
+		return "", err
+	}
+
+	args := []string{"-c", "advice.detachedHead=false", "checkout", "-q", "--force"}
+	if c.Tag == "" && c.Branch != "" {
+		args = append(args, "-B", c.Branch)
+	}
+	if err := git(append(args, target)...); err != nil {
+		return "", err
+	}
+
+	if c.Submodules {
+		args := append([]string{"submodule", "update", "--init", "--recursive"}, depth...)
+		if err := git(args...); err != nil {
+			return "", err
+		}
+	}
+
+	var sha bytes.Buffer
+	if err := w.git(ctx, io.Discard, &sha, "rev-parse", "HEAD"); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sha.String()), nil
+}
+
+// git 在工作目录中执行 git 命令。执行的命令写入 out，仓库地址中的凭据被隐去；
+// stdout 不为 nil 时标准输出写入 stdout 而不是 out
+func (w *localWorkspace) git(ctx context.Context, out io.Writer, stdout io.Writer, args ...string) error {
+	fmt.Fprintf(out, "$ git %s\n", strings.Join(redactArgs(args), " "))
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = w.Dir()
+	// 禁止交互式地询问凭据，否则私有仓库会使检出一直挂起
+	cmd.Env = append(w.env(), "GIT_TERMINAL_PROMPT=0", gitAllowProtocol)
+	cmd.Stdout = out
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = out
+	release := configureProcess(cmd, w.executor.grace)
+	defer release()
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("git %s exited with code %d", args[0], exitErr.ExitCode())
+		}
+		return fmt.Errorf("failed to run git %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// validRefName 按 git check-ref-format 的规则判断分支或标签名是否有效，并且不以 - 开头，
+// 避免被当作 git 的选项或改变 fetch 的 refspec
+func validRefName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") ||
+		strings.HasSuffix(name, ".") || strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return false
+		}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRepoURL 检查仓库地址使用允许的协议，主机名不以 - 开头
+func validateRepoURL(repoURL string) error {
+	u, err := url.Parse(repoURL)
+	if err != nil || !repoSchemes[u.Scheme] || u.Host == "" || strings.HasPrefix(u.Host, "-") {
+		return fmt.Errorf("invalid repository url %q, only http(s), ssh and git urls are supported", redactArgs([]string{repoURL})[0])
+	}
+	return nil
+}
+
+// redactArgs 隐去参数中仓库地址的用户信息
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = arg
+		if u, err := url.Parse(arg); err == nil && u.User != nil {
+			u.User = url.User("xxxxx")
+			redacted[i] = u.String()
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,206 @@
+package executor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// 镜像拉取策略
+const (
+	PullAlways       = "always"
+	PullIfNotPresent = "if-not-present"
+	PullNever        = "never"
+)
+
+// containerWorkspace 工作空间在容器中的挂载路径
+const containerWorkspace = "/workspace"
+
+type dockerExecutor struct {
+	local  *localExecutor
+	client *dockerClient
+	cfg    config.DockerConfig
+}
+
+// NewDocker 创建在容器中执行步骤的执行器。工作空间与本地执行器相同，
+// 以绑定挂载的方式提供给每个步骤容器
+func NewDocker(cfg *config.Config) (Executor, error) {
+	switch cfg.Executor.Docker.PullPolicy {
+	case PullAlways, PullIfNotPresent, PullNever:
+	default:
+		return nil, fmt.Errorf("invalid docker pull policy %q", cfg.Executor.Docker.PullPolicy)
+	}
+
+	client, err := newDockerClient(cfg.Executor.Docker.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dockerExecutor{
+		local:  NewLocal(cfg).(*localExecutor),
+		client: client,
+		cfg:    cfg.Executor.Docker,
+	}, nil
+}
+
+// Prepare 创建工作空间
+func (e *dockerExecutor) Prepare(ctx context.Context, build *model.Build, job *model.BuildJob) (Workspace, error) {
+	ws, err := e.local.Prepare(ctx, build, job)
+	if err != nil {
+		return nil, err
+	}
+	return &dockerWorkspace{localWorkspace: ws.(*localWorkspace), executor: e, buildID: build.ID, jobID: job.ID}, nil
+}
+
+type dockerWorkspace struct {
+	*localWorkspace
+	executor *dockerExecutor
+	buildID  int
+	jobID    int
+}
+
+// Run 在容器中执行命令，命令结束或被取消后删除容器
+func (w *dockerWorkspace) Run(ctx context.Context, c *Command) (*Result, error) {
+	client := w.executor.client
+
+	image := c.Image
+	if image == "" {
+		image = w.executor.cfg.DefaultImage
+	}
+	if image == "" {
+		return nil, errors.New("no image specified for step")
+	}
+
+	out := c.Output
+	if w.executor.local.timestamps {
+		out = newTimestampWriter(out)
+	}
+
+	if err := w.ensureImage(ctx, image, out); err != nil {
+		return nil, err
+	}
+
+	id, err := client.CreateContainer(ctx, w.containerName(), w.containerConfig(image, c))
+	if err != nil {
+		return nil, err
+	}
+	// 容器结束后无论结果如何都删除，ctx 可能已被取消
+	defer func() {
+		if err := client.RemoveContainer(context.WithoutCancel(ctx), id); err != nil {
+			logger.Warn("Failed to remove container", zap.String("container", id), zap.Error(err))
+		}
+	}()
+
+	if err := client.StartContainer(ctx, id); err != nil {
+		return nil, err
+	}
+
+	logsDone := make(chan error, 1)
+	go func() {
+		logsDone <- client.StreamLogs(context.WithoutCancel(ctx), id, out)
+	}()
+
+	// 取消时让Docker先发送SIGTERM，宽限期后发送SIGKILL
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			grace := int(w.executor.local.grace.Seconds())
+			if err := client.StopContainer(context.WithoutCancel(ctx), id, grace); err != nil {
+				logger.Warn("Failed to stop container", zap.String("container", id), zap.Error(err))
+			}
+		case <-stopped:
+		}
+	}()
+
+	exitCode, err := client.WaitContainer(context.WithoutCancel(ctx), id)
+	close(stopped)
+	if err != nil {
+		return nil, err
+	}
+	if err := <-logsDone; err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("failed to read container output: %w", err)
+	}
+
+	if ctx.Err() != nil && exitCode != 0 {
+		// 与本地执行器一致，被终止的命令没有退出码
+		exitCode = -1
+	}
+	return &Result{ExitCode: exitCode}, nil
+}
+
+// ensureImage 按拉取策略准备镜像
+func (w *dockerWorkspace) ensureImage(ctx context.Context, image string, out io.Writer) error {
+	client := w.executor.client
+
+	policy := w.executor.cfg.PullPolicy
+	if policy != PullAlways {
+		exists, err := client.ImageExists(ctx, image)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		if policy == PullNever {
+			return fmt.Errorf("image %s not found and pull policy is %s", image, PullNever)
+		}
+	}
+
+	fmt.Fprintf(out, "拉取镜像 %s\n", image)
+	return client.PullImage(ctx, image)
+}
+
+// containerConfig 组装容器配置：挂载工作空间，应用资源限制
+func (w *dockerWorkspace) containerConfig(image string, c *Command) *containerConfig {
+	env := []string{
+		"HOME=" + containerWorkspace + "/home",
+		"TMPDIR=" + containerWorkspace + "/tmp",
+		"VORTEXIA_WORKSPACE=" + containerWorkspace + "/work",
+	}
+	env = append(env, c.Env...)
+
+	cfg := w.executor.cfg
+	return &containerConfig{
+		Image:      image,
+		Cmd:        []string{"/bin/sh", "-c", c.Script},
+		Env:        env,
+		WorkingDir: containerWorkspace + "/work",
+		Labels: map[string]string{
+			"vortexia.build_id": strconv.Itoa(w.buildID),
+			"vortexia.job_id":   strconv.Itoa(w.jobID),
+		},
+		HostConfig: hostConfig{
+			Binds:    []string{w.hostPath() + ":" + containerWorkspace},
+			NanoCpus: int64(cfg.CPUs * 1e9),
+			Memory:   int64(cfg.MemoryMB) * 1024 * 1024,
+		},
+	}
+}
+
+// hostPath 返回工作空间在Docker宿主机上的路径
+func (w *dockerWorkspace) hostPath() string {
+	hostRoot := w.executor.cfg.WorkspaceHostRoot
+	if hostRoot == "" {
+		return w.root
+	}
+	return filepath.Join(hostRoot, filepath.Base(w.root))
+}
+
+// containerName 生成步骤容器名称，同一作业的多次执行使用不同的名称
+func (w *dockerWorkspace) containerName() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("vortexia-build-%d-job-%d-%s", w.buildID, w.jobID, hex.EncodeToString(suffix))
+}
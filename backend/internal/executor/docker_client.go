@@ -0,0 +1,244 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// dockerAPIVersion 使用的Docker Engine API版本（Docker 20.10及以上）
+const dockerAPIVersion = "v1.41"
+
+// errImageNotFound 本地不存在镜像
+var errImageNotFound = errors.New("image not found")
+
+// dockerClient Docker Engine API的最小客户端，只实现执行步骤需要的接口。
+// 通过 HTTP 访问，地址可以是 unix 套接字、tcp 地址或 http 地址（便于对接模拟的API服务）
+type dockerClient struct {
+	http    *http.Client
+	baseURL string
+}
+
+// containerConfig 创建容器的请求体
+type containerConfig struct {
+	Image      string
+	Cmd        []string
+	Env        []string
+	WorkingDir string
+	Labels     map[string]string
+	Tty        bool
+	HostConfig hostConfig
+}
+
+type hostConfig struct {
+	Binds    []string
+	NanoCpus int64 `json:",omitempty"`
+	Memory   int64 `json:",omitempty"`
+}
+
+// newDockerClient 根据 DOCKER_HOST 形式的地址创建客户端
+func newDockerClient(host string) (*dockerClient, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	transport := &http.Transport{}
+	baseURL := ""
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		baseURL = "http://docker"
+	case "tcp":
+		baseURL = "http://" + u.Host
+	case "http", "https":
+		baseURL = strings.TrimSuffix(u.String(), "/")
+	default:
+		return nil, fmt.Errorf("unsupported docker host %q", host)
+	}
+
+	return &dockerClient{
+		http:    &http.Client{Transport: transport},
+		baseURL: baseURL + "/" + dockerAPIVersion,
+	}, nil
+}
+
+// do 发送请求，状态码不在 2xx 时返回Docker的错误信息
+func (c *dockerClient) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker request %s %s failed: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/images/") {
+			return nil, errImageNotFound
+		}
+		return nil, fmt.Errorf("docker %s %s: %s (status %d)", method, path, apiErr.Message, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// call 发送请求并将JSON响应解码到 out，out 为 nil 时丢弃响应
+func (c *dockerClient) call(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode docker response: %w", err)
+	}
+	return nil
+}
+
+// ImageExists 判断镜像是否已存在于本地
+func (c *dockerClient) ImageExists(ctx context.Context, image string) (bool, error) {
+	err := c.call(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil, nil)
+	if errors.Is(err, errImageNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// PullImage 拉取镜像，阻塞直到拉取结束
+func (c *dockerClient) PullImage(ctx context.Context, image string) error {
+	resp, err := c.do(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": {image}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 拉取进度以JSON流返回，失败信息出现在流中而不是状态码里
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &msg) == nil && msg.Error != "" {
+			return fmt.Errorf("failed to pull image %s: %s", image, msg.Error)
+		}
+	}
+	return scanner.Err()
+}
+
+// CreateContainer 创建容器，返回容器ID
+func (c *dockerClient) CreateContainer(ctx context.Context, name string, cfg *containerConfig) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := c.call(ctx, http.MethodPost, "/containers/create", url.Values{"name": {name}}, cfg, &created)
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// StartContainer 启动容器
+func (c *dockerClient) StartContainer(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil, nil)
+}
+
+// StreamLogs 持续读取容器输出直到容器结束，标准输出和标准错误按顺序写入 w
+func (c *dockerClient) StreamLogs(ctx context.Context, id string, w io.Writer) error {
+	query := url.Values{"follow": {"1"}, "stdout": {"1"}, "stderr": {"1"}}
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+id+"/logs", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return demuxLogs(resp.Body, w)
+}
+
+// WaitContainer 等待容器结束，返回退出码
+func (c *dockerClient) WaitContainer(ctx context.Context, id string) (int, error) {
+	var result struct {
+		StatusCode int
+		Error      *struct {
+			Message string
+		}
+	}
+	err := c.call(ctx, http.MethodPost, "/containers/"+id+"/wait", url.Values{"condition": {"not-running"}}, nil, &result)
+	if err != nil {
+		return 0, err
+	}
+	if result.Error != nil && result.Error.Message != "" {
+		return 0, fmt.Errorf("failed to wait container: %s", result.Error.Message)
+	}
+	return result.StatusCode, nil
+}
+
+// StopContainer 先发送SIGTERM，timeout 秒后仍未退出则发送SIGKILL
+func (c *dockerClient) StopContainer(ctx context.Context, id string, timeout int) error {
+	return c.call(ctx, http.MethodPost, "/containers/"+id+"/stop", url.Values{"t": {fmt.Sprint(timeout)}}, nil, nil)
+}
+
+// RemoveContainer 强制删除容器及其匿名卷
+func (c *dockerClient) RemoveContainer(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, "/containers/"+id, url.Values{"force": {"1"}, "v": {"1"}}, nil, nil)
+}
+
+// demuxLogs 解析未启用TTY时的多路复用日志流：每帧以8字节头开始，
+// 第1字节为流类型，后4字节为大端序的帧长度
+func demuxLogs(r io.Reader, w io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+const fakeContainerID = "c0ffee"
+
+// fakeDocker 模拟Docker Engine API，记录收到的请求
+type fakeDocker struct {
+	t *testing.T
+
+	images     map[string]bool // 本地已有的镜像
+	pullError  string          // 拉取进度流中返回的错误
+	createCode int             // 创建容器的状态码，0 表示成功
+	startCode  int             // 启动容器的状态码，0 表示成功
+	removeCode int             // 删除容器的状态码，0 表示成功
+	output     []string        // 容器的标准输出和标准错误，按顺序交替
+	exitCode   int
+	blockWait  bool // 等待容器时阻塞直到容器被停止
+
+	mu       sync.Mutex
+	requests []string // "METHOD /path?query"
+	created  *containerConfig
+	stopped  chan struct{}
+}
+
+func newFakeDocker(t *testing.T) *fakeDocker {
+	return &fakeDocker{t: t, images: map[string]bool{}, stopped: make(chan struct{})}
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+dockerAPIVersion)
+	f.mu.Lock()
+	req := r.Method + " " + path
+	if r.URL.RawQuery != "" {
+		req += "?" + r.URL.RawQuery
+	}
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		image := strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")
+		if !f.images[image] {
+			writeDockerError(w, http.StatusNotFound, "No such image: "+image)
+			return
+		}
+		fmt.Fprint(w, `{"Id":"sha256:1234"}`)
+
+	case r.Method == http.MethodPost && path == "/images/create":
+		fmt.Fprintln(w, `{"status":"Pulling from library/alpine"}`)
+		if f.pullError != "" {
+			fmt.Fprintf(w, "{\"error\":%q}\n", f.pullError)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"Download complete"}`)
+
+	case r.Method == http.MethodPost && path == "/containers/create":
+		if f.createCode != 0 {
+			writeDockerError(w, f.createCode, "conflict")
+			return
+		}
+		var cfg containerConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			f.t.Errorf("invalid create body: %v", err)
+		}
+		f.mu.Lock()
+		f.created = &cfg
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"Id":%q}`, fakeContainerID)
+
+	case r.Method == http.MethodPost && path == "/containers/"+fakeContainerID+"/start":
+		if f.startCode != 0 {
+			writeDockerError(w, f.startCode, "cannot start container")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && path == "/containers/"+fakeContainerID+"/logs":
+		for i, line := range f.output {
+			stream := byte(1 + i%2)
+			header := make([]byte, 8)
+			header[0] = stream
+			binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+			_, _ = w.Write(append(header, line...))
+		}
+
+	case r.Method == http.MethodPost && path == "/containers/"+fakeContainerID+"/wait":
+		if f.blockWait {
+			<-f.stopped
+		}
+		fmt.Fprintf(w, `{"StatusCode":%d}`, f.exitCode)
+
+	case r.Method == http.MethodPost && path == "/containers/"+fakeContainerID+"/stop":
+		close(f.stopped)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodDelete && path == "/containers/"+fakeContainerID:
+		if f.removeCode != 0 {
+			writeDockerError(w, f.removeCode, "removal in progress")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		f.t.Errorf("unexpected docker request %s", req)
+		http.NotFound(w, r)
+	}
+}
+
+func writeDockerError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"message":%q}`, msg)
+}
+
+// called 返回方法和路径与 prefix 匹配的请求
+func (f *fakeDocker) called(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matched []string
+	for _, req := range f.requests {
+		if strings.HasPrefix(req, prefix) {
+			matched = append(matched, req)
+		}
+	}
+	return matched
+}
+
+// newDockerWorkspace 创建使用模拟API的Docker执行器并准备构建7作业3的工作空间
+func newDockerWorkspace(t *testing.T, f *fakeDocker, docker config.DockerConfig) Workspace {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	docker.Host = srv.URL
+	if docker.PullPolicy == "" {
+		docker.PullPolicy = PullIfNotPresent
+	}
+	cfg := &config.Config{
+		Executor: config.ExecutorConfig{
+			Type:          TypeDocker,
+			WorkspaceRoot: t.TempDir(),
+			Docker:        docker,
+		},
+		Worker: config.WorkerConfig{CancelGracePeriod: 5},
+	}
+	e, err := NewDocker(cfg)
+	if err != nil {
+		t.Fatalf("NewDocker() error = %v", err)
+	}
+	ws, err := e.Prepare(context.Background(), &model.Build{ID: 7}, &model.BuildJob{ID: 3})
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+func TestNewDockerRejectsUnknownPullPolicy(t *testing.T) {
+	cfg := &config.Config{Executor: config.ExecutorConfig{Docker: config.DockerConfig{
+		Host:       "unix:///var/run/docker.sock",
+		PullPolicy: "sometimes",
+	}}}
+	if _, err := NewDocker(cfg); err == nil || !strings.Contains(err.Error(), `invalid docker pull policy "sometimes"`) {
+		t.Errorf("NewDocker() error = %v", err)
+	}
+}
+
+func TestDockerRun(t *testing.T) {
+	f := newFakeDocker(t)
+	f.images["golang:1.22"] = true
+	f.output = []string{"building\n", "warning: deprecated\n", "done\n"}
+	f.exitCode = 3
+
+	hostRoot := "/srv/vortexia/workspaces"
+	ws := newDockerWorkspace(t, f, config.DockerConfig{
+		CPUs:              1.5,
+		MemoryMB:          512,
+		WorkspaceHostRoot: hostRoot,
+	})
+
+	var out bytes.Buffer
+	result, err := ws.Run(context.Background(), &Command{
+		Script: "make build",
+		Image:  "golang:1.22",
+		Env:    []string{"CI=true"},
+		Output: &out,
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3", result.ExitCode)
+	}
+	if got := out.String(); got != "building\nwarning: deprecated\ndone\n" {
+		t.Errorf("output = %q", got)
+	}
+
+	if pulls := f.called("POST /images/create"); len(pulls) != 0 {
+		t.Errorf("image present locally but pulled: %v", pulls)
+	}
+
+	c := f.created
+	if c == nil {
+		t.Fatal("no container created")
+	}
+	if c.Image != "golang:1.22" || !reflect.DeepEqual(c.Cmd, []string{"/bin/sh", "-c", "make build"}) {
+		t.Errorf("container image/cmd = %q %v", c.Image, c.Cmd)
+	}
+	if c.WorkingDir != "/workspace/work" {
+		t.Errorf("working dir = %q", c.WorkingDir)
+	}
+	if c.Env[len(c.Env)-1] != "CI=true" {
+		t.Errorf("step env not passed last: %v", c.Env)
+	}
+	if c.Labels["vortexia.build_id"] != "7" || c.Labels["vortexia.job_id"] != "3" {
+		t.Errorf("labels = %v", c.Labels)
+	}
+
+	wantBind := filepath.Join(hostRoot, "build-7-job-3") + ":/workspace"
+	if !reflect.DeepEqual(c.HostConfig.Binds, []string{wantBind}) {
+		t.Errorf("binds = %v, want [%s]", c.HostConfig.Binds, wantBind)
+	}
+	if c.HostConfig.NanoCpus != 1_500_000_000 {
+		t.Errorf("NanoCpus = %d, want 1500000000", c.HostConfig.NanoCpus)
+	}
+	if c.HostConfig.Memory != 512*1024*1024 {
+		t.Errorf("Memory = %d, want %d", c.HostConfig.Memory, 512*1024*1024)
+	}
+
+	if names := f.called("POST /containers/create?name=vortexia-build-7-job-3-"); len(names) != 1 {
+		t.Errorf("container name not derived from build and job: %v", f.called("POST /containers/create"))
+	}
+	removes := f.called("DELETE /containers/")
+	if !reflect.DeepEqual(removes, []string{"DELETE /containers/" + fakeContainerID + "?force=1&v=1"}) {
+		t.Errorf("remove requests = %v", removes)
+	}
+}
+
+func TestDockerRunWithoutLimits(t *testing.T) {
+	f := newFakeDocker(t)
+	f.images["alpine:3"] = true
+	ws := newDockerWorkspace(t, f, config.DockerConfig{DefaultImage: "alpine:3"})
+
+	if _, err := ws.Run(context.Background(), &Command{Script: "true", Output: io.Discard}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	c := f.created
+	if c.Image != "alpine:3" {
+		t.Errorf("image = %q, want the default image", c.Image)
+	}
+	if c.HostConfig.NanoCpus != 0 || c.HostConfig.Memory != 0 {
+		t.Errorf("limits = %d CPUs, %d memory, want none", c.HostConfig.NanoCpus, c.HostConfig.Memory)
+	}
+	want := ws.(*dockerWorkspace).root + ":/workspace"
+	if !reflect.DeepEqual(c.HostConfig.Binds, []string{want}) {
+		t.Errorf("binds = %v, want [%s]", c.HostConfig.Binds, want)
+	}
+}
+
+func TestDockerPullPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		present   bool
+		wantPull  bool
+		wantCheck bool
+		wantErr   string
+	}{
+		{name: "always pulls present image", policy: PullAlways, present: true, wantPull: true},
+		{name: "if-not-present skips present image", policy: PullIfNotPresent, present: true, wantCheck: true},
+		{name: "if-not-present pulls missing image", policy: PullIfNotPresent, wantCheck: true, wantPull: true},
+		{name: "never uses present image", policy: PullNever, present: true, wantCheck: true},
+		{
+			name:      "never fails on missing image",
+			policy:    PullNever,
+			wantCheck: true,
+			wantErr:   "image golang:1.22 not found and pull policy is never",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDocker(t)
+			f.images["golang:1.22"] = tt.present
+			ws := newDockerWorkspace(t, f, config.DockerConfig{PullPolicy: tt.policy})
+
+			var out bytes.Buffer
+			_, err := ws.Run(context.Background(), &Command{Script: "go test", Image: "golang:1.22", Output: &out})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				if f.created != nil {
+					t.Error("container created although the image is missing")
+				}
+			} else if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			pulls := f.called("POST /images/create")
+			if tt.wantPull {
+				if !reflect.DeepEqual(pulls, []string{"POST /images/create?fromImage=golang%3A1.22"}) {
+					t.Errorf("pull requests = %v", pulls)
+				}
+				if !strings.Contains(out.String(), "拉取镜像 golang:1.22") {
+					t.Errorf("output = %q, want pull notice", out.String())
+				}
+			} else if len(pulls) != 0 {
+				t.Errorf("unexpected pull requests %v", pulls)
+			}
+			if checked := len(f.called("GET /images/golang:1.22/json")) > 0; checked != tt.wantCheck {
+				t.Errorf("image inspected = %v, want %v", checked, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func TestDockerRunFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(f *fakeDocker)
+		wantErr    string
+		wantRemove bool
+	}{
+		{
+			name:    "pull error in progress stream",
+			setup:   func(f *fakeDocker) { f.pullError = "manifest unknown" },
+			wantErr: "failed to pull image golang:1.22: manifest unknown",
+		},
+		{
+			name: "create fails",
+			setup: func(f *fakeDocker) {
+				f.images["golang:1.22"] = true
+				f.createCode = http.StatusConflict
+			},
+			wantErr: "docker POST /containers/create: conflict (status 409)",
+		},
+		{
+			name: "start fails",
+			setup: func(f *fakeDocker) {
+				f.images["golang:1.22"] = true
+				f.startCode = http.StatusInternalServerError
+			},
+			wantErr:    "docker POST /containers/" + fakeContainerID + "/start: cannot start container (status 500)",
+			wantRemove: true,
+		},
+		{
+			name: "remove fails",
+			setup: func(f *fakeDocker) {
+				f.images["golang:1.22"] = true
+				f.removeCode = http.StatusConflict
+			},
+			wantRemove: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeDocker(t)
+			tt.setup(f)
+			ws := newDockerWorkspace(t, f, config.DockerConfig{})
+
+			result, err := ws.Run(context.Background(), &Command{Script: "make", Image: "golang:1.22", Output: io.Discard})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || result.ExitCode != 0 {
+				// 删除容器失败只记录日志，不影响步骤结果
+				t.Fatalf("Run() = %+v, %v, want success", result, err)
+			}
+
+			removed := len(f.called("DELETE /containers/"+fakeContainerID)) == 1
+			if removed != tt.wantRemove {
+				t.Errorf("container removed = %v, want %v", removed, tt.wantRemove)
+			}
+		})
+	}
+}
+
+func TestDockerRunCanceled(t *testing.T) {
+	f := newFakeDocker(t)
+	f.images["alpine:3"] = true
+	f.blockWait = true
+	f.exitCode = 143
+	ws := newDockerWorkspace(t, f, config.DockerConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	result, err := ws.Run(ctx, &Command{Script: "sleep 60", Image: "alpine:3", Output: io.Discard})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.ExitCode != -1 {
+		t.Errorf("exit code = %d, want -1 for a canceled step", result.ExitCode)
+	}
+	if stops := f.called("POST /containers/" + fakeContainerID + "/stop"); !reflect.DeepEqual(stops, []string{"POST /containers/" + fakeContainerID + "/stop?t=5"}) {
+		t.Errorf("stop requests = %v", stops)
+	}
+	if len(f.called("DELETE /containers/"+fakeContainerID)) != 1 {
+		t.Error("canceled container not removed")
+	}
+}
+
+func TestNewDockerClientHosts(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{host: "unix:///var/run/docker.sock", want: "http://docker/" + dockerAPIVersion},
+		{host: "tcp://10.0.0.2:2375", want: "http://10.0.0.2:2375/" + dockerAPIVersion},
+		{host: "http://127.0.0.1:8080/", want: "http://127.0.0.1:8080/" + dockerAPIVersion},
+		{host: "ssh://docker@host", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		c, err := newDockerClient(tt.host)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("newDockerClient(%q) succeeded, want error", tt.host)
+			}
+			continue
+		}
+		if err != nil || c.baseURL != tt.want {
+			t.Errorf("newDockerClient(%q) = %v, %v, want base URL %q", tt.host, c, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+)
+
+// 执行器类型
+const (
+	TypeLocal  = "local"
+	TypeDocker = "docker"
+)
+
+// Executor 构建执行器，为构建作业准备工作空间并在其中执行步骤命令
+type Executor interface {
+	// Prepare 为构建作业创建全新的工作空间，作业结束后必须调用 Workspace.Close
+	Prepare(ctx context.Context, build *model.Build, job *model.BuildJob) (Workspace, error)
+}
+
+// Workspace 单个构建作业的工作空间，作业的所有步骤共享同一个工作空间
+type Workspace interface {
+	// Dir 返回步骤命令的工作目录
+	Dir() string
+	// Run 执行命令直到结束，命令以非零状态退出不视为错误。
+	// ctx 结束时命令及其启动的子进程被终止
+	Run(ctx context.Context, cmd *Command) (*Result, error)
+	// Checkout 将代码仓库检出到工作目录，返回检出的提交SHA
+	Checkout(ctx context.Context, c *Checkout) (string, error)
+	// Close 按保留策略清理工作空间
+	Close() error
+}
+
+// Command 在工作空间中执行的命令
+type Command struct {
+	Script string    // 由 /bin/sh -c 执行的脚本
+	Image  string    // 执行命令的容器镜像，只有容器执行器支持
+	Env    []string  // KEY=VALUE 形式的环境变量，后出现的覆盖先出现的
+	Output io.Writer // 标准输出和标准错误按产生的顺序交错写入
+}
+
+// Result 命令执行结果
+type Result struct {
+	ExitCode int // 命令被信号终止时为-1
+}
+
+// New 根据配置创建执行器。配置有误时返回的执行器在准备工作空间时报错，
+// 使构建失败而不是退回到其他执行器
+func New(cfg *config.Config) Executor {
+	switch cfg.Executor.Type {
+	case TypeLocal, "":
+		return NewLocal(cfg)
+	case TypeDocker:
+		e, err := NewDocker(cfg)
+		if err != nil {
+			return brokenExecutor{err: err}
+		}
+		return e
+	default:
+		return brokenExecutor{err: fmt.Errorf("unknown executor type %q", cfg.Executor.Type)}
+	}
+}
+
+type brokenExecutor struct {
+	err error
+}
+
+// Prepare 返回创建执行器时的配置错误
+func (e brokenExecutor) Prepare(ctx context.Context, build *model.Build, job *model.BuildJob) (Workspace, error) {
+	return nil, e.err
+}
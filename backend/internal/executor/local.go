@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	workspacePrefix = "build-"    // 工作空间目录名前缀
+	finishedMarker  = ".finished" // 构建结束后写入的标记文件，只有带标记的工作空间会被清理
+	defaultPath     = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// 从宿主环境继承的变量，其余变量（如数据库密码、JWT密钥）不会传给步骤命令
+var inheritedEnv = []string{"PATH", "LANG", "LC_ALL", "TZ"}
+
+type localExecutor struct {
+	root       string
+	retention  time.Duration
+	grace      time.Duration
+	timestamps bool
+}
+
+// NewLocal 创建在本机进程中执行命令的执行器
+func NewLocal(cfg *config.Config) Executor {
+	return &localExecutor{
+		root:       cfg.Executor.WorkspaceRoot,
+		retention:  time.Duration(cfg.Executor.WorkspaceRetention) * time.Hour,
+		grace:      time.Duration(cfg.Worker.CancelGracePeriod) * time.Second,
+		timestamps: cfg.Executor.Timestamps,
+	}
+}
+
+// Prepare 创建 build-<id>-job-<id> 工作空间，包含 work（工作目录）、home 和 tmp 三个子目录
+func (e *localExecutor) Prepare(ctx context.Context, build *model.Build, job *model.BuildJob) (Workspace, error) {
+	if err := os.MkdirAll(e.root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace root: %w", err)
+	}
+	e.prune()
+
+	dir := filepath.Join(e.root, fmt.Sprintf("%s%d-job-%d", workspacePrefix, build.ID, job.ID))
+	// 被中断的上一次执行可能留下了文件
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to clean workspace: %w", err)
+	}
+
+	ws := &localWorkspace{executor: e, root: dir}
+	for _, sub := range []string{ws.Dir(), ws.home(), ws.tmp()} {
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create workspace: %w", err)
+		}
+	}
+	return ws, nil
+}
+
+// prune 删除超过保留时长的已结束工作空间
+func (e *localExecutor) prune() {
+	if e.retention <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(e.root)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), workspacePrefix) {
+			continue
+		}
+		dir := filepath.Join(e.root, entry.Name())
+		info, err := os.Stat(filepath.Join(dir, finishedMarker))
+		if err != nil || time.Since(info.ModTime()) < e.retention {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Warn("Failed to prune workspace", zap.String("dir", dir), zap.Error(err))
+		}
+	}
+}
+
+type localWorkspace struct {
+	executor *localExecutor
+	root     string
+}
+
+// Dir 返回工作目录
+func (w *localWorkspace) Dir() string { return filepath.Join(w.root, "work") }
+
+func (w *localWorkspace) home() string { return filepath.Join(w.root, "home") }
+func (w *localWorkspace) tmp() string  { return filepath.Join(w.root, "tmp") }
+
+// Run 在独立的进程组中执行命令，取消时整个进程组被终止，命令结束后留在后台的进程也被终止
+func (w *localWorkspace) Run(ctx context.Context, c *Command) (*Result, error) {
+	if c.Image != "" {
+		return nil, fmt.Errorf("local executor cannot run image %s, set EXECUTOR_TYPE=docker", c.Image)
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", c.Script)
+	cmd.Dir = w.Dir()
+	cmd.Env = append(w.env(), c.Env...)
+
+	// 标准输出和标准错误使用同一个 Writer 时共用一个管道，输出顺序与产生顺序一致
+	out := c.Output
+	if w.executor.timestamps {
+		out = newTimestampWriter(out)
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	release := configureProcess(cmd, w.executor.grace)
+
+	err := cmd.Run()
+	release()
+	if err != nil && cmd.ProcessState == nil {
+		return nil, err
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+		return nil, err
+	}
+	return &Result{ExitCode: cmd.ProcessState.ExitCode()}, nil
+}
+
+// env 返回命令的基础环境变量
+func (w *localWorkspace) env() []string {
+	env := []string{
+		"HOME=" + w.home(),
+		"TMPDIR=" + w.tmp(),
+		"SHELL=/bin/sh",
+		"VORTEXIA_WORKSPACE=" + w.Dir(),
+	}
+	for _, name := range inheritedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		} else if name == "PATH" {
+			env = append(env, "PATH="+defaultPath)
+		}
+	}
+	return env
+}
+
+// Close 未配置保留时长时立即删除工作空间，否则写入结束标记，超过保留时长后被清理
+func (w *localWorkspace) Close() error {
+	if w.executor.retention <= 0 {
+		if err := os.RemoveAll(w.root); err != nil {
+			return fmt.Errorf("failed to remove workspace: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(filepath.Join(w.root, finishedMarker), nil, 0o644); err != nil {
+		return fmt.Errorf("failed to mark workspace finished: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+//go:build unix
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"Vortexia/internal/model"
+)
+
+// newLocalWorkspace 在临时目录中为构建作业准备工作空间
+func newLocalWorkspace(t *testing.T) Workspace {
+	t.Helper()
+	e := &localExecutor{root: t.TempDir(), grace: 200 * time.Millisecond}
+	ws, err := e.Prepare(context.Background(), &model.Build{ID: 1}, &model.BuildJob{ID: 2})
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// readPID 读取脚本写入工作目录的进程ID
+func readPID(t *testing.T, ws Workspace) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(ws.Dir(), "pid"))
+	if err != nil {
+		t.Fatalf("read pid: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("parse pid %q: %v", data, err)
+	}
+	return pid
+}
+
+// waitExited 等待进程退出，已退出但未被回收的僵尸进程视为已退出
+func waitExited(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if errors.Is(syscall.Kill(pid, 0), syscall.ESRCH) {
+			return
+		}
+		if stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat"); err == nil {
+			if i := bytes.LastIndexByte(stat, ')'); i >= 0 && bytes.HasPrefix(stat[i+1:], []byte(" Z")) {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+	t.Errorf("process %d is still running", pid)
+}
+
+func TestLocalRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		wantCode   int
+		wantOutput string
+	}{
+		{name: "success", script: "echo hello", wantCode: 0, wantOutput: "hello\n"},
+		{name: "exit code", script: "echo failing >&2; exit 3", wantCode: 3, wantOutput: "failing\n"},
+		{name: "killed by signal", script: "kill -9 $$", wantCode: -1},
+		{name: "environment", script: `echo "$FOO" && test "$PWD" = "$VORTEXIA_WORKSPACE"`, wantCode: 0, wantOutput: "bar\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newLocalWorkspace(t)
+			var out bytes.Buffer
+			result, err := ws.Run(context.Background(), &Command{Script: tt.script, Env: []string{"FOO=bar"}, Output: &out})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if result.ExitCode != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", result.ExitCode, tt.wantCode)
+			}
+			if out.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestLocalRunRejectsImage(t *testing.T) {
+	ws := newLocalWorkspace(t)
+	if _, err := ws.Run(context.Background(), &Command{Script: "true", Image: "alpine", Output: &bytes.Buffer{}}); err == nil {
+		t.Fatal("Run() error = nil, want the local executor to reject images")
+	}
+}
+
+func TestLocalRunCanceled(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "command", script: "echo $$ > pid; sleep 30"},
+		// 忽略SIGTERM的命令在 grace 后被SIGKILL终止
+		{name: "ignores SIGTERM", script: "trap '' TERM; echo $$ > pid; sleep 30"},
+		// 命令启动的子进程同样被终止
+		{name: "child process", script: "sleep 30 & echo $! > pid; wait"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newLocalWorkspace(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			result, err := ws.Run(ctx, &Command{Script: tt.script, Output: &bytes.Buffer{}})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Run() returned after %v, want the command to be stopped", elapsed)
+			}
+			if result.ExitCode == 0 {
+				t.Errorf("exit code = 0, want the canceled command to fail")
+			}
+			waitExited(t, readPID(t, ws))
+		})
+	}
+}
+
+func TestLocalRunKillsBackgroundProcesses(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "detached output", script: "sleep 30 >/dev/null 2>&1 & echo $! > pid"},
+		// 持有输出管道的后台进程不会使 Run 一直等待
+		{name: "holding output", script: "sleep 30 & echo $! > pid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newLocalWorkspace(t)
+			result, err := ws.Run(context.Background(), &Command{Script: tt.script, Output: &bytes.Buffer{}})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if result.ExitCode != 0 {
+				t.Errorf("exit code = %d, want 0", result.ExitCode)
+			}
+			waitExited(t, readPID(t, ws))
+		})
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package executor
+
+import (
+	"os/exec"
+	"time"
+)
+
+// configureProcess 在不支持进程组的平台上，取消时直接终止命令进程
+func configureProcess(cmd *exec.Cmd, grace time.Duration) func() {
+	cmd.WaitDelay = grace
+	return func() {}
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package executor
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// configureProcess 让步骤命令运行在独立的进程组中。
+// 步骤被取消时先向整个进程组发送SIGTERM，grace 后仍未退出则发送SIGKILL，
+// 这样命令启动的子进程也会被终止。返回的函数在命令结束后调用，停止计时器并向进程组发送SIGKILL，
+// 终止命令退出后仍在运行的后台进程。
+func configureProcess(cmd *exec.Cmd, grace time.Duration) func() {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	var timer *time.Timer
+	cmd.Cancel = func() error {
+		pgid := -cmd.Process.Pid
+		timer = time.AfterFunc(grace, func() {
+			_ = syscall.Kill(pgid, syscall.SIGKILL)
+		})
+		return syscall.Kill(pgid, syscall.SIGTERM)
+	}
+	// 子进程可能继承并持有输出管道，SIGKILL之后不再等待管道关闭
+	cmd.WaitDelay = grace + time.Second
+
+	return func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		if cmd.Process != nil {
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+}
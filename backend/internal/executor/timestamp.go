@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"io"
+	"time"
+)
+
+// timestampFormat 输出行前缀的时间格式
+const timestampFormat = "2006-01-02T15:04:05.000Z07:00 "
+
+// timestampWriter 在每一行的开头写入该行第一个字节到达的时间
+type timestampWriter struct {
+	w         io.Writer
+	lineStart bool
+}
+
+func newTimestampWriter(w io.Writer) *timestampWriter {
+	return &timestampWriter{w: w, lineStart: true}
+}
+
+// Write 实现 io.Writer
+func (t *timestampWriter) Write(p []byte) (int, error) {
+	var buf, prefix []byte
+	for _, b := range p {
+		if t.lineStart {
+			// 同一次写入中的行使用同一个时间
+			if prefix == nil {
+				prefix = time.Now().UTC().AppendFormat(nil, timestampFormat)
+			}
+			buf = append(buf, prefix...)
+			t.lineStart = false
+		}
+		buf = append(buf, b)
+		if b == '\n' {
+			t.lineStart = true
+		}
+	}
+
+	if _, err := t.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -10,11 +10,36 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// JWTAuth JWT认证中间件
+// JWTAuth JWT认证中间件，令牌只能通过 Authorization 请求头传递
 func JWTAuth(authService service.AuthService) gin.HandlerFunc {
+	return jwtAuth(authService, false)
+}
+
+// StreamTicketAuth 允许通过 ticket 查询参数传递流式请求票据的认证中间件。
+// 浏览器的WebSocket/EventSource和下载链接无法设置请求头，只用于实时日志和制品下载路由。
+// 票据只能使用一次且很快失效，JWT 不应出现在URL中，避免被访问日志和Referer记录
+func StreamTicketAuth(authService service.AuthService) gin.HandlerFunc {
+	return jwtAuth(authService, true)
+}
+
+func jwtAuth(authService service.AuthService, allowTicket bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从请求头获取token
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && allowTicket && c.Query("ticket") != "" {
+			user, err := authService.RedeemStreamTicket(c.Query("ticket"))
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, model.APIResponse{
+					Code:    http.StatusUnauthorized,
+					Message: "认证票据无效: " + err.Error(),
+				})
+				c.Abort()
+				return
+			}
+			setCurrentUser(c, user)
+			c.Next()
+			return
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, model.APIResponse{
 				Code:    http.StatusUnauthorized,
@@ -46,15 +71,18 @@ func JWTAuth(authService service.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		// 将用户信息存储到上下文
-		c.Set("user", user)
-		c.Set("user_id", user.ID)
-		c.Set("user_role", user.Role)
-
+		setCurrentUser(c, user)
 		c.Next()
 	}
 }
 
+// setCurrentUser 将用户信息存储到上下文
+func setCurrentUser(c *gin.Context, user *model.User) {
+	c.Set("user", user)
+	c.Set("user_id", user.ID)
+	c.Set("user_role", user.Role)
+}
+
 // AdminRequired 管理员权限中间件
 func AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -82,6 +110,34 @@ func AdminRequired() gin.HandlerFunc {
 	}
 }
 
+// RunnerAuth 执行器认证中间件，执行器使用注册时获得的令牌访问执行器API
+func RunnerAuth(runnerService service.RunnerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+			c.JSON(http.StatusUnauthorized, model.APIResponse{
+				Code:    http.StatusUnauthorized,
+				Message: "未提供执行器令牌",
+			})
+			c.Abort()
+			return
+		}
+
+		runner, err := runnerService.Authenticate(parts[1])
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, model.APIResponse{
+				Code:    http.StatusUnauthorized,
+				Message: "执行器令牌无效: " + err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("runner", runner)
+		c.Next()
+	}
+}
+
 // GetCurrentUser 从上下文获取当前用户
 func GetCurrentUser(c *gin.Context) (*model.User, bool) {
 	user, exists := c.Get("user")
@@ -103,3 +159,14 @@ func GetCurrentUserID(c *gin.Context) (int, bool) {
 	id, ok := userID.(int)
 	return id, ok
 }
+
+// GetCurrentRunner 从上下文获取当前执行器
+func GetCurrentRunner(c *gin.Context) (*model.Runner, bool) {
+	runner, exists := c.Get("runner")
+	if !exists {
+		return nil, false
+	}
+
+	r, ok := runner.(*model.Runner)
+	return r, ok
+}
@@ -2,18 +2,23 @@ package middleware
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// sensitiveQueryParams 记录日志前需要隐藏值的查询参数
+var sensitiveQueryParams = []string{"token", "ticket"}
+
 // Logger 日志中间件
 func Logger(logger *zap.Logger) gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		logger.Info("HTTP Request",
 			zap.String("method", param.Method),
-			zap.String("path", param.Path),
+			zap.String("path", redactPath(param.Path)),
 			zap.Int("status", param.StatusCode),
 			zap.Duration("latency", param.Latency),
 			zap.String("client_ip", param.ClientIP),
@@ -23,6 +28,30 @@ func Logger(logger *zap.Logger) gin.HandlerFunc {
 	})
 }
 
+// redactPath 隐藏请求路径中敏感查询参数的值，无法解析的查询字符串整体丢弃
+func redactPath(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base
+	}
+
+	redacted := false
+	for _, name := range sensitiveQueryParams {
+		if _, ok := query[name]; ok {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return base + "?" + query.Encode()
+}
+
 // Recovery 错误恢复中间件
 func Recovery(logger *zap.Logger) gin.HandlerFunc {
 	return gin.RecoveryWithWriter(gin.DefaultErrorWriter, func(c *gin.Context, err interface{}) {
@@ -0,0 +1,26 @@
+package middleware
+
+import "testing"
+
+func TestRedactPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "no query", path: "/api/v1/builds/1", want: "/api/v1/builds/1"},
+		{name: "other params", path: "/api/v1/builds?page=2&size=10", want: "/api/v1/builds?page=2&size=10"},
+		{name: "token", path: "/api/v1/ws/builds/1/logs?token=eyJhbGciOi.x.y", want: "/api/v1/ws/builds/1/logs?token=REDACTED"},
+		{name: "ticket with other params", path: "/api/v1/builds/1/logs/stream?since=5&ticket=abc", want: "/api/v1/builds/1/logs/stream?since=5&ticket=REDACTED"},
+		{name: "repeated token", path: "/x?token=a&token=b", want: "/x?token=REDACTED"},
+		{name: "invalid query", path: "/x?token=%zz", want: "/x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactPath(tt.path); got != tt.want {
+				t.Errorf("redactPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -51,23 +51,203 @@ type Build struct {
 	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
 	Duration   *int       `json:"duration,omitempty" db:"duration"` // 秒
 	TriggerBy  int        `json:"trigger_by" db:"trigger_by"`
+	CanceledBy *int       `json:"canceled_by,omitempty" db:"canceled_by"`
+	CanceledAt *time.Time `json:"canceled_at,omitempty" db:"canceled_at"`
+	Config     string     `json:"-" db:"config"` // 创建构建时的流水线配置快照
+	RerunOf    *int       `json:"rerun_of,omitempty" db:"rerun_of"`
+	RerunMode  string     `json:"rerun_mode,omitempty" db:"rerun_mode"`
+	RunnerID   *int       `json:"runner_id,omitempty" db:"runner_id"` // 执行构建的远程执行器，在服务端执行时为空
+	RunsOn     string     `json:"runs_on,omitempty" db:"runs_on"`     // 执行器需满足的标签表达式
+	Parameters []string   `json:"parameters" db:"parameters"`         // 触发构建时指定的参数，如 environment=staging
+	Event      string     `json:"event,omitempty" db:"event"`         // 触发构建的仓库事件：push 或 tag，通过API触发时为空
+	Tag        string     `json:"tag,omitempty" db:"tag"`             // 推送标签触发的构建检出的标签
 	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+
+	PendingReason string `json:"pending_reason,omitempty" db:"-"` // 构建等待执行的原因，如等待匹配 runs_on 的执行器
+}
+
+// BuildJob 构建作业模型，作业在独立的工作空间中依次执行步骤，作业之间按依赖关系并行执行
+type BuildJob struct {
+	ID         int        `json:"id" db:"id"`
+	BuildID    int        `json:"build_id" db:"build_id"`
+	Name       string     `json:"name" db:"name"`
+	Stage      string     `json:"stage" db:"stage"`
+	Status     string     `json:"status" db:"status"`
+	Needs      []string   `json:"needs" db:"needs"`                   // 依赖的作业，全部成功后才会执行
+	MatrixOf   string     `json:"matrix_of,omitempty" db:"matrix_of"` // 按矩阵展开的作业展开前的名称
+	Matrix     []string   `json:"matrix,omitempty" db:"matrix"`       // 矩阵变量的取值，如 go=1.21
+	StartedAt  *time.Time `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+	Duration   *int       `json:"duration,omitempty" db:"duration"`
+	JobOrder   int        `json:"job_order" db:"job_order"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+
+	Steps []*BuildStep `json:"steps,omitempty" db:"-"` // 作业的步骤，代码检出步骤排在最前
 }
 
 // BuildStep 构建步骤模型
 type BuildStep struct {
 	ID         int        `json:"id" db:"id"`
 	BuildID    int        `json:"build_id" db:"build_id"`
+	JobID      *int       `json:"job_id,omitempty" db:"job_id"` // 所属作业，早期的构建没有作业
 	Name       string     `json:"name" db:"name"`
 	Command    string     `json:"command" db:"command"`
 	Status     string     `json:"status" db:"status"`
-	Output     string     `json:"output" db:"output"`
+	ExitCode   *int       `json:"exit_code,omitempty" db:"exit_code"` // 最后一次执行的退出码
+	Output     string     `json:"output,omitempty" db:"output"`       // 旧版本写入的完整输出，新日志见 BuildLogStore
 	StartedAt  time.Time  `json:"started_at" db:"started_at"`
 	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
 	Duration   *int       `json:"duration,omitempty" db:"duration"`
 	StepOrder  int        `json:"step_order" db:"step_order"`
+	ReusedFrom *int       `json:"reused_from,omitempty" db:"reused_from"` // 复用了原构建中该步骤的结果
+
+	Attempts []*BuildStepAttempt `json:"attempts,omitempty" db:"-"`
+}
+
+// BuildStepAttempt 步骤的一次执行，输出为步骤日志中的一段
+type BuildStepAttempt struct {
+	ID         int        `json:"id" db:"id"`
+	StepID     int        `json:"step_id" db:"step_id"`
+	Attempt    int        `json:"attempt" db:"attempt"`
+	Status     string     `json:"status" db:"status"`
+	ExitCode   *int       `json:"exit_code,omitempty" db:"exit_code"`
+	LogOffset  int64      `json:"log_offset" db:"log_offset"`
+	LogBytes   int64      `json:"log_bytes" db:"log_bytes"`
+	LogLine    int        `json:"log_line" db:"log_line"`
+	LogLines   int        `json:"log_lines" db:"log_lines"`
+	StartedAt  time.Time  `json:"started_at" db:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+	Duration   *int       `json:"duration,omitempty" db:"duration"`
+}
+
+// Runner 远程执行器，通过执行器API领取构建并上报执行结果
+type Runner struct {
+	ID         int        `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Labels     []string   `json:"labels" db:"labels"`
+	TokenHash  string     `json:"-" db:"token_hash"` // 执行器令牌的SHA-256，令牌本身只在注册时返回一次
+	Version    string     `json:"version" db:"version"`
+	Paused     bool       `json:"paused" db:"paused"` // 暂停的执行器不再领取新构建
+	Online     bool       `json:"online" db:"-"`      // 最近一次心跳在有效期内
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty" db:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"` // 吊销后令牌失效
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Secret 项目或流水线的密钥，值加密保存，接口只返回名称等元数据。
+// 项目的密钥注入该项目全部构建的步骤环境变量，流水线的密钥只注入该流水线的构建并覆盖同名的项目密钥
+type Secret struct {
+	ID         int       `json:"id" db:"id"`
+	ProjectID  int       `json:"project_id" db:"project_id"`
+	PipelineID *int      `json:"pipeline_id,omitempty" db:"pipeline_id"` // 为空时为项目的密钥
+	Name       string    `json:"name" db:"name"`
+	KeyID      string    `json:"-" db:"key_id"` // 加密使用的主密钥标识
+	Value      []byte    `json:"-" db:"value"`  // 随机数和密文
+	CreatedBy  int       `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Artifact 构建制品，作业的步骤结束后按流水线配置从工作目录上传到制品存储
+type Artifact struct {
+	ID         int        `json:"id" db:"id"`
+	BuildID    int        `json:"build_id" db:"build_id"`
+	JobID      int        `json:"job_id" db:"job_id"`
+	Path       string     `json:"path" db:"path"` // 文件在工作目录中的相对路径
+	Mode       uint32     `json:"mode" db:"mode"` // 文件的权限位，如 0755
+	Size       int64      `json:"size" db:"size"`
+	Checksum   string     `json:"checksum" db:"checksum"` // 文件内容的SHA-256，十六进制
+	StorageKey string     `json:"-" db:"storage_key"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"` // 为空时永久保存
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Cache 项目的依赖缓存，作业按缓存键恢复和保存工作目录中的依赖文件，内容为 tar.gz 压缩包
+type Cache struct {
+	ID         int       `json:"id" db:"id"`
+	ProjectID  int       `json:"project_id" db:"project_id"`
+	Key        string    `json:"key" db:"key"`
+	Size       int64     `json:"size" db:"size"`
+	Checksum   string    `json:"checksum" db:"checksum"` // 压缩包的SHA-256，十六进制
+	StorageKey string    `json:"-" db:"storage_key"`
+	BuildID    *int      `json:"build_id,omitempty" db:"build_id"` // 保存缓存的构建，构建删除后为空
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`   // 最近一次保存或恢复的时间，超出大小上限时按此淘汰
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// ProjectWebhook 项目接收代码托管平台推送事件的 webhook，密钥加密保存，只在生成时返回一次
+type ProjectWebhook struct {
+	ProjectID int               `json:"project_id" db:"project_id"`
+	KeyID     string            `json:"-" db:"key_id"` // 加密使用的主密钥标识
+	Secret    []byte            `json:"-" db:"secret"` // 随机数和密文
+	CreatedBy int               `json:"created_by" db:"created_by"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	URLs      map[string]string `json:"urls" db:"-"` // 各代码托管平台填写的 webhook 地址路径
 }
 
+// WebhookResult 处理一次推送事件的结果
+type WebhookResult struct {
+	Event   string   `json:"event,omitempty"` // push 或 tag
+	Branch  string   `json:"branch,omitempty"`
+	Tag     string   `json:"tag,omitempty"`
+	Commit  string   `json:"commit,omitempty"`
+	Ignored string   `json:"ignored,omitempty"` // 事件被忽略的原因，如不是推送事件或删除了分支
+	Builds  []*Build `json:"builds"`            // 创建的构建
+	Skipped []string `json:"skipped,omitempty"` // 未触发构建的流水线及原因
+}
+
+// RunnerJob 交给执行器执行的构建，包含执行所需的全部信息，作业和步骤记录已由服务端创建
+type RunnerJob struct {
+	Build         *Build                        `json:"build"`
+	ProjectID     int                           `json:"project_id"`
+	Config        string                        `json:"config"` // 流水线配置快照
+	RepoURL       string                        `json:"repo_url,omitempty"`
+	DefaultBranch string                        `json:"default_branch,omitempty"` // 项目的默认分支
+	Jobs          []*BuildJob                   `json:"jobs"`                     // 与流水线定义中的作业一一对应，包含各自的步骤
+	Reusable      map[string]map[int]*BuildStep `json:"reusable,omitempty"`       // 只重新执行失败步骤时可复用的原步骤，按作业名称和步骤顺序索引
+	Secrets       map[string]string             `json:"secrets,omitempty"`        // 注入步骤环境变量的密钥
+}
+
+// BuildGraph 构建的作业依赖图
+type BuildGraph struct {
+	BuildID int          `json:"build_id"`
+	Stages  []string     `json:"stages"` // 按定义顺序排列的阶段
+	Jobs    []*BuildJob  `json:"jobs"`
+	Edges   []*GraphEdge `json:"edges"`
+}
+
+// GraphEdge 作业依赖图中的边，From 结束后才会执行 To
+type GraphEdge struct {
+	From int `json:"from"` // 作业ID
+	To   int `json:"to"`
+}
+
+// StepLog 步骤日志片段，按字节或按行分段读取
+type StepLog struct {
+	StepID     int      `json:"step_id"`
+	Attempt    int      `json:"attempt,omitempty"` // 只读取第几次执行的输出，0表示整个步骤日志
+	Size       int64    `json:"size"`              // 当前日志总字节数
+	Offset     int64    `json:"offset"`            // 按字节读取时本次内容的起始字节
+	NextOffset int64    `json:"next_offset"`       // 按字节读取时下一次的起始字节
+	Content    string   `json:"content,omitempty"` // 按字节读取的内容
+	Line       int      `json:"line"`              // 按行读取时的起始行
+	NextLine   int      `json:"next_line"`         // 按行读取时下一次的起始行
+	Lines      []string `json:"lines,omitempty"`   // 按行读取的内容
+	Complete   bool     `json:"complete"`          // 步骤已结束且已读到日志末尾
+}
+
+// RerunMode 重新执行方式常量
+const (
+	RerunModeAll    = "all"
+	RerunModeFailed = "failed"
+)
+
+// BuildEvent 触发构建的仓库事件常量
+const (
+	BuildEventPush = "push"
+	BuildEventTag  = "tag"
+)
+
 // BuildStatus 构建状态常量
 const (
 	BuildStatusPending  = "pending"
@@ -75,6 +255,7 @@ const (
 	BuildStatusSuccess  = "success"
 	BuildStatusFailed   = "failed"
 	BuildStatusCanceled = "canceled"
+	BuildStatusTimedOut = "timed_out"
 )
 
 // StepStatus 步骤状态常量
@@ -84,6 +265,43 @@ const (
 	StepStatusSuccess  = "success"
 	StepStatusFailed   = "failed"
 	StepStatusSkipped  = "skipped"
+	StepStatusCanceled = "canceled"
+	StepStatusTimedOut = "timed_out"
+)
+
+// JobStatus 作业状态常量
+const (
+	JobStatusPending  = "pending"
+	JobStatusRunning  = "running"
+	JobStatusSuccess  = "success"
+	JobStatusFailed   = "failed"
+	JobStatusSkipped  = "skipped"
+	JobStatusCanceled = "canceled"
+	JobStatusTimedOut = "timed_out"
+)
+
+// LogEvent 构建日志事件，通过WebSocket/SSE推送给客户端
+type LogEvent struct {
+	Seq      int64     `json:"seq"` // 构建内单调递增的序号，用于断线续传
+	Type     string    `json:"type"`
+	BuildID  int       `json:"build_id"`
+	JobID    int       `json:"job_id,omitempty"`
+	JobName  string    `json:"job_name,omitempty"`
+	StepID   int       `json:"step_id,omitempty"`
+	StepName string    `json:"step_name,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Line     string    `json:"line,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// LogEventType 日志事件类型常量
+const (
+	LogEventJobStart  = "job_start"
+	LogEventJobEnd    = "job_end"
+	LogEventStepStart = "step_start"
+	LogEventLog       = "log"
+	LogEventStepEnd   = "step_end"
+	LogEventBuildEnd  = "build_end"
 )
 
 // UserRole 用户角色常量
@@ -112,6 +330,12 @@ type LoginResponse struct {
 	User  User   `json:"user"`
 }
 
+// StreamTicketResponse 流式请求票据响应
+type StreamTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
 // CreateProjectRequest 创建项目请求
 type CreateProjectRequest struct {
 	Name        string `json:"name" binding:"required,min=1,max=100"`
@@ -120,6 +344,17 @@ type CreateProjectRequest struct {
 	Branch      string `json:"branch" binding:"required"`
 }
 
+// CreateSecretRequest 创建密钥请求
+type CreateSecretRequest struct {
+	Name  string `json:"name" binding:"required,min=1,max=100"`
+	Value string `json:"value" binding:"required,max=65536"`
+}
+
+// UpdateSecretRequest 更新密钥请求，只能修改值
+type UpdateSecretRequest struct {
+	Value string `json:"value" binding:"required,max=65536"`
+}
+
 // CreatePipelineRequest 创建流水线请求
 type CreatePipelineRequest struct {
 	ProjectID int    `json:"project_id" binding:"required"`
@@ -131,7 +366,78 @@ type CreatePipelineRequest struct {
 type TriggerBuildRequest struct {
 	PipelineID int    `json:"pipeline_id" binding:"required"`
 	Branch     string `json:"branch" binding:"required"`
-	Commit     string `json:"commit"`
+	Commit     string `json:"commit" binding:"omitempty,hexadecimal,min=7,max=40"` // 为空时构建分支的最新提交
+
+	Parameters map[string]interface{} `json:"parameters"` // 流水线中声明的参数，取值为字符串、布尔值或数字，未指定的参数使用默认值
+}
+
+// RerunBuildRequest 重新执行构建请求
+type RerunBuildRequest struct {
+	Mode string `form:"mode" binding:"omitempty,oneof=all failed"`
+}
+
+// UpdateBuildStatusRequest 更新构建状态请求
+type UpdateBuildStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=pending running success failed canceled timed_out"`
+}
+
+// RegisterRunnerRequest 注册执行器请求
+type RegisterRunnerRequest struct {
+	Token   string   `json:"token" binding:"required"` // 服务端配置的注册令牌
+	Name    string   `json:"name" binding:"required,min=1,max=100"`
+	Labels  []string `json:"labels" binding:"max=20,dive,min=1,max=50"`
+	Version string   `json:"version" binding:"max=50"`
+}
+
+// RegisterRunnerResponse 注册执行器响应，令牌只返回这一次
+type RegisterRunnerResponse struct {
+	Runner *Runner `json:"runner"`
+	Token  string  `json:"token"`
+}
+
+// RotateWebhookResponse 生成 webhook 密钥响应，密钥只返回这一次
+type RotateWebhookResponse struct {
+	Webhook *ProjectWebhook `json:"webhook"`
+	Secret  string          `json:"secret"`
+}
+
+// RunnerHeartbeatRequest 执行器心跳请求
+type RunnerHeartbeatRequest struct {
+	Running []int    `json:"running"`                                             // 执行器正在执行的构建
+	Labels  []string `json:"labels" binding:"omitempty,max=20,dive,min=1,max=50"` // 执行器当前的标签，为 null 时不更新
+}
+
+// RunnerHeartbeatResponse 执行器心跳响应
+type RunnerHeartbeatResponse struct {
+	Cancel []int `json:"cancel"` // 需要终止的构建：已被取消，或已不再分配给该执行器
+}
+
+// RunnerBuildJobUpdate 执行器上报的构建作业状态
+type RunnerBuildJobUpdate struct {
+	Status string `json:"status" binding:"required,oneof=running success failed skipped canceled timed_out"`
+}
+
+// RunnerStepUpdate 执行器上报的步骤状态，未设置的字段不修改
+type RunnerStepUpdate struct {
+	Status     string `json:"status" binding:"omitempty,oneof=running success failed skipped canceled timed_out"`
+	ExitCode   *int   `json:"exit_code"`
+	ReusedFrom *int   `json:"reused_from"` // 复用原步骤的结果，步骤直接标记为成功
+}
+
+// RunnerCommitUpdate 执行器上报的检出提交
+type RunnerCommitUpdate struct {
+	Commit string `json:"commit" binding:"required,hexadecimal,len=40"`
+}
+
+// RunnerCacheQuery 执行器查找缓存的请求，key 未命中时依次按 restore_keys 前缀匹配最近使用的缓存
+type RunnerCacheQuery struct {
+	Key         string   `json:"key" binding:"required,max=255"`
+	RestoreKeys []string `json:"restore_keys" binding:"max=10,dive,required,max=255"`
+}
+
+// RunnerBuildStatus 构建状态，执行器在步骤之间查询以发现被取消的构建，结束时上报最终状态
+type RunnerBuildStatus struct {
+	Status string `json:"status" binding:"required,oneof=success failed canceled timed_out"`
 }
 
 // APIResponse 统一API响应格式
@@ -148,4 +454,4 @@ type PaginationResponse struct {
 	Page       int         `json:"page"`
 	PageSize   int         `json:"page_size"`
 	TotalPages int         `json:"total_pages"`
-} 
\ No newline at end of file
+}
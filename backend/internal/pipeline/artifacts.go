@@ -0,0 +1,72 @@
+package pipeline
+
+// 上传制品的时机
+const (
+	ArtifactsOnSuccess = "on_success"
+	ArtifactsOnFailure = "on_failure"
+	ArtifactsAlways    = "always"
+)
+
+// maxArtifactPaths 一个作业最多配置的制品路径数
+const maxArtifactPaths = 50
+
+// Artifacts 作业的制品，作业的步骤结束后将工作目录中与 paths 匹配的文件上传保存。例如：
+//
+//	artifacts:
+//	  paths: ["dist/", "reports/**/*.xml"]
+//	  expire_in: 7d
+//	  when: always
+//
+// 路径相对于工作目录，支持 *、?、[...] 通配符和匹配任意层目录的 **，与目录匹配时上传目录中的全部文件
+type Artifacts struct {
+	Paths    []string  `yaml:"paths" json:"paths"`
+	ExpireIn *Duration `yaml:"expire_in" json:"expire_in,omitempty"` // 制品的保存时长，未配置时使用服务端的默认值
+	When     string    `yaml:"when" json:"when,omitempty"`           // on_success（默认）、on_failure 或 always
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// WhenOr 返回上传制品的时机，未配置时为 on_success
+func (a *Artifacts) WhenOr() string {
+	if a.When == "" {
+		return ArtifactsOnSuccess
+	}
+	return a.When
+}
+
+// Upload 判断作业以 succeeded 结束时是否需要上传制品
+func (a *Artifacts) Upload(succeeded bool) bool {
+	switch a.WhenOr() {
+	case ArtifactsAlways:
+		return true
+	case ArtifactsOnFailure:
+		return !succeeded
+	default:
+		return succeeded
+	}
+}
+
+// Matches 判断工作目录中以 / 分隔的相对路径 name 是否与某个制品路径匹配，
+// 路径本身或其所在的任意一级目录匹配时都视为匹配
+func (a *Artifacts) Matches(name string) bool {
+	return matchPaths(a.Paths, name)
+}
+
+func (a *Artifacts) validate(errs *ValidationErrors, field string) {
+	*errs = append(*errs, a.issues...)
+	if len(a.Paths) == 0 {
+		errs.add(a.Pos, field+".paths", "至少需要一个制品路径")
+	} else if len(a.Paths) > maxArtifactPaths {
+		errs.add(a.Pos, field+".paths", "制品路径不能超过%d个", maxArtifactPaths)
+	}
+
+	validatePaths(errs, a.Pos, field+".paths", a.Paths, "制品路径")
+	validateDuration(errs, field+".expire_in", a.ExpireIn)
+
+	switch a.When {
+	case "", ArtifactsOnSuccess, ArtifactsOnFailure, ArtifactsAlways:
+	default:
+		errs.add(a.Pos, field+".when", "无效的上传时机 %q，必须为 on_success、on_failure 或 always", a.When)
+	}
+}
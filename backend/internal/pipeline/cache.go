@@ -0,0 +1,61 @@
+package pipeline
+
+import "fmt"
+
+// maxCachePaths 一个作业最多配置的缓存路径数
+const maxCachePaths = 20
+
+// maxRestoreKeys 一个作业最多配置的备用缓存键数
+const maxRestoreKeys = 10
+
+// Cache 作业的依赖缓存，检出代码后按缓存键恢复，作业成功且未命中缓存键时将与 paths 匹配的文件打包保存。例如：
+//
+//	cache:
+//	  key: go-{{ hashFiles "go.sum" }}
+//	  restore_keys: ["go-"]
+//	  paths: [".cache/go-mod"]
+//
+// key 和 restore_keys 为模板，key 未命中时依次按 restore_keys 前缀匹配项目中最近保存的缓存。
+// 路径相对于工作目录，规则同制品路径；缓存的依赖需要通过环境变量等方式放在工作目录中，如 GOMODCACHE
+type Cache struct {
+	Key         *Template   `yaml:"key" json:"key"`
+	RestoreKeys []*Template `yaml:"restore_keys" json:"restore_keys,omitempty"`
+	Paths       []string    `yaml:"paths" json:"paths"`
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// Matches 判断工作目录中以 / 分隔的相对路径 name 是否与某个缓存路径匹配，
+// 路径本身或其所在的任意一级目录匹配时都视为匹配
+func (c *Cache) Matches(name string) bool {
+	return matchPaths(c.Paths, name)
+}
+
+func (c *Cache) validate(errs *ValidationErrors, field string) {
+	*errs = append(*errs, c.issues...)
+	if c.Key == nil {
+		errs.add(c.Pos, field+".key", "缓存键不能为空")
+	} else {
+		c.Key.validate(errs, field+".key")
+	}
+
+	if len(c.RestoreKeys) > maxRestoreKeys {
+		errs.add(c.Pos, field+".restore_keys", "备用缓存键不能超过%d个", maxRestoreKeys)
+	}
+	for i, key := range c.RestoreKeys {
+		keyField := fmt.Sprintf("%s.restore_keys[%d]", field, i)
+		if key == nil {
+			errs.add(c.Pos, keyField, "备用缓存键不能为空")
+			continue
+		}
+		key.validate(errs, keyField)
+	}
+
+	if len(c.Paths) == 0 {
+		errs.add(c.Pos, field+".paths", "至少需要一个缓存路径")
+	} else if len(c.Paths) > maxCachePaths {
+		errs.add(c.Pos, field+".paths", "缓存路径不能超过%d个", maxCachePaths)
+	}
+	validatePaths(errs, c.Pos, field+".paths", c.Paths, "缓存路径")
+}
@@ -0,0 +1,330 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"path"
+	"time"
+)
+
+// Position 配置节点在YAML中的位置
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Definition 流水线定义，对应 Pipeline.Config 中的YAML
+type Definition struct {
+	Name       string            `yaml:"name" json:"name,omitempty"`
+	Image      string            `yaml:"image" json:"image,omitempty"` // 步骤默认使用的容器镜像
+	Env        map[string]string `yaml:"env" json:"env,omitempty"`
+	Timeout    *Duration         `yaml:"timeout" json:"timeout,omitempty"` // 整个构建的超时时间
+	Checkout   *Checkout         `yaml:"checkout" json:"checkout,omitempty"`
+	RunsOn     *LabelSelector    `yaml:"runs_on" json:"runs_on,omitempty"`       // 执行构建的执行器需满足的标签表达式
+	Triggers   *Triggers         `yaml:"triggers" json:"triggers,omitempty"`     // 仓库推送时自动触发构建的分支和标签
+	Parameters []*Parameter      `yaml:"parameters" json:"parameters,omitempty"` // 触发构建时可以指定的参数
+	Stages     []*Stage          `yaml:"stages" json:"stages"`
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// Checkout 代码检出配置，构建在执行步骤前将项目仓库检出到工作目录
+type Checkout struct {
+	Skip       bool `yaml:"skip" json:"skip,omitempty"`             // 不检出代码
+	Depth      *int `yaml:"depth" json:"depth,omitempty"`           // 克隆深度，0 表示完整历史，默认为1
+	Submodules bool `yaml:"submodules" json:"submodules,omitempty"` // 同时检出子模块
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// defaultCheckoutDepth 默认只克隆最新的一个提交
+const defaultCheckoutDepth = 1
+
+// Enabled 判断是否需要检出代码，未配置时检出
+func (c *Checkout) Enabled() bool {
+	return c == nil || !c.Skip
+}
+
+// CloneDepth 返回克隆深度，0 表示完整历史
+func (c *Checkout) CloneDepth() int {
+	if c == nil || c.Depth == nil {
+		return defaultCheckoutDepth
+	}
+	return *c.Depth
+}
+
+// HasSubmodules 判断是否需要检出子模块
+func (c *Checkout) HasSubmodules() bool {
+	return c != nil && c.Submodules
+}
+
+// Triggers 仓库推送触发构建的规则，分支和标签按 path.Match 匹配，如 release/*、v*。
+// 未配置 branches 时推送到项目的默认分支触发构建，branches: [] 表示推送分支不触发；
+// 未配置 tags 时推送标签不触发构建
+type Triggers struct {
+	Branches []string `yaml:"branches" json:"branches,omitempty"`
+	Tags     []string `yaml:"tags" json:"tags,omitempty"`
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// MatchBranch 判断推送到分支 branch 是否触发构建，defaultBranch 为项目的默认分支
+func (t *Triggers) MatchBranch(branch, defaultBranch string) bool {
+	if t == nil || t.Branches == nil {
+		return branch == defaultBranch
+	}
+	return matchAny(t.Branches, branch)
+}
+
+// MatchTag 判断推送标签 tag 是否触发构建
+func (t *Triggers) MatchTag(tag string) bool {
+	return t != nil && matchAny(t.Tags, tag)
+}
+
+// matchAny 判断 name 是否与任一模式匹配
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Stage 流水线阶段。阶段包含若干并行的作业，只有步骤的阶段视为一个与阶段同名的作业
+type Stage struct {
+	Name         string     `yaml:"name" json:"name"`
+	Steps        []*Step    `yaml:"steps" json:"steps,omitempty"`
+	Artifacts    *Artifacts `yaml:"artifacts" json:"artifacts,omitempty"`       // 只有步骤的阶段的制品
+	Cache        *Cache     `yaml:"cache" json:"cache,omitempty"`               // 只有步骤的阶段的依赖缓存
+	Dependencies []string   `yaml:"dependencies" json:"dependencies,omitempty"` // 只有步骤的阶段获取制品的作业
+	Jobs         []*Job     `yaml:"jobs" json:"jobs,omitempty"`
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// Job 作业，在独立的工作空间中依次执行步骤。
+// 未声明 needs 的作业在上一个阶段的全部作业结束后执行，声明了 needs 的作业只等待所需的作业。
+// 配置了矩阵的作业展开为多个作业，依赖它的作业等待展开后的全部作业。
+// 作业在执行步骤前将上游作业的制品下载到工作目录：未声明 dependencies 时获取所依赖的作业中
+// 在成功时上传制品的作业的制品，声明了 dependencies 时只获取其中的作业（可以是间接依赖的作业）的制品
+type Job struct {
+	Name         string     `yaml:"name" json:"name"`
+	Needs        []string   `yaml:"needs" json:"needs,omitempty"`
+	Dependencies []string   `yaml:"dependencies" json:"dependencies,omitempty"` // 获取制品的作业，dependencies: [] 表示不获取
+	Matrix       *Matrix    `yaml:"matrix" json:"matrix,omitempty"`
+	Steps        []*Step    `yaml:"steps" json:"steps"`
+	Artifacts    *Artifacts `yaml:"artifacts" json:"artifacts,omitempty"` // 步骤结束后上传的制品
+	Cache        *Cache     `yaml:"cache" json:"cache,omitempty"`         // 依赖缓存
+
+	// 以下字段由 Definition.Jobs 计算
+	Stage         string       `yaml:"-" json:"stage"`                    // 所属阶段
+	DependsOn     []string     `yaml:"-" json:"depends_on"`               // 实际依赖的作业
+	ArtifactsFrom []string     `yaml:"-" json:"artifacts_from,omitempty"` // 实际获取制品的作业
+	MatrixOf      string       `yaml:"-" json:"matrix_of,omitempty"`      // 展开前的作业名称
+	MatrixValues  MatrixValues `yaml:"-" json:"matrix_values,omitempty"`  // 矩阵变量的取值
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// Step 流水线步骤
+type Step struct {
+	Name    string            `yaml:"name" json:"name"`
+	Run     string            `yaml:"run" json:"run"`
+	Image   string            `yaml:"image" json:"image,omitempty"`
+	Env     map[string]string `yaml:"env" json:"env,omitempty"`
+	When    *Condition        `yaml:"when" json:"when,omitempty"`
+	If      *Expression       `yaml:"if" json:"if,omitempty"`           // 步骤的执行条件表达式
+	Timeout *Duration         `yaml:"timeout" json:"timeout,omitempty"` // 步骤的超时时间
+	Retry   *Retry            `yaml:"retry" json:"retry,omitempty"`
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// Condition 步骤执行条件
+type Condition struct {
+	Branches []string `yaml:"branches" json:"branches,omitempty"`
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// ImageOr 返回步骤的镜像，步骤未指定时返回 image
+func (s *Step) ImageOr(image string) string {
+	if s.Image != "" {
+		return s.Image
+	}
+	return image
+}
+
+// Retry 步骤失败后的重试策略
+type Retry struct {
+	MaxAttempts int       `yaml:"max_attempts" json:"max_attempts"`             // 包括首次执行在内的最多执行次数
+	Backoff     *Duration `yaml:"backoff" json:"backoff,omitempty"`             // 首次重试前的等待时间，之后每次翻倍
+	OnExitCodes []int     `yaml:"on_exit_codes" json:"on_exit_codes,omitempty"` // 只在这些退出码时重试，为空时任意非零退出码都重试
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// maxRetryBackoff 重试等待时间的上限
+const maxRetryBackoff = 10 * time.Minute
+
+// Attempts 返回最多执行次数，未配置重试时为1
+func (r *Retry) Attempts() int {
+	if r == nil || r.MaxAttempts < 1 {
+		return 1
+	}
+	return r.MaxAttempts
+}
+
+// ShouldRetry 判断以 exitCode 退出的执行是否需要重试
+func (r *Retry) ShouldRetry(exitCode int) bool {
+	if r == nil || exitCode == 0 {
+		return false
+	}
+	if len(r.OnExitCodes) == 0 {
+		return true
+	}
+	for _, code := range r.OnExitCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay 返回第 attempt 次执行失败后、下一次执行前的等待时间
+func (r *Retry) Delay(attempt int) time.Duration {
+	if r == nil || r.Backoff == nil {
+		return 0
+	}
+	delay := r.Backoff.Duration
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay
+}
+
+// Duration 时长配置，YAML中写作 "30s"、"10m"、"1h30m"、"7d"
+type Duration struct {
+	time.Duration
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// MarshalJSON 以与YAML相同的字符串形式输出时长
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Matches 判断分支是否满足条件，未配置分支时总是满足
+func (c *Condition) Matches(branch string) bool {
+	if c == nil || len(c.Branches) == 0 {
+		return true
+	}
+	return matchAny(c.Branches, branch)
+}
+
+// Jobs 按声明顺序返回按矩阵展开后的所有作业并计算各作业的依赖：
+// 声明了 needs 的作业依赖 needs 中的作业（needs: [] 表示不依赖任何作业），
+// 否则依赖上一个阶段的全部作业；依赖矩阵作业即依赖其展开后的全部作业。
+// 获取制品的作业同样展开矩阵作业
+func (d *Definition) Jobs() []*Job {
+	expanded := make(map[string][]string)    // 作业名称到展开后的作业名称
+	artifacts := make(map[string]*Artifacts) // 展开后的作业名称到作业的制品配置
+	for _, stage := range d.Stages {
+		if stage == nil {
+			continue
+		}
+		if len(stage.Steps) > 0 {
+			artifacts[stage.Name] = stage.Artifacts
+			continue
+		}
+		for _, job := range stage.Jobs {
+			if job == nil {
+				continue
+			}
+			for _, child := range job.expand() {
+				expanded[job.Name] = append(expanded[job.Name], child.Name)
+				artifacts[child.Name] = job.Artifacts
+			}
+		}
+	}
+
+	var jobs, previous []*Job
+	for _, stage := range d.Stages {
+		if stage == nil {
+			continue
+		}
+
+		stageJobs := stage.Jobs
+		if len(stage.Steps) > 0 {
+			stageJobs = []*Job{{
+				Name:         stage.Name,
+				Dependencies: stage.Dependencies,
+				Steps:        stage.Steps,
+				Artifacts:    stage.Artifacts,
+				Cache:        stage.Cache,
+				Pos:          stage.Pos,
+			}}
+		}
+
+		var current []*Job
+		for _, job := range stageJobs {
+			if job == nil {
+				continue
+			}
+			job.Stage = stage.Name
+			if job.Needs != nil {
+				job.DependsOn = make([]string, 0, len(job.Needs))
+				for _, need := range job.Needs {
+					if names, ok := expanded[need]; ok {
+						job.DependsOn = append(job.DependsOn, names...)
+					} else {
+						job.DependsOn = append(job.DependsOn, need)
+					}
+				}
+			} else {
+				job.DependsOn = make([]string, 0, len(previous))
+				for _, dep := range previous {
+					job.DependsOn = append(job.DependsOn, dep.Name)
+				}
+			}
+
+			if job.Dependencies != nil {
+				job.ArtifactsFrom = make([]string, 0, len(job.Dependencies))
+				for _, dep := range job.Dependencies {
+					if names, ok := expanded[dep]; ok {
+						job.ArtifactsFrom = append(job.ArtifactsFrom, names...)
+					} else {
+						job.ArtifactsFrom = append(job.ArtifactsFrom, dep)
+					}
+				}
+			} else {
+				job.ArtifactsFrom = nil
+				for _, dep := range job.DependsOn {
+					if a := artifacts[dep]; a != nil && a.Upload(true) {
+						job.ArtifactsFrom = append(job.ArtifactsFrom, dep)
+					}
+				}
+			}
+			current = append(current, job.expand()...)
+		}
+
+		jobs = append(jobs, current...)
+		if len(current) > 0 {
+			previous = current
+		}
+	}
+	return jobs
+}
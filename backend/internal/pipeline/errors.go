@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError 单个配置错误
+type ValidationError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ", column %d", e.Column)
+		}
+		b.WriteString(": ")
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field + ": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// ValidationErrors 配置校验发现的全部错误
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return "invalid pipeline config: " + strings.Join(msgs, "; ")
+}
+
+// add 追加一条带位置的错误
+func (errs *ValidationErrors) add(pos Position, field, format string, args ...interface{}) {
+	*errs = append(*errs, ValidationError{
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
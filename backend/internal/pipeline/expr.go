@@ -0,0 +1,722 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Expression 步骤的执行条件表达式，只能读取构建上下文，不能调用外部命令或修改任何状态。
+// 支持的语法：
+//
+//	字面量    'text'、"text"、42、1.5、true、false、null
+//	变量      branch、tag、commit、trigger、env.NAME、matrix.NAME、params.NAME、steps.NAME.status、steps['step name'].status
+//	运算符    ==、!=、<、<=、>、>=、&&、||、!、括号
+//	函数      success()、failure()、always()、contains(s, sub)、startsWith(s, prefix)、endsWith(s, suffix)、
+//	          matches(s, pattern)（按 path.Match 匹配，如 matches(branch, 'release/*')）
+//
+// 不使用 success()、failure()、always() 的表达式只在之前的步骤全部成功时求值，
+// 即相当于 success() && (表达式)
+type Expression struct {
+	source string
+	expr   exprNode
+	status bool // 表达式使用了状态函数
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// ExprContext 表达式求值时的构建上下文
+type ExprContext struct {
+	Branch  string
+	Tag     string // 推送标签触发的构建的标签，其他构建为空
+	Commit  string
+	Trigger string            // 构建的触发方式：manual、rerun、push 或 tag
+	Env     map[string]string // 步骤的环境变量
+	Matrix  map[string]string // 矩阵变量
+	Params  map[string]string // 触发构建时指定的参数
+	Steps   map[string]string // 作业中之前步骤的名称到状态
+	Failed  bool              // 作业中之前的步骤是否有失败
+
+	// HashFiles 计算工作目录中与路径匹配的文件的摘要，只在模板中使用
+	HashFiles func(patterns []string) (string, error)
+	err       error // 求值过程中第一个出错的函数调用
+}
+
+// 表达式可以引用的顶层变量
+var exprRoots = map[string]bool{
+	"branch":  true,
+	"tag":     true,
+	"commit":  true,
+	"trigger": true,
+	"env":     true,
+	"matrix":  true,
+	"steps":   true,
+	"params":  true,
+}
+
+// 表达式可以调用的函数及参数个数，-1 表示至少一个参数
+var exprFuncs = map[string]int{
+	"success":    0,
+	"failure":    0,
+	"always":     0,
+	"contains":   2,
+	"startsWith": 2,
+	"endsWith":   2,
+	"matches":    2,
+	"hashFiles":  -1,
+}
+
+// templateFuncs 只能在模板中调用的函数，需要读取工作目录
+var templateFuncs = map[string]bool{
+	"hashFiles": true,
+}
+
+// ParseExpression 解析条件表达式
+func ParseExpression(s string) (*Expression, error) {
+	p := &exprParser{input: s}
+	expr, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{source: strings.TrimSpace(s), expr: expr, status: p.status}, nil
+}
+
+// String 返回表达式原文
+func (e *Expression) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.source
+}
+
+// MarshalJSON 以表达式原文输出
+func (e Expression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.source)
+}
+
+// Eval 判断步骤是否需要执行，未配置条件时只在之前的步骤全部成功时执行
+func (e *Expression) Eval(ctx *ExprContext) bool {
+	if e == nil || e.expr == nil {
+		return !ctx.Failed
+	}
+	if !e.status && ctx.Failed {
+		return false
+	}
+	return truthy(e.expr.eval(ctx))
+}
+
+// UnmarshalYAML 解析条件表达式并记录位置
+func (e *Expression) UnmarshalYAML(node *yaml.Node) error {
+	e.Pos = Position{Line: node.Line, Column: node.Column}
+	if node.Kind != yaml.ScalarNode {
+		e.issues.add(e.Pos, "", "期望为条件表达式")
+		return nil
+	}
+
+	parsed, err := ParseExpression(node.Value)
+	if err != nil {
+		e.issues.add(e.Pos, "", "无效的条件表达式 %q: %v", node.Value, err)
+		return nil
+	}
+	e.source, e.expr, e.status = parsed.source, parsed.expr, parsed.status
+	return nil
+}
+
+func (e *Expression) validate(errs *ValidationErrors, field string) {
+	for _, issue := range e.issues {
+		if issue.Field == "" {
+			issue.Field = field
+		}
+		*errs = append(*errs, issue)
+	}
+	if len(e.issues) == 0 && e.expr == nil {
+		errs.add(e.Pos, field, "条件表达式不能为空")
+	}
+}
+
+// exprNode 表达式语法树节点，求值结果为 string、float64、bool、nil 或 map[string]interface{}
+type exprNode interface {
+	eval(ctx *ExprContext) interface{}
+}
+
+type exprLiteral struct{ value interface{} }
+
+func (l exprLiteral) eval(*ExprContext) interface{} { return l.value }
+
+type exprVar struct{ name string }
+
+func (v exprVar) eval(ctx *ExprContext) interface{} {
+	switch v.name {
+	case "branch":
+		return ctx.Branch
+	case "tag":
+		return ctx.Tag
+	case "commit":
+		return ctx.Commit
+	case "trigger":
+		return ctx.Trigger
+	case "env":
+		return stringMap(ctx.Env)
+	case "matrix":
+		return stringMap(ctx.Matrix)
+	case "params":
+		return stringMap(ctx.Params)
+	case "steps":
+		steps := make(map[string]interface{}, len(ctx.Steps))
+		for name, status := range ctx.Steps {
+			steps[name] = map[string]interface{}{"status": status}
+		}
+		return steps
+	}
+	return nil
+}
+
+func stringMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// exprIndex 属性访问，a.b 与 a['b'] 等价，访问不存在的属性得到 null
+type exprIndex struct {
+	target exprNode
+	key    string
+}
+
+func (i exprIndex) eval(ctx *ExprContext) interface{} {
+	if m, ok := i.target.eval(ctx).(map[string]interface{}); ok {
+		return m[i.key]
+	}
+	return nil
+}
+
+type exprNot struct{ expr exprNode }
+
+func (n exprNot) eval(ctx *ExprContext) interface{} { return !truthy(n.expr.eval(ctx)) }
+
+type exprLogical struct {
+	op          string
+	left, right exprNode
+}
+
+func (l exprLogical) eval(ctx *ExprContext) interface{} {
+	left := truthy(l.left.eval(ctx))
+	if l.op == "&&" {
+		return left && truthy(l.right.eval(ctx))
+	}
+	return left || truthy(l.right.eval(ctx))
+}
+
+type exprCompare struct {
+	op          string
+	left, right exprNode
+}
+
+func (c exprCompare) eval(ctx *ExprContext) interface{} {
+	left, right := c.left.eval(ctx), c.right.eval(ctx)
+	switch c.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	// 两边都是数字时按数值比较，否则按字符串比较
+	var cmp int
+	ln, lok := number(left)
+	rn, rok := number(right)
+	if lok && rok {
+		switch {
+		case ln < rn:
+			cmp = -1
+		case ln > rn:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(toString(left), toString(right))
+	}
+
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+type exprCall struct {
+	name string
+	args []exprNode
+}
+
+func (c exprCall) eval(ctx *ExprContext) interface{} {
+	switch c.name {
+	case "success":
+		return !ctx.Failed
+	case "failure":
+		return ctx.Failed
+	case "always":
+		return true
+	case "hashFiles":
+		return c.hashFiles(ctx)
+	}
+
+	a, b := toString(c.args[0].eval(ctx)), toString(c.args[1].eval(ctx))
+	switch c.name {
+	case "contains":
+		return strings.Contains(a, b)
+	case "startsWith":
+		return strings.HasPrefix(a, b)
+	case "endsWith":
+		return strings.HasSuffix(a, b)
+	case "matches":
+		ok, _ := path.Match(b, a)
+		return ok
+	}
+	return nil
+}
+
+// hashFiles 返回与参数中的路径匹配的文件的摘要，没有匹配的文件时为空字符串
+func (c exprCall) hashFiles(ctx *ExprContext) interface{} {
+	if ctx.HashFiles == nil {
+		return ""
+	}
+	patterns := make([]string, len(c.args))
+	for i, arg := range c.args {
+		patterns[i] = toString(arg.eval(ctx))
+	}
+	sum, err := ctx.HashFiles(patterns)
+	if err != nil {
+		if ctx.err == nil {
+			ctx.err = fmt.Errorf("hashFiles: %w", err)
+		}
+		return ""
+	}
+	return sum
+}
+
+// truthy 空字符串、0、false、null 为假，其他值为真
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	default:
+		return true
+	}
+}
+
+// equal 比较两个值，数字与数字字符串相等（如 matrix.go == 1.21），null 只与 null 相等
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if an, ok := number(a); ok {
+		if bn, ok := number(b); ok {
+			return an == bn
+		}
+	}
+	return toString(a) == toString(b)
+}
+
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// 记号类型
+const (
+	tokEOF = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type exprToken struct {
+	kind int
+	text string // 运算符、标识符，或字符串去掉引号后的内容
+	pos  int
+}
+
+// exprParser 条件表达式的递归下降解析器：
+//
+//	or      = and { "||" and }
+//	and     = compare { "&&" compare }
+//	compare = unary [ ("==" | "!=" | "<" | "<=" | ">" | ">=") unary ]
+//	unary   = "!" unary | postfix
+//	postfix = primary { "." ident | "[" string "]" }
+//	primary = literal | ident | ident "(" [ or { "," or } ] ")" | "(" or ")"
+//
+// 模板中的 templateFuncs 还可以像 Go 模板一样以空格分隔参数调用：ident { postfix }
+type exprParser struct {
+	input    string
+	pos      int
+	tok      exprToken
+	status   bool // 解析到了状态函数
+	template bool // 解析模板中的表达式，可以调用 templateFuncs
+}
+
+// parse 解析完整的输入
+func (p *exprParser) parse() (exprNode, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("第%d个字符处有多余的 %q", p.tok.pos+1, p.tok.text)
+	}
+	return expr, nil
+}
+
+var exprOps = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ".", ","}
+
+func (p *exprParser) next() error {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.input) {
+		p.tok = exprToken{kind: tokEOF, pos: start}
+		return nil
+	}
+
+	rest := p.input[p.pos:]
+	c := rest[0]
+	switch {
+	case c == '\'' || c == '"':
+		var b strings.Builder
+		for i := 1; i < len(rest); i++ {
+			if rest[i] != c {
+				b.WriteByte(rest[i])
+				continue
+			}
+			// 单引号字符串中用两个单引号表示一个单引号
+			if c == '\'' && i+1 < len(rest) && rest[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			p.pos += i + 1
+			p.tok = exprToken{kind: tokString, text: b.String(), pos: start}
+			return nil
+		}
+		return fmt.Errorf("第%d个字符处的字符串缺少结束引号", start+1)
+	case c >= '0' && c <= '9':
+		end := 0
+		for end < len(rest) && (rest[end] >= '0' && rest[end] <= '9' || rest[end] == '.') {
+			end++
+		}
+		p.pos += end
+		p.tok = exprToken{kind: tokNumber, text: rest[:end], pos: start}
+		return nil
+	case isIdentStart(c):
+		end := 1
+		for end < len(rest) && isIdentPart(rest[end]) {
+			end++
+		}
+		p.pos += end
+		p.tok = exprToken{kind: tokIdent, text: rest[:end], pos: start}
+		return nil
+	}
+
+	for _, op := range exprOps {
+		if strings.HasPrefix(rest, op) {
+			p.pos += len(op)
+			p.tok = exprToken{kind: tokOp, text: op, pos: start}
+			return nil
+		}
+	}
+	return fmt.Errorf("第%d个字符处有无法识别的字符 %q", start+1, c)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '-'
+}
+
+// expect 消耗指定的运算符
+func (p *exprParser) expect(op string) error {
+	if p.tok.kind != tokOp || p.tok.text != op {
+		return p.unexpected(fmt.Sprintf("缺少 %q", op))
+	}
+	return p.next()
+}
+
+func (p *exprParser) unexpected(msg string) error {
+	if p.tok.kind == tokEOF {
+		return fmt.Errorf("表达式不完整，%s", msg)
+	}
+	return fmt.Errorf("第%d个字符处%s", p.tok.pos+1, msg)
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	if p.tok.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = exprLogical{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = exprLogical{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=") {
+		return left, nil
+	}
+	op := p.tok.text
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return exprCompare{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("!") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return exprNot{expr: expr}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isOp("."):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokIdent {
+				return nil, p.unexpected("缺少属性名")
+			}
+			expr = exprIndex{target: expr, key: p.tok.text}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case p.isOp("["):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokString {
+				return nil, p.unexpected("方括号中应为字符串")
+			}
+			expr = exprIndex{target: expr, key: p.tok.text}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokString:
+		return exprLiteral{value: tok.text}, p.next()
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个字符处的数字 %q 无效", tok.pos+1, tok.text)
+		}
+		return exprLiteral{value: n}, p.next()
+	case tokIdent:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch tok.text {
+		case "true":
+			return exprLiteral{value: true}, nil
+		case "false":
+			return exprLiteral{value: false}, nil
+		case "null":
+			return exprLiteral{value: nil}, nil
+		}
+		if p.isOp("(") {
+			return p.parseCall(tok)
+		}
+		if p.template && templateFuncs[tok.text] {
+			return p.parseCommand(tok)
+		}
+		if !exprRoots[tok.text] {
+			return nil, fmt.Errorf("第%d个字符处有未知的变量 %q", tok.pos+1, tok.text)
+		}
+		return exprVar{name: tok.text}, nil
+	case tokOp:
+		if tok.text == "(" {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			expr, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expect(")")
+		}
+	}
+	return nil, p.unexpected("缺少值")
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	if _, ok := exprFuncs[name.text]; !ok {
+		return nil, fmt.Errorf("第%d个字符处有未知的函数 %q", name.pos+1, name.text)
+	}
+	if templateFuncs[name.text] && !p.template {
+		return nil, fmt.Errorf("第%d个字符处的函数 %s 只能在缓存键中使用", name.pos+1, name.text)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var args []exprNode
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return p.call(name, args)
+}
+
+// parseCommand 解析模板中以空格分隔参数的函数调用，如 hashFiles "go.sum" "go.mod"，
+// 参数为字面量或变量，不能包含运算符
+func (p *exprParser) parseCommand(name exprToken) (exprNode, error) {
+	var args []exprNode
+	for p.tok.kind == tokString || p.tok.kind == tokNumber || p.tok.kind == tokIdent {
+		arg, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return p.call(name, args)
+}
+
+// call 检查函数的参数个数并创建函数调用
+func (p *exprParser) call(name exprToken, args []exprNode) (exprNode, error) {
+	arity := exprFuncs[name.text]
+	if arity < 0 && len(args) == 0 {
+		return nil, fmt.Errorf("函数 %s 至少需要1个参数", name.text)
+	}
+	if arity >= 0 && len(args) != arity {
+		return nil, fmt.Errorf("函数 %s 需要%d个参数，实际为%d个", name.text, arity, len(args))
+	}
+	switch name.text {
+	case "success", "failure", "always":
+		p.status = true
+	}
+	return exprCall{name: name.text, args: args}, nil
+}
@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpressionEval(t *testing.T) {
+	ctx := &ExprContext{
+		Branch:  "release/1.2",
+		Commit:  "0a1b2c3d",
+		Trigger: "push",
+		Env:     map[string]string{"DEPLOY": "1"},
+		Matrix:  map[string]string{"go": "1.21", "os": "linux"},
+		Params:  map[string]string{"dry_run": "true", "environment": "staging"},
+		Steps:   map[string]string{"build": "success", "unit tests": "failed"},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: "branch == 'release/1.2'", want: true},
+		{expr: `branch != "main"`, want: true},
+		{expr: "startsWith(branch, 'release/')", want: true},
+		{expr: "endsWith(commit, 'ff')", want: false},
+		{expr: "contains(branch, '1.2')", want: true},
+		{expr: "matches(branch, 'release/*')", want: true},
+		{expr: "matches(branch, 'release')", want: false},
+		{expr: "trigger == 'push' && env.DEPLOY == 1", want: true},
+		{expr: "matrix.go == 1.21", want: true},
+		{expr: "matrix.go >= '1.22'", want: false},
+		{expr: "matrix.go < 1.3", want: true},
+		{expr: "matrix.os == 'linux' || matrix.os == 'darwin'", want: true},
+		{expr: "params.dry_run == true", want: true},
+		{expr: "params.environment == 'production'", want: false},
+		{expr: "steps.build.status == 'success'", want: true},
+		{expr: "steps['unit tests'].status == 'failed'", want: true},
+		{expr: "steps.missing.status == null", want: true},
+		{expr: "env.MISSING", want: false},
+		{expr: "tag", want: false},
+		{expr: "!(branch == 'main')", want: true},
+		{expr: "'it''s' == \"it's\"", want: true},
+		{expr: "0", want: false},
+		{expr: "2 > 10", want: false},
+		{expr: "'2' > '10'", want: false},
+		{expr: "'b' > 'a10'", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+			if got := e.Eval(ctx); got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressionStatusFunctions(t *testing.T) {
+	tests := []struct {
+		expr   string
+		failed bool
+		want   bool
+	}{
+		{expr: "", failed: false, want: true},
+		{expr: "", failed: true, want: false},
+		{expr: "branch == 'main'", failed: true, want: false},
+		{expr: "success()", failed: true, want: false},
+		{expr: "failure()", failed: true, want: true},
+		{expr: "failure()", failed: false, want: false},
+		{expr: "always()", failed: true, want: true},
+		{expr: "failure() && branch == 'main'", failed: true, want: true},
+		{expr: "always() && branch == 'dev'", failed: false, want: false},
+	}
+
+	for _, tt := range tests {
+		var e *Expression
+		if tt.expr != "" {
+			var err error
+			if e, err = ParseExpression(tt.expr); err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expr, err)
+			}
+		}
+		ctx := &ExprContext{Branch: "main", Failed: tt.failed}
+		if got := e.Eval(ctx); got != tt.want {
+			t.Errorf("%q with failed=%v: Eval() = %v, want %v", tt.expr, tt.failed, got, tt.want)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "foo == 1", want: `第1个字符处有未知的变量 "foo"`},
+		{expr: "exec('rm -rf /')", want: `第1个字符处有未知的函数 "exec"`},
+		{expr: "branch == os.Getenv('HOME')", want: `第11个字符处有未知的变量 "os"`},
+		{expr: "hashFiles('go.sum') != ''", want: "第1个字符处的函数 hashFiles 只能在缓存键中使用"},
+		{expr: "contains(branch)", want: "函数 contains 需要2个参数，实际为1个"},
+		{expr: "branch == 'main", want: "第11个字符处的字符串缺少结束引号"},
+		{expr: "branch ==", want: "表达式不完整，缺少值"},
+		{expr: "(branch == 'main'", want: `表达式不完整，缺少 ")"`},
+		{expr: "branch 'main'", want: `第8个字符处有多余的 "main"`},
+		{expr: "branch = 'main'", want: `第8个字符处有无法识别的字符 '='`},
+		{expr: "steps[0]", want: "第7个字符处方括号中应为字符串"},
+		{expr: "1.2.3 == 1", want: `第1个字符处的数字 "1.2.3" 无效`},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseExpression(tt.expr)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("ParseExpression(%q) error = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestStepIfConfig(t *testing.T) {
+	def, err := Parse(`stages:
+  - name: build
+    steps:
+      - name: notify
+        run: ./notify.sh
+        if: failure() && branch == 'main'
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	step := def.Stages[0].Steps[0]
+	if step.If.String() != "failure() && branch == 'main'" {
+		t.Errorf("if = %q", step.If.String())
+	}
+	if !step.If.Eval(&ExprContext{Branch: "main", Failed: true}) {
+		t.Errorf("Eval() = false, want true")
+	}
+
+	errs := parseErrors(t, `stages:
+  - name: build
+    steps:
+      - name: notify
+        run: ./notify.sh
+        if: system('id')
+`)
+	e, ok := findError(errs, `未知的函数 "system"`)
+	if !ok || e.Field != "stages[0].steps[0].if" || e.Line != 6 {
+		t.Errorf("errors = %v, want unknown function at stages[0].steps[0].if line 6", errs)
+	}
+	if !strings.Contains(e.Message, "无效的条件表达式") {
+		t.Errorf("message = %q", e.Message)
+	}
+}
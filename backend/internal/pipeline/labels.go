@@ -0,0 +1,287 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LabelPattern 执行器标签规则，如 linux、arch=arm64、gpu.nvidia
+var LabelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:/=-]*$`)
+
+// LabelSelector 执行器标签表达式，构建只会交给标签满足表达式的执行器。
+// 支持 &&、||、! 和括号，如 "linux && (docker || podman) && !arm64"；
+// YAML中也可以写作标签列表，表示需要同时具有这些标签
+type LabelSelector struct {
+	expr labelExpr
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// ParseLabelSelector 解析标签表达式
+func ParseLabelSelector(s string) (*LabelSelector, error) {
+	p := &labelParser{input: s}
+	p.next()
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("第%d个字符处有多余的 %q", p.tokPos+1, p.tok)
+	}
+	return &LabelSelector{expr: expr}, nil
+}
+
+// Matches 判断标签集合是否满足表达式，未配置时总是满足
+func (s *LabelSelector) Matches(labels []string) bool {
+	if s == nil || s.expr == nil {
+		return true
+	}
+	set := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		set[label] = true
+	}
+	return s.expr.match(set)
+}
+
+// String 返回规范化的表达式，未配置时为空字符串
+func (s *LabelSelector) String() string {
+	if s == nil || s.expr == nil {
+		return ""
+	}
+	return s.expr.format(precOr)
+}
+
+// MarshalJSON 以表达式字符串形式输出
+func (s LabelSelector) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalYAML 解析标签表达式或标签列表并记录位置
+func (s *LabelSelector) UnmarshalYAML(node *yaml.Node) error {
+	s.Pos = Position{Line: node.Line, Column: node.Column}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		parsed, err := ParseLabelSelector(node.Value)
+		if err != nil {
+			s.issues.add(s.Pos, "", "无效的标签表达式 %q: %v", node.Value, err)
+			return nil
+		}
+		s.expr = parsed.expr
+	case yaml.SequenceNode:
+		var all labelAnd
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode || !LabelPattern.MatchString(item.Value) {
+				s.issues.add(Position{Line: item.Line, Column: item.Column}, "", "无效的标签 %q", item.Value)
+				continue
+			}
+			all = append(all, labelName(item.Value))
+		}
+		switch len(all) {
+		case 0:
+		case 1:
+			s.expr = all[0]
+		default:
+			s.expr = all
+		}
+	default:
+		s.issues.add(s.Pos, "", "期望为标签表达式或标签列表")
+	}
+	return nil
+}
+
+func (s *LabelSelector) validate(errs *ValidationErrors, field string) {
+	for _, issue := range s.issues {
+		if issue.Field == "" {
+			issue.Field = field
+		}
+		*errs = append(*errs, issue)
+	}
+	if len(s.issues) == 0 && s.expr == nil {
+		errs.add(s.Pos, field, "标签表达式不能为空")
+	}
+}
+
+// 表达式的优先级，用于格式化时决定是否需要括号
+const (
+	precOr = iota
+	precAnd
+	precNot
+)
+
+type labelExpr interface {
+	match(labels map[string]bool) bool
+	format(prec int) string
+}
+
+type labelName string
+
+func (n labelName) match(labels map[string]bool) bool { return labels[string(n)] }
+func (n labelName) format(int) string                 { return string(n) }
+
+type labelNot struct{ expr labelExpr }
+
+func (n labelNot) match(labels map[string]bool) bool { return !n.expr.match(labels) }
+func (n labelNot) format(int) string                 { return "!" + n.expr.format(precNot) }
+
+type labelAnd []labelExpr
+
+func (a labelAnd) match(labels map[string]bool) bool {
+	for _, expr := range a {
+		if !expr.match(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a labelAnd) format(prec int) string {
+	return formatBinary(a, " && ", precAnd, prec)
+}
+
+type labelOr []labelExpr
+
+func (o labelOr) match(labels map[string]bool) bool {
+	for _, expr := range o {
+		if expr.match(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o labelOr) format(prec int) string {
+	return formatBinary(o, " || ", precOr, prec)
+}
+
+func formatBinary(exprs []labelExpr, op string, own, prec int) string {
+	parts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		parts[i] = expr.format(own + 1)
+	}
+	s := strings.Join(parts, op)
+	if prec > own {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// labelParser 标签表达式的递归下降解析器：
+//
+//	or   = and { "||" and }
+//	and  = not { "&&" not }
+//	not  = "!" not | "(" or ")" | label
+type labelParser struct {
+	input  string
+	pos    int
+	tok    string // 当前记号，到达末尾时为空
+	tokPos int
+}
+
+func (p *labelParser) next() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+	p.tokPos = p.pos
+	if p.pos >= len(p.input) {
+		p.tok = ""
+		return
+	}
+
+	rest := p.input[p.pos:]
+	for _, op := range []string{"&&", "||", "!", "(", ")"} {
+		if strings.HasPrefix(rest, op) {
+			p.tok = op
+			p.pos += len(op)
+			return
+		}
+	}
+
+	end := strings.IndexAny(rest, " \t&|!()")
+	if end < 0 {
+		end = len(rest)
+	}
+	if end == 0 {
+		// 单个 & 或 |
+		end = 1
+	}
+	p.tok = rest[:end]
+	p.pos += end
+}
+
+func (p *labelParser) parseOr() (labelExpr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := labelOr{first}
+	for p.tok == "||" {
+		p.next()
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return first, nil
+	}
+	return exprs, nil
+}
+
+func (p *labelParser) parseAnd() (labelExpr, error) {
+	first, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	exprs := labelAnd{first}
+	for p.tok == "&&" {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return first, nil
+	}
+	return exprs, nil
+}
+
+func (p *labelParser) parseNot() (labelExpr, error) {
+	switch tok := p.tok; {
+	case tok == "":
+		return nil, errors.New("表达式不完整")
+	case tok == "!":
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return labelNot{expr}, nil
+	case tok == "(":
+		open := p.tokPos
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, fmt.Errorf("第%d个字符处的括号未闭合", open+1)
+		}
+		p.next()
+		return expr, nil
+	case LabelPattern.MatchString(tok):
+		p.next()
+		return labelName(tok), nil
+	default:
+		return nil, fmt.Errorf("第%d个字符处的 %q 不是有效的标签", p.tokPos+1, tok)
+	}
+}
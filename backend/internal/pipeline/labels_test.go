@@ -0,0 +1,109 @@
+package pipeline
+
+import "testing"
+
+func TestLabelSelectorMatches(t *testing.T) {
+	tests := []struct {
+		selector string
+		labels   []string
+		want     bool
+	}{
+		{selector: "linux", labels: []string{"linux", "docker"}, want: true},
+		{selector: "linux", labels: []string{"windows"}, want: false},
+		{selector: "linux && docker", labels: []string{"linux"}, want: false},
+		{selector: "linux || windows", labels: []string{"windows"}, want: true},
+		{selector: "!arm64", labels: []string{"amd64"}, want: true},
+		{selector: "!arm64", labels: []string{"arm64"}, want: false},
+		{selector: "linux && (docker || podman) && !arm64", labels: []string{"linux", "podman"}, want: true},
+		{selector: "linux && (docker || podman) && !arm64", labels: []string{"linux", "podman", "arm64"}, want: false},
+		{selector: "linux && (docker || podman) && !arm64", labels: []string{"linux"}, want: false},
+		{selector: "arch=arm64 || gpu.nvidia", labels: []string{"gpu.nvidia"}, want: true},
+		{selector: "a || b && c", labels: []string{"a"}, want: true},
+		{selector: "gpu", labels: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := ParseLabelSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("ParseLabelSelector(%q) error = %v", tt.selector, err)
+			}
+			if got := s.Matches(tt.labels); got != tt.want {
+				t.Errorf("Matches(%v) = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+
+	var none *LabelSelector
+	if !none.Matches(nil) {
+		t.Errorf("nil selector Matches() = false, want true")
+	}
+}
+
+func TestLabelSelectorString(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{selector: "linux&&docker", want: "linux && docker"},
+		{selector: "(a || b) && c", want: "(a || b) && c"},
+		{selector: "a || (b && c)", want: "a || b && c"},
+		{selector: "!(a || b)", want: "!(a || b)"},
+		{selector: "((linux))", want: "linux"},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseLabelSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("ParseLabelSelector(%q) error = %v", tt.selector, err)
+		}
+		if got := s.String(); got != tt.want {
+			t.Errorf("ParseLabelSelector(%q).String() = %q, want %q", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestParseLabelSelectorErrors(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{selector: "", want: "表达式不完整"},
+		{selector: "linux &&", want: "表达式不完整"},
+		{selector: "(linux || docker", want: "第1个字符处的括号未闭合"},
+		{selector: "linux docker", want: `第7个字符处有多余的 "docker"`},
+		{selector: "linux && & docker", want: `第10个字符处的 "&" 不是有效的标签`},
+		{selector: "linux)", want: `第6个字符处有多余的 ")"`},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseLabelSelector(tt.selector)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("ParseLabelSelector(%q) error = %v, want %q", tt.selector, err, tt.want)
+		}
+	}
+}
+
+func TestRunsOnConfig(t *testing.T) {
+	def, err := Parse(`runs_on: [linux, docker]
+stages:
+  - name: build
+    steps: [{name: a, run: make}]
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := def.RunsOn.String(); got != "linux && docker" {
+		t.Errorf("runs_on = %q, want %q", got, "linux && docker")
+	}
+
+	errs := parseErrors(t, `runs_on: "linux &&"
+stages:
+  - name: build
+    steps: [{name: a, run: make}]
+`)
+	e, ok := findError(errs, "无效的标签表达式")
+	if !ok || e.Field != "runs_on" || e.Line != 1 {
+		t.Errorf("errors = %v, want invalid runs_on at line 1", errs)
+	}
+}
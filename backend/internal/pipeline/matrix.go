@@ -0,0 +1,321 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxMatrixJobs 一个矩阵最多展开的作业数
+const maxMatrixJobs = 256
+
+// Matrix 作业矩阵，构建时按变量取值的组合将作业展开为多个作业。
+// 除 include、exclude、fail_fast 外的键都是矩阵变量，例如：
+//
+//	matrix:
+//	  go: ["1.21", "1.22"]
+//	  postgres: ["15", "16"]
+//	  exclude:
+//	    - {go: "1.21", postgres: "16"}
+//	  include:
+//	    - {go: "1.23", postgres: "16"}
+//	  fail_fast: false
+type Matrix struct {
+	Axes     []*MatrixAxis       `json:"axes"`
+	Include  []map[string]string `json:"include,omitempty"` // 额外加入的组合
+	Exclude  []map[string]string `json:"exclude,omitempty"` // 排除与之匹配的组合
+	FailFast *bool               `json:"fail_fast,omitempty"`
+
+	Pos    Position `json:"-"`
+	issues ValidationErrors
+}
+
+// MatrixAxis 矩阵变量及其取值
+type MatrixAxis struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// MatrixValue 矩阵变量在一个组合中的取值
+type MatrixValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MatrixValues 矩阵的一个组合，按变量在矩阵中出现的顺序排列
+type MatrixValues []MatrixValue
+
+// String 返回 "go=1.21, postgres=15" 形式的组合
+func (v MatrixValues) String() string {
+	parts := make([]string, len(v))
+	for i, mv := range v {
+		parts[i] = mv.Name + "=" + mv.Value
+	}
+	return strings.Join(parts, ", ")
+}
+
+// StopOnFailure 判断其中一个作业失败时是否终止同一矩阵的其他作业，未配置时终止
+func (m *Matrix) StopOnFailure() bool {
+	return m == nil || m.FailFast == nil || *m.FailFast
+}
+
+// Combinations 返回矩阵展开后的全部组合：先按变量顺序生成笛卡尔积并去掉被排除的组合，
+// 再依次加入 include 中尚不存在的组合
+func (m *Matrix) Combinations() []MatrixValues {
+	var combos []MatrixValues
+	if len(m.Axes) > 0 {
+		combos = []MatrixValues{{}}
+		for _, axis := range m.Axes {
+			next := make([]MatrixValues, 0, len(combos)*len(axis.Values))
+			for _, combo := range combos {
+				for _, value := range axis.Values {
+					c := append(append(MatrixValues{}, combo...), MatrixValue{Name: axis.Name, Value: value})
+					next = append(next, c)
+				}
+			}
+			combos = next
+		}
+	}
+
+	kept := combos[:0]
+	for _, combo := range combos {
+		excluded := false
+		for _, rule := range m.Exclude {
+			if combo.matches(rule) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, combo)
+		}
+	}
+	combos = kept
+
+	for _, extra := range m.Include {
+		combo := m.ordered(extra)
+		exists := false
+		for _, c := range combos {
+			if c.String() == combo.String() {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			combos = append(combos, combo)
+		}
+	}
+	return combos
+}
+
+// matches 判断组合是否包含 rule 中的全部取值
+func (v MatrixValues) matches(rule map[string]string) bool {
+	for name, value := range rule {
+		found := false
+		for _, mv := range v {
+			if mv.Name == name && mv.Value == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ordered 将 include 中的组合按矩阵变量的顺序排列，矩阵中没有的变量按名称排在最后
+func (m *Matrix) ordered(values map[string]string) MatrixValues {
+	var combo MatrixValues
+	seen := make(map[string]bool, len(values))
+	for _, axis := range m.Axes {
+		if value, ok := values[axis.Name]; ok {
+			combo = append(combo, MatrixValue{Name: axis.Name, Value: value})
+			seen[axis.Name] = true
+		}
+	}
+
+	var extra []string
+	for name := range values {
+		if !seen[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		combo = append(combo, MatrixValue{Name: name, Value: values[name]})
+	}
+	return combo
+}
+
+// expand 将作业按矩阵展开，作业名称后附加组合的取值，如 "test (1.21, 15)"
+func (j *Job) expand() []*Job {
+	if j.Matrix == nil {
+		return []*Job{j}
+	}
+
+	combos := j.Matrix.Combinations()
+	jobs := make([]*Job, 0, len(combos))
+	for _, combo := range combos {
+		values := make([]string, len(combo))
+		for i, mv := range combo {
+			values[i] = mv.Value
+		}
+
+		child := *j
+		child.Name = fmt.Sprintf("%s (%s)", j.Name, strings.Join(values, ", "))
+		child.MatrixOf = j.Name
+		child.MatrixValues = combo
+		jobs = append(jobs, &child)
+	}
+	return jobs
+}
+
+// UnmarshalYAML 解析矩阵并记录位置，保留变量的书写顺序
+func (m *Matrix) UnmarshalYAML(node *yaml.Node) error {
+	m.Pos = Position{Line: node.Line, Column: node.Column}
+	if node.Kind != yaml.MappingNode {
+		m.issues.add(m.Pos, "", "期望为映射类型")
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		pos := Position{Line: key.Line, Column: key.Column}
+
+		switch key.Value {
+		case "include":
+			m.Include = m.decodeCombinations(value, "include")
+		case "exclude":
+			m.Exclude = m.decodeCombinations(value, "exclude")
+		case "fail_fast":
+			var failFast bool
+			if err := value.Decode(&failFast); err != nil {
+				m.issues.add(pos, "", "fail_fast 期望为布尔值")
+				continue
+			}
+			m.FailFast = &failFast
+		default:
+			if value.Kind != yaml.SequenceNode {
+				m.issues.add(pos, "", "矩阵变量 %q 的取值应为列表", key.Value)
+				continue
+			}
+			axis := &MatrixAxis{Name: key.Value, Values: []string{}}
+			for _, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					m.issues.add(Position{Line: item.Line, Column: item.Column}, "", "矩阵变量 %q 的取值应为字符串或数字", key.Value)
+					continue
+				}
+				axis.Values = append(axis.Values, item.Value)
+			}
+			m.Axes = append(m.Axes, axis)
+		}
+	}
+	return nil
+}
+
+// decodeCombinations 解析 include、exclude 中的组合列表
+func (m *Matrix) decodeCombinations(node *yaml.Node, field string) []map[string]string {
+	if node.Kind != yaml.SequenceNode {
+		m.issues.add(Position{Line: node.Line, Column: node.Column}, "", "%s 期望为组合列表", field)
+		return nil
+	}
+
+	var combos []map[string]string
+	for _, item := range node.Content {
+		pos := Position{Line: item.Line, Column: item.Column}
+		if item.Kind != yaml.MappingNode {
+			m.issues.add(pos, "", "%s 中的组合应为变量到取值的映射", field)
+			continue
+		}
+		combo := make(map[string]string, len(item.Content)/2)
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			k, v := item.Content[i], item.Content[i+1]
+			if v.Kind != yaml.ScalarNode {
+				m.issues.add(pos, "", "矩阵变量 %q 的取值应为字符串或数字", k.Value)
+				continue
+			}
+			combo[k.Value] = v.Value
+		}
+		combos = append(combos, combo)
+	}
+	return combos
+}
+
+func (m *Matrix) validate(errs *ValidationErrors, field string) {
+	*errs = append(*errs, m.issues...)
+
+	axes := make(map[string]bool, len(m.Axes))
+	for _, axis := range m.Axes {
+		axes[axis.Name] = true
+		if !envNamePattern.MatchString(axis.Name) {
+			errs.add(m.Pos, field+"."+axis.Name, "无效的矩阵变量名 %q，只能包含字母、数字和下划线", axis.Name)
+		}
+		if len(axis.Values) == 0 {
+			errs.add(m.Pos, field+"."+axis.Name, "矩阵变量 %q 至少需要一个取值", axis.Name)
+		}
+		seen := make(map[string]bool, len(axis.Values))
+		for _, value := range axis.Values {
+			if seen[value] {
+				errs.add(m.Pos, field+"."+axis.Name, "矩阵变量 %q 的取值 %q 重复", axis.Name, value)
+			}
+			seen[value] = true
+		}
+	}
+
+	for i, combo := range m.Include {
+		for _, name := range sortedKeys(combo) {
+			if !envNamePattern.MatchString(name) {
+				errs.add(m.Pos, fmt.Sprintf("%s.include[%d]", field, i), "无效的矩阵变量名 %q，只能包含字母、数字和下划线", name)
+			}
+		}
+		if len(combo) == 0 {
+			errs.add(m.Pos, fmt.Sprintf("%s.include[%d]", field, i), "组合不能为空")
+		}
+	}
+	for i, combo := range m.Exclude {
+		for _, name := range sortedKeys(combo) {
+			if !axes[name] {
+				errs.add(m.Pos, fmt.Sprintf("%s.exclude[%d]", field, i), "排除的组合引用了不存在的矩阵变量 %q", name)
+			}
+		}
+		if len(combo) == 0 {
+			errs.add(m.Pos, fmt.Sprintf("%s.exclude[%d]", field, i), "组合不能为空")
+		}
+	}
+
+	if len(m.Axes) == 0 && len(m.Include) == 0 {
+		errs.add(m.Pos, field, "矩阵至少需要一个变量或 include 组合")
+		return
+	}
+
+	total := 1
+	for _, axis := range m.Axes {
+		if len(axis.Values) == 0 {
+			// 已报告缺少取值
+			return
+		}
+		total *= len(axis.Values)
+		if total > maxMatrixJobs {
+			break
+		}
+	}
+	if total+len(m.Include) > maxMatrixJobs {
+		errs.add(m.Pos, field, "矩阵展开后的作业数不能超过%d", maxMatrixJobs)
+	} else if len(m.Combinations()) == 0 {
+		errs.add(m.Pos, field, "矩阵展开后没有作业，所有组合都被排除")
+	}
+}
+
+// sortedKeys 返回按名称排序的变量名，使错误信息的顺序稳定
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
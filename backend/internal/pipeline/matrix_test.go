@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+// jobNames 返回按矩阵展开后的作业名称
+func jobNames(def *Definition) []string {
+	var names []string
+	for _, job := range def.Jobs() {
+		names = append(names, job.Name)
+	}
+	return names
+}
+
+func TestMatrixExpansion(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix string
+		want   []string
+	}{
+		{
+			name: "cartesian product in declaration order",
+			matrix: `
+          go: ["1.21", "1.22"]
+          postgres: ["15", "16"]`,
+			want: []string{"test (1.21, 15)", "test (1.21, 16)", "test (1.22, 15)", "test (1.22, 16)"},
+		},
+		{
+			name: "exclude partial combination",
+			matrix: `
+          go: ["1.21", "1.22"]
+          postgres: ["15", "16"]
+          exclude:
+            - {go: "1.21", postgres: "16"}`,
+			want: []string{"test (1.21, 15)", "test (1.22, 15)", "test (1.22, 16)"},
+		},
+		{
+			name: "exclude by one variable",
+			matrix: `
+          go: ["1.21", "1.22"]
+          postgres: ["15", "16"]
+          exclude:
+            - {go: "1.21"}`,
+			want: []string{"test (1.22, 15)", "test (1.22, 16)"},
+		},
+		{
+			name: "include new and existing combinations",
+			matrix: `
+          go: ["1.21"]
+          postgres: ["15"]
+          include:
+            - {postgres: "16", go: "1.23"}
+            - {go: "1.21", postgres: "15"}`,
+			want: []string{"test (1.21, 15)", "test (1.23, 16)"},
+		},
+		{
+			name: "include only",
+			matrix: `
+          include:
+            - {os: linux}
+            - {os: windows}`,
+			want: []string{"test (linux)", "test (windows)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, err := Parse(`stages:
+  - name: ci
+    jobs:
+      - name: test
+        matrix:` + tt.matrix + `
+        steps: [{name: a, run: make}]
+`)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := jobNames(def); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jobs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatrixValuesAndNeeds(t *testing.T) {
+	def, err := Parse(`stages:
+  - name: ci
+    jobs:
+      - name: test
+        matrix:
+          go: ["1.21", "1.22"]
+        steps: [{name: a, run: make}]
+      - name: report
+        needs: [test]
+        steps: [{name: a, run: make}]
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	jobs := def.Jobs()
+	if len(jobs) != 3 {
+		t.Fatalf("len(jobs) = %d, want 3", len(jobs))
+	}
+	if jobs[0].MatrixOf != "test" || jobs[0].MatrixValues.String() != "go=1.21" {
+		t.Errorf("first job = %q of %q with %q", jobs[0].Name, jobs[0].MatrixOf, jobs[0].MatrixValues)
+	}
+	want := []string{"test (1.21)", "test (1.22)"}
+	if !reflect.DeepEqual(jobs[2].DependsOn, want) {
+		t.Errorf("report depends on %v, want %v", jobs[2].DependsOn, want)
+	}
+}
+
+func TestMatrixFailFast(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix string
+		want   bool
+	}{
+		{name: "default", matrix: `{go: ["1.21"]}`, want: true},
+		{name: "enabled", matrix: `{go: ["1.21"], fail_fast: true}`, want: true},
+		{name: "disabled", matrix: `{go: ["1.21"], fail_fast: false}`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, err := Parse(`stages:
+  - name: ci
+    jobs:
+      - name: test
+        matrix: ` + tt.matrix + `
+        steps: [{name: a, run: make}]
+`)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := def.Stages[0].Jobs[0].Matrix.StopOnFailure(); got != tt.want {
+				t.Errorf("StopOnFailure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var none *Matrix
+	if !none.StopOnFailure() {
+		t.Errorf("StopOnFailure() without matrix = false, want true")
+	}
+}
+
+func TestMatrixValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix string
+		msg    string
+	}{
+		{name: "empty axis", matrix: `{go: []}`, msg: `矩阵变量 "go" 至少需要一个取值`},
+		{name: "duplicate value", matrix: `{go: ["1.21", "1.21"]}`, msg: `矩阵变量 "go" 的取值 "1.21" 重复`},
+		{name: "invalid name", matrix: `{go-version: ["1.21"]}`, msg: `无效的矩阵变量名 "go-version"`},
+		{name: "axis not a list", matrix: `{go: "1.21"}`, msg: `矩阵变量 "go" 的取值应为列表`},
+		{name: "exclude unknown variable", matrix: `{go: ["1.21"], exclude: [{os: linux}]}`, msg: `引用了不存在的矩阵变量 "os"`},
+		{name: "everything excluded", matrix: `{go: ["1.21"], exclude: [{go: "1.21"}]}`, msg: "所有组合都被排除"},
+		{name: "fail_fast not bool", matrix: `{go: ["1.21"], fail_fast: sometimes}`, msg: "fail_fast 期望为布尔值"},
+		{name: "no variables", matrix: `{fail_fast: false}`, msg: "矩阵至少需要一个变量或 include 组合"},
+		{
+			name:   "too many jobs",
+			matrix: `{a: [1,2,3,4,5,6,7,8], b: [1,2,3,4,5,6,7,8], c: [1,2,3,4,5]}`,
+			msg:    "矩阵展开后的作业数不能超过256",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := parseErrors(t, `stages:
+  - name: ci
+    jobs:
+      - name: test
+        matrix: `+tt.matrix+`
+        steps: [{name: a, run: make}]
+`)
+			if _, ok := findError(errs, tt.msg); !ok {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 参数类型
+const (
+	ParamTypeString = "string"
+	ParamTypeBool   = "bool"
+	ParamTypeChoice = "choice"
+)
+
+// maxParamValueLength 参数取值的最大长度
+const maxParamValueLength = 1000
+
+// Parameter 触发构建时可以指定的参数，步骤中以环境变量 PARAM_<NAME> 读取，
+// 条件表达式中以 params.NAME 读取，bool 参数的取值为字符串 true 或 false，应写作 params.NAME == true。例如：
+//
+//	parameters:
+//	  - name: environment
+//	    type: choice
+//	    options: [staging, production]
+//	    default: staging
+//	  - name: dry_run
+//	    type: bool
+//	    default: true
+type Parameter struct {
+	Name        string   `yaml:"name" json:"name"`
+	Type        string   `yaml:"type" json:"type"`                         // string、bool 或 choice，默认为 string
+	Description string   `yaml:"description" json:"description,omitempty"` // 参数说明
+	Default     *string  `yaml:"default" json:"default,omitempty"`         // 默认值，未配置时触发构建必须指定该参数
+	Options     []string `yaml:"options" json:"options,omitempty"`         // choice 参数的可选值
+	Required    bool     `yaml:"required" json:"required,omitempty"`       // string 参数的取值不能为空
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// TypeOr 返回参数类型，未配置时为 string
+func (p *Parameter) TypeOr() string {
+	if p.Type == "" {
+		return ParamTypeString
+	}
+	return p.Type
+}
+
+// ParamEnvName 返回步骤中读取参数的环境变量名
+func ParamEnvName(name string) string {
+	return "PARAM_" + strings.ToUpper(name)
+}
+
+// check 校验参数的取值，返回规范化后的取值
+func (p *Parameter) check(value string) (string, error) {
+	switch p.TypeOr() {
+	case ParamTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("参数 %s 的取值应为 true 或 false", p.Name)
+		}
+		return strconv.FormatBool(b), nil
+	case ParamTypeChoice:
+		for _, option := range p.Options {
+			if value == option {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("参数 %s 的取值应为 %s 之一", p.Name, strings.Join(p.Options, ", "))
+	default:
+		if p.Required && strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("参数 %s 不能为空", p.Name)
+		}
+		if len(value) > maxParamValueLength {
+			return "", fmt.Errorf("参数 %s 的取值不能超过%d个字符", p.Name, maxParamValueLength)
+		}
+		return value, nil
+	}
+}
+
+// ResolveParameters 校验触发构建时指定的参数并补全默认值，返回按声明顺序排列的 name=value。
+// 取值可以是字符串、布尔值或数字
+func (d *Definition) ResolveParameters(values map[string]interface{}) ([]string, error) {
+	declared := make(map[string]bool, len(d.Parameters))
+	for _, p := range d.Parameters {
+		declared[p.Name] = true
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !declared[name] {
+			return nil, fmt.Errorf("流水线没有声明参数 %s", name)
+		}
+	}
+
+	resolved := make([]string, 0, len(d.Parameters))
+	for _, p := range d.Parameters {
+		var value string
+		switch v := values[p.Name].(type) {
+		case nil:
+			if p.Default == nil {
+				return nil, fmt.Errorf("缺少参数 %s", p.Name)
+			}
+			value = *p.Default
+		case string:
+			value = v
+		case bool:
+			value = strconv.FormatBool(v)
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("参数 %s 的取值应为字符串、布尔值或数字", p.Name)
+		}
+
+		value, err := p.check(value)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, p.Name+"="+value)
+	}
+	return resolved, nil
+}
+
+// SplitParameter 将 name=value 形式的参数拆分为名称和取值
+func SplitParameter(param string) (string, string) {
+	name, value, _ := strings.Cut(param, "=")
+	return name, value
+}
+
+func (p *Parameter) validate(errs *ValidationErrors, field string, names map[string]bool) {
+	*errs = append(*errs, p.issues...)
+	if !envNamePattern.MatchString(p.Name) {
+		errs.add(p.Pos, field+".name", "无效的参数名 %q，只能包含字母、数字和下划线", p.Name)
+	} else if names[ParamEnvName(p.Name)] {
+		// 参数名不区分大小写，否则环境变量名会冲突
+		errs.add(p.Pos, field+".name", "参数名 %q 重复", p.Name)
+	}
+	names[ParamEnvName(p.Name)] = true
+	if len(p.Description) > 500 {
+		errs.add(p.Pos, field+".description", "参数说明不能超过500个字符")
+	}
+
+	switch p.TypeOr() {
+	case ParamTypeString, ParamTypeBool:
+		if len(p.Options) > 0 {
+			errs.add(p.Pos, field+".options", "只有 choice 参数可以配置可选值")
+		}
+	case ParamTypeChoice:
+		if len(p.Options) == 0 {
+			errs.add(p.Pos, field+".options", "choice 参数至少需要一个可选值")
+		}
+		seen := make(map[string]bool, len(p.Options))
+		for i, option := range p.Options {
+			if seen[option] {
+				errs.add(p.Pos, fmt.Sprintf("%s.options[%d]", field, i), "可选值 %q 重复", option)
+			}
+			seen[option] = true
+		}
+	default:
+		errs.add(p.Pos, field+".type", "未知的参数类型 %q，可选 string、bool、choice", p.Type)
+		return
+	}
+	if p.Required && p.TypeOr() != ParamTypeString {
+		errs.add(p.Pos, field+".required", "只有 string 参数可以配置 required")
+	}
+
+	// choice 参数没有可选值时已报告错误，不再校验默认值
+	if p.Default != nil && (p.TypeOr() != ParamTypeChoice || len(p.Options) > 0) {
+		if _, err := p.check(*p.Default); err != nil {
+			errs.add(p.Pos, field+".default", "默认值无效: %v", err)
+		}
+	}
+}
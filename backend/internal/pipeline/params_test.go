@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+const paramsConfig = `parameters:
+  - name: environment
+    type: choice
+    options: [staging, production]
+    default: staging
+  - name: dry_run
+    type: bool
+    default: "true"
+  - name: version
+    required: true
+stages:
+  - name: deploy
+    steps: [{name: a, run: ./deploy.sh}]
+`
+
+func TestResolveParameters(t *testing.T) {
+	def, err := Parse(paramsConfig)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			values: map[string]interface{}{"version": "1.2.0"},
+			want:   []string{"environment=staging", "dry_run=true", "version=1.2.0"},
+		},
+		{
+			name:   "typed values",
+			values: map[string]interface{}{"environment": "production", "dry_run": false, "version": float64(2)},
+			want:   []string{"environment=production", "dry_run=false", "version=2"},
+		},
+		{
+			name:   "bool from string",
+			values: map[string]interface{}{"dry_run": "1", "version": "x"},
+			want:   []string{"environment=staging", "dry_run=true", "version=x"},
+		},
+		{
+			name:    "missing required",
+			values:  map[string]interface{}{},
+			wantErr: "缺少参数 version",
+		},
+		{
+			name:    "empty required string",
+			values:  map[string]interface{}{"version": "  "},
+			wantErr: "参数 version 不能为空",
+		},
+		{
+			name:    "invalid choice",
+			values:  map[string]interface{}{"environment": "prod", "version": "1"},
+			wantErr: "参数 environment 的取值应为 staging, production 之一",
+		},
+		{
+			name:    "invalid bool",
+			values:  map[string]interface{}{"dry_run": "maybe", "version": "1"},
+			wantErr: "参数 dry_run 的取值应为 true 或 false",
+		},
+		{
+			name:    "undeclared",
+			values:  map[string]interface{}{"version": "1", "region": "eu"},
+			wantErr: "流水线没有声明参数 region",
+		},
+		{
+			name:    "unsupported type",
+			values:  map[string]interface{}{"version": []interface{}{"1"}},
+			wantErr: "参数 version 的取值应为字符串、布尔值或数字",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := def.ResolveParameters(tt.values)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ResolveParameters() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveParameters() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveParameters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParameterValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		param string
+		msg   string
+	}{
+		{name: "unknown type", param: "{name: a, type: int}", msg: `未知的参数类型 "int"`},
+		{name: "invalid name", param: "{name: my-param}", msg: `无效的参数名 "my-param"`},
+		{name: "choice without options", param: "{name: a, type: choice}", msg: "choice 参数至少需要一个可选值"},
+		{name: "duplicate option", param: "{name: a, type: choice, options: [x, x]}", msg: `可选值 "x" 重复`},
+		{name: "options on string", param: "{name: a, options: [x]}", msg: "只有 choice 参数可以配置可选值"},
+		{name: "required on bool", param: "{name: a, type: bool, required: true}", msg: "只有 string 参数可以配置 required"},
+		{name: "invalid choice default", param: "{name: a, type: choice, options: [x], default: y}", msg: "默认值无效: 参数 a 的取值应为 x 之一"},
+		{name: "invalid bool default", param: "{name: a, type: bool, default: maybe}", msg: "默认值无效: 参数 a 的取值应为 true 或 false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := parseErrors(t, "parameters:\n  - "+tt.param+`
+stages:
+  - name: deploy
+    steps: [{name: a, run: ./deploy.sh}]
+`)
+			if _, ok := findError(errs, tt.msg); !ok {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.msg)
+			}
+		})
+	}
+
+	errs := parseErrors(t, `parameters:
+  - name: env
+  - name: ENV
+stages:
+  - name: deploy
+    steps: [{name: a, run: ./deploy.sh}]
+`)
+	if _, ok := findError(errs, `参数名 "ENV" 重复`); !ok {
+		t.Errorf("errors = %v, want case-insensitive duplicate name", errs)
+	}
+}
+
+func TestParamHelpers(t *testing.T) {
+	if got := ParamEnvName("dry_run"); got != "PARAM_DRY_RUN" {
+		t.Errorf("ParamEnvName() = %q", got)
+	}
+	name, value := SplitParameter("url=https://example.com/?a=b")
+	if name != "url" || value != "https://example.com/?a=b" {
+		t.Errorf("SplitParameter() = %q, %q", name, value)
+	}
+}
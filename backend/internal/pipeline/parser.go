@@ -0,0 +1,208 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yaml错误信息中的位置前缀，例如 "yaml: line 3: ..." 或 "line 3, column 5: ..."
+var linePrefix = regexp.MustCompile(`^(?:yaml: )?line (\d+)(?:, column (\d+))?: (.*)$`)
+
+// Parse 解析流水线YAML配置并进行校验，出错时返回 ValidationErrors
+func Parse(config string) (*Definition, error) {
+	def := &Definition{}
+	var errs ValidationErrors
+
+	if err := yaml.Unmarshal([]byte(config), def); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			// 语法错误，无法继续校验
+			return nil, ValidationErrors{parseProblem(err.Error())}
+		}
+		for _, msg := range typeErr.Errors {
+			errs = append(errs, parseProblem(msg))
+		}
+	}
+
+	errs = append(errs, def.Validate()...)
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			if errs[i].Line != errs[j].Line {
+				return errs[i].Line < errs[j].Line
+			}
+			return errs[i].Column < errs[j].Column
+		})
+		return nil, errs
+	}
+
+	return def, nil
+}
+
+// UnmarshalYAML 解析流水线定义并记录位置
+func (d *Definition) UnmarshalYAML(node *yaml.Node) error {
+	type plain Definition
+	return decodeMapping(node, (*plain)(d), &d.Pos, &d.issues)
+}
+
+// UnmarshalYAML 解析阶段并记录位置
+func (s *Stage) UnmarshalYAML(node *yaml.Node) error {
+	type plain Stage
+	return decodeMapping(node, (*plain)(s), &s.Pos, &s.issues)
+}
+
+// UnmarshalYAML 解析作业并记录位置
+func (j *Job) UnmarshalYAML(node *yaml.Node) error {
+	type plain Job
+	return decodeMapping(node, (*plain)(j), &j.Pos, &j.issues)
+}
+
+// UnmarshalYAML 解析制品配置并记录位置
+func (a *Artifacts) UnmarshalYAML(node *yaml.Node) error {
+	type plain Artifacts
+	return decodeMapping(node, (*plain)(a), &a.Pos, &a.issues)
+}
+
+// UnmarshalYAML 解析缓存配置并记录位置
+func (c *Cache) UnmarshalYAML(node *yaml.Node) error {
+	type plain Cache
+	return decodeMapping(node, (*plain)(c), &c.Pos, &c.issues)
+}
+
+// UnmarshalYAML 解析参数并记录位置
+func (p *Parameter) UnmarshalYAML(node *yaml.Node) error {
+	type plain Parameter
+	return decodeMapping(node, (*plain)(p), &p.Pos, &p.issues)
+}
+
+// UnmarshalYAML 解析步骤并记录位置
+func (s *Step) UnmarshalYAML(node *yaml.Node) error {
+	type plain Step
+	return decodeMapping(node, (*plain)(s), &s.Pos, &s.issues)
+}
+
+// UnmarshalYAML 解析重试策略并记录位置
+func (r *Retry) UnmarshalYAML(node *yaml.Node) error {
+	type plain Retry
+	return decodeMapping(node, (*plain)(r), &r.Pos, &r.issues)
+}
+
+// UnmarshalYAML 解析代码检出配置并记录位置
+func (c *Checkout) UnmarshalYAML(node *yaml.Node) error {
+	type plain Checkout
+	return decodeMapping(node, (*plain)(c), &c.Pos, &c.issues)
+}
+
+// UnmarshalYAML 解析触发规则并记录位置
+func (t *Triggers) UnmarshalYAML(node *yaml.Node) error {
+	type plain Triggers
+	return decodeMapping(node, (*plain)(t), &t.Pos, &t.issues)
+}
+
+// UnmarshalYAML 解析执行条件并记录位置
+func (c *Condition) UnmarshalYAML(node *yaml.Node) error {
+	type plain Condition
+	return decodeMapping(node, (*plain)(c), &c.Pos, &c.issues)
+}
+
+// UnmarshalYAML 解析时长并记录位置
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	d.Pos = Position{Line: node.Line, Column: node.Column}
+	if node.Kind != yaml.ScalarNode {
+		d.issues.add(d.Pos, "", "期望为时长，例如 \"30s\"、\"10m\"、\"1h\"")
+		return nil
+	}
+
+	v, err := parseDuration(node.Value)
+	if err != nil {
+		d.issues.add(d.Pos, "", "无效的时长 %q，例如 \"30s\"、\"10m\"、\"1h\"、\"7d\"", node.Value)
+		return nil
+	}
+	d.Duration = v
+	return nil
+}
+
+// parseDuration 在 time.ParseDuration 的基础上支持以天为单位的开头部分，如 "7d"、"1d12h"
+func parseDuration(s string) (time.Duration, error) {
+	days, rest, ok := strings.Cut(s, "d")
+	if !ok {
+		return time.ParseDuration(s)
+	}
+
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	d := time.Duration(n) * 24 * time.Hour
+	if rest != "" {
+		r, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, err
+		}
+		d += r
+	}
+	return d, nil
+}
+
+// decodeMapping 将映射节点解码到结构体，拒绝未知字段。
+// 发现的问题记录到 issues 而不是作为错误返回，否则yaml会丢弃出错的节点，
+// 导致其内部的其他问题无法被报告。
+func decodeMapping(node *yaml.Node, out interface{}, pos *Position, issues *ValidationErrors) error {
+	pos.Line, pos.Column = node.Line, node.Column
+	if node.Kind != yaml.MappingNode {
+		issues.add(*pos, "", "期望为映射类型")
+		return nil
+	}
+
+	known := yamlFields(reflect.TypeOf(out).Elem())
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !known[key.Value] {
+			issues.add(Position{Line: key.Line, Column: key.Column}, "", "未知字段 %q", key.Value)
+		}
+	}
+
+	if err := node.Decode(out); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return err
+		}
+		for _, msg := range typeErr.Errors {
+			*issues = append(*issues, parseProblem(msg))
+		}
+	}
+
+	return nil
+}
+
+// yamlFields 返回结构体允许出现的yaml字段名
+func yamlFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("yaml")
+		name := strings.Split(tag, ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+// parseProblem 将yaml错误信息转换为带位置的 ValidationError
+func parseProblem(msg string) ValidationError {
+	m := linePrefix.FindStringSubmatch(msg)
+	if m == nil {
+		return ValidationError{Message: strings.TrimPrefix(msg, "yaml: ")}
+	}
+	line, _ := strconv.Atoi(m[1])
+	column, _ := strconv.Atoi(m[2])
+	return ValidationError{Line: line, Column: column, Message: m[3]}
+}
@@ -0,0 +1,276 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseErrors 解析配置并返回校验错误，配置有效时测试失败
+func parseErrors(t *testing.T, config string) ValidationErrors {
+	t.Helper()
+	_, err := Parse(config)
+	if err == nil {
+		t.Fatalf("Parse() succeeded, want validation errors")
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Parse() error = %T, want ValidationErrors", err)
+	}
+	return errs
+}
+
+// findError 返回消息包含 msg 的错误
+func findError(errs ValidationErrors, msg string) (ValidationError, bool) {
+	for _, e := range errs {
+		if strings.Contains(e.Message, msg) {
+			return e, true
+		}
+	}
+	return ValidationError{}, false
+}
+
+func TestParse(t *testing.T) {
+	config := `
+name: ci
+image: golang:1.22
+env:
+  GOFLAGS: -mod=readonly
+stages:
+  - name: test
+    steps:
+      - name: unit
+        run: go test ./...
+  - name: deploy
+    steps:
+      - name: push
+        image: alpine:3.19
+        run: ./deploy.sh
+        when:
+          branches: [main, release/*]
+`
+	def, err := Parse(config)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if def.Name != "ci" || def.Image != "golang:1.22" || def.Env["GOFLAGS"] != "-mod=readonly" {
+		t.Errorf("Parse() top-level fields = %q, %q, %v", def.Name, def.Image, def.Env)
+	}
+	if len(def.Stages) != 2 {
+		t.Fatalf("len(stages) = %d, want 2", len(def.Stages))
+	}
+
+	unit := def.Stages[0].Steps[0]
+	if unit.Pos != (Position{Line: 9, Column: 9}) {
+		t.Errorf("step position = %+v, want line 9, column 9", unit.Pos)
+	}
+
+	push := def.Stages[1].Steps[0]
+	if push.ImageOr(def.Image) != "alpine:3.19" || unit.ImageOr(def.Image) != "golang:1.22" {
+		t.Errorf("step images = %q, %q", push.ImageOr(def.Image), unit.ImageOr(def.Image))
+	}
+	if !push.When.Matches("release/1.0") || push.When.Matches("feature/x") {
+		t.Errorf("when.branches matching is wrong")
+	}
+}
+
+func TestParseTimeouts(t *testing.T) {
+	def, err := Parse(`timeout: 1h
+stages:
+  - name: test
+    steps:
+      - name: unit
+        run: go test ./...
+        timeout: 10m
+      - name: lint
+        run: make lint
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if def.Timeout.Duration != time.Hour {
+		t.Errorf("timeout = %v, want 1h", def.Timeout.Duration)
+	}
+	steps := def.Stages[0].Steps
+	if steps[0].Timeout.Duration != 10*time.Minute {
+		t.Errorf("step timeout = %v, want 10m", steps[0].Timeout.Duration)
+	}
+	if steps[1].Timeout != nil {
+		t.Errorf("step without timeout = %v, want nil", steps[1].Timeout)
+	}
+}
+
+func TestParseRetry(t *testing.T) {
+	def, err := Parse(`stages:
+  - name: test
+    steps:
+      - name: unit
+        run: go test ./...
+        retry:
+          max_attempts: 3
+          backoff: 5s
+          on_exit_codes: [2]
+      - name: lint
+        run: make lint
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	unit := def.Stages[0].Steps[0]
+	if unit.Retry.Attempts() != 3 || !unit.Retry.ShouldRetry(2) || unit.Retry.ShouldRetry(1) {
+		t.Errorf("retry = %+v", unit.Retry)
+	}
+	if got := unit.Retry.Delay(3); got != 20*time.Second {
+		t.Errorf("retry delay after attempt 3 = %v, want 20s", got)
+	}
+	if lint := def.Stages[0].Steps[1]; lint.Retry.Attempts() != 1 {
+		t.Errorf("attempts without retry = %d, want 1", lint.Retry.Attempts())
+	}
+}
+
+func TestParseCheckout(t *testing.T) {
+	tests := []struct {
+		name     string
+		checkout string
+		enabled  bool
+		depth    int
+	}{
+		{name: "default", checkout: "", enabled: true, depth: defaultCheckoutDepth},
+		{name: "full history", checkout: "checkout:\n  depth: 0\n", enabled: true, depth: 0},
+		{name: "skipped", checkout: "checkout:\n  skip: true\n", enabled: false, depth: defaultCheckoutDepth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, err := Parse(tt.checkout + "stages:\n  - name: build\n    steps: [{name: a, run: make}]\n")
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if def.Checkout.Enabled() != tt.enabled || def.Checkout.CloneDepth() != tt.depth {
+				t.Errorf("checkout enabled = %v, depth = %d, want %v, %d",
+					def.Checkout.Enabled(), def.Checkout.CloneDepth(), tt.enabled, tt.depth)
+			}
+		})
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		msg    string
+		line   int
+		column int
+	}{
+		{
+			name:   "syntax error",
+			config: "stages: [\n",
+			msg:    "did not find expected node content",
+			line:   1,
+		},
+		{
+			name: "unknown field",
+			config: `stages:
+  - name: build
+    steps:
+      - name: compile
+        run: make
+        comand: make install
+`,
+			msg:    `未知字段 "comand"`,
+			line:   6,
+			column: 9,
+		},
+		{
+			name: "unknown top-level field",
+			config: `stagez: []
+stages:
+  - name: build
+    steps: [{name: compile, run: make}]
+`,
+			msg:    `未知字段 "stagez"`,
+			line:   1,
+			column: 1,
+		},
+		{
+			name: "invalid duration",
+			config: `stages:
+  - name: build
+    steps:
+      - name: compile
+        run: make
+        timeout: 3q
+`,
+			msg:    `无效的时长 "3q"`,
+			line:   6,
+			column: 18,
+		},
+		{
+			name: "wrong type",
+			config: `stages:
+  - name: build
+    steps:
+      - name: compile
+        run: make
+        retry:
+          max_attempts: many
+`,
+			msg:  "cannot unmarshal",
+			line: 7,
+		},
+		{
+			name: "missing run",
+			config: `stages:
+  - name: build
+    steps:
+      - name: compile
+`,
+			msg:    "步骤缺少要执行的命令",
+			line:   4,
+			column: 9,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := parseErrors(t, tt.config)
+			e, ok := findError(errs, tt.msg)
+			if !ok {
+				t.Fatalf("errors = %v, want one containing %q", errs, tt.msg)
+			}
+			if e.Line != tt.line || (tt.column != 0 && e.Column != tt.column) {
+				t.Errorf("error position = line %d, column %d, want line %d, column %d", e.Line, e.Column, tt.line, tt.column)
+			}
+		})
+	}
+}
+
+func TestParseReportsAllErrorsInOrder(t *testing.T) {
+	errs := parseErrors(t, `stages:
+  - name: build
+    steps:
+      - name: compile
+        run: make
+        image: "Not An Image"
+      - name: compile
+        run: make
+        env:
+          1BAD: x
+`)
+
+	if len(errs) != 3 {
+		t.Fatalf("len(errors) = %d, want 3: %v", len(errs), errs)
+	}
+	for i := 1; i < len(errs); i++ {
+		if errs[i].Line < errs[i-1].Line {
+			t.Errorf("errors are not sorted by line: %v", errs)
+		}
+	}
+	if !strings.HasPrefix(errs.Error(), "invalid pipeline config: line ") {
+		t.Errorf("Error() = %q", errs.Error())
+	}
+}
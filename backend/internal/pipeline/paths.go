@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MatchFile 判断工作目录中以 / 分隔的相对路径 name 是否与路径 pattern 匹配。
+// pattern 支持 *、?、[...] 通配符和匹配任意层目录的 **
+func MatchFile(pattern, name string) bool {
+	return matchSegments(splitPattern(pattern), strings.Split(name, "/"))
+}
+
+// matchPaths 判断工作目录中以 / 分隔的相对路径 name 是否与某个路径匹配，
+// 路径本身或其所在的任意一级目录匹配时都视为匹配
+func matchPaths(patterns []string, name string) bool {
+	segments := strings.Split(name, "/")
+	for _, pattern := range patterns {
+		patternSegments := splitPattern(pattern)
+		for i := 1; i <= len(segments); i++ {
+			if matchSegments(patternSegments, segments[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func splitPattern(pattern string) []string {
+	return strings.Split(strings.Trim(path.Clean(pattern), "/"), "/")
+}
+
+// matchSegments 逐级匹配路径，** 匹配零或多级目录，其余各级按 path.Match 匹配
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// validatePaths 校验相对于工作目录的路径，noun 为错误信息中路径的名称，如 "制品路径"
+func validatePaths(errs *ValidationErrors, pos Position, field string, paths []string, noun string) {
+	for i, pattern := range paths {
+		pathField := fmt.Sprintf("%s[%d]", field, i)
+		switch {
+		case strings.TrimSpace(pattern) == "":
+			errs.add(pos, pathField, "%s不能为空", noun)
+		case path.IsAbs(pattern) || strings.Contains(pattern, "\\"):
+			errs.add(pos, pathField, "%s %q 必须是相对于工作目录的路径", noun, pattern)
+		case path.Clean(pattern) == ".":
+			errs.add(pos, pathField, "%s不能是工作目录本身，匹配全部文件请使用 **", noun)
+		case path.Clean(pattern) == ".." || strings.HasPrefix(path.Clean(pattern), "../"):
+			errs.add(pos, pathField, "%s %q 不能指向工作目录之外", noun, pattern)
+		default:
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs.add(pos, pathField, "无效的%s %q", noun, pattern)
+			}
+		}
+	}
+}
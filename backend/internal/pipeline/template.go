@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template 包含表达式的字符串模板，{{ }} 中的表达式求值后替换为字符串，例如：
+//
+//	go-{{ matrix.go }}-{{ hashFiles "go.sum" }}
+//
+// 表达式的语法与变量同 Expression，此外可以调用 hashFiles，参数可以用空格分隔，如 hashFiles "go.sum" "go.mod"，
+// 也可以写作 hashFiles("go.sum", "go.mod")。hashFiles 返回工作目录中与路径匹配的全部文件内容的SHA-256，
+// 路径支持 * 和 ** 通配符，没有匹配的文件时为空字符串
+type Template struct {
+	source string
+	parts  []templatePart
+
+	Pos    Position `yaml:"-" json:"-"`
+	issues ValidationErrors
+}
+
+// templatePart 模板的一段，expr 为 nil 时为原样输出的文本
+type templatePart struct {
+	text string
+	expr exprNode
+}
+
+// ParseTemplate 解析模板
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{source: s}
+	rest := s
+	for rest != "" {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			t.parts = append(t.parts, templatePart{text: rest})
+			break
+		}
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{text: rest[:start]})
+		}
+
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("第%d个字符处的 {{ 缺少结束的 }}", len(s)-len(rest)+start+1)
+		}
+		source := rest[start+2 : start+end]
+		p := &exprParser{input: source, template: true}
+		expr, err := p.parse()
+		if err != nil {
+			return nil, fmt.Errorf("表达式 %q: %v", strings.TrimSpace(source), err)
+		}
+		t.parts = append(t.parts, templatePart{expr: expr})
+		rest = rest[start+end+2:]
+	}
+	return t, nil
+}
+
+// String 返回模板原文
+func (t *Template) String() string {
+	if t == nil {
+		return ""
+	}
+	return t.source
+}
+
+// MarshalJSON 以模板原文输出
+func (t Template) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.source)
+}
+
+// Render 在构建上下文中对模板求值
+func (t *Template) Render(ctx *ExprContext) (string, error) {
+	ctx.err = nil
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.expr == nil {
+			b.WriteString(part.text)
+			continue
+		}
+		b.WriteString(toString(part.expr.eval(ctx)))
+	}
+	if ctx.err != nil {
+		return "", ctx.err
+	}
+	return b.String(), nil
+}
+
+// UnmarshalYAML 解析模板并记录位置
+func (t *Template) UnmarshalYAML(node *yaml.Node) error {
+	t.Pos = Position{Line: node.Line, Column: node.Column}
+	if node.Kind != yaml.ScalarNode {
+		t.issues.add(t.Pos, "", "期望为字符串")
+		return nil
+	}
+
+	parsed, err := ParseTemplate(node.Value)
+	if err != nil {
+		t.issues.add(t.Pos, "", "无效的模板 %q: %v", node.Value, err)
+		return nil
+	}
+	t.source, t.parts = parsed.source, parsed.parts
+	return nil
+}
+
+func (t *Template) validate(errs *ValidationErrors, field string) {
+	for _, issue := range t.issues {
+		if issue.Field == "" {
+			issue.Field = field
+		}
+		*errs = append(*errs, issue)
+	}
+	if len(t.issues) == 0 && strings.TrimSpace(t.source) == "" {
+		errs.add(t.Pos, field, "不能为空")
+	}
+}
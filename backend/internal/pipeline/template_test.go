@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestTemplateRender(t *testing.T) {
+	ctx := &ExprContext{
+		Branch: "main",
+		Matrix: map[string]string{"go": "1.21"},
+		HashFiles: func(patterns []string) (string, error) {
+			return "hash(" + strings.Join(patterns, ",") + ")", nil
+		},
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{template: `go-{{ hashFiles "go.sum" }}`, want: "go-hash(go.sum)"},
+		{template: `go-{{ hashFiles("go.sum") }}`, want: "go-hash(go.sum)"},
+		{template: `{{hashFiles 'go.sum' "go.mod"}}`, want: "hash(go.sum,go.mod)"},
+		{template: `{{ hashFiles("go.sum", "go.mod") }}`, want: "hash(go.sum,go.mod)"},
+		{template: `go-{{ matrix.go }}-{{ hashFiles "**/go.sum" }}`, want: "go-1.21-hash(**/go.sum)"},
+		{template: `{{ hashFiles "lock-" branch }}`, want: "hash(lock-,main)"},
+		{template: `{{ hashFiles matrix.go }}`, want: "hash(1.21)"},
+		{template: `{{ hashFiles "go.sum" == "" }}`, want: "false"},
+		{template: "npm-{{ branch }}", want: "npm-main"},
+		{template: "plain", want: "plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("ParseTemplate(%q) error = %v", tt.template, err)
+			}
+			got, err := tmpl.Render(ctx)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+			if tmpl.String() != tt.template {
+				t.Errorf("String() = %q, want the source", tmpl.String())
+			}
+		})
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{template: "go-{{ hashFiles }}", want: "函数 hashFiles 至少需要1个参数"},
+		{template: "go-{{ hashFiles() }}", want: "函数 hashFiles 至少需要1个参数"},
+		{template: `go-{{ hashFiles "go.sum"`, want: "第4个字符处的 {{ 缺少结束的 }}"},
+		{template: `{{ hashFiles "go.sum", "go.mod" }}`, want: `第20个字符处有多余的 ","`},
+		{template: `{{ contains "a" "b" }}`, want: `第2个字符处有未知的变量 "contains"`},
+		{template: "{{ unknown }}", want: `第2个字符处有未知的变量 "unknown"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			_, err := ParseTemplate(tt.template)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseTemplate(%q) error = %v, want %q", tt.template, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateRenderHashFilesError(t *testing.T) {
+	ctx := &ExprContext{HashFiles: func([]string) (string, error) {
+		return "", errors.New("permission denied")
+	}}
+	tmpl, err := ParseTemplate(`go-{{ hashFiles "go.sum" }}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+	if _, err := tmpl.Render(ctx); err == nil || err.Error() != "hashFiles: permission denied" {
+		t.Errorf("Render() error = %v, want hashFiles: permission denied", err)
+	}
+}
+
+func TestCacheKeyTemplateYAML(t *testing.T) {
+	// 请求中的写法不加引号即为合法的 YAML
+	var cache Cache
+	if err := yaml.Unmarshal([]byte(`key: go-{{ hashFiles "go.sum" }}`), &cache); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	if len(cache.issues) > 0 || len(cache.Key.issues) > 0 {
+		t.Fatalf("issues = %v %v", cache.issues, cache.Key.issues)
+	}
+	if got := cache.Key.String(); got != `go-{{ hashFiles "go.sum" }}` {
+		t.Errorf("key = %q", got)
+	}
+}
@@ -0,0 +1,470 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 环境变量名规则
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 镜像引用规则，如 golang:1.21、registry.example.com/team/app@sha256:...
+var imagePattern = regexp.MustCompile(`^[a-z0-9]+([._/:@-][A-Za-z0-9_.-]+)*$`)
+
+// Validate 校验流水线定义，返回发现的全部错误
+func (d *Definition) Validate() ValidationErrors {
+	errs := append(ValidationErrors(nil), d.issues...)
+
+	validateImage(&errs, d.Pos, "image", d.Image)
+	validateEnv(&errs, d.Pos, "env", d.Env)
+	validateDuration(&errs, "timeout", d.Timeout)
+	if d.Checkout != nil {
+		d.Checkout.validate(&errs, "checkout")
+	}
+	if d.RunsOn != nil {
+		d.RunsOn.validate(&errs, "runs_on")
+	}
+	if d.Triggers != nil {
+		d.Triggers.validate(&errs, "triggers")
+	}
+	paramNames := make(map[string]bool, len(d.Parameters))
+	for i, p := range d.Parameters {
+		field := fmt.Sprintf("parameters[%d]", i)
+		if p == nil {
+			errs.add(d.Pos, field, "参数不能为空")
+			continue
+		}
+		p.validate(&errs, field, paramNames)
+	}
+
+	if len(d.Stages) == 0 {
+		errs.add(d.Pos, "stages", "至少需要定义一个阶段")
+	}
+
+	stageNames := make(map[string]bool)
+	jobs := make(map[string]*Job)
+	for i, stage := range d.Stages {
+		field := fmt.Sprintf("stages[%d]", i)
+		if stage == nil {
+			errs.add(d.Pos, field, "阶段不能为空")
+			continue
+		}
+		stage.validate(&errs, field, jobs)
+
+		if stage.Name != "" {
+			if stageNames[stage.Name] {
+				errs.add(stage.Pos, field+".name", "阶段名称 %q 重复", stage.Name)
+			}
+			stageNames[stage.Name] = true
+		}
+	}
+
+	validateNeeds(&errs, d.Stages, jobs)
+	validateDependencies(&errs, d.Stages, jobs)
+	return errs
+}
+
+func (s *Stage) validate(errs *ValidationErrors, field string, jobs map[string]*Job) {
+	*errs = append(*errs, s.issues...)
+	if strings.TrimSpace(s.Name) == "" {
+		errs.add(s.Pos, field+".name", "阶段名称不能为空")
+	} else if len(s.Name) > 100 {
+		errs.add(s.Pos, field+".name", "阶段名称不能超过100个字符")
+	}
+
+	switch {
+	case len(s.Steps) > 0 && len(s.Jobs) > 0:
+		errs.add(s.Pos, field, "阶段不能同时定义 steps 和 jobs")
+	case len(s.Steps) > 0:
+		// 只有步骤的阶段是一个与阶段同名的作业
+		validateSteps(errs, s.Pos, field, s.Steps)
+		if s.Artifacts != nil {
+			s.Artifacts.validate(errs, field+".artifacts")
+		}
+		if s.Cache != nil {
+			s.Cache.validate(errs, field+".cache")
+		}
+		validateDependencyNames(errs, s.Pos, field, s.Name, s.Dependencies)
+		if s.Name != "" {
+			addJobName(errs, jobs, &Job{Name: s.Name, Artifacts: s.Artifacts, Pos: s.Pos}, field+".name")
+		}
+	case len(s.Jobs) > 0:
+		if s.Artifacts != nil {
+			errs.add(s.Artifacts.Pos, field+".artifacts", "包含作业的阶段不能配置 artifacts，请在作业中配置")
+		}
+		if s.Cache != nil {
+			errs.add(s.Cache.Pos, field+".cache", "包含作业的阶段不能配置 cache，请在作业中配置")
+		}
+		if s.Dependencies != nil {
+			errs.add(s.Pos, field+".dependencies", "包含作业的阶段不能配置 dependencies，请在作业中配置")
+		}
+		for i, job := range s.Jobs {
+			jobField := fmt.Sprintf("%s.jobs[%d]", field, i)
+			if job == nil {
+				errs.add(s.Pos, jobField, "作业不能为空")
+				continue
+			}
+			job.validate(errs, jobField)
+			if job.Name != "" {
+				addJobName(errs, jobs, job, jobField+".name")
+			}
+		}
+	default:
+		errs.add(s.Pos, field+".steps", "阶段至少需要一个步骤或作业")
+	}
+}
+
+// addJobName 登记作业名称，作业名称在整个流水线中唯一
+func addJobName(errs *ValidationErrors, jobs map[string]*Job, job *Job, field string) {
+	if _, ok := jobs[job.Name]; ok {
+		errs.add(job.Pos, field, "作业名称 %q 重复", job.Name)
+		return
+	}
+	jobs[job.Name] = job
+}
+
+func (j *Job) validate(errs *ValidationErrors, field string) {
+	*errs = append(*errs, j.issues...)
+	if strings.TrimSpace(j.Name) == "" {
+		errs.add(j.Pos, field+".name", "作业名称不能为空")
+	} else if len(j.Name) > 100 {
+		errs.add(j.Pos, field+".name", "作业名称不能超过100个字符")
+	}
+	if len(j.Steps) == 0 {
+		errs.add(j.Pos, field+".steps", "作业至少需要一个步骤")
+	}
+	if j.Matrix != nil {
+		j.Matrix.validate(errs, field+".matrix")
+	}
+	validateSteps(errs, j.Pos, field, j.Steps)
+	if j.Artifacts != nil {
+		j.Artifacts.validate(errs, field+".artifacts")
+	}
+	if j.Cache != nil {
+		j.Cache.validate(errs, field+".cache")
+	}
+
+	seen := make(map[string]bool)
+	for i, need := range j.Needs {
+		needField := fmt.Sprintf("%s.needs[%d]", field, i)
+		switch {
+		case need == j.Name:
+			errs.add(j.Pos, needField, "作业不能依赖自身")
+		case seen[need]:
+			errs.add(j.Pos, needField, "依赖的作业 %q 重复", need)
+		}
+		seen[need] = true
+	}
+	validateDependencyNames(errs, j.Pos, field, j.Name, j.Dependencies)
+}
+
+// validateDependencyNames 检查作业 name 获取制品的作业是否重复或为自身
+func validateDependencyNames(errs *ValidationErrors, pos Position, field, name string, deps []string) {
+	seen := make(map[string]bool)
+	for i, dep := range deps {
+		depField := fmt.Sprintf("%s.dependencies[%d]", field, i)
+		switch {
+		case dep == name:
+			errs.add(pos, depField, "作业不能获取自身的制品")
+		case seen[dep]:
+			errs.add(pos, depField, "获取制品的作业 %q 重复", dep)
+		}
+		seen[dep] = true
+	}
+}
+
+func validateSteps(errs *ValidationErrors, pos Position, field string, steps []*Step) {
+	stepNames := make(map[string]bool)
+	for i, step := range steps {
+		stepField := fmt.Sprintf("%s.steps[%d]", field, i)
+		if step == nil {
+			errs.add(pos, stepField, "步骤不能为空")
+			continue
+		}
+		step.validate(errs, stepField)
+
+		if step.Name != "" {
+			if stepNames[step.Name] {
+				errs.add(step.Pos, stepField+".name", "步骤名称 %q 重复", step.Name)
+			}
+			stepNames[step.Name] = true
+		}
+	}
+}
+
+// validateNeeds 检查 needs 引用的作业是否存在，以及作业之间是否存在循环依赖
+func validateNeeds(errs *ValidationErrors, stages []*Stage, jobs map[string]*Job) {
+	valid := true
+	for i, stage := range stages {
+		if stage == nil || len(stage.Steps) > 0 {
+			continue
+		}
+		for j, job := range stage.Jobs {
+			if job == nil {
+				continue
+			}
+			for k, need := range job.Needs {
+				if _, ok := jobs[need]; !ok && need != job.Name {
+					valid = false
+					errs.add(job.Pos, fmt.Sprintf("stages[%d].jobs[%d].needs[%d]", i, j, k), "依赖的作业 %q 不存在", need)
+				}
+			}
+		}
+	}
+
+	// 依赖不完整时无法判断是否存在循环；其他配置有误时（如矩阵过大）不展开作业，修正后再检查
+	if !valid || len(*errs) > 0 {
+		return
+	}
+
+	// 按矩阵展开后的作业名称同样需要唯一
+	def := &Definition{Stages: stages}
+	expanded := def.Jobs()
+	graph := make(map[string][]string, len(expanded))
+	for _, job := range expanded {
+		if job.MatrixOf == "" {
+			continue
+		}
+		if len(job.Name) > 100 {
+			errs.add(job.Pos, "", "作业 %q 按矩阵展开后的名称 %q 超过100个字符", job.MatrixOf, job.Name)
+			valid = false
+		}
+		if _, ok := jobs[job.Name]; ok {
+			errs.add(job.Pos, "", "作业 %q 按矩阵展开后的名称 %q 与其他作业重复", job.MatrixOf, job.Name)
+			valid = false
+		}
+	}
+	for _, job := range expanded {
+		if _, ok := graph[job.Name]; ok && job.MatrixOf != "" {
+			errs.add(job.Pos, "", "作业 %q 按矩阵展开后的名称 %q 重复", job.MatrixOf, job.Name)
+			valid = false
+		}
+		graph[job.Name] = job.DependsOn
+	}
+	if !valid {
+		return
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	positions := make(map[string]Position, len(expanded))
+	for _, job := range expanded {
+		positions[job.Name] = job.Pos
+	}
+	var path []string
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch state[name] {
+		case visiting:
+			start := 0
+			for path[start] != name {
+				start++
+			}
+			cycle := append(append([]string{}, path[start:]...), name)
+			errs.add(positions[name], "", "作业之间存在循环依赖: %s", strings.Join(cycle, " -> "))
+			return true
+		case visited:
+			return false
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range graph[name] {
+			if visit(dep) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return false
+	}
+
+	for _, job := range expanded {
+		if visit(job.Name) {
+			return
+		}
+	}
+}
+
+// validateDependencies 检查 dependencies 引用的作业是否存在、是否在成功时上传制品，
+// 以及是否为当前作业直接或间接依赖的作业，保证获取制品时上游作业已经成功结束
+func validateDependencies(errs *ValidationErrors, stages []*Stage, jobs map[string]*Job) {
+	// 其他配置有误时无法可靠地计算依赖关系，只检查引用的作业
+	var ancestors func(name string) map[string]bool
+	first := make(map[string]string) // 作业名称到按矩阵展开后的第一个作业名称
+	if len(*errs) == 0 {
+		graph := make(map[string][]string)
+		for _, job := range (&Definition{Stages: stages}).Jobs() {
+			graph[job.Name] = job.DependsOn
+			name := job.Name
+			if job.MatrixOf != "" {
+				name = job.MatrixOf
+			}
+			if _, ok := first[name]; !ok {
+				first[name] = job.Name
+			}
+		}
+
+		// 依赖关系已确认没有循环
+		memo := make(map[string]map[string]bool)
+		ancestors = func(name string) map[string]bool {
+			if result, ok := memo[name]; ok {
+				return result
+			}
+			result := make(map[string]bool)
+			for _, dep := range graph[name] {
+				result[dep] = true
+				for ancestor := range ancestors(dep) {
+					result[ancestor] = true
+				}
+			}
+			memo[name] = result
+			return result
+		}
+	}
+
+	check := func(pos Position, field, name string, deps []string) {
+		for i, dep := range deps {
+			depField := fmt.Sprintf("%s.dependencies[%d]", field, i)
+			upstream, ok := jobs[dep]
+			switch {
+			case dep == name:
+				// 已由 validateDependencyNames 报告
+			case !ok:
+				errs.add(pos, depField, "获取制品的作业 %q 不存在", dep)
+			case upstream.Artifacts == nil:
+				errs.add(pos, depField, "作业 %q 没有配置 artifacts", dep)
+			case !upstream.Artifacts.Upload(true):
+				errs.add(pos, depField, "作业 %q 的制品只在失败时上传，无法传递给后续作业", dep)
+			case ancestors != nil && !ancestors(first[name])[first[dep]]:
+				errs.add(pos, depField, "作业 %q 不是当前作业直接或间接依赖的作业，请在 needs 中声明", dep)
+			}
+		}
+	}
+
+	for i, stage := range stages {
+		if stage == nil {
+			continue
+		}
+		if len(stage.Steps) > 0 {
+			check(stage.Pos, fmt.Sprintf("stages[%d]", i), stage.Name, stage.Dependencies)
+			continue
+		}
+		for j, job := range stage.Jobs {
+			if job != nil {
+				check(job.Pos, fmt.Sprintf("stages[%d].jobs[%d]", i, j), job.Name, job.Dependencies)
+			}
+		}
+	}
+}
+
+func (s *Step) validate(errs *ValidationErrors, field string) {
+	*errs = append(*errs, s.issues...)
+	if strings.TrimSpace(s.Name) == "" {
+		errs.add(s.Pos, field+".name", "步骤名称不能为空")
+	} else if len(s.Name) > 100 {
+		errs.add(s.Pos, field+".name", "步骤名称不能超过100个字符")
+	}
+	if strings.TrimSpace(s.Run) == "" {
+		errs.add(s.Pos, field+".run", "步骤缺少要执行的命令")
+	}
+	validateImage(errs, s.Pos, field+".image", s.Image)
+	validateEnv(errs, s.Pos, field+".env", s.Env)
+	validateDuration(errs, field+".timeout", s.Timeout)
+	if s.Retry != nil {
+		s.Retry.validate(errs, field+".retry")
+	}
+	if s.If != nil {
+		s.If.validate(errs, field+".if")
+	}
+
+	if s.When != nil {
+		*errs = append(*errs, s.When.issues...)
+		for i, pattern := range s.When.Branches {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs.add(s.When.Pos, fmt.Sprintf("%s.when.branches[%d]", field, i), "无效的分支匹配模式 %q", pattern)
+			}
+		}
+	}
+}
+
+func (c *Checkout) validate(errs *ValidationErrors, field string) {
+	*errs = append(*errs, c.issues...)
+	if c.Depth != nil && *c.Depth < 0 {
+		errs.add(c.Pos, field+".depth", "克隆深度不能小于0，0 表示完整历史")
+	}
+}
+
+func (t *Triggers) validate(errs *ValidationErrors, field string) {
+	*errs = append(*errs, t.issues...)
+	for i, pattern := range t.Branches {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			errs.add(t.Pos, fmt.Sprintf("%s.branches[%d]", field, i), "无效的分支匹配模式 %q", pattern)
+		}
+	}
+	for i, pattern := range t.Tags {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			errs.add(t.Pos, fmt.Sprintf("%s.tags[%d]", field, i), "无效的标签匹配模式 %q", pattern)
+		}
+	}
+}
+
+// 重试次数上限
+const maxRetryAttempts = 10
+
+func (r *Retry) validate(errs *ValidationErrors, field string) {
+	*errs = append(*errs, r.issues...)
+	if r.MaxAttempts < 1 || r.MaxAttempts > maxRetryAttempts {
+		errs.add(r.Pos, field+".max_attempts", "最多执行次数必须在1到%d之间", maxRetryAttempts)
+	}
+	if r.Backoff != nil {
+		validateDuration(errs, field+".backoff", r.Backoff)
+	}
+	for i, code := range r.OnExitCodes {
+		if code < 1 || code > 255 {
+			errs.add(r.Pos, fmt.Sprintf("%s.on_exit_codes[%d]", field, i), "无效的退出码 %d，必须在1到255之间", code)
+		}
+	}
+}
+
+func validateImage(errs *ValidationErrors, pos Position, field, image string) {
+	if image != "" && !imagePattern.MatchString(image) {
+		errs.add(pos, field, "无效的镜像 %q", image)
+	}
+}
+
+func validateEnv(errs *ValidationErrors, pos Position, field string, env map[string]string) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !envNamePattern.MatchString(name) {
+			errs.add(pos, field, "无效的环境变量名 %q", name)
+		}
+	}
+}
+
+func validateDuration(errs *ValidationErrors, field string, d *Duration) {
+	if d == nil {
+		return
+	}
+	if len(d.issues) > 0 {
+		for _, issue := range d.issues {
+			issue.Field = field
+			*errs = append(*errs, issue)
+		}
+		return
+	}
+	if d.Duration <= 0 {
+		errs.add(d.Pos, field, "时长必须大于0")
+	}
+}
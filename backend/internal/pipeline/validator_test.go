@@ -0,0 +1,211 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		msg    string
+	}{
+		{
+			name:   "no stages",
+			config: "name: empty\n",
+			msg:    "至少需要定义一个阶段",
+		},
+		{
+			name: "duplicate stage",
+			config: `stages:
+  - name: build
+    steps: [{name: a, run: make}]
+  - name: build
+    steps: [{name: a, run: make}]
+`,
+			msg: `阶段名称 "build" 重复`,
+		},
+		{
+			name: "steps and jobs in one stage",
+			config: `stages:
+  - name: build
+    steps: [{name: a, run: make}]
+    jobs:
+      - name: lint
+        steps: [{name: a, run: make}]
+`,
+			msg: "阶段不能同时定义 steps 和 jobs",
+		},
+		{
+			name: "duplicate job across stages",
+			config: `stages:
+  - name: build
+    jobs:
+      - name: compile
+        steps: [{name: a, run: make}]
+  - name: compile
+    steps: [{name: a, run: make}]
+`,
+			msg: `作业名称 "compile" 重复`,
+		},
+		{
+			name: "unknown need",
+			config: `stages:
+  - name: build
+    jobs:
+      - name: compile
+        needs: [lint]
+        steps: [{name: a, run: make}]
+`,
+			msg: `依赖的作业 "lint" 不存在`,
+		},
+		{
+			name: "self need",
+			config: `stages:
+  - name: build
+    jobs:
+      - name: compile
+        needs: [compile]
+        steps: [{name: a, run: make}]
+`,
+			msg: "作业不能依赖自身",
+		},
+		{
+			name: "needs cycle",
+			config: `stages:
+  - name: build
+    jobs:
+      - name: a
+        needs: [c]
+        steps: [{name: s, run: make}]
+      - name: b
+        needs: [a]
+        steps: [{name: s, run: make}]
+      - name: c
+        needs: [b]
+        steps: [{name: s, run: make}]
+`,
+			msg: "作业之间存在循环依赖: a -> c -> b -> a",
+		},
+		{
+			name: "needs cycle across stages",
+			config: `stages:
+  - name: build
+    jobs:
+      - name: compile
+        needs: [publish]
+        steps: [{name: s, run: make}]
+  - name: release
+    jobs:
+      - name: publish
+        steps: [{name: s, run: make}]
+`,
+			msg: "作业之间存在循环依赖: compile -> publish -> compile",
+		},
+		{
+			name: "retry attempts out of range",
+			config: `stages:
+  - name: build
+    steps:
+      - name: a
+        run: make
+        retry: {max_attempts: 20}
+`,
+			msg: "最多执行次数必须在1到10之间",
+		},
+		{
+			name: "invalid exit code",
+			config: `stages:
+  - name: build
+    steps:
+      - name: a
+        run: make
+        retry: {max_attempts: 2, on_exit_codes: [0]}
+`,
+			msg: "无效的退出码 0",
+		},
+		{
+			name: "invalid trigger pattern",
+			config: `triggers:
+  branches: ["release/["]
+stages:
+  - name: build
+    steps: [{name: a, run: make}]
+`,
+			msg: `无效的分支匹配模式 "release/["`,
+		},
+		{
+			name: "dependency without artifacts",
+			config: `stages:
+  - name: build
+    steps: [{name: a, run: make}]
+  - name: test
+    dependencies: [build]
+    steps: [{name: a, run: make}]
+`,
+			msg: `作业 "build" 没有配置 artifacts`,
+		},
+		{
+			name: "dependency not upstream",
+			config: `stages:
+  - name: build
+    jobs:
+      - name: compile
+        artifacts: {paths: [bin/]}
+        steps: [{name: a, run: make}]
+      - name: lint
+        dependencies: [compile]
+        steps: [{name: a, run: make}]
+`,
+			msg: `作业 "compile" 不是当前作业直接或间接依赖的作业`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := parseErrors(t, tt.config)
+			if _, ok := findError(errs, tt.msg); !ok {
+				t.Errorf("errors = %v, want one containing %q", errs, tt.msg)
+			}
+		})
+	}
+}
+
+func TestJobsDependencies(t *testing.T) {
+	def, err := Parse(`stages:
+  - name: build
+    jobs:
+      - name: compile
+        steps: [{name: a, run: make}]
+      - name: lint
+        steps: [{name: a, run: make}]
+  - name: test
+    jobs:
+      - name: unit
+        needs: [compile]
+        steps: [{name: a, run: make}]
+      - name: e2e
+        steps: [{name: a, run: make}]
+  - name: deploy
+    steps: [{name: a, run: make}]
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got := make(map[string][]string)
+	for _, job := range def.Jobs() {
+		got[job.Name] = job.DependsOn
+	}
+	want := map[string][]string{
+		"compile": {},
+		"lint":    {},
+		"unit":    {"compile"},
+		"e2e":     {"compile", "lint"},
+		"deploy":  {"unit", "e2e"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Jobs() dependencies = %v, want %v", got, want)
+	}
+}
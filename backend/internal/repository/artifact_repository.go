@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Vortexia/internal/model"
+)
+
+// artifactColumns 制品查询的列，与 scanArtifact 的扫描顺序一致
+const artifactColumns = `id, build_id, job_id, path, mode, size, checksum, storage_key, expires_at, created_at`
+
+type artifactRepository struct {
+	db *sql.DB
+}
+
+// NewArtifactRepository 创建制品仓库实例
+func NewArtifactRepository(db *sql.DB) ArtifactRepository {
+	return &artifactRepository{db: db}
+}
+
+// Create 创建制品记录，同一作业中相同路径的制品已存在时覆盖（如执行器重试上传）
+func (r *artifactRepository) Create(artifact *model.Artifact) error {
+	query := `
+		INSERT INTO artifacts (build_id, job_id, path, mode, size, checksum, storage_key, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (job_id, path) DO UPDATE
+		SET mode = EXCLUDED.mode, size = EXCLUDED.size, checksum = EXCLUDED.checksum, storage_key = EXCLUDED.storage_key,
+			expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRow(
+		query,
+		artifact.BuildID,
+		artifact.JobID,
+		artifact.Path,
+		artifact.Mode,
+		artifact.Size,
+		artifact.Checksum,
+		artifact.StorageKey,
+		artifact.ExpiresAt,
+		now,
+	).Scan(&artifact.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create artifact: %w", err)
+	}
+
+	artifact.CreatedAt = now
+	return nil
+}
+
+// GetByID 根据ID获取制品
+func (r *artifactRepository) GetByID(id int) (*model.Artifact, error) {
+	query := `SELECT ` + artifactColumns + ` FROM artifacts WHERE id = $1`
+
+	artifact, err := scanArtifact(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get artifact by id: %w", err)
+	}
+
+	return artifact, nil
+}
+
+// GetByBuild 获取构建的制品，按作业和路径排序
+func (r *artifactRepository) GetByBuild(buildID int) ([]*model.Artifact, error) {
+	query := `
+		SELECT ` + artifactColumns + `
+		FROM artifacts
+		WHERE build_id = $1
+		ORDER BY job_id, path`
+
+	return r.query(query, buildID)
+}
+
+// GetByJob 获取作业的制品，按路径排序
+func (r *artifactRepository) GetByJob(jobID int) ([]*model.Artifact, error) {
+	query := `
+		SELECT ` + artifactColumns + `
+		FROM artifacts
+		WHERE job_id = $1
+		ORDER BY path`
+
+	return r.query(query, jobID)
+}
+
+// GetExpired 获取在 before 之前过期的制品，最多返回 limit 个
+func (r *artifactRepository) GetExpired(before time.Time, limit int) ([]*model.Artifact, error) {
+	query := `
+		SELECT ` + artifactColumns + `
+		FROM artifacts
+		WHERE expires_at IS NOT NULL AND expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2`
+
+	return r.query(query, before, limit)
+}
+
+// Delete 删除制品记录
+func (r *artifactRepository) Delete(id int) error {
+	if _, err := r.db.Exec(`DELETE FROM artifacts WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete artifact: %w", err)
+	}
+	return nil
+}
+
+func (r *artifactRepository) query(query string, args ...interface{}) ([]*model.Artifact, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	artifacts := []*model.Artifact{}
+	for rows.Next() {
+		artifact, err := scanArtifact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan artifact: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+
+	return artifacts, rows.Err()
+}
+
+// scanArtifact 按 artifactColumns 的顺序扫描一行制品
+func scanArtifact(row rowScanner) (*model.Artifact, error) {
+	artifact := &model.Artifact{}
+	err := row.Scan(
+		&artifact.ID,
+		&artifact.BuildID,
+		&artifact.JobID,
+		&artifact.Path,
+		&artifact.Mode,
+		&artifact.Size,
+		&artifact.Checksum,
+		&artifact.StorageKey,
+		&artifact.ExpiresAt,
+		&artifact.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// buildCancelChannel 构建取消信号的发布订阅频道，所有工作进程共享
+const buildCancelChannel = "vortexia:builds:cancel"
+
+type redisBuildCancelSignal struct {
+	redis *redis.Client
+}
+
+// NewBuildCancelSignal 创建基于Redis发布订阅的构建取消信号
+func NewBuildCancelSignal(redis *redis.Client) BuildCancelSignal {
+	return &redisBuildCancelSignal{redis: redis}
+}
+
+// Publish 广播取消信号，由正在执行该构建的工作进程处理
+func (s *redisBuildCancelSignal) Publish(ctx context.Context, buildID int) error {
+	if err := s.redis.Publish(ctx, buildCancelChannel, buildID).Err(); err != nil {
+		return fmt.Errorf("failed to publish build cancel signal: %w", err)
+	}
+	return nil
+}
+
+// Subscribe 订阅取消信号，返回被取消的构建ID，ctx 结束后通道关闭
+func (s *redisBuildCancelSignal) Subscribe(ctx context.Context) (<-chan int, error) {
+	pubsub := s.redis.Subscribe(ctx, buildCancelChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe build cancel signal: %w", err)
+	}
+
+	ids := make(chan int, 16)
+	go func() {
+		defer close(ids)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				id, err := strconv.Atoi(msg.Payload)
+				if err != nil {
+					continue
+				}
+				select {
+				case ids <- id:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ids, nil
+}
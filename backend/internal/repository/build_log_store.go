@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+)
+
+type buildLogStore struct {
+	db *sql.DB
+}
+
+// NewBuildLogStore 创建基于PostgreSQL分块表的构建日志存储
+func NewBuildLogStore(db *sql.DB) BuildLogStore {
+	return &buildLogStore{db: db}
+}
+
+// Append 追加一段步骤输出，已写入的分块不会再被修改
+func (s *buildLogStore) Append(stepID int, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	// 分块序号和偏移量根据上一个分块计算，同一步骤只有一个写入方
+	query := `
+		INSERT INTO build_log_chunks (step_id, chunk_index, byte_offset, line_offset, line_count, data)
+		SELECT $1,
+		       COALESCE(MAX(chunk_index) + 1, 0),
+		       COALESCE(MAX(byte_offset + octet_length(data)), 0),
+		       COALESCE(MAX(line_offset + line_count), 0),
+		       $2, $3
+		FROM build_log_chunks
+		WHERE step_id = $1`
+
+	_, err := s.db.Exec(query, stepID, bytes.Count(data, []byte("\n")), data)
+	if err != nil {
+		return fmt.Errorf("failed to append build log: %w", err)
+	}
+
+	return nil
+}
+
+// Size 获取步骤日志的总字节数
+func (s *buildLogStore) Size(stepID int) (int64, error) {
+	var size int64
+	query := `SELECT COALESCE(SUM(octet_length(data)), 0) FROM build_log_chunks WHERE step_id = $1`
+	if err := s.db.QueryRow(query, stepID).Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed to get build log size: %w", err)
+	}
+	return size, nil
+}
+
+// ReadBytes 读取 [offset, offset+limit) 范围内的日志
+func (s *buildLogStore) ReadBytes(stepID int, offset int64, limit int) ([]byte, error) {
+	query := `
+		SELECT byte_offset, data
+		FROM build_log_chunks
+		WHERE step_id = $1 AND byte_offset + octet_length(data) > $2 AND byte_offset < $2 + $3
+		ORDER BY chunk_index ASC`
+
+	rows, err := s.db.Query(query, stepID, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build log: %w", err)
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	for rows.Next() {
+		var start int64
+		var data []byte
+		if err := rows.Scan(&start, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan build log chunk: %w", err)
+		}
+		if start < offset {
+			data = data[offset-start:]
+		}
+		buf.Write(data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read build log: %w", err)
+	}
+
+	if buf.Len() > limit {
+		return buf.Bytes()[:limit], nil
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadLines 读取从第 from 行（从0开始）起的至多 count 个完整行，尚未写完的末行不返回
+func (s *buildLogStore) ReadLines(stepID int, from, count int) ([]string, error) {
+	// 第 i 行位于第 i 个换行符之后，所在分块满足 line_offset <= i <= line_offset + line_count
+	query := `
+		SELECT line_offset, data
+		FROM build_log_chunks
+		WHERE step_id = $1 AND line_offset + line_count >= $2 AND line_offset <= $2 + $3
+		ORDER BY chunk_index ASC`
+
+	rows, err := s.db.Query(query, stepID, from, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build log lines: %w", err)
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	first := -1
+	for rows.Next() {
+		var lineOffset int
+		var data []byte
+		if err := rows.Scan(&lineOffset, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan build log chunk: %w", err)
+		}
+		if first < 0 {
+			first = lineOffset
+		}
+		buf.Write(data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read build log lines: %w", err)
+	}
+	if first < 0 {
+		return nil, nil
+	}
+
+	content := buf.Bytes()
+	// 跳过目标行之前的内容
+	for skip := from - first; skip > 0; skip-- {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			return nil, nil
+		}
+		content = content[i+1:]
+	}
+
+	var lines []string
+	for len(content) > 0 && len(lines) < count {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, string(content[:i]))
+		content = content[i+1:]
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logChunk build_log_chunks 表中的一行
+type logChunk struct {
+	stepID, index, byteOffset, lineOffset, lineCount int64
+	data                                             []byte
+}
+
+// chunkDriver 在内存中模拟 build_log_chunks 表的 database/sql 驱动，只支持 buildLogStore 使用的语句，
+// 按语句中的条件筛选分块。不同的数据源名称使用各自的表
+type chunkDriver struct {
+	mu     sync.Mutex
+	tables map[string][]*logChunk
+}
+
+var testChunkDriver = &chunkDriver{tables: map[string][]*logChunk{}}
+
+func init() {
+	sql.Register("build_log_chunks", testChunkDriver)
+}
+
+// newTestLogStore 创建使用空的分块表的日志存储
+func newTestLogStore(t *testing.T) (BuildLogStore, func() []*logChunk) {
+	t.Helper()
+	db, err := sql.Open("build_log_chunks", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	chunks := func() []*logChunk {
+		testChunkDriver.mu.Lock()
+		defer testChunkDriver.mu.Unlock()
+		return testChunkDriver.tables[t.Name()]
+	}
+	return NewBuildLogStore(db), chunks
+}
+
+func (d *chunkDriver) Open(name string) (driver.Conn, error) {
+	return &chunkConn{driver: d, table: name}, nil
+}
+
+type chunkConn struct {
+	driver *chunkDriver
+	table  string
+}
+
+func (c *chunkConn) Prepare(query string) (driver.Stmt, error) {
+	return &chunkStmt{conn: c, query: query}, nil
+}
+
+func (c *chunkConn) Close() error { return nil }
+
+func (c *chunkConn) Begin() (driver.Tx, error) { return nil, errors.New("transactions not supported") }
+
+type chunkStmt struct {
+	conn  *chunkConn
+	query string
+}
+
+func (s *chunkStmt) Close() error  { return nil }
+func (s *chunkStmt) NumInput() int { return -1 }
+
+func (s *chunkStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if !strings.Contains(s.query, "INSERT INTO build_log_chunks") {
+		return nil, fmt.Errorf("unsupported statement: %s", s.query)
+	}
+	d := s.conn.driver
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	chunk := &logChunk{stepID: args[0].(int64), lineCount: args[1].(int64), data: append([]byte(nil), args[2].([]byte)...)}
+	for _, c := range d.tables[s.conn.table] {
+		if c.stepID != chunk.stepID {
+			continue
+		}
+		chunk.index = max(chunk.index, c.index+1)
+		chunk.byteOffset = max(chunk.byteOffset, c.byteOffset+int64(len(c.data)))
+		chunk.lineOffset = max(chunk.lineOffset, c.lineOffset+c.lineCount)
+	}
+	d.tables[s.conn.table] = append(d.tables[s.conn.table], chunk)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *chunkStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.conn.driver
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stepID := args[0].(int64)
+	var chunks []*logChunk
+	for _, c := range d.tables[s.conn.table] {
+		if c.stepID == stepID {
+			chunks = append(chunks, c)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].index < chunks[j].index })
+
+	rows := &chunkRows{}
+	switch {
+	case strings.Contains(s.query, "SUM(octet_length(data))"):
+		var size int64
+		for _, c := range chunks {
+			size += int64(len(c.data))
+		}
+		rows.columns = []string{"size"}
+		rows.values = [][]driver.Value{{size}}
+	case strings.Contains(s.query, "SELECT byte_offset, data"):
+		offset, limit := args[1].(int64), args[2].(int64)
+		rows.columns = []string{"byte_offset", "data"}
+		for _, c := range chunks {
+			if c.byteOffset+int64(len(c.data)) > offset && c.byteOffset < offset+limit {
+				rows.values = append(rows.values, []driver.Value{c.byteOffset, c.data})
+			}
+		}
+	case strings.Contains(s.query, "SELECT line_offset, data"):
+		from, count := args[1].(int64), args[2].(int64)
+		rows.columns = []string{"line_offset", "data"}
+		for _, c := range chunks {
+			if c.lineOffset+c.lineCount >= from && c.lineOffset <= from+count {
+				rows.values = append(rows.values, []driver.Value{c.lineOffset, c.data})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported query: %s", s.query)
+	}
+	return rows, nil
+}
+
+type chunkRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *chunkRows) Columns() []string { return r.columns }
+func (r *chunkRows) Close() error      { return nil }
+
+func (r *chunkRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestBuildLogStoreAppend(t *testing.T) {
+	s, chunks := newTestLogStore(t)
+
+	for _, data := range []string{"a\nb", "", "c\n", "d\ne\n"} {
+		if err := s.Append(1, []byte(data)); err != nil {
+			t.Fatalf("Append(%q) error = %v", data, err)
+		}
+	}
+	// 其他步骤的日志互不影响
+	if err := s.Append(2, []byte("other\n")); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	// 空输出不写入分块，分块的偏移量接续上一个分块
+	want := []logChunk{
+		{stepID: 1, index: 0, byteOffset: 0, lineOffset: 0, lineCount: 1, data: []byte("a\nb")},
+		{stepID: 1, index: 1, byteOffset: 3, lineOffset: 1, lineCount: 1, data: []byte("c\n")},
+		{stepID: 1, index: 2, byteOffset: 5, lineOffset: 2, lineCount: 2, data: []byte("d\ne\n")},
+		{stepID: 2, index: 0, byteOffset: 0, lineOffset: 0, lineCount: 1, data: []byte("other\n")},
+	}
+	got := chunks()
+	if len(got) != len(want) {
+		t.Fatalf("stored %d chunks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(*got[i], want[i]) {
+			t.Errorf("chunk %d = %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+
+	for stepID, want := range map[int]int64{1: 9, 2: 6, 3: 0} {
+		if size, err := s.Size(stepID); err != nil || size != want {
+			t.Errorf("Size(%d) = %d, %v, want %d", stepID, size, err, want)
+		}
+	}
+}
+
+func TestBuildLogStoreReadBytes(t *testing.T) {
+	s, _ := newTestLogStore(t)
+	for _, data := range []string{"a\nb", "c\n", "d\ne\n"} {
+		if err := s.Append(1, []byte(data)); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		offset int64
+		limit  int
+		want   string
+	}{
+		{name: "all", offset: 0, limit: 100, want: "a\nbc\nd\ne\n"},
+		{name: "within a chunk", offset: 1, limit: 1, want: "\n"},
+		{name: "across chunks", offset: 2, limit: 4, want: "bc\nd"},
+		{name: "chunk boundary", offset: 3, limit: 2, want: "c\n"},
+		{name: "tail", offset: 7, limit: 100, want: "e\n"},
+		{name: "past the end", offset: 9, limit: 100, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ReadBytes(1, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("ReadBytes() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ReadBytes(%d, %d) = %q, want %q", tt.offset, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildLogStoreReadLines(t *testing.T) {
+	s, _ := newTestLogStore(t)
+	// 行被拆分到多个分块中，最后一行尚未写完
+	for _, data := range []string{"line 1\nli", "ne 2\n", "line 3\nline 4\nline 5\n", "\n", "line 7\nline"} {
+		if err := s.Append(1, []byte(data)); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		from, count int
+		want        []string
+	}{
+		{name: "all", from: 0, count: 100, want: []string{"line 1", "line 2", "line 3", "line 4", "line 5", "", "line 7"}},
+		{name: "split line", from: 1, count: 1, want: []string{"line 2"}},
+		{name: "middle of a chunk", from: 3, count: 2, want: []string{"line 4", "line 5"}},
+		{name: "empty line", from: 5, count: 2, want: []string{"", "line 7"}},
+		{name: "unfinished line", from: 7, count: 10, want: nil},
+		{name: "past the end", from: 20, count: 10, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ReadLines(1, tt.from, tt.count)
+			if err != nil {
+				t.Fatalf("ReadLines() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadLines(%d, %d) = %q, want %q", tt.from, tt.count, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildLogStoreReplay(t *testing.T) {
+	s, _ := newTestLogStore(t)
+
+	// 模拟不按行分割的输出，按页回放的结果与完整的日志一致
+	var full bytes.Buffer
+	for i := 0; i < 50; i++ {
+		data := []byte(strings.Repeat(fmt.Sprintf("output %d\n", i), i%4))
+		data = append(data, []byte(fmt.Sprintf("partial %d ", i))...)
+		if i%5 == 0 {
+			data = append(data, '\n')
+		}
+		full.Write(data)
+		if err := s.Append(1, data); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	content := full.String()
+	complete := strings.Split(content[:strings.LastIndexByte(content, '\n')], "\n")
+
+	for _, pageSize := range []int{1, 3, 7, 1000} {
+		t.Run(fmt.Sprintf("lines per page %d", pageSize), func(t *testing.T) {
+			var lines []string
+			for {
+				page, err := s.ReadLines(1, len(lines), pageSize)
+				if err != nil {
+					t.Fatalf("ReadLines() error = %v", err)
+				}
+				if len(page) == 0 {
+					break
+				}
+				lines = append(lines, page...)
+			}
+			if !reflect.DeepEqual(lines, complete) {
+				t.Errorf("replayed %d lines, want %d", len(lines), len(complete))
+			}
+		})
+
+		t.Run(fmt.Sprintf("bytes per page %d", pageSize), func(t *testing.T) {
+			var buf bytes.Buffer
+			for {
+				page, err := s.ReadBytes(1, int64(buf.Len()), pageSize)
+				if err != nil {
+					t.Fatalf("ReadBytes() error = %v", err)
+				}
+				if len(page) == 0 {
+					break
+				}
+				buf.Write(page)
+			}
+			if buf.String() != content {
+				t.Errorf("replayed %d bytes, want %d", buf.Len(), len(content))
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Vortexia/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 构建日志在Redis中的保留时间，每次发布事件时刷新，过期后从数据库中的步骤输出回放
+const buildLogTTL = time.Hour
+
+// buildLogHistoryLimit 每个构建在Redis中保留的最近日志事件数。Redis 不淘汰数据，
+// 完整的日志保存在 BuildLogStore 中，更早的事件从数据库中的步骤输出回放
+const buildLogHistoryLimit = 1000
+
+// publishLogScript 原子地分配序号、写入历史并广播，保证历史顺序与序号一致，历史只保留最近的事件
+var publishLogScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+local event = cjson.decode(ARGV[1])
+event['seq'] = seq
+local payload = cjson.encode(event)
+redis.call('RPUSH', KEYS[2], payload)
+redis.call('LTRIM', KEYS[2], -tonumber(ARGV[3]), -1)
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+redis.call('PUBLISH', KEYS[3], payload)
+return seq
+`)
+
+// historyLogScript 获取序号大于 ARGV[1] 的历史事件。历史中是序号连续的最近事件，
+// 最后一个事件的序号为当前序号，据此计算起始下标
+var historyLogScript = redis.NewScript(`
+local seq = tonumber(redis.call('GET', KEYS[1]) or '0')
+local start = tonumber(ARGV[1]) - (seq - redis.call('LLEN', KEYS[2]))
+if start < 0 then
+  start = 0
+end
+return redis.call('LRANGE', KEYS[2], start, -1)
+`)
+
+type redisBuildLogStream struct {
+	redis *redis.Client
+}
+
+// NewBuildLogStream 创建基于Redis的构建日志流
+func NewBuildLogStream(redis *redis.Client) BuildLogStream {
+	return &redisBuildLogStream{redis: redis}
+}
+
+func logSeqKey(buildID int) string     { return fmt.Sprintf("vortexia:builds:%d:logs:seq", buildID) }
+func logHistoryKey(buildID int) string { return fmt.Sprintf("vortexia:builds:%d:logs", buildID) }
+func logChannel(buildID int) string    { return fmt.Sprintf("vortexia:builds:%d:logs:live", buildID) }
+
+// Publish 发布日志事件，分配的序号写回 event.Seq
+func (s *redisBuildLogStream) Publish(ctx context.Context, event *model.LogEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log event: %w", err)
+	}
+
+	seq, err := publishLogScript.Run(ctx, s.redis,
+		[]string{logSeqKey(event.BuildID), logHistoryKey(event.BuildID), logChannel(event.BuildID)},
+		payload, int(buildLogTTL.Seconds()), buildLogHistoryLimit,
+	).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to publish log event: %w", err)
+	}
+
+	event.Seq = seq
+	return nil
+}
+
+// History 获取序号大于 afterSeq 的历史事件。只保留了最近的事件，
+// 第一个事件的序号大于 afterSeq+1 时说明更早的事件已被丢弃
+func (s *redisBuildLogStream) History(ctx context.Context, buildID int, afterSeq int64) ([]*model.LogEvent, error) {
+	payloads, err := historyLogScript.Run(ctx, s.redis,
+		[]string{logSeqKey(buildID), logHistoryKey(buildID)}, afterSeq,
+	).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log history: %w", err)
+	}
+
+	events := make([]*model.LogEvent, 0, len(payloads))
+	for _, payload := range payloads {
+		event := &model.LogEvent{}
+		if err := json.Unmarshal([]byte(payload), event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal log event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Subscribe 订阅构建的实时日志事件，ctx 结束时通道关闭
+func (s *redisBuildLogStream) Subscribe(ctx context.Context, buildID int) (<-chan *model.LogEvent, error) {
+	pubsub := s.redis.Subscribe(ctx, logChannel(buildID))
+	// 等待订阅确认，确保之后发布的事件不会丢失
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe log events: %w", err)
+	}
+
+	events := make(chan *model.LogEvent, 64)
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				event := &model.LogEvent{}
+				if err := json.Unmarshal([]byte(msg.Payload), event); err != nil {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
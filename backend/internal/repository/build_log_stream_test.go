@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"Vortexia/internal/model"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestBuildLogStreamHistory(t *testing.T) {
+	mr, client := newTestRedis(t)
+	s := NewBuildLogStream(client)
+	ctx := context.Background()
+
+	total := buildLogHistoryLimit + 5
+	for i := 1; i <= total; i++ {
+		event := &model.LogEvent{Type: model.LogEventLog, BuildID: 7, Line: fmt.Sprintf("line %d", i)}
+		if err := s.Publish(ctx, event); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		if event.Seq != int64(i) {
+			t.Fatalf("Publish() seq = %d, want %d", event.Seq, i)
+		}
+	}
+
+	// 历史只保留最近的事件
+	if n, _ := client.LLen(ctx, logHistoryKey(7)).Result(); n != buildLogHistoryLimit {
+		t.Errorf("history length = %d, want %d", n, buildLogHistoryLimit)
+	}
+	if ttl := mr.TTL(logHistoryKey(7)); ttl != buildLogTTL {
+		t.Errorf("history TTL = %v, want %v", ttl, buildLogTTL)
+	}
+
+	tests := []struct {
+		name      string
+		afterSeq  int64
+		wantFirst int64
+		wantLen   int
+	}{
+		{name: "from start", afterSeq: 0, wantFirst: 6, wantLen: buildLogHistoryLimit},
+		{name: "trimmed start", afterSeq: 3, wantFirst: 6, wantLen: buildLogHistoryLimit},
+		{name: "first kept", afterSeq: 5, wantFirst: 6, wantLen: buildLogHistoryLimit},
+		{name: "recent", afterSeq: int64(total - 2), wantFirst: int64(total - 1), wantLen: 2},
+		{name: "up to date", afterSeq: int64(total), wantLen: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := s.History(ctx, 7, tt.afterSeq)
+			if err != nil {
+				t.Fatalf("History() error = %v", err)
+			}
+			if len(events) != tt.wantLen {
+				t.Fatalf("History() returned %d events, want %d", len(events), tt.wantLen)
+			}
+			for i, event := range events {
+				if want := tt.wantFirst + int64(i); event.Seq != want || event.Line != fmt.Sprintf("line %d", want) {
+					t.Fatalf("History()[%d] = seq %d %q, want seq %d", i, event.Seq, event.Line, want)
+				}
+			}
+		})
+	}
+
+	if events, err := s.History(ctx, 8, 0); err != nil || len(events) != 0 {
+		t.Errorf("History() of a build without events = %v, %v", events, err)
+	}
+}
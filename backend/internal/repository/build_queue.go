@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 构建队列使用的Redis键
+const (
+	buildQueuePendingKey    = "vortexia:queue:builds:pending"    // 待执行构建（LIST）
+	buildQueueProcessingKey = "vortexia:queue:builds:processing" // 执行中构建及租约到期时间（ZSET）
+	buildQueueDeliveriesKey = "vortexia:queue:builds:deliveries" // 构建被领取的次数（HASH）
+)
+
+// claimScript 原子地从待执行列表中取出指定构建并登记租约，避免取出后进程崩溃导致构建丢失。
+// 构建已被其他进程领取时返回空
+var claimScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], -1, ARGV[1]) == 0 then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+return redis.call('HINCRBY', KEYS[3], ARGV[1], 1)
+`)
+
+// enqueueScript 构建不在待执行列表和执行中集合时才加入队尾，避免同一构建被重复执行
+var enqueueScript = redis.NewScript(`
+if redis.call('ZSCORE', KEYS[2], ARGV[1]) or redis.call('LPOS', KEYS[1], ARGV[1]) then
+	return 0
+end
+redis.call('LPUSH', KEYS[1], ARGV[1])
+return 1
+`)
+
+// restoreScript 将不在待执行列表和执行中集合中的构建按参数顺序放回队首，返回放回的构建
+var restoreScript = redis.NewScript(`
+local restored = {}
+for i = #ARGV, 1, -1 do
+	local id = ARGV[i]
+	if not redis.call('ZSCORE', KEYS[2], id) and not redis.call('LPOS', KEYS[1], id) then
+		redis.call('RPUSH', KEYS[1], id)
+		table.insert(restored, 1, tonumber(id))
+	end
+end
+return restored
+`)
+
+// requeueScript 将租约已过期的构建放回队首
+var requeueScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('RPUSH', KEYS[1], id)
+end
+return #expired
+`)
+
+// QueueItem 从队列领取的构建
+type QueueItem struct {
+	BuildID    int
+	Deliveries int // 包括本次在内被领取的次数，大于1说明之前的执行被中断
+}
+
+type redisBuildQueue struct {
+	redis *redis.Client
+}
+
+// NewBuildQueue 创建基于Redis的构建队列
+func NewBuildQueue(redis *redis.Client) BuildQueue {
+	return &redisBuildQueue{redis: redis}
+}
+
+// Enqueue 将构建加入队列，构建已在队列中时不做任何事
+func (q *redisBuildQueue) Enqueue(ctx context.Context, buildID int) error {
+	err := enqueueScript.Run(ctx, q.redis,
+		[]string{buildQueuePendingKey, buildQueueProcessingKey},
+		buildID,
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to enqueue build: %w", err)
+	}
+	return nil
+}
+
+// Restore 将不在队列中（既未等待执行也未被领取）的构建按 ids 的顺序放回队首，返回放回的构建。
+// 用于找回已保存但入队失败，或队列数据丢失的构建
+func (q *redisBuildQueue) Restore(ctx context.Context, ids []int) ([]int, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	restored, err := restoreScript.Run(ctx, q.redis,
+		[]string{buildQueuePendingKey, buildQueueProcessingKey},
+		args...,
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore builds: %w", err)
+	}
+
+	result := make([]int, len(restored))
+	for i, id := range restored {
+		result[i] = int(id)
+	}
+	return result, nil
+}
+
+// Pending 按入队顺序返回跳过最早的 offset 个后的至多 limit 个待执行构建
+func (q *redisBuildQueue) Pending(ctx context.Context, offset, limit int) ([]int, error) {
+	// 新构建从左侧入队，最早的构建在列表末尾，从末尾计算位置使新入队的构建不影响分页
+	values, err := q.redis.LRange(ctx, buildQueuePendingKey, int64(-offset-limit), int64(-offset-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending builds: %w", err)
+	}
+
+	ids := make([]int, 0, len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		id, err := strconv.Atoi(values[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid build id in queue: %v", values[i])
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Claim 领取指定的待执行构建，租约在 visibility 后过期；构建已被其他进程领取时返回 nil
+func (q *redisBuildQueue) Claim(ctx context.Context, buildID int, visibility time.Duration) (*QueueItem, error) {
+	deadline := time.Now().Add(visibility).Unix()
+	deliveries, err := claimScript.Run(ctx, q.redis,
+		[]string{buildQueuePendingKey, buildQueueProcessingKey, buildQueueDeliveriesKey},
+		buildID, deadline,
+	).Int()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim build: %w", err)
+	}
+
+	return &QueueItem{BuildID: buildID, Deliveries: deliveries}, nil
+}
+
+// Remove 将构建移出待执行列表，已被领取的构建不受影响
+func (q *redisBuildQueue) Remove(ctx context.Context, buildID int) error {
+	if err := q.redis.LRem(ctx, buildQueuePendingKey, 0, buildID).Err(); err != nil {
+		return fmt.Errorf("failed to remove build from queue: %w", err)
+	}
+	return nil
+}
+
+// Extend 延长构建的租约，执行中的构建需要定期调用
+func (q *redisBuildQueue) Extend(ctx context.Context, buildID int, visibility time.Duration) error {
+	deadline := float64(time.Now().Add(visibility).Unix())
+	err := q.redis.ZAddXX(ctx, buildQueueProcessingKey, redis.Z{Score: deadline, Member: buildID}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to extend build lease: %w", err)
+	}
+	return nil
+}
+
+// Ack 确认构建已处理完毕，将其移出队列
+func (q *redisBuildQueue) Ack(ctx context.Context, buildID int) error {
+	pipe := q.redis.TxPipeline()
+	pipe.ZRem(ctx, buildQueueProcessingKey, buildID)
+	pipe.HDel(ctx, buildQueueDeliveriesKey, strconv.Itoa(buildID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to ack build: %w", err)
+	}
+	return nil
+}
+
+// RequeueExpired 将租约过期（工作进程崩溃或失联）的构建重新放回队列，返回数量
+func (q *redisBuildQueue) RequeueExpired(ctx context.Context) (int, error) {
+	n, err := requeueScript.Run(ctx, q.redis,
+		[]string{buildQueuePendingKey, buildQueueProcessingKey},
+		time.Now().Unix(),
+	).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue expired builds: %w", err)
+	}
+	return n, nil
+}
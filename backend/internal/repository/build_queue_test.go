@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBuildQueueEnqueue(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewBuildQueue(client)
+	ctx := context.Background()
+
+	for _, id := range []int{1, 2, 3, 2} {
+		if err := q.Enqueue(ctx, id); err != nil {
+			t.Fatalf("Enqueue(%d) error = %v", id, err)
+		}
+	}
+	// 已领取的构建不会再次入队
+	if _, err := q.Claim(ctx, 3, time.Minute); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if err := q.Enqueue(ctx, 3); err != nil {
+		t.Fatalf("Enqueue(3) error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          []int
+	}{
+		{name: "all", offset: 0, limit: 10, want: []int{1, 2}},
+		{name: "first page", offset: 0, limit: 1, want: []int{1}},
+		{name: "second page", offset: 1, limit: 1, want: []int{2}},
+		{name: "past the end", offset: 2, limit: 1, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := q.Pending(ctx, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("Pending() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pending(%d, %d) = %v, want %v", tt.offset, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildQueueClaim(t *testing.T) {
+	mr, client := newTestRedis(t)
+	q := NewBuildQueue(client)
+	ctx := context.Background()
+
+	for _, id := range []int{1, 2, 3} {
+		if err := q.Enqueue(ctx, id); err != nil {
+			t.Fatalf("Enqueue(%d) error = %v", id, err)
+		}
+	}
+
+	item, err := q.Claim(ctx, 2, time.Minute)
+	if err != nil || item == nil || item.BuildID != 2 || item.Deliveries != 1 {
+		t.Fatalf("Claim(2) = %+v, %v, want the first delivery", item, err)
+	}
+	// 已被领取的构建不能再次领取
+	if item, err := q.Claim(ctx, 2, time.Minute); err != nil || item != nil {
+		t.Fatalf("Claim(2) again = %+v, %v, want nil", item, err)
+	}
+	if item, err := q.Claim(ctx, 4, time.Minute); err != nil || item != nil {
+		t.Fatalf("Claim(4) = %+v, %v, want nil for a build not in the queue", item, err)
+	}
+
+	score, err := mr.ZScore(buildQueueProcessingKey, "2")
+	if err != nil {
+		t.Fatalf("lease of build 2: %v", err)
+	}
+	if deadline := time.Unix(int64(score), 0); time.Until(deadline) < 50*time.Second {
+		t.Errorf("lease deadline = %v, want about a minute from now", deadline)
+	}
+
+	// 取消的构建移出待执行列表，已领取的构建不受影响
+	for _, id := range []int{3, 2} {
+		if err := q.Remove(ctx, id); err != nil {
+			t.Fatalf("Remove(%d) error = %v", id, err)
+		}
+	}
+	if pending, _ := q.Pending(ctx, 0, 10); !reflect.DeepEqual(pending, []int{1}) {
+		t.Errorf("Pending() = %v, want [1]", pending)
+	}
+	if err := client.ZScore(ctx, buildQueueProcessingKey, "2").Err(); err != nil {
+		t.Errorf("Remove() dropped the lease of claimed build 2: %v", err)
+	}
+
+	if err := q.Ack(ctx, 2); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if mr.Exists(buildQueueProcessingKey) || mr.Exists(buildQueueDeliveriesKey) {
+		t.Error("Ack() left the lease or delivery count behind")
+	}
+}
+
+func TestBuildQueueRequeueExpired(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewBuildQueue(client)
+	ctx := context.Background()
+
+	for _, id := range []int{1, 2, 3} {
+		if err := q.Enqueue(ctx, id); err != nil {
+			t.Fatalf("Enqueue(%d) error = %v", id, err)
+		}
+	}
+	// 构建 1 的执行进程失联，租约已过期；构建 2 的执行进程仍在续租
+	if _, err := q.Claim(ctx, 1, -time.Second); err != nil {
+		t.Fatalf("Claim(1) error = %v", err)
+	}
+	if _, err := q.Claim(ctx, 2, -time.Second); err != nil {
+		t.Fatalf("Claim(2) error = %v", err)
+	}
+	if err := q.Extend(ctx, 2, time.Minute); err != nil {
+		t.Fatalf("Extend(2) error = %v", err)
+	}
+	// 未被领取的构建不能续租
+	if err := q.Extend(ctx, 3, time.Minute); err != nil {
+		t.Fatalf("Extend(3) error = %v", err)
+	}
+	if err := client.ZScore(ctx, buildQueueProcessingKey, "3").Err(); err != redis.Nil {
+		t.Errorf("lease of build 3 error = %v, want Extend() not to lease a build that was not claimed", err)
+	}
+
+	n, err := q.RequeueExpired(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("RequeueExpired() = %d, %v, want 1", n, err)
+	}
+	// 重新入队的构建排在队首
+	if pending, _ := q.Pending(ctx, 0, 10); !reflect.DeepEqual(pending, []int{1, 3}) {
+		t.Errorf("Pending() = %v, want [1 3]", pending)
+	}
+
+	item, err := q.Claim(ctx, 1, time.Minute)
+	if err != nil || item == nil || item.Deliveries != 2 {
+		t.Fatalf("Claim(1) = %+v, %v, want the second delivery", item, err)
+	}
+	if n, err := q.RequeueExpired(ctx); err != nil || n != 0 {
+		t.Errorf("RequeueExpired() = %d, %v, want nothing expired", n, err)
+	}
+}
+
+func TestBuildQueueRestore(t *testing.T) {
+	_, client := newTestRedis(t)
+	q := NewBuildQueue(client)
+	ctx := context.Background()
+
+	for _, id := range []int{1, 2} {
+		if err := q.Enqueue(ctx, id); err != nil {
+			t.Fatalf("Enqueue(%d) error = %v", id, err)
+		}
+	}
+	if _, err := q.Claim(ctx, 2, time.Minute); err != nil {
+		t.Fatalf("Claim(2) error = %v", err)
+	}
+
+	// 只放回既未等待执行也未被领取的构建，按参数顺序排在队首
+	restored, err := q.Restore(ctx, []int{1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if !reflect.DeepEqual(restored, []int{3, 4}) {
+		t.Errorf("Restore() = %v, want [3 4]", restored)
+	}
+	if pending, _ := q.Pending(ctx, 0, 10); !reflect.DeepEqual(pending, []int{3, 4, 1}) {
+		t.Errorf("Pending() = %v, want [3 4 1]", pending)
+	}
+
+	if restored, err := q.Restore(ctx, nil); err != nil || restored != nil {
+		t.Errorf("Restore(nil) = %v, %v", restored, err)
+	}
+}
+
+func TestBuildCancelSignal(t *testing.T) {
+	_, client := newTestRedis(t)
+	s := NewBuildCancelSignal(client)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ids, err := s.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	// 其他进程发布的无效消息被忽略
+	if err := client.Publish(ctx, buildCancelChannel, "invalid").Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{5, 6} {
+		if err := s.Publish(ctx, id); err != nil {
+			t.Fatalf("Publish(%d) error = %v", id, err)
+		}
+	}
+
+	for _, want := range []int{5, 6} {
+		select {
+		case id := <-ids:
+			if id != want {
+				t.Errorf("received build %d, want %d", id, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("cancel signal for build %d not received", want)
+		}
+	}
+
+	// ctx 结束后通道关闭
+	cancel()
+	select {
+	case _, ok := <-ids:
+		if ok {
+			t.Error("received a signal after the subscription ended")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed after the subscription ended")
+	}
+}
@@ -7,9 +7,18 @@ import (
 
 	"Vortexia/internal/model"
 
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 )
 
+// 构建、构建作业和构建步骤查询的列，与 scanBuild、scanJob、scanStep 的扫描顺序一致
+const (
+	buildColumns = `id, pipeline_id, branch, commit, status, started_at, finished_at, duration, trigger_by,
+		canceled_by, canceled_at, config, rerun_of, rerun_mode, runner_id, runs_on, parameters, event, tag, created_at`
+	jobColumns  = `id, build_id, name, stage, status, needs, matrix_of, matrix, started_at, finished_at, duration, job_order, created_at`
+	stepColumns = `id, build_id, job_id, name, command, status, exit_code, output, started_at, finished_at, duration, step_order, reused_from`
+)
+
 type buildRepository struct {
 	db    *sql.DB
 	redis *redis.Client
@@ -23,8 +32,8 @@ func NewBuildRepository(db *sql.DB, redis *redis.Client) BuildRepository {
 // Create 创建构建
 func (r *buildRepository) Create(build *model.Build) error {
 	query := `
-		INSERT INTO builds (pipeline_id, branch, commit, status, started_at, trigger_by, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO builds (pipeline_id, branch, commit, status, started_at, trigger_by, config, rerun_of, rerun_mode, runs_on, parameters, event, tag, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13, $14)
 		RETURNING id`
 
 	now := time.Now()
@@ -36,6 +45,13 @@ func (r *buildRepository) Create(build *model.Build) error {
 		build.Status,
 		build.StartedAt,
 		build.TriggerBy,
+		build.Config,
+		build.RerunOf,
+		build.RerunMode,
+		build.RunsOn,
+		pq.Array(build.Parameters),
+		build.Event,
+		build.Tag,
 		now,
 	).Scan(&build.ID)
 
@@ -50,24 +66,11 @@ func (r *buildRepository) Create(build *model.Build) error {
 // GetByID 根据ID获取构建
 func (r *buildRepository) GetByID(id int) (*model.Build, error) {
 	query := `
-		SELECT id, pipeline_id, branch, commit, status, started_at, finished_at, duration, trigger_by, created_at
+		SELECT ` + buildColumns + `
 		FROM builds
 		WHERE id = $1`
 
-	build := &model.Build{}
-	err := r.db.QueryRow(query, id).Scan(
-		&build.ID,
-		&build.PipelineID,
-		&build.Branch,
-		&build.Commit,
-		&build.Status,
-		&build.StartedAt,
-		&build.FinishedAt,
-		&build.Duration,
-		&build.TriggerBy,
-		&build.CreatedAt,
-	)
-
+	build, err := scanBuild(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -90,7 +93,7 @@ func (r *buildRepository) GetByPipeline(pipelineID int, offset, limit int) ([]*m
 
 	// 获取列表
 	query := `
-		SELECT id, pipeline_id, branch, commit, status, started_at, finished_at, duration, trigger_by, created_at
+		SELECT ` + buildColumns + `
 		FROM builds
 		WHERE pipeline_id = $1
 		ORDER BY created_at DESC
@@ -104,19 +107,7 @@ func (r *buildRepository) GetByPipeline(pipelineID int, offset, limit int) ([]*m
 
 	var builds []*model.Build
 	for rows.Next() {
-		build := &model.Build{}
-		err := rows.Scan(
-			&build.ID,
-			&build.PipelineID,
-			&build.Branch,
-			&build.Commit,
-			&build.Status,
-			&build.StartedAt,
-			&build.FinishedAt,
-			&build.Duration,
-			&build.TriggerBy,
-			&build.CreatedAt,
-		)
+		build, err := scanBuild(rows)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan build: %w", err)
 		}
@@ -131,7 +122,7 @@ func (r *buildRepository) UpdateStatus(id int, status string) error {
 	var query string
 	var args []interface{}
 
-	if status == model.BuildStatusSuccess || status == model.BuildStatusFailed || status == model.BuildStatusCanceled {
+	if status == model.BuildStatusSuccess || status == model.BuildStatusFailed || status == model.BuildStatusCanceled || status == model.BuildStatusTimedOut {
 		// 完成状态，更新结束时间和持续时间
 		query = `
 			UPDATE builds 
@@ -139,12 +130,25 @@ func (r *buildRepository) UpdateStatus(id int, status string) error {
 			    duration = EXTRACT(EPOCH FROM ($2 - started_at))::int
 			WHERE id = $3`
 		args = []interface{}{status, time.Now(), id}
+	} else if status == model.BuildStatusRunning {
+		// 进入运行状态时记录实际开始时间
+		query = `
+			UPDATE builds
+			SET status = $1,
+			    started_at = CASE WHEN status = $1 THEN started_at ELSE $2 END
+			WHERE id = $3`
+		args = []interface{}{status, time.Now(), id}
 	} else {
 		// 其他状态，只更新状态
 		query = `UPDATE builds SET status = $1 WHERE id = $2`
 		args = []interface{}{status, id}
 	}
 
+	if status != model.BuildStatusCanceled {
+		// 已取消的构建不会被执行结果覆盖
+		query += ` AND status <> '` + model.BuildStatusCanceled + `'`
+	}
+
 	_, err := r.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update build status: %w", err)
@@ -153,6 +157,30 @@ func (r *buildRepository) UpdateStatus(id int, status string) error {
 	return nil
 }
 
+// Cancel 取消等待中或执行中的构建，构建已结束时返回 false
+func (r *buildRepository) Cancel(id int, canceledBy int) (bool, error) {
+	query := `
+		UPDATE builds
+		SET status = $1, canceled_by = $2, canceled_at = $3, finished_at = $3,
+		    duration = EXTRACT(EPOCH FROM ($3 - started_at))::int
+		WHERE id = $4 AND status IN ($5, $6)`
+
+	res, err := r.db.Exec(query,
+		model.BuildStatusCanceled, canceledBy, time.Now(), id,
+		model.BuildStatusPending, model.BuildStatusRunning,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel build: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel build: %w", err)
+	}
+
+	return n > 0, nil
+}
+
 // List 获取构建列表
 func (r *buildRepository) List(offset, limit int) ([]*model.Build, int, error) {
 	// 获取总数
@@ -165,7 +193,7 @@ func (r *buildRepository) List(offset, limit int) ([]*model.Build, int, error) {
 
 	// 获取列表
 	query := `
-		SELECT id, pipeline_id, branch, commit, status, started_at, finished_at, duration, trigger_by, created_at
+		SELECT ` + buildColumns + `
 		FROM builds
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2`
@@ -178,19 +206,7 @@ func (r *buildRepository) List(offset, limit int) ([]*model.Build, int, error) {
 
 	var builds []*model.Build
 	for rows.Next() {
-		build := &model.Build{}
-		err := rows.Scan(
-			&build.ID,
-			&build.PipelineID,
-			&build.Branch,
-			&build.Commit,
-			&build.Status,
-			&build.StartedAt,
-			&build.FinishedAt,
-			&build.Duration,
-			&build.TriggerBy,
-			&build.CreatedAt,
-		)
+		build, err := scanBuild(rows)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan build: %w", err)
 		}
@@ -200,16 +216,138 @@ func (r *buildRepository) List(offset, limit int) ([]*model.Build, int, error) {
 	return builds, total, nil
 }
 
+// CreateJob 创建构建作业
+func (r *buildRepository) CreateJob(job *model.BuildJob) error {
+	query := `
+		INSERT INTO build_jobs (build_id, name, stage, status, needs, matrix_of, matrix, job_order, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRow(
+		query,
+		job.BuildID,
+		job.Name,
+		job.Stage,
+		job.Status,
+		pq.Array(job.Needs),
+		job.MatrixOf,
+		pq.Array(job.Matrix),
+		job.JobOrder,
+		now,
+	).Scan(&job.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create build job: %w", err)
+	}
+
+	job.CreatedAt = now
+	return nil
+}
+
+// GetJobByID 根据ID获取构建作业
+func (r *buildRepository) GetJobByID(id int) (*model.BuildJob, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM build_jobs
+		WHERE id = $1`
+
+	job, err := scanJob(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get build job by id: %w", err)
+	}
+
+	return job, nil
+}
+
+// GetJobsByBuild 根据构建ID获取作业列表，早期的构建没有作业
+func (r *buildRepository) GetJobsByBuild(buildID int) ([]*model.BuildJob, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM build_jobs
+		WHERE build_id = $1
+		ORDER BY job_order ASC`
+
+	rows, err := r.db.Query(query, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get build jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*model.BuildJob
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan build job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// UpdateJobStatus 更新作业状态
+func (r *buildRepository) UpdateJobStatus(id int, status string) error {
+	var query string
+	var args []interface{}
+
+	switch status {
+	case model.JobStatusRunning:
+		// 进入运行状态时记录开始时间
+		query = `
+			UPDATE build_jobs
+			SET status = $1,
+			    started_at = CASE WHEN status = $1 THEN started_at ELSE $2 END
+			WHERE id = $3`
+		args = []interface{}{status, time.Now(), id}
+	case model.JobStatusSuccess, model.JobStatusFailed, model.JobStatusSkipped,
+		model.JobStatusCanceled, model.JobStatusTimedOut:
+		// 完成状态，更新结束时间和持续时间，未执行就跳过的作业没有持续时间
+		query = `
+			UPDATE build_jobs
+			SET status = $1, finished_at = $2,
+			    duration = EXTRACT(EPOCH FROM ($2 - started_at))::int
+			WHERE id = $3`
+		args = []interface{}{status, time.Now(), id}
+	default:
+		query = `UPDATE build_jobs SET status = $1 WHERE id = $2`
+		args = []interface{}{status, id}
+	}
+
+	_, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update job status: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteJobsByBuild 删除构建的全部作业及其步骤，用于重新执行被中断的构建
+func (r *buildRepository) DeleteJobsByBuild(buildID int) error {
+	query := `DELETE FROM build_jobs WHERE build_id = $1`
+
+	_, err := r.db.Exec(query, buildID)
+	if err != nil {
+		return fmt.Errorf("failed to delete build jobs: %w", err)
+	}
+
+	return nil
+}
+
 // CreateStep 创建构建步骤
 func (r *buildRepository) CreateStep(step *model.BuildStep) error {
 	query := `
-		INSERT INTO build_steps (build_id, name, command, status, output, started_at, step_order)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO build_steps (build_id, job_id, name, command, status, output, started_at, step_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id`
 
 	err := r.db.QueryRow(
 		query,
 		step.BuildID,
+		step.JobID,
 		step.Name,
 		step.Command,
 		step.Status,
@@ -228,10 +366,10 @@ func (r *buildRepository) CreateStep(step *model.BuildStep) error {
 // GetStepsByBuild 根据构建ID获取步骤列表
 func (r *buildRepository) GetStepsByBuild(buildID int) ([]*model.BuildStep, error) {
 	query := `
-		SELECT id, build_id, name, command, status, output, started_at, finished_at, duration, step_order
+		SELECT ` + stepColumns + `
 		FROM build_steps
 		WHERE build_id = $1
-		ORDER BY step_order ASC`
+		ORDER BY job_id ASC NULLS FIRST, step_order ASC`
 
 	rows, err := r.db.Query(query, buildID)
 	if err != nil {
@@ -241,19 +379,7 @@ func (r *buildRepository) GetStepsByBuild(buildID int) ([]*model.BuildStep, erro
 
 	var steps []*model.BuildStep
 	for rows.Next() {
-		step := &model.BuildStep{}
-		err := rows.Scan(
-			&step.ID,
-			&step.BuildID,
-			&step.Name,
-			&step.Command,
-			&step.Status,
-			&step.Output,
-			&step.StartedAt,
-			&step.FinishedAt,
-			&step.Duration,
-			&step.StepOrder,
-		)
+		step, err := scanStep(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan build step: %w", err)
 		}
@@ -263,23 +389,32 @@ func (r *buildRepository) GetStepsByBuild(buildID int) ([]*model.BuildStep, erro
 	return steps, nil
 }
 
-// UpdateStepStatus 更新步骤状态
-func (r *buildRepository) UpdateStepStatus(id int, status string, output string) error {
+// UpdateStepStatus 更新步骤状态，步骤输出通过 BuildLogStore 追加写入
+func (r *buildRepository) UpdateStepStatus(id int, status string) error {
 	var query string
 	var args []interface{}
 
-	if status == model.StepStatusSuccess || status == model.StepStatusFailed || status == model.StepStatusSkipped {
+	if status == model.StepStatusSuccess || status == model.StepStatusFailed || status == model.StepStatusSkipped ||
+		status == model.StepStatusCanceled || status == model.StepStatusTimedOut {
 		// 完成状态，更新结束时间和持续时间
 		query = `
 			UPDATE build_steps 
-			SET status = $1, output = $2, finished_at = $3, 
-			    duration = EXTRACT(EPOCH FROM ($3 - started_at))::int
-			WHERE id = $4`
-		args = []interface{}{status, output, time.Now(), id}
+			SET status = $1, finished_at = $2, 
+			    duration = EXTRACT(EPOCH FROM ($2 - started_at))::int
+			WHERE id = $3`
+		args = []interface{}{status, time.Now(), id}
+	} else if status == model.StepStatusRunning {
+		// 进入运行状态时记录实际开始时间
+		query = `
+			UPDATE build_steps
+			SET status = $1,
+			    started_at = CASE WHEN status = $1 THEN started_at ELSE $2 END
+			WHERE id = $3`
+		args = []interface{}{status, time.Now(), id}
 	} else {
-		// 其他状态，只更新状态和输出
-		query = `UPDATE build_steps SET status = $1, output = $2 WHERE id = $3`
-		args = []interface{}{status, output, id}
+		// 其他状态，只更新状态
+		query = `UPDATE build_steps SET status = $1 WHERE id = $2`
+		args = []interface{}{status, id}
 	}
 
 	_, err := r.db.Exec(query, args...)
@@ -289,3 +424,325 @@ func (r *buildRepository) UpdateStepStatus(id int, status string, output string)
 
 	return nil
 }
+
+// GetStepByID 根据ID获取构建步骤
+func (r *buildRepository) GetStepByID(id int) (*model.BuildStep, error) {
+	query := `
+		SELECT ` + stepColumns + `
+		FROM build_steps
+		WHERE id = $1`
+
+	step, err := scanStep(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get build step by id: %w", err)
+	}
+
+	return step, nil
+}
+
+// DeleteStepsByBuild 删除构建的全部步骤，用于重新执行被中断的构建
+func (r *buildRepository) DeleteStepsByBuild(buildID int) error {
+	query := `DELETE FROM build_steps WHERE build_id = $1`
+
+	_, err := r.db.Exec(query, buildID)
+	if err != nil {
+		return fmt.Errorf("failed to delete build steps: %w", err)
+	}
+
+	return nil
+}
+
+// SetRunner 记录执行构建的远程执行器，runnerID 为 nil 表示在服务端执行
+func (r *buildRepository) SetRunner(id int, runnerID *int) error {
+	_, err := r.db.Exec(`UPDATE builds SET runner_id = $1 WHERE id = $2`, runnerID, id)
+	if err != nil {
+		return fmt.Errorf("failed to set build runner: %w", err)
+	}
+	return nil
+}
+
+// GetRunsOn 批量获取等待执行或执行中的构建的标签表达式，不存在或已结束的构建不在结果中
+func (r *buildRepository) GetRunsOn(ids []int) (map[int]string, error) {
+	rows, err := r.db.Query(`SELECT id, runs_on FROM builds WHERE id = ANY($1) AND status IN ($2, $3)`,
+		pq.Array(ids), model.BuildStatusPending, model.BuildStatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get build runs_on: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int]string, len(ids))
+	for rows.Next() {
+		var id int
+		var runsOn string
+		if err := rows.Scan(&id, &runsOn); err != nil {
+			return nil, fmt.Errorf("failed to scan build runs_on: %w", err)
+		}
+		result[id] = runsOn
+	}
+	return result, rows.Err()
+}
+
+// GetUnfinishedIDs 按创建顺序返回等待执行和执行中的构建ID
+func (r *buildRepository) GetUnfinishedIDs() ([]int, error) {
+	rows, err := r.db.Query(`SELECT id FROM builds WHERE status IN ($1, $2) ORDER BY id`,
+		model.BuildStatusPending, model.BuildStatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unfinished builds: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan build id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdateCommit 记录构建实际检出的提交
+func (r *buildRepository) UpdateCommit(id int, commit string) error {
+	_, err := r.db.Exec(`UPDATE builds SET commit = $1 WHERE id = $2`, commit, id)
+	if err != nil {
+		return fmt.Errorf("failed to update build commit: %w", err)
+	}
+	return nil
+}
+
+// SetStepExitCode 记录步骤命令的退出码
+func (r *buildRepository) SetStepExitCode(id int, exitCode int) error {
+	_, err := r.db.Exec(`UPDATE build_steps SET exit_code = $1 WHERE id = $2`, exitCode, id)
+	if err != nil {
+		return fmt.Errorf("failed to set step exit code: %w", err)
+	}
+	return nil
+}
+
+// ReuseStep 将步骤标记为成功并记录复用的原步骤，用于只重新执行失败步骤的构建
+func (r *buildRepository) ReuseStep(id int, fromStepID int) error {
+	query := `
+		UPDATE build_steps
+		SET status = $1, reused_from = $2, started_at = $3, finished_at = $3, duration = 0
+		WHERE id = $4`
+
+	_, err := r.db.Exec(query, model.StepStatusSuccess, fromStepID, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to reuse build step: %w", err)
+	}
+
+	return nil
+}
+
+// CreateStepAttempt 创建步骤执行记录
+func (r *buildRepository) CreateStepAttempt(attempt *model.BuildStepAttempt) error {
+	query := `
+		INSERT INTO build_step_attempts (step_id, attempt, status, log_offset, log_line, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err := r.db.QueryRow(
+		query,
+		attempt.StepID,
+		attempt.Attempt,
+		attempt.Status,
+		attempt.LogOffset,
+		attempt.LogLine,
+		attempt.StartedAt,
+	).Scan(&attempt.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create step attempt: %w", err)
+	}
+
+	return nil
+}
+
+// FinishStepAttempt 写入步骤执行的结果、输出范围和持续时间
+func (r *buildRepository) FinishStepAttempt(attempt *model.BuildStepAttempt) error {
+	query := `
+		UPDATE build_step_attempts
+		SET status = $1, exit_code = $2, log_bytes = $3, log_lines = $4, finished_at = $5,
+		    duration = EXTRACT(EPOCH FROM ($5 - started_at))::int
+		WHERE id = $6`
+
+	_, err := r.db.Exec(
+		query,
+		attempt.Status,
+		attempt.ExitCode,
+		attempt.LogBytes,
+		attempt.LogLines,
+		attempt.FinishedAt,
+		attempt.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish step attempt: %w", err)
+	}
+
+	return nil
+}
+
+// GetStepAttempt 获取步骤的第 attempt 次执行记录
+func (r *buildRepository) GetStepAttempt(stepID, attempt int) (*model.BuildStepAttempt, error) {
+	query := `
+		SELECT id, step_id, attempt, status, exit_code, log_offset, log_bytes, log_line, log_lines, started_at, finished_at, duration
+		FROM build_step_attempts
+		WHERE step_id = $1 AND attempt = $2`
+
+	a, err := scanStepAttempt(r.db.QueryRow(query, stepID, attempt))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get step attempt: %w", err)
+	}
+
+	return a, nil
+}
+
+// GetAttemptsByBuild 获取构建所有步骤的执行记录
+func (r *buildRepository) GetAttemptsByBuild(buildID int) ([]*model.BuildStepAttempt, error) {
+	query := `
+		SELECT a.id, a.step_id, a.attempt, a.status, a.exit_code, a.log_offset, a.log_bytes, a.log_line, a.log_lines,
+		       a.started_at, a.finished_at, a.duration
+		FROM build_step_attempts a
+		JOIN build_steps s ON s.id = a.step_id
+		WHERE s.build_id = $1
+		ORDER BY s.step_order ASC, a.attempt ASC`
+
+	rows, err := r.db.Query(query, buildID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get step attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []*model.BuildStepAttempt
+	for rows.Next() {
+		a, err := scanStepAttempt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan step attempt: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+
+	return attempts, nil
+}
+
+// scanBuild 按 buildColumns 的顺序扫描一行构建
+func scanBuild(row rowScanner) (*model.Build, error) {
+	build := &model.Build{}
+	var config, rerunMode sql.NullString
+	err := row.Scan(
+		&build.ID,
+		&build.PipelineID,
+		&build.Branch,
+		&build.Commit,
+		&build.Status,
+		&build.StartedAt,
+		&build.FinishedAt,
+		&build.Duration,
+		&build.TriggerBy,
+		&build.CanceledBy,
+		&build.CanceledAt,
+		&config,
+		&build.RerunOf,
+		&rerunMode,
+		&build.RunnerID,
+		&build.RunsOn,
+		pq.Array(&build.Parameters),
+		&build.Event,
+		&build.Tag,
+		&build.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	build.Config = config.String
+	build.RerunMode = rerunMode.String
+	return build, nil
+}
+
+// scanJob 按 jobColumns 的顺序扫描一行构建作业
+func scanJob(row rowScanner) (*model.BuildJob, error) {
+	job := &model.BuildJob{}
+	err := row.Scan(
+		&job.ID,
+		&job.BuildID,
+		&job.Name,
+		&job.Stage,
+		&job.Status,
+		pq.Array(&job.Needs),
+		&job.MatrixOf,
+		pq.Array(&job.Matrix),
+		&job.StartedAt,
+		&job.FinishedAt,
+		&job.Duration,
+		&job.JobOrder,
+		&job.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if job.Needs == nil {
+		job.Needs = []string{}
+	}
+	return job, nil
+}
+
+// scanStep 按 stepColumns 的顺序扫描一行构建步骤
+func scanStep(row rowScanner) (*model.BuildStep, error) {
+	step := &model.BuildStep{}
+	var output sql.NullString
+	err := row.Scan(
+		&step.ID,
+		&step.BuildID,
+		&step.JobID,
+		&step.Name,
+		&step.Command,
+		&step.Status,
+		&step.ExitCode,
+		&output,
+		&step.StartedAt,
+		&step.FinishedAt,
+		&step.Duration,
+		&step.StepOrder,
+		&step.ReusedFrom,
+	)
+	if err != nil {
+		return nil, err
+	}
+	step.Output = output.String
+	return step, nil
+}
+
+// rowScanner 由 *sql.Row 和 *sql.Rows 实现
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanStepAttempt 扫描一行步骤执行记录
+func scanStepAttempt(row rowScanner) (*model.BuildStepAttempt, error) {
+	a := &model.BuildStepAttempt{}
+	err := row.Scan(
+		&a.ID,
+		&a.StepID,
+		&a.Attempt,
+		&a.Status,
+		&a.ExitCode,
+		&a.LogOffset,
+		&a.LogBytes,
+		&a.LogLine,
+		&a.LogLines,
+		&a.StartedAt,
+		&a.FinishedAt,
+		&a.Duration,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Vortexia/internal/model"
+)
+
+// cacheColumns 缓存查询的列，与 scanCache 的扫描顺序一致
+const cacheColumns = `id, project_id, key, size, checksum, storage_key, build_id, last_used_at, created_at`
+
+type cacheRepository struct {
+	db *sql.DB
+}
+
+// NewCacheRepository 创建缓存仓库实例
+func NewCacheRepository(db *sql.DB) CacheRepository {
+	return &cacheRepository{db: db}
+}
+
+// Save 保存缓存记录，项目中相同缓存键的缓存已存在时覆盖（如并行的作业保存同一个缓存键）
+func (r *cacheRepository) Save(cache *model.Cache) error {
+	query := `
+		INSERT INTO caches (project_id, key, size, checksum, storage_key, build_id, last_used_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (project_id, key) DO UPDATE
+		SET size = EXCLUDED.size, checksum = EXCLUDED.checksum, storage_key = EXCLUDED.storage_key,
+			build_id = EXCLUDED.build_id, last_used_at = EXCLUDED.last_used_at, created_at = EXCLUDED.created_at
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRow(
+		query,
+		cache.ProjectID,
+		cache.Key,
+		cache.Size,
+		cache.Checksum,
+		cache.StorageKey,
+		cache.BuildID,
+		now,
+	).Scan(&cache.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to save cache: %w", err)
+	}
+
+	cache.LastUsedAt = now
+	cache.CreatedAt = now
+	return nil
+}
+
+// GetInProject 根据ID获取项目的缓存，缓存不属于该项目时返回 nil
+func (r *cacheRepository) GetInProject(projectID, id int) (*model.Cache, error) {
+	query := `SELECT ` + cacheColumns + ` FROM caches WHERE id = $1 AND project_id = $2`
+	return r.get(query, id, projectID)
+}
+
+// GetByKey 根据缓存键获取项目的缓存
+func (r *cacheRepository) GetByKey(projectID int, key string) (*model.Cache, error) {
+	query := `SELECT ` + cacheColumns + ` FROM caches WHERE project_id = $1 AND key = $2`
+	return r.get(query, projectID, key)
+}
+
+// GetLatestByPrefix 获取项目中缓存键以 prefix 开头的缓存中最近保存的一个
+func (r *cacheRepository) GetLatestByPrefix(projectID int, prefix string) (*model.Cache, error) {
+	query := `
+		SELECT ` + cacheColumns + `
+		FROM caches
+		WHERE project_id = $1 AND LEFT(key, LENGTH($2)) = $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`
+	return r.get(query, projectID, prefix)
+}
+
+// GetByProject 获取项目的缓存，按最近使用时间倒序
+func (r *cacheRepository) GetByProject(projectID int) ([]*model.Cache, error) {
+	query := `
+		SELECT ` + cacheColumns + `
+		FROM caches
+		WHERE project_id = $1
+		ORDER BY last_used_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caches: %w", err)
+	}
+	defer rows.Close()
+
+	caches := []*model.Cache{}
+	for rows.Next() {
+		cache, err := scanCache(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cache: %w", err)
+		}
+		caches = append(caches, cache)
+	}
+
+	return caches, rows.Err()
+}
+
+// Touch 将缓存的最近使用时间更新为当前时间
+func (r *cacheRepository) Touch(id int) error {
+	if _, err := r.db.Exec(`UPDATE caches SET last_used_at = $1 WHERE id = $2`, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to touch cache: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除缓存记录
+func (r *cacheRepository) Delete(id int) error {
+	if _, err := r.db.Exec(`DELETE FROM caches WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete cache: %w", err)
+	}
+	return nil
+}
+
+func (r *cacheRepository) get(query string, args ...interface{}) (*model.Cache, error) {
+	cache, err := scanCache(r.db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get cache: %w", err)
+	}
+	return cache, nil
+}
+
+// scanCache 按 cacheColumns 的顺序扫描一行缓存
+func scanCache(row rowScanner) (*model.Cache, error) {
+	cache := &model.Cache{}
+	err := row.Scan(
+		&cache.ID,
+		&cache.ProjectID,
+		&cache.Key,
+		&cache.Size,
+		&cache.Checksum,
+		&cache.StorageKey,
+		&cache.BuildID,
+		&cache.LastUsedAt,
+		&cache.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"Vortexia/internal/model"
 
@@ -14,6 +16,16 @@ type Repositories struct {
 	Project  ProjectRepository
 	Pipeline PipelineRepository
 	Build    BuildRepository
+	Runner   RunnerRepository
+	Secret   SecretRepository
+	Artifact ArtifactRepository
+	Cache    CacheRepository
+	Webhook  WebhookRepository
+	Queue    BuildQueue
+	Logs     BuildLogStream
+	LogStore BuildLogStore
+	Cancels  BuildCancelSignal
+	Tickets  StreamTicketStore
 }
 
 // NewRepositories 创建仓库集合
@@ -23,6 +35,16 @@ func NewRepositories(db *sql.DB, redis *redis.Client) *Repositories {
 		Project:  NewProjectRepository(db),
 		Pipeline: NewPipelineRepository(db),
 		Build:    NewBuildRepository(db, redis),
+		Runner:   NewRunnerRepository(db),
+		Secret:   NewSecretRepository(db),
+		Artifact: NewArtifactRepository(db),
+		Cache:    NewCacheRepository(db),
+		Webhook:  NewWebhookRepository(db),
+		Queue:    NewBuildQueue(redis),
+		Logs:     NewBuildLogStream(redis),
+		LogStore: NewBuildLogStore(db),
+		Cancels:  NewBuildCancelSignal(redis),
+		Tickets:  NewStreamTicketStore(redis),
 	}
 }
 
@@ -63,10 +85,128 @@ type BuildRepository interface {
 	GetByID(id int) (*model.Build, error)
 	GetByPipeline(pipelineID int, offset, limit int) ([]*model.Build, int, error)
 	UpdateStatus(id int, status string) error
+	Cancel(id int, canceledBy int) (bool, error)
+	UpdateCommit(id int, commit string) error
+	SetRunner(id int, runnerID *int) error
+	GetRunsOn(ids []int) (map[int]string, error)
+	GetUnfinishedIDs() ([]int, error)
 	List(offset, limit int) ([]*model.Build, int, error)
 
+	// 构建作业相关
+	CreateJob(job *model.BuildJob) error
+	GetJobByID(id int) (*model.BuildJob, error)
+	GetJobsByBuild(buildID int) ([]*model.BuildJob, error)
+	UpdateJobStatus(id int, status string) error
+	DeleteJobsByBuild(buildID int) error
+
 	// 构建步骤相关
 	CreateStep(step *model.BuildStep) error
+	GetStepByID(id int) (*model.BuildStep, error)
 	GetStepsByBuild(buildID int) ([]*model.BuildStep, error)
-	UpdateStepStatus(id int, status string, output string) error
+	UpdateStepStatus(id int, status string) error
+	SetStepExitCode(id int, exitCode int) error
+	ReuseStep(id int, fromStepID int) error
+	DeleteStepsByBuild(buildID int) error
+
+	// 步骤执行记录相关
+	CreateStepAttempt(attempt *model.BuildStepAttempt) error
+	FinishStepAttempt(attempt *model.BuildStepAttempt) error
+	GetStepAttempt(stepID, attempt int) (*model.BuildStepAttempt, error)
+	GetAttemptsByBuild(buildID int) ([]*model.BuildStepAttempt, error)
+}
+
+// RunnerRepository 远程执行器仓库接口
+type RunnerRepository interface {
+	Create(runner *model.Runner) error
+	GetByID(id int) (*model.Runner, error)
+	GetByTokenHash(tokenHash string) (*model.Runner, error)
+	List(offset, limit int) ([]*model.Runner, int, error)
+	SetPaused(id int, paused bool) error
+	SetLabels(id int, labels []string) error
+	Revoke(id int) error
+	Touch(id int) error
+}
+
+// SecretRepository 密钥仓库接口
+type SecretRepository interface {
+	Create(secret *model.Secret) error
+	GetByID(id int) (*model.Secret, error)
+	GetByProject(projectID int) ([]*model.Secret, error)
+	GetByPipeline(pipelineID int) ([]*model.Secret, error)
+	GetForBuild(projectID, pipelineID int) ([]*model.Secret, error)
+	GetNotEncryptedWith(keyID string) ([]*model.Secret, error)
+	UpdateValue(secret *model.Secret) error
+	Delete(id int) error
+}
+
+// ArtifactRepository 制品仓库接口
+type ArtifactRepository interface {
+	Create(artifact *model.Artifact) error
+	GetByID(id int) (*model.Artifact, error)
+	GetByBuild(buildID int) ([]*model.Artifact, error)
+	GetByJob(jobID int) ([]*model.Artifact, error)
+	GetExpired(before time.Time, limit int) ([]*model.Artifact, error)
+	Delete(id int) error
+}
+
+// CacheRepository 依赖缓存仓库接口
+type CacheRepository interface {
+	Save(cache *model.Cache) error
+	GetInProject(projectID, id int) (*model.Cache, error)
+	GetByKey(projectID int, key string) (*model.Cache, error)
+	GetLatestByPrefix(projectID int, prefix string) (*model.Cache, error)
+	GetByProject(projectID int) ([]*model.Cache, error)
+	Touch(id int) error
+	Delete(id int) error
+}
+
+// WebhookRepository 项目 webhook 仓库接口
+type WebhookRepository interface {
+	Save(webhook *model.ProjectWebhook) error
+	GetByProject(projectID int) (*model.ProjectWebhook, error)
+	GetNotEncryptedWith(keyID string) ([]*model.ProjectWebhook, error)
+	UpdateSecret(webhook *model.ProjectWebhook) error
+	Delete(projectID int) error
+	RecordDelivery(projectID int, provider, deliveryID string, since time.Time) (bool, error)
+	ForgetDelivery(projectID int, provider, deliveryID string) error
+	DeleteDeliveriesBefore(t time.Time) error
+}
+
+// BuildQueue 构建队列接口，领取的构建在租约过期前未确认会被重新投递
+type BuildQueue interface {
+	Enqueue(ctx context.Context, buildID int) error
+	Pending(ctx context.Context, offset, limit int) ([]int, error)
+	Claim(ctx context.Context, buildID int, visibility time.Duration) (*QueueItem, error)
+	Remove(ctx context.Context, buildID int) error
+	Extend(ctx context.Context, buildID int, visibility time.Duration) error
+	Ack(ctx context.Context, buildID int) error
+	RequeueExpired(ctx context.Context) (int, error)
+	Restore(ctx context.Context, ids []int) ([]int, error)
+}
+
+// BuildLogStream 构建日志流接口，提供历史回放和跨实例的实时订阅
+type BuildLogStream interface {
+	Publish(ctx context.Context, event *model.LogEvent) error
+	History(ctx context.Context, buildID int, afterSeq int64) ([]*model.LogEvent, error)
+	Subscribe(ctx context.Context, buildID int) (<-chan *model.LogEvent, error)
+}
+
+// BuildLogStore 构建日志存储接口，步骤输出按分块追加写入
+type BuildLogStore interface {
+	Append(stepID int, data []byte) error
+	Size(stepID int) (int64, error)
+	ReadBytes(stepID int, offset int64, limit int) ([]byte, error)
+	ReadLines(stepID int, from, count int) ([]string, error)
+}
+
+// BuildCancelSignal 构建取消信号接口，通知正在执行构建的工作进程终止构建
+type BuildCancelSignal interface {
+	Publish(ctx context.Context, buildID int) error
+	Subscribe(ctx context.Context) (<-chan int, error)
+}
+
+// StreamTicketStore 流式请求票据存储接口，票据只能使用一次
+type StreamTicketStore interface {
+	Save(ctx context.Context, ticket string, userID int, ttl time.Duration) error
+	Take(ctx context.Context, ticket string) (int, error)
 }
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Vortexia/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// runnerColumns 执行器查询的列，与 scanRunner 的扫描顺序一致
+const runnerColumns = `id, name, labels, token_hash, version, paused, last_seen_at, revoked_at, created_at`
+
+type runnerRepository struct {
+	db *sql.DB
+}
+
+// NewRunnerRepository 创建执行器仓库实例
+func NewRunnerRepository(db *sql.DB) RunnerRepository {
+	return &runnerRepository{db: db}
+}
+
+// Create 创建执行器
+func (r *runnerRepository) Create(runner *model.Runner) error {
+	query := `
+		INSERT INTO runners (name, labels, token_hash, version, paused, last_seen_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRow(
+		query,
+		runner.Name,
+		pq.Array(runner.Labels),
+		runner.TokenHash,
+		runner.Version,
+		runner.Paused,
+		now,
+	).Scan(&runner.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create runner: %w", err)
+	}
+
+	runner.LastSeenAt = &now
+	runner.CreatedAt = now
+	return nil
+}
+
+// GetByID 根据ID获取执行器
+func (r *runnerRepository) GetByID(id int) (*model.Runner, error) {
+	query := `SELECT ` + runnerColumns + ` FROM runners WHERE id = $1`
+
+	runner, err := scanRunner(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get runner by id: %w", err)
+	}
+
+	return runner, nil
+}
+
+// GetByTokenHash 根据令牌的SHA-256获取执行器
+func (r *runnerRepository) GetByTokenHash(tokenHash string) (*model.Runner, error) {
+	query := `SELECT ` + runnerColumns + ` FROM runners WHERE token_hash = $1`
+
+	runner, err := scanRunner(r.db.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get runner by token: %w", err)
+	}
+
+	return runner, nil
+}
+
+// List 获取执行器列表
+func (r *runnerRepository) List(offset, limit int) ([]*model.Runner, int, error) {
+	// 获取总数
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM runners`).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count runners: %w", err)
+	}
+
+	// 获取列表
+	query := `
+		SELECT ` + runnerColumns + `
+		FROM runners
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list runners: %w", err)
+	}
+	defer rows.Close()
+
+	var runners []*model.Runner
+	for rows.Next() {
+		runner, err := scanRunner(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan runner: %w", err)
+		}
+		runners = append(runners, runner)
+	}
+
+	return runners, total, nil
+}
+
+// SetPaused 暂停或恢复执行器
+func (r *runnerRepository) SetPaused(id int, paused bool) error {
+	_, err := r.db.Exec(`UPDATE runners SET paused = $1 WHERE id = $2`, paused, id)
+	if err != nil {
+		return fmt.Errorf("failed to update runner: %w", err)
+	}
+	return nil
+}
+
+// SetLabels 更新执行器的标签
+func (r *runnerRepository) SetLabels(id int, labels []string) error {
+	_, err := r.db.Exec(`UPDATE runners SET labels = $1 WHERE id = $2`, pq.Array(labels), id)
+	if err != nil {
+		return fmt.Errorf("failed to update runner labels: %w", err)
+	}
+	return nil
+}
+
+// Revoke 吊销执行器，已吊销的执行器保持原吊销时间
+func (r *runnerRepository) Revoke(id int) error {
+	query := `UPDATE runners SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2`
+	if _, err := r.db.Exec(query, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to revoke runner: %w", err)
+	}
+	return nil
+}
+
+// Touch 记录执行器的最近活动时间
+func (r *runnerRepository) Touch(id int) error {
+	_, err := r.db.Exec(`UPDATE runners SET last_seen_at = $1 WHERE id = $2`, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update runner last seen: %w", err)
+	}
+	return nil
+}
+
+// scanRunner 按 runnerColumns 的顺序扫描一行执行器
+func scanRunner(row rowScanner) (*model.Runner, error) {
+	runner := &model.Runner{}
+	err := row.Scan(
+		&runner.ID,
+		&runner.Name,
+		pq.Array(&runner.Labels),
+		&runner.TokenHash,
+		&runner.Version,
+		&runner.Paused,
+		&runner.LastSeenAt,
+		&runner.RevokedAt,
+		&runner.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return runner, nil
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Vortexia/internal/model"
+)
+
+// secretColumns 密钥查询的列，与 scanSecret 的扫描顺序一致
+const secretColumns = `id, project_id, pipeline_id, name, key_id, value, created_by, created_at, updated_at`
+
+type secretRepository struct {
+	db *sql.DB
+}
+
+// NewSecretRepository 创建密钥仓库实例
+func NewSecretRepository(db *sql.DB) SecretRepository {
+	return &secretRepository{db: db}
+}
+
+// Create 创建密钥
+func (r *secretRepository) Create(secret *model.Secret) error {
+	query := `
+		INSERT INTO secrets (project_id, pipeline_id, name, key_id, value, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRow(
+		query,
+		secret.ProjectID,
+		secret.PipelineID,
+		secret.Name,
+		secret.KeyID,
+		secret.Value,
+		secret.CreatedBy,
+		now,
+	).Scan(&secret.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create secret: %w", err)
+	}
+
+	secret.CreatedAt = now
+	secret.UpdatedAt = now
+	return nil
+}
+
+// GetByID 根据ID获取密钥
+func (r *secretRepository) GetByID(id int) (*model.Secret, error) {
+	query := `SELECT ` + secretColumns + ` FROM secrets WHERE id = $1`
+
+	secret, err := scanSecret(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get secret by id: %w", err)
+	}
+
+	return secret, nil
+}
+
+// GetByProject 获取项目的密钥，不包括流水线的密钥
+func (r *secretRepository) GetByProject(projectID int) ([]*model.Secret, error) {
+	query := `
+		SELECT ` + secretColumns + `
+		FROM secrets
+		WHERE project_id = $1 AND pipeline_id IS NULL
+		ORDER BY name`
+
+	return r.query(query, projectID)
+}
+
+// GetByPipeline 获取流水线的密钥
+func (r *secretRepository) GetByPipeline(pipelineID int) ([]*model.Secret, error) {
+	query := `
+		SELECT ` + secretColumns + `
+		FROM secrets
+		WHERE pipeline_id = $1
+		ORDER BY name`
+
+	return r.query(query, pipelineID)
+}
+
+// GetForBuild 获取流水线的构建可以使用的密钥，项目的密钥排在流水线的密钥之前
+func (r *secretRepository) GetForBuild(projectID, pipelineID int) ([]*model.Secret, error) {
+	query := `
+		SELECT ` + secretColumns + `
+		FROM secrets
+		WHERE project_id = $1 AND (pipeline_id IS NULL OR pipeline_id = $2)
+		ORDER BY pipeline_id NULLS FIRST, name`
+
+	return r.query(query, projectID, pipelineID)
+}
+
+// GetNotEncryptedWith 获取不是用指定主密钥加密的密钥
+func (r *secretRepository) GetNotEncryptedWith(keyID string) ([]*model.Secret, error) {
+	query := `
+		SELECT ` + secretColumns + `
+		FROM secrets
+		WHERE key_id <> $1
+		ORDER BY id`
+
+	return r.query(query, keyID)
+}
+
+// UpdateValue 更新密钥的密文
+func (r *secretRepository) UpdateValue(secret *model.Secret) error {
+	query := `UPDATE secrets SET key_id = $1, value = $2, updated_at = $3 WHERE id = $4`
+
+	now := time.Now()
+	if _, err := r.db.Exec(query, secret.KeyID, secret.Value, now, secret.ID); err != nil {
+		return fmt.Errorf("failed to update secret: %w", err)
+	}
+
+	secret.UpdatedAt = now
+	return nil
+}
+
+// Delete 删除密钥
+func (r *secretRepository) Delete(id int) error {
+	if _, err := r.db.Exec(`DELETE FROM secrets WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+	return nil
+}
+
+func (r *secretRepository) query(query string, args ...interface{}) ([]*model.Secret, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secrets: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := []*model.Secret{}
+	for rows.Next() {
+		secret, err := scanSecret(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan secret: %w", err)
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, rows.Err()
+}
+
+// scanSecret 按 secretColumns 的顺序扫描一行密钥
+func scanSecret(row rowScanner) (*model.Secret, error) {
+	secret := &model.Secret{}
+	err := row.Scan(
+		&secret.ID,
+		&secret.ProjectID,
+		&secret.PipelineID,
+		&secret.Name,
+		&secret.KeyID,
+		&secret.Value,
+		&secret.CreatedBy,
+		&secret.CreatedAt,
+		&secret.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
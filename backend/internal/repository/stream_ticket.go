@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// streamTicketKey 流式请求票据的键，值为签发票据的用户ID
+const streamTicketKey = "vortexia:stream_tickets:%s"
+
+type redisStreamTicketStore struct {
+	redis *redis.Client
+}
+
+// NewStreamTicketStore 创建基于Redis的流式请求票据存储
+func NewStreamTicketStore(redis *redis.Client) StreamTicketStore {
+	return &redisStreamTicketStore{redis: redis}
+}
+
+// Save 保存票据，ttl 后自动失效
+func (s *redisStreamTicketStore) Save(ctx context.Context, ticket string, userID int, ttl time.Duration) error {
+	if err := s.redis.Set(ctx, fmt.Sprintf(streamTicketKey, ticket), userID, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save stream ticket: %w", err)
+	}
+	return nil
+}
+
+// Take 取出并删除票据，返回签发票据的用户ID，票据不存在或已失效时返回 0
+func (s *redisStreamTicketStore) Take(ctx context.Context, ticket string) (int, error) {
+	value, err := s.redis.GetDel(ctx, fmt.Sprintf(streamTicketKey, ticket)).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to take stream ticket: %w", err)
+	}
+	userID, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stream ticket value %q: %w", value, err)
+	}
+	return userID, nil
+}
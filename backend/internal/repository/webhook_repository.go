@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Vortexia/internal/model"
+)
+
+// webhookColumns webhook 查询的列，与 scanWebhook 的扫描顺序一致
+const webhookColumns = `project_id, key_id, secret, created_by, created_at`
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository 创建 webhook 仓库实例
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// Save 保存项目的 webhook，已存在时替换密钥
+func (r *webhookRepository) Save(webhook *model.ProjectWebhook) error {
+	query := `
+		INSERT INTO project_webhooks (project_id, key_id, secret, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (project_id) DO UPDATE
+		SET key_id = EXCLUDED.key_id, secret = EXCLUDED.secret,
+			created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at`
+
+	now := time.Now()
+	_, err := r.db.Exec(
+		query,
+		webhook.ProjectID,
+		webhook.KeyID,
+		webhook.Secret,
+		webhook.CreatedBy,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	webhook.CreatedAt = now
+	return nil
+}
+
+// GetByProject 获取项目的 webhook
+func (r *webhookRepository) GetByProject(projectID int) (*model.ProjectWebhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM project_webhooks WHERE project_id = $1`
+
+	webhook, err := scanWebhook(r.db.QueryRow(query, projectID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get webhook by project: %w", err)
+	}
+	return webhook, nil
+}
+
+// GetNotEncryptedWith 获取密钥不是用指定主密钥加密的 webhook
+func (r *webhookRepository) GetNotEncryptedWith(keyID string) ([]*model.ProjectWebhook, error) {
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM project_webhooks
+		WHERE key_id <> $1
+		ORDER BY project_id`
+
+	rows, err := r.db.Query(query, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*model.ProjectWebhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// UpdateSecret 更新 webhook 密钥的密文
+func (r *webhookRepository) UpdateSecret(webhook *model.ProjectWebhook) error {
+	query := `UPDATE project_webhooks SET key_id = $1, secret = $2 WHERE project_id = $3`
+
+	if _, err := r.db.Exec(query, webhook.KeyID, webhook.Secret, webhook.ProjectID); err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除项目的 webhook
+func (r *webhookRepository) Delete(projectID int) error {
+	if _, err := r.db.Exec(`DELETE FROM project_webhooks WHERE project_id = $1`, projectID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// RecordDelivery 记录项目收到的一次投递，since 之后已记录过相同的投递ID时返回 false。
+// 更早的记录视为已过期，更新为本次投递的时间
+func (r *webhookRepository) RecordDelivery(projectID int, provider, deliveryID string, since time.Time) (bool, error) {
+	query := `
+		INSERT INTO webhook_deliveries (project_id, provider, delivery_id, received_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id, provider, delivery_id) DO UPDATE SET received_at = EXCLUDED.received_at
+		WHERE webhook_deliveries.received_at < $5`
+
+	result, err := r.db.Exec(query, projectID, provider, deliveryID, time.Now(), since)
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ForgetDelivery 删除投递记录，处理失败后代码托管平台重新投递时可以再次处理
+func (r *webhookRepository) ForgetDelivery(projectID int, provider, deliveryID string) error {
+	query := `DELETE FROM webhook_deliveries WHERE project_id = $1 AND provider = $2 AND delivery_id = $3`
+
+	if _, err := r.db.Exec(query, projectID, provider, deliveryID); err != nil {
+		return fmt.Errorf("failed to forget webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// DeleteDeliveriesBefore 删除早于 t 的投递记录
+func (r *webhookRepository) DeleteDeliveriesBefore(t time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM webhook_deliveries WHERE received_at < $1`, t); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// scanWebhook 按 webhookColumns 的顺序扫描一行 webhook
+func scanWebhook(row rowScanner) (*model.ProjectWebhook, error) {
+	webhook := &model.ProjectWebhook{}
+	err := row.Scan(
+		&webhook.ProjectID,
+		&webhook.KeyID,
+		&webhook.Secret,
+		&webhook.CreatedBy,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
@@ -0,0 +1,364 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"Vortexia/internal/model"
+)
+
+const (
+	requestTimeout = 60 * time.Second // 单次请求超时，需大于服务端领取构建的长轮询时长
+	maxRetries     = 5                // 网络错误或服务端5xx时的最大重试次数
+	retryBackoff   = time.Second      // 首次重试的等待时间，之后每次加倍
+)
+
+// APIError 执行器API返回的错误响应
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("runner api returned %d: %s", e.StatusCode, e.Message)
+}
+
+// IsConflict 构建已不再分配给本执行器
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// Client 执行器API客户端
+type Client struct {
+	baseURL  string
+	token    string
+	http     *http.Client
+	transfer *http.Client // 上传和下载制品与缓存，传输时长与文件大小有关，不设置整体超时
+}
+
+// NewClient 创建执行器API客户端，token 为空时只能调用注册接口
+func NewClient(serverURL, token string) *Client {
+	return &Client{
+		baseURL:  strings.TrimRight(serverURL, "/") + "/api/v1/runners",
+		token:    token,
+		http:     &http.Client{Timeout: requestTimeout},
+		transfer: &http.Client{},
+	}
+}
+
+// Register 使用注册令牌注册执行器
+func (c *Client) Register(ctx context.Context, req *model.RegisterRunnerRequest) (*model.RegisterRunnerResponse, error) {
+	var resp model.RegisterRunnerResponse
+	if _, err := c.do(ctx, http.MethodPost, "/register", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Heartbeat 上报当前标签和正在执行的构建，返回需要终止的构建
+func (c *Client) Heartbeat(ctx context.Context, running []int, labels []string) ([]int, error) {
+	var resp model.RunnerHeartbeatResponse
+	req := &model.RunnerHeartbeatRequest{Running: running, Labels: labels}
+	if _, err := c.do(ctx, http.MethodPost, "/heartbeat", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Cancel, nil
+}
+
+// RequestJob 长轮询领取构建，没有构建时返回 nil
+func (c *Client) RequestJob(ctx context.Context) (*model.RunnerJob, error) {
+	var job model.RunnerJob
+	status, err := c.do(ctx, http.MethodGet, "/jobs", nil, &job)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// JobStatus 获取构建当前的状态
+func (c *Client) JobStatus(ctx context.Context, buildID int) (string, error) {
+	var resp model.RunnerBuildStatus
+	if _, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/jobs/%d/status", buildID), nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// UpdateCommit 上报构建检出的提交
+func (c *Client) UpdateCommit(ctx context.Context, buildID int, commit string) error {
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/jobs/%d/commit", buildID), &model.RunnerCommitUpdate{Commit: commit}, nil)
+	return err
+}
+
+// FinishJob 上报构建的最终状态
+func (c *Client) FinishJob(ctx context.Context, buildID int, status string) error {
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/jobs/%d/finish", buildID), &model.RunnerBuildStatus{Status: status}, nil)
+	return err
+}
+
+// PublishEvents 批量上报日志事件
+func (c *Client) PublishEvents(ctx context.Context, events []*model.LogEvent) error {
+	_, err := c.do(ctx, http.MethodPost, "/events", events, nil)
+	return err
+}
+
+// UpdateBuildJob 更新构建作业的状态
+func (c *Client) UpdateBuildJob(ctx context.Context, jobID int, status string) error {
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/build-jobs/%d", jobID), &model.RunnerBuildJobUpdate{Status: status}, nil)
+	return err
+}
+
+// UpdateStep 更新步骤的状态、退出码或复用的原步骤
+func (c *Client) UpdateStep(ctx context.Context, stepID int, update *model.RunnerStepUpdate) error {
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/steps/%d", stepID), update, nil)
+	return err
+}
+
+// AppendStepLog 追加步骤日志
+func (c *Client) AppendStepLog(ctx context.Context, stepID int, data []byte) error {
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/steps/%d/log", stepID), data, nil)
+	return err
+}
+
+// CreateStepAttempt 创建步骤执行记录，成功后写入记录ID
+func (c *Client) CreateStepAttempt(ctx context.Context, attempt *model.BuildStepAttempt) error {
+	var created model.BuildStepAttempt
+	if _, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/steps/%d/attempts", attempt.StepID), attempt, &created); err != nil {
+		return err
+	}
+	attempt.ID = created.ID
+	return nil
+}
+
+// FinishStepAttempt 写入步骤执行结果
+func (c *Client) FinishStepAttempt(ctx context.Context, attempt *model.BuildStepAttempt) error {
+	path := fmt.Sprintf("/steps/%d/attempts/%d", attempt.StepID, attempt.Attempt)
+	_, err := c.do(ctx, http.MethodPut, path, attempt, nil)
+	return err
+}
+
+// UploadArtifact 上传制品文件，成功后写入制品的ID、大小和SHA-256
+func (c *Client) UploadArtifact(ctx context.Context, artifact *model.Artifact, content io.ReadSeeker, size int64) error {
+	query := url.Values{"path": {artifact.Path}}
+	if artifact.Mode != 0 {
+		query.Set("mode", strconv.FormatUint(uint64(artifact.Mode), 8))
+	}
+	if artifact.ExpiresAt != nil {
+		query.Set("expires_at", artifact.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	path := fmt.Sprintf("/build-jobs/%d/artifacts?%s", artifact.JobID, query.Encode())
+
+	var created model.Artifact
+	if _, err := c.do(ctx, http.MethodPost, path, &fileBody{content: content, size: size}, &created); err != nil {
+		return err
+	}
+	artifact.ID = created.ID
+	artifact.BuildID = created.BuildID
+	artifact.Mode = created.Mode
+	artifact.Size = created.Size
+	artifact.Checksum = created.Checksum
+	artifact.ExpiresAt = created.ExpiresAt
+	artifact.CreatedAt = created.CreatedAt
+	return nil
+}
+
+// DependencyArtifacts 获取作业所在构建中上游作业 upstream 的制品
+func (c *Client) DependencyArtifacts(ctx context.Context, jobID int, upstream string) ([]*model.Artifact, error) {
+	path := fmt.Sprintf("/build-jobs/%d/dependencies?%s", jobID, url.Values{"job": {upstream}}.Encode())
+
+	var artifacts []*model.Artifact
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &artifacts); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// DownloadArtifact 下载上游作业的制品文件，调用方负责关闭返回的内容
+func (c *Client) DownloadArtifact(ctx context.Context, jobID, artifactID int) (io.ReadCloser, error) {
+	return c.download(ctx, fmt.Sprintf("/build-jobs/%d/dependencies/%d", jobID, artifactID))
+}
+
+// FindCache 查找作业所属项目的缓存，未命中时返回 nil
+func (c *Client) FindCache(ctx context.Context, jobID int, query *model.RunnerCacheQuery) (*model.Cache, error) {
+	var cache model.Cache
+	status, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/build-jobs/%d/caches/restore", jobID), query, &cache)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+// DownloadCache 下载缓存的压缩包，调用方负责关闭返回的内容
+func (c *Client) DownloadCache(ctx context.Context, jobID, cacheID int) (io.ReadCloser, error) {
+	return c.download(ctx, fmt.Sprintf("/build-jobs/%d/caches/%d", jobID, cacheID))
+}
+
+// UploadCache 上传作业打包的缓存压缩包，成功后写入缓存的ID、大小和SHA-256
+func (c *Client) UploadCache(ctx context.Context, jobID int, cache *model.Cache, content io.ReadSeeker, size int64) error {
+	path := fmt.Sprintf("/build-jobs/%d/caches?%s", jobID, url.Values{"key": {cache.Key}}.Encode())
+
+	var saved model.Cache
+	if _, err := c.do(ctx, http.MethodPost, path, &fileBody{content: content, size: size}, &saved); err != nil {
+		return err
+	}
+	*cache = saved
+	return nil
+}
+
+// download 以流的形式下载文件，不重试，调用方负责关闭返回的内容
+func (c *Client) download(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.transfer.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+		var apiResp model.APIResponse
+		if json.NewDecoder(resp.Body).Decode(&apiResp) == nil && apiResp.Message != "" {
+			apiErr.Message = apiResp.Message
+		}
+		return nil, apiErr
+	}
+	return resp.Body, nil
+}
+
+// fileBody 以原始内容发送的文件，重试时从头读取
+type fileBody struct {
+	content io.ReadSeeker
+	size    int64
+}
+
+// do 发送请求并将响应的 data 解码到 out，网络错误和5xx响应按指数退避重试。
+// body 为 []byte 时原样发送，为 *fileBody 时以流的形式发送文件，否则编码为JSON
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var payload []byte
+	var file *fileBody
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		payload = b
+		contentType = "application/octet-stream"
+	case *fileBody:
+		file = b
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request: %w", err)
+		}
+		payload = data
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		var status int
+		var err error
+		if file != nil {
+			status, err = c.sendFile(ctx, method, path, file, out)
+		} else {
+			status, err = c.send(ctx, method, path, contentType, payload, out)
+		}
+		if err == nil || attempt >= maxRetries || !retryable(err) {
+			return status, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path, contentType string, payload []byte, out interface{}) (int, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return c.roundTrip(c.http, req, out)
+}
+
+// sendFile 从头发送文件，请求带有 Content-Length
+func (c *Client) sendFile(ctx context.Context, method, path string, file *fileBody, out interface{}) (int, error) {
+	if _, err := file.content.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	var body io.Reader = io.LimitReader(file.content, file.size)
+	if file.size == 0 {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = file.size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	return c.roundTrip(c.transfer, req, out)
+}
+
+// roundTrip 添加执行器令牌并发送请求，将响应的 data 解码到 out
+func (c *Client) roundTrip(client *http.Client, req *http.Request, out interface{}) (int, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return resp.StatusCode, nil
+	}
+
+	apiResp := model.APIResponse{Data: out}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		if resp.StatusCode >= 300 {
+			return resp.StatusCode, &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+		}
+		return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, &APIError{StatusCode: resp.StatusCode, Message: apiResp.Message}
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable 网络错误和服务端5xx错误可重试，其他API错误（如令牌无效、构建已重新分配）重试无意义
+func retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode >= 500
+}
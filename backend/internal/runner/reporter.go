@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"Vortexia/internal/engine"
+	"Vortexia/internal/model"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	eventFlushInterval = 500 * time.Millisecond // 输出行事件的批量上报间隔
+	maxEventBatch      = 500                    // 缓冲的输出行事件达到该数量时立即上报
+)
+
+// reporter 通过执行器API上报构建执行过程，实现 engine.Reporter。
+// 输出行事件数量多，缓冲后批量上报；其他事件先上报已缓冲的事件再同步上报，保持事件顺序
+type reporter struct {
+	client *Client
+
+	mu     sync.Mutex
+	events []*model.LogEvent
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+var _ engine.Reporter = (*reporter)(nil)
+
+func newReporter(client *Client) *reporter {
+	r := &reporter{
+		client: client,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+// Close 上报剩余的事件并停止后台上报
+func (r *reporter) Close() {
+	close(r.stop)
+	<-r.done
+	r.flush(context.Background())
+}
+
+func (r *reporter) loop() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(eventFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.flush(context.Background())
+		}
+	}
+}
+
+// flush 上报已缓冲的事件，日志事件只用于实时展示，上报失败时丢弃
+func (r *reporter) flush(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.events) == 0 {
+		return
+	}
+	if err := r.client.PublishEvents(ctx, r.events); err != nil {
+		logger.Warn("Failed to publish log events", zap.Int("count", len(r.events)), zap.Error(err))
+	}
+	r.events = nil
+}
+
+// UpdateJobStatus 更新作业状态
+func (r *reporter) UpdateJobStatus(jobID int, status string) error {
+	return r.client.UpdateBuildJob(context.Background(), jobID, status)
+}
+
+// UpdateStepStatus 更新步骤状态
+func (r *reporter) UpdateStepStatus(stepID int, status string) error {
+	return r.client.UpdateStep(context.Background(), stepID, &model.RunnerStepUpdate{Status: status})
+}
+
+// SetStepExitCode 记录步骤命令的退出码
+func (r *reporter) SetStepExitCode(stepID int, exitCode int) error {
+	return r.client.UpdateStep(context.Background(), stepID, &model.RunnerStepUpdate{ExitCode: &exitCode})
+}
+
+// ReuseStep 复用原步骤的结果
+func (r *reporter) ReuseStep(stepID int, fromStepID int) error {
+	return r.client.UpdateStep(context.Background(), stepID, &model.RunnerStepUpdate{ReusedFrom: &fromStepID})
+}
+
+// CreateStepAttempt 创建步骤执行记录
+func (r *reporter) CreateStepAttempt(attempt *model.BuildStepAttempt) error {
+	return r.client.CreateStepAttempt(context.Background(), attempt)
+}
+
+// FinishStepAttempt 写入步骤执行结果
+func (r *reporter) FinishStepAttempt(attempt *model.BuildStepAttempt) error {
+	return r.client.FinishStepAttempt(context.Background(), attempt)
+}
+
+// UpdateCommit 记录构建实际检出的提交
+func (r *reporter) UpdateCommit(buildID int, commit string) error {
+	return r.client.UpdateCommit(context.Background(), buildID, commit)
+}
+
+// AppendLog 追加步骤日志
+func (r *reporter) AppendLog(stepID int, data []byte) error {
+	return r.client.AppendStepLog(context.Background(), stepID, data)
+}
+
+// Publish 发布日志事件
+func (r *reporter) Publish(ctx context.Context, event *model.LogEvent) error {
+	if event.Type == model.LogEventLog {
+		r.mu.Lock()
+		r.events = append(r.events, event)
+		full := len(r.events) >= maxEventBatch
+		r.mu.Unlock()
+
+		if full {
+			r.flush(ctx)
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+	r.flush(ctx)
+	return nil
+}
+
+// UploadArtifact 上传制品文件
+func (r *reporter) UploadArtifact(ctx context.Context, artifact *model.Artifact, content io.ReadSeeker, size int64) error {
+	return r.client.UploadArtifact(ctx, artifact, content, size)
+}
+
+// DependencyArtifacts 获取上游作业的制品
+func (r *reporter) DependencyArtifacts(ctx context.Context, jobID int, upstream string) ([]*model.Artifact, error) {
+	return r.client.DependencyArtifacts(ctx, jobID, upstream)
+}
+
+// DownloadArtifact 下载上游作业的制品文件
+func (r *reporter) DownloadArtifact(ctx context.Context, jobID int, artifact *model.Artifact) (io.ReadCloser, error) {
+	return r.client.DownloadArtifact(ctx, jobID, artifact.ID)
+}
+
+// RestoreCache 查找并下载缓存
+func (r *reporter) RestoreCache(ctx context.Context, jobID int, cache *model.Cache, restoreKeys []string) (io.ReadCloser, error) {
+	found, err := r.client.FindCache(ctx, jobID, &model.RunnerCacheQuery{Key: cache.Key, RestoreKeys: restoreKeys})
+	if err != nil || found == nil {
+		return nil, err
+	}
+
+	content, err := r.client.DownloadCache(ctx, jobID, found.ID)
+	if err != nil {
+		return nil, err
+	}
+	*cache = *found
+	return content, nil
+}
+
+// SaveCache 上传缓存压缩包
+func (r *reporter) SaveCache(ctx context.Context, jobID int, cache *model.Cache, content io.ReadSeeker, size int64) error {
+	return r.client.UploadCache(ctx, jobID, cache, content, size)
+}
+
+// BuildStatus 返回构建当前的状态
+func (r *reporter) BuildStatus(buildID int) (string, error) {
+	return r.client.JobStatus(context.Background(), buildID)
+}
+
+// FinishBuild 写入构建的最终状态
+func (r *reporter) FinishBuild(buildID int, status string) error {
+	return r.client.FinishJob(context.Background(), buildID, status)
+}
@@ -0,0 +1,239 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/engine"
+	"Vortexia/internal/model"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Version 执行器版本，注册时上报，构建时可通过 -ldflags "-X Vortexia/internal/runner.Version=..." 指定
+var Version = "dev"
+
+// errorBackoff 领取构建失败后的等待时间
+const errorBackoff = 5 * time.Second
+
+// Runner 远程执行器，从服务端领取构建在本机执行，并通过执行器API上报结果
+type Runner struct {
+	cfg    *config.Config
+	client *Client
+
+	mu   sync.Mutex
+	jobs map[int]*engine.Engine // 正在执行的构建
+
+	ctx    context.Context // Stop 时取消，中断长轮询
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 创建执行器，没有保存的执行器令牌时使用注册令牌注册
+func New(ctx context.Context, cfg *config.Config) (*Runner, error) {
+	token, err := loadToken(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Runner{
+		cfg:    cfg,
+		client: NewClient(cfg.Runner.ServerURL, token),
+		jobs:   make(map[int]*engine.Engine),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r, nil
+}
+
+// loadToken 读取保存的执行器令牌，不存在时注册执行器并保存令牌
+func loadToken(ctx context.Context, cfg *config.Config) (string, error) {
+	data, err := os.ReadFile(cfg.Runner.TokenFile)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read runner token: %w", err)
+	}
+
+	if cfg.Runner.RegistrationToken == "" {
+		return "", errors.New("runner is not registered and RUNNER_REGISTRATION_TOKEN is not set")
+	}
+
+	resp, err := NewClient(cfg.Runner.ServerURL, "").Register(ctx, &model.RegisterRunnerRequest{
+		Token:   cfg.Runner.RegistrationToken,
+		Name:    cfg.Runner.Name,
+		Labels:  cfg.Runner.Labels,
+		Version: Version,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to register runner: %w", err)
+	}
+
+	if err := os.WriteFile(cfg.Runner.TokenFile, []byte(resp.Token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to save runner token: %w", err)
+	}
+
+	logger.Info("Runner registered", zap.Int("runner_id", resp.Runner.ID), zap.String("name", resp.Runner.Name))
+	return resp.Token, nil
+}
+
+// Start 启动心跳协程和领取构建的协程
+func (r *Runner) Start() {
+	r.wg.Add(1)
+	go r.heartbeat()
+
+	concurrency := r.cfg.Runner.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	for i := 0; i < concurrency; i++ {
+		r.wg.Add(1)
+		go r.work(i)
+	}
+
+	logger.Info("Runner started",
+		zap.String("server", r.cfg.Runner.ServerURL),
+		zap.Int("concurrency", concurrency),
+	)
+}
+
+// Stop 停止领取新构建并等待执行中的构建结束。
+// ctx 到期时直接返回，未完成的构建在租约过期后由服务端重新投递
+func (r *Runner) Stop(ctx context.Context) error {
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work 领取构建的主循环
+func (r *Runner) work(id int) {
+	defer r.wg.Done()
+
+	for r.ctx.Err() == nil {
+		job, err := r.client.RequestJob(r.ctx)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+			logger.Error("Failed to request job", zap.Int("worker", id), zap.Error(err))
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(errorBackoff):
+			}
+			continue
+		}
+		if job == nil {
+			continue
+		}
+
+		r.execute(id, job)
+	}
+}
+
+// execute 执行领取到的构建。构建不随 Stop 取消，以便执行完后正常上报结果
+func (r *Runner) execute(id int, job *model.RunnerJob) {
+	log := logger.Logger.With(zap.Int("worker", id), zap.Int("build_id", job.Build.ID))
+
+	rep := newReporter(r.client)
+	defer rep.Close()
+
+	eng := engine.NewRemote(rep, r.cfg)
+	r.mu.Lock()
+	r.jobs[job.Build.ID] = eng
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.jobs, job.Build.ID)
+		r.mu.Unlock()
+	}()
+
+	log.Info("Executing build")
+	if err := eng.Run(context.Background(), job); err != nil {
+		if IsConflict(err) {
+			// 执行器失联期间构建已被取消或重新分配，结果由服务端处理
+			log.Warn("Build is no longer assigned to this runner")
+			return
+		}
+		log.Error("Build execution failed", zap.Error(err))
+		return
+	}
+	log.Info("Build finished")
+}
+
+// running 返回正在执行的构建
+func (r *Runner) running() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]int, 0, len(r.jobs))
+	for id := range r.jobs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// abort 终止正在执行的构建
+func (r *Runner) abort(buildID int) {
+	r.mu.Lock()
+	eng, ok := r.jobs[buildID]
+	r.mu.Unlock()
+
+	if ok && eng.Abort(buildID) {
+		logger.Info("Build aborted by server", zap.Int("build_id", buildID))
+	}
+}
+
+// heartbeat 定期上报在线状态、标签和正在执行的构建，为这些构建续租，并终止服务端要求终止的构建。
+// 启动时立即上报一次，使修改后的 RUNNER_LABELS 尽快生效；Stop 后继续心跳，直到执行中的构建结束
+func (r *Runner) heartbeat() {
+	defer r.wg.Done()
+
+	interval := time.Duration(r.cfg.Runner.HeartbeatInterval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// 标签为空时也上报，以便清除注册时的标签
+	labels := append([]string{}, r.cfg.Runner.Labels...)
+	for {
+		cancel, err := r.client.Heartbeat(context.Background(), r.running(), labels)
+		if err != nil {
+			logger.Warn("Heartbeat failed", zap.Error(err))
+		}
+		for _, id := range cancel {
+			r.abort(id)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.ctx.Done():
+			if len(r.running()) == 0 {
+				return
+			}
+			// 等待执行中的构建结束期间仍需心跳
+			<-ticker.C
+		}
+	}
+}
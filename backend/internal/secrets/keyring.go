@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+)
+
+// ErrNoMasterKey 未配置主密钥，无法加密密钥
+var ErrNoMasterKey = errors.New("no secrets master key configured")
+
+// Keyring 使用主密钥以 AES-256-GCM 加解密密钥的值。
+// 第一个主密钥用于加密，其余主密钥只用于解密轮换前保存的密钥
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring 根据配置的主密钥创建密钥环，未配置主密钥时加密返回 ErrNoMasterKey
+func NewKeyring(keys []config.MasterKey) (*Keyring, error) {
+	k := &Keyring{aeads: make(map[string]cipher.AEAD, len(keys))}
+	for i, key := range keys {
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher for master key %q: %w", key.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCM for master key %q: %w", key.ID, err)
+		}
+		k.aeads[key.ID] = aead
+		if i == 0 {
+			k.primary = key.ID
+		}
+	}
+	return k, nil
+}
+
+// Primary 返回用于加密的主密钥标识，未配置主密钥时为空
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Seal 使用当前主密钥加密密钥的值，写入 KeyID 和 Value。
+// 密钥的作用域和名称作为附加数据参与认证，密文不能被挪用到其他密钥
+func (k *Keyring) Seal(secret *model.Secret, value string) error {
+	keyID, sealed, err := k.seal([]byte(value), additionalData(secret))
+	if err != nil {
+		return err
+	}
+	secret.KeyID, secret.Value = keyID, sealed
+	return nil
+}
+
+// Open 解密密钥的值
+func (k *Keyring) Open(secret *model.Secret) (string, error) {
+	plaintext, err := k.open(secret.KeyID, secret.Value, additionalData(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %d: %w", secret.ID, err)
+	}
+	return string(plaintext), nil
+}
+
+// SealWebhook 使用当前主密钥加密项目 webhook 的密钥，写入 KeyID 和 Secret
+func (k *Keyring) SealWebhook(webhook *model.ProjectWebhook, secret string) error {
+	keyID, sealed, err := k.seal([]byte(secret), webhookAdditionalData(webhook))
+	if err != nil {
+		return err
+	}
+	webhook.KeyID, webhook.Secret = keyID, sealed
+	return nil
+}
+
+// OpenWebhook 解密项目 webhook 的密钥
+func (k *Keyring) OpenWebhook(webhook *model.ProjectWebhook) (string, error) {
+	plaintext, err := k.open(webhook.KeyID, webhook.Secret, webhookAdditionalData(webhook))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt webhook secret of project %d: %w", webhook.ProjectID, err)
+	}
+	return string(plaintext), nil
+}
+
+// seal 使用当前主密钥加密，返回主密钥标识以及随机数和密文
+func (k *Keyring) seal(plaintext, ad []byte) (string, []byte, error) {
+	aead, ok := k.aeads[k.primary]
+	if !ok {
+		return "", nil, ErrNoMasterKey
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return k.primary, aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+// open 使用 keyID 对应的主密钥解密随机数和密文
+func (k *Keyring) open(keyID string, sealed, ad []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q is not configured", keyID)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, ad)
+}
+
+// additionalData 密钥的作用域和名称
+func additionalData(secret *model.Secret) []byte {
+	pipelineID := 0
+	if secret.PipelineID != nil {
+		pipelineID = *secret.PipelineID
+	}
+	return []byte(fmt.Sprintf("project=%d;pipeline=%d;name=%s", secret.ProjectID, pipelineID, secret.Name))
+}
+
+// webhookAdditionalData webhook 所属的项目，与密钥的附加数据格式不同，两者的密文不能互换
+func webhookAdditionalData(webhook *model.ProjectWebhook) []byte {
+	return []byte(fmt.Sprintf("webhook;project=%d", webhook.ProjectID))
+}
@@ -0,0 +1,202 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+)
+
+func testKey(id string, b byte) config.MasterKey {
+	return config.MasterKey{ID: id, Key: bytes.Repeat([]byte{b}, 32)}
+}
+
+func newTestKeyring(t *testing.T, keys ...config.MasterKey) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(keys)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	return k
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestKeyringRoundTrip(t *testing.T) {
+	k := newTestKeyring(t, testKey("k1", 1))
+
+	tests := []struct {
+		name   string
+		secret model.Secret
+		value  string
+	}{
+		{name: "project secret", secret: model.Secret{ID: 1, ProjectID: 3, Name: "TOKEN"}, value: "s3cr3t"},
+		{name: "pipeline secret", secret: model.Secret{ID: 2, ProjectID: 3, PipelineID: intPtr(5), Name: "TOKEN"}, value: "s3cr3t"},
+		{name: "empty value", secret: model.Secret{ID: 3, ProjectID: 3, Name: "EMPTY"}, value: ""},
+		{name: "multi-line value", secret: model.Secret{ID: 4, ProjectID: 3, Name: "KEY"}, value: "-----BEGIN KEY-----\nabc\n-----END KEY-----\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := tt.secret
+			if err := k.Seal(&secret, tt.value); err != nil {
+				t.Fatalf("Seal() error = %v", err)
+			}
+			if secret.KeyID != "k1" {
+				t.Errorf("KeyID = %q, want k1", secret.KeyID)
+			}
+			if tt.value != "" && bytes.Contains(secret.Value, []byte(tt.value)) {
+				t.Errorf("sealed value contains the plaintext")
+			}
+
+			got, err := k.Open(&secret)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if got != tt.value {
+				t.Errorf("Open() = %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestKeyringSealUsesFreshNonce(t *testing.T) {
+	k := newTestKeyring(t, testKey("k1", 1))
+	a := model.Secret{ProjectID: 1, Name: "TOKEN"}
+	b := a
+	if err := k.Seal(&a, "value"); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if err := k.Seal(&b, "value"); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if bytes.Equal(a.Value, b.Value) {
+		t.Errorf("sealing the same value twice produced identical ciphertexts")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	// 轮换前使用 old 加密的密钥，轮换后 old 排在第二位，仍可解密
+	before := newTestKeyring(t, testKey("old", 1))
+	secret := model.Secret{ID: 1, ProjectID: 2, Name: "TOKEN"}
+	if err := before.Seal(&secret, "before rotation"); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	hook := model.ProjectWebhook{ProjectID: 2}
+	if err := before.SealWebhook(&hook, "hook secret"); err != nil {
+		t.Fatalf("SealWebhook() error = %v", err)
+	}
+
+	after := newTestKeyring(t, testKey("new", 2), testKey("old", 1))
+	if after.Primary() != "new" {
+		t.Errorf("Primary() = %q, want new", after.Primary())
+	}
+
+	got, err := after.Open(&secret)
+	if err != nil || got != "before rotation" {
+		t.Errorf("Open() with retired key = %q, %v, want %q", got, err, "before rotation")
+	}
+	gotHook, err := after.OpenWebhook(&hook)
+	if err != nil || gotHook != "hook secret" {
+		t.Errorf("OpenWebhook() with retired key = %q, %v, want %q", gotHook, err, "hook secret")
+	}
+
+	// 重新加密后使用新的主密钥，移除 old 后仍可解密
+	if err := after.Seal(&secret, "after rotation"); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if secret.KeyID != "new" {
+		t.Errorf("KeyID after rotation = %q, want new", secret.KeyID)
+	}
+	retired := newTestKeyring(t, testKey("new", 2))
+	if got, err := retired.Open(&secret); err != nil || got != "after rotation" {
+		t.Errorf("Open() after removing old key = %q, %v, want %q", got, err, "after rotation")
+	}
+}
+
+func TestKeyringRejectsMismatchedAdditionalData(t *testing.T) {
+	k := newTestKeyring(t, testKey("k1", 1))
+	sealed := model.Secret{ID: 1, ProjectID: 2, PipelineID: intPtr(3), Name: "TOKEN"}
+	if err := k.Seal(&sealed, "value"); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *model.Secret)
+	}{
+		{name: "other name", modify: func(s *model.Secret) { s.Name = "OTHER" }},
+		{name: "other project", modify: func(s *model.Secret) { s.ProjectID = 9 }},
+		{name: "other pipeline", modify: func(s *model.Secret) { s.PipelineID = intPtr(4) }},
+		{name: "moved to project scope", modify: func(s *model.Secret) { s.PipelineID = nil }},
+		{name: "tampered ciphertext", modify: func(s *model.Secret) {
+			s.Value = append([]byte(nil), s.Value...)
+			s.Value[len(s.Value)-1] ^= 1
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := sealed
+			tt.modify(&secret)
+			if got, err := k.Open(&secret); err == nil {
+				t.Errorf("Open() = %q, want error", got)
+			}
+		})
+	}
+}
+
+func TestKeyringWebhookAndSecretNotInterchangeable(t *testing.T) {
+	k := newTestKeyring(t, testKey("k1", 1))
+
+	hook := model.ProjectWebhook{ProjectID: 2}
+	if err := k.SealWebhook(&hook, "hook secret"); err != nil {
+		t.Fatalf("SealWebhook() error = %v", err)
+	}
+	secret := model.Secret{ProjectID: 2, Name: "TOKEN", KeyID: hook.KeyID, Value: hook.Secret}
+	if _, err := k.Open(&secret); err == nil {
+		t.Errorf("Open() accepted a webhook ciphertext")
+	}
+
+	other := model.ProjectWebhook{ProjectID: 3, KeyID: hook.KeyID, Secret: hook.Secret}
+	if _, err := k.OpenWebhook(&other); err == nil {
+		t.Errorf("OpenWebhook() accepted a ciphertext of another project")
+	}
+}
+
+func TestKeyringErrors(t *testing.T) {
+	t.Run("no master key", func(t *testing.T) {
+		k := newTestKeyring(t)
+		err := k.Seal(&model.Secret{Name: "TOKEN"}, "value")
+		if !errors.Is(err, ErrNoMasterKey) {
+			t.Errorf("Seal() error = %v, want ErrNoMasterKey", err)
+		}
+	})
+
+	t.Run("invalid key length", func(t *testing.T) {
+		if _, err := NewKeyring([]config.MasterKey{{ID: "short", Key: []byte("short")}}); err == nil {
+			t.Errorf("NewKeyring() accepted a 5-byte key")
+		}
+	})
+
+	k := newTestKeyring(t, testKey("k1", 1))
+	tests := []struct {
+		name    string
+		secret  model.Secret
+		wantErr string
+	}{
+		{name: "unknown key", secret: model.Secret{KeyID: "gone", Value: make([]byte, 64)}, wantErr: `master key "gone" is not configured`},
+		{name: "short ciphertext", secret: model.Secret{KeyID: "k1", Value: []byte{1, 2, 3}}, wantErr: "ciphertext is too short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := k.Open(&tt.secret)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Open() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
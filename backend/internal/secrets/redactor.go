@@ -0,0 +1,157 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Mask 替换密钥的文本
+const Mask = "***"
+
+// minRedactLength 参与替换的最短文本，过短的密钥（如 1、true）会把大量无关输出替换掉
+const minRedactLength = 4
+
+// Redactor 将输出中的密钥替换为 ***，同时替换密钥的 base64 编码和URL编码形式。
+// 多行密钥（如私钥）还会逐行替换，避免输出时换行符被改写后无法匹配
+type Redactor struct {
+	byFirst map[byte][][]byte // 按首字节索引的待替换文本，同一首字节的按长度从长到短排列
+	maxLen  int
+}
+
+// NewRedactor 根据密钥的值创建替换器
+func NewRedactor(values []string) *Redactor {
+	r := &Redactor{byFirst: make(map[byte][][]byte)}
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if len(s) < minRedactLength || seen[s] {
+			return
+		}
+		seen[s] = true
+		r.byFirst[s[0]] = append(r.byFirst[s[0]], []byte(s))
+		if len(s) > r.maxLen {
+			r.maxLen = len(s)
+		}
+	}
+
+	for _, value := range values {
+		// 过短的密钥连同其编码形式都不替换，否则如 ab 的 base64 编码 YWI= 仍会被替换
+		if len(value) < minRedactLength {
+			continue
+		}
+		for _, form := range encodedForms(value) {
+			add(form)
+		}
+		if strings.Contains(value, "\n") {
+			for _, line := range strings.Split(value, "\n") {
+				add(strings.TrimSpace(line))
+			}
+		}
+	}
+
+	for _, patterns := range r.byFirst {
+		sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	}
+	return r
+}
+
+// encodedForms 返回密钥本身及其常见的编码形式。base64 同时包含带填充和不带填充的形式，
+// 以及 echo 输出的带换行符的版本，即 echo $TOKEN | base64 的结果
+func encodedForms(value string) []string {
+	forms := []string{value, url.QueryEscape(value), url.PathEscape(value)}
+	for _, s := range []string{value, value + "\n"} {
+		for _, enc := range []*base64.Encoding{
+			base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+		} {
+			forms = append(forms, enc.EncodeToString([]byte(s)))
+		}
+	}
+	return forms
+}
+
+// Empty 判断是否没有需要替换的文本
+func (r *Redactor) Empty() bool {
+	return r == nil || len(r.byFirst) == 0
+}
+
+// Redact 替换一段完整文本中的密钥
+func (r *Redactor) Redact(data []byte) []byte {
+	if r.Empty() {
+		return data
+	}
+	out, _ := r.redact(data, true)
+	return out
+}
+
+// Stream 创建流式替换器，用于分多次写入的输出
+func (r *Redactor) Stream() *RedactStream {
+	return &RedactStream{r: r}
+}
+
+// redact 替换 data 中的密钥。final 为 false 时，末尾可能是某个密钥开头的部分不输出，
+// 作为 rest 返回，等待与后续输出拼接后再判断
+func (r *Redactor) redact(data []byte, final bool) (out, rest []byte) {
+	out = make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		matched, partial := r.match(data[i:])
+		switch {
+		case partial && !final:
+			return out, data[i:]
+		case matched > 0:
+			out = append(out, Mask...)
+			i += matched
+		default:
+			out = append(out, data[i])
+			i++
+		}
+	}
+	return out, nil
+}
+
+// match 返回 data 开头匹配的最长密钥的长度。partial 表示 data 是某个更长的密钥的开头部分，
+// 此时需要后续输出才能确定应替换的范围
+func (r *Redactor) match(data []byte) (matched int, partial bool) {
+	for _, pattern := range r.byFirst[data[0]] {
+		if bytes.HasPrefix(data, pattern) {
+			return len(pattern), partial
+		}
+		if len(data) < len(pattern) && bytes.HasPrefix(pattern, data) {
+			partial = true
+		}
+	}
+	return 0, partial
+}
+
+// RedactStream 流式替换器，密钥被拆分到相邻的两次写入中时也能替换。
+// 末尾可能是密钥开头的内容会暂缓输出，直到后续内容能够确定或调用 Flush
+type RedactStream struct {
+	r    *Redactor
+	held []byte
+}
+
+// Write 返回替换后可以输出的内容
+func (s *RedactStream) Write(p []byte) []byte {
+	if s.r.Empty() {
+		return p
+	}
+
+	data := p
+	if len(s.held) > 0 {
+		data = append(s.held, p...)
+	}
+	out, rest := s.r.redact(data, false)
+	s.held = append([]byte(nil), rest...)
+	return out
+}
+
+// Flush 返回暂缓输出的内容
+func (s *RedactStream) Flush() []byte {
+	if len(s.held) == 0 {
+		return nil
+	}
+	out := s.r.Redact(s.held)
+	s.held = nil
+	return out
+}
@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	const secret = "s3cr3t/t0ken+value"
+
+	tests := []struct {
+		name   string
+		values []string
+		input  string
+		want   string
+	}{
+		{name: "plain", values: []string{secret}, input: "token=" + secret + " done", want: "token=*** done"},
+		{name: "repeated", values: []string{secret}, input: secret + secret, want: "******"},
+		{name: "base64 std", values: []string{secret}, input: base64.StdEncoding.EncodeToString([]byte(secret)), want: "***"},
+		{name: "base64 raw std", values: []string{secret}, input: "x " + base64.RawStdEncoding.EncodeToString([]byte(secret)) + " x", want: "x *** x"},
+		{name: "base64 url", values: []string{secret}, input: base64.URLEncoding.EncodeToString([]byte(secret)), want: "***"},
+		{name: "base64 raw url", values: []string{secret}, input: base64.RawURLEncoding.EncodeToString([]byte(secret)), want: "***"},
+		{name: "base64 of echo output", values: []string{secret}, input: base64.StdEncoding.EncodeToString([]byte(secret + "\n")), want: "***"},
+		{name: "query escaped", values: []string{secret}, input: "?t=" + url.QueryEscape(secret), want: "?t=***"},
+		{name: "path escaped", values: []string{"a b/c?d"}, input: "/x/" + url.PathEscape("a b/c?d"), want: "/x/***"},
+		{
+			name:   "multi-line as a whole",
+			values: []string{"-----BEGIN KEY-----\nAAAABBBB\nCCCCDDDD\n-----END KEY-----"},
+			input:  "-----BEGIN KEY-----\nAAAABBBB\nCCCCDDDD\n-----END KEY-----",
+			want:   "***",
+		},
+		{
+			name:   "multi-line with rewritten line endings",
+			values: []string{"-----BEGIN KEY-----\nAAAABBBB\nCCCCDDDD\n-----END KEY-----"},
+			input:  "-----BEGIN KEY-----\r\n  AAAABBBB\r\n  CCCCDDDD\r\n",
+			want:   "***\r\n  ***\r\n  ***\r\n",
+		},
+		{name: "longest match wins", values: []string{"abcd", "abcdefgh"}, input: "abcdefgh abcd", want: "*** ***"},
+		{name: "shorter than minimum", values: []string{"abc", "true"}, input: "abc is true", want: "abc is ***"},
+		{name: "encoded forms of short value", values: []string{"abc"}, input: "YWJj " + url.QueryEscape("abc"), want: "YWJj abc"},
+		{name: "empty value", values: []string{""}, input: "nothing to hide", want: "nothing to hide"},
+		{name: "no secrets", values: nil, input: secret, want: secret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(NewRedactor(tt.values).Redact([]byte(tt.input)))
+			if got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorEmpty(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   bool
+	}{
+		{name: "nil", values: nil, want: true},
+		{name: "only short values", values: []string{"", "a", "ab"}, want: true},
+		{name: "minimum length", values: []string{"abcd"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRedactor(tt.values).Empty(); got != tt.want {
+				t.Errorf("Empty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var r *Redactor
+	if !r.Empty() {
+		t.Errorf("nil Redactor is not empty")
+	}
+}
+
+// streamAll 按 sizes 将 input 切分后逐块写入，返回全部输出
+func streamAll(r *Redactor, input string, sizes ...int) string {
+	s := r.Stream()
+	var out strings.Builder
+	for _, size := range sizes {
+		out.Write(s.Write([]byte(input[:size])))
+		input = input[size:]
+	}
+	out.Write(s.Write([]byte(input)))
+	out.Write(s.Flush())
+	return out.String()
+}
+
+func TestRedactStreamEveryBoundary(t *testing.T) {
+	// 密钥在任意位置被拆分到两次写入中都能替换
+	const secret = "s3cr3t-t0ken"
+	r := NewRedactor([]string{secret})
+	encoded := base64.StdEncoding.EncodeToString([]byte(secret))
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain", input: "before " + secret + " after", want: "before *** after"},
+		{name: "base64", input: "b64: " + encoded + "\n", want: "b64: ***\n"},
+		{name: "adjacent", input: secret + secret, want: "******"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i <= len(tt.input); i++ {
+				if got := streamAll(r, tt.input, i); got != tt.want {
+					t.Errorf("split at %d: got %q, want %q", i, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactStreamByteByByte(t *testing.T) {
+	const secret = "s3cr3t-t0ken"
+	input := "x" + secret + "y" + url.QueryEscape(secret+"?") + "z"
+	sizes := make([]int, len(input))
+	for i := range sizes {
+		sizes[i] = 1
+	}
+
+	r := NewRedactor([]string{secret, secret + "?"})
+	got := streamAll(r, input, sizes...)
+	if want := "x***y***z"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRedactStreamHoldsPartialMatch(t *testing.T) {
+	s := NewRedactor([]string{"s3cr3t-t0ken"}).Stream()
+
+	if got := string(s.Write([]byte("log s3cr3t"))); got != "log " {
+		t.Errorf("Write() = %q, want the possible secret prefix held back", got)
+	}
+	// 后续输出与暂缓的内容不构成密钥时原样输出
+	if got := string(s.Write([]byte("-x\n"))); got != "s3cr3t-x\n" {
+		t.Errorf("Write() = %q, want %q", got, "s3cr3t-x\n")
+	}
+
+	if got := string(s.Write([]byte("end s3cr"))); got != "end " {
+		t.Errorf("Write() = %q, want %q", got, "end ")
+	}
+	if got := string(s.Flush()); got != "s3cr" {
+		t.Errorf("Flush() = %q, want %q", got, "s3cr")
+	}
+	if got := s.Flush(); got != nil {
+		t.Errorf("second Flush() = %q, want nil", got)
+	}
+}
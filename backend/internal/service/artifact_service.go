@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/storage"
+)
+
+// ErrArtifactExpired 制品已过期，文件即将或已经被删除
+var ErrArtifactExpired = errors.New("制品已过期")
+
+type artifactService struct {
+	artifactRepo repository.ArtifactRepository
+	buildRepo    repository.BuildRepository
+	pipelineRepo repository.PipelineRepository
+	projectRepo  repository.ProjectRepository
+	artifacts    *storage.ArtifactStore
+}
+
+// NewArtifactService 创建制品服务实例
+func NewArtifactService(repos *repository.Repositories, artifacts *storage.ArtifactStore) ArtifactService {
+	return &artifactService{
+		artifactRepo: repos.Artifact,
+		buildRepo:    repos.Build,
+		pipelineRepo: repos.Pipeline,
+		projectRepo:  repos.Project,
+		artifacts:    artifacts,
+	}
+}
+
+// ListByBuild 获取构建的制品，构建不存在或用户不是构建所属项目的所有者或管理员时返回 nil
+func (s *artifactService) ListByBuild(buildID int, user *model.User) ([]*model.Artifact, error) {
+	allowed, err := s.canAccessBuild(buildID, user)
+	if err != nil || !allowed {
+		return nil, err
+	}
+	return s.artifactRepo.GetByBuild(buildID)
+}
+
+// Open 打开构建的制品文件，制品不存在、不属于该构建或用户无权访问构建时返回 nil，
+// 已过期时返回 ErrArtifactExpired
+func (s *artifactService) Open(ctx context.Context, buildID, artifactID int, user *model.User) (*model.Artifact, io.ReadCloser, error) {
+	allowed, err := s.canAccessBuild(buildID, user)
+	if err != nil || !allowed {
+		return nil, nil, err
+	}
+
+	artifact, err := s.artifactRepo.GetByID(artifactID)
+	if err != nil || artifact == nil || artifact.BuildID != buildID {
+		return nil, nil, err
+	}
+	if artifact.ExpiresAt != nil && !artifact.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrArtifactExpired
+	}
+
+	content, err := s.artifacts.Open(ctx, artifact)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// 记录存在但文件已丢失，视为制品不存在
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	return artifact, content, nil
+}
+
+// canAccessBuild 判断用户能否访问构建的制品，构建不存在时返回 false。
+// 无权访问与不存在的结果相同，不暴露其他项目的构建是否存在
+func (s *artifactService) canAccessBuild(buildID int, user *model.User) (bool, error) {
+	build, err := s.buildRepo.GetByID(buildID)
+	if err != nil || build == nil {
+		return false, err
+	}
+	pipeline, err := s.pipelineRepo.GetByID(build.PipelineID)
+	if err != nil || pipeline == nil {
+		return false, err
+	}
+	project, err := s.projectRepo.GetByID(pipeline.ProjectID)
+	if err != nil {
+		return false, err
+	}
+	return canAccessProject(project, user), nil
+}
+
+// DeleteExpired 删除已过期的制品，返回删除的数量
+func (s *artifactService) DeleteExpired(ctx context.Context) (int, error) {
+	return s.artifacts.DeleteExpired(ctx)
+}
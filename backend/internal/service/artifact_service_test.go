@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+)
+
+type fakeBuildRepo struct {
+	repository.BuildRepository
+	builds map[int]*model.Build
+}
+
+func (r *fakeBuildRepo) GetByID(id int) (*model.Build, error) { return r.builds[id], nil }
+
+type fakePipelineRepo struct {
+	repository.PipelineRepository
+	pipelines map[int]*model.Pipeline
+}
+
+func (r *fakePipelineRepo) GetByID(id int) (*model.Pipeline, error) { return r.pipelines[id], nil }
+
+type fakeProjectRepo struct {
+	repository.ProjectRepository
+	projects map[int]*model.Project
+}
+
+func (r *fakeProjectRepo) GetByID(id int) (*model.Project, error) { return r.projects[id], nil }
+
+type fakeArtifactRepo struct {
+	repository.ArtifactRepository
+	artifacts []*model.Artifact
+}
+
+func (r *fakeArtifactRepo) GetByID(id int) (*model.Artifact, error) {
+	for _, artifact := range r.artifacts {
+		if artifact.ID == id {
+			return artifact, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeArtifactRepo) GetByBuild(buildID int) ([]*model.Artifact, error) {
+	result := []*model.Artifact{}
+	for _, artifact := range r.artifacts {
+		if artifact.BuildID == buildID {
+			result = append(result, artifact)
+		}
+	}
+	return result, nil
+}
+
+func TestArtifactServiceAccess(t *testing.T) {
+	// 构建 1 属于用户 10 的项目
+	s := &artifactService{
+		artifactRepo: &fakeArtifactRepo{artifacts: []*model.Artifact{{ID: 5, BuildID: 1, Path: "dist/app"}}},
+		buildRepo:    &fakeBuildRepo{builds: map[int]*model.Build{1: {ID: 1, PipelineID: 2}}},
+		pipelineRepo: &fakePipelineRepo{pipelines: map[int]*model.Pipeline{2: {ID: 2, ProjectID: 3}}},
+		projectRepo:  &fakeProjectRepo{projects: map[int]*model.Project{3: {ID: 3, OwnerID: 10}}},
+	}
+
+	tests := []struct {
+		name    string
+		buildID int
+		user    *model.User
+		want    bool
+	}{
+		{name: "owner", buildID: 1, user: &model.User{ID: 10, Role: model.RoleUser}, want: true},
+		{name: "admin", buildID: 1, user: &model.User{ID: 1, Role: model.RoleAdmin}, want: true},
+		{name: "other user", buildID: 1, user: &model.User{ID: 11, Role: model.RoleUser}},
+		{name: "no user", buildID: 1},
+		{name: "missing build", buildID: 9, user: &model.User{ID: 1, Role: model.RoleAdmin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			artifacts, err := s.ListByBuild(tt.buildID, tt.user)
+			if err != nil {
+				t.Fatalf("ListByBuild() error = %v", err)
+			}
+			if got := artifacts != nil; got != tt.want {
+				t.Errorf("ListByBuild() = %v, want allowed %v", artifacts, tt.want)
+			}
+
+			if tt.want {
+				return
+			}
+			// 无权访问时与制品不存在的结果相同，不读取文件
+			artifact, content, err := s.Open(context.Background(), tt.buildID, 5, tt.user)
+			if err != nil || artifact != nil || content != nil {
+				t.Errorf("Open() = %v, %v, %v, want not found", artifact, content, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -12,13 +15,17 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// streamTicketTTL 流式请求票据的有效期，票据签发后应立即用于建立连接
+const streamTicketTTL = 30 * time.Second
+
 type authService struct {
 	userRepo repository.UserRepository
+	tickets  repository.StreamTicketStore
 }
 
 // NewAuthService 创建认证服务实例
-func NewAuthService(userRepo repository.UserRepository) AuthService {
-	return &authService{userRepo: userRepo}
+func NewAuthService(userRepo repository.UserRepository, tickets repository.StreamTicketStore) AuthService {
+	return &authService{userRepo: userRepo, tickets: tickets}
 }
 
 // Login 用户登录
@@ -113,6 +120,43 @@ func (s *authService) GenerateToken(user *model.User) (string, error) {
 	return token.SignedString([]byte(cfg.JWT.Secret))
 }
 
+// IssueStreamTicket 为用户签发只能使用一次的短期票据。浏览器的WebSocket/EventSource和下载链接
+// 无法设置请求头，使用票据代替URL中的JWT，避免长期有效的令牌被访问日志和Referer记录
+func (s *authService) IssueStreamTicket(user *model.User) (*model.StreamTicketResponse, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	ticket := hex.EncodeToString(b)
+	if err := s.tickets.Save(context.Background(), ticket, user.ID, streamTicketTTL); err != nil {
+		return nil, err
+	}
+	return &model.StreamTicketResponse{Ticket: ticket, ExpiresIn: int(streamTicketTTL / time.Second)}, nil
+}
+
+// RedeemStreamTicket 使用票据，返回签发票据的用户，票据使用后立即失效
+func (s *authService) RedeemStreamTicket(ticket string) (*model.User, error) {
+	userID, err := s.tickets.Take(context.Background(), ticket)
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return nil, errors.New("票据无效或已过期")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("用户不存在")
+	}
+	if !user.IsActive {
+		return nil, errors.New("用户已被禁用")
+	}
+	return user, nil
+}
+
 // HashPassword 密码哈希
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+)
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[int]*model.User
+}
+
+func (r *fakeUserRepo) GetByID(id int) (*model.User, error) { return r.users[id], nil }
+
+type fakeTicketStore struct {
+	tickets map[string]int
+	ttl     time.Duration
+}
+
+func (s *fakeTicketStore) Save(ctx context.Context, ticket string, userID int, ttl time.Duration) error {
+	s.tickets[ticket] = userID
+	s.ttl = ttl
+	return nil
+}
+
+func (s *fakeTicketStore) Take(ctx context.Context, ticket string) (int, error) {
+	userID := s.tickets[ticket]
+	delete(s.tickets, ticket)
+	return userID, nil
+}
+
+func TestStreamTicket(t *testing.T) {
+	store := &fakeTicketStore{tickets: make(map[string]int)}
+	s := NewAuthService(&fakeUserRepo{users: map[int]*model.User{
+		1: {ID: 1, Username: "alice", IsActive: true},
+		2: {ID: 2, Username: "bob"},
+	}}, store)
+
+	resp, err := s.IssueStreamTicket(&model.User{ID: 1})
+	if err != nil {
+		t.Fatalf("IssueStreamTicket() error = %v", err)
+	}
+	if len(resp.Ticket) != 64 || store.ttl != streamTicketTTL || resp.ExpiresIn != 30 {
+		t.Errorf("IssueStreamTicket() = %+v with ttl %v", resp, store.ttl)
+	}
+
+	user, err := s.RedeemStreamTicket(resp.Ticket)
+	if err != nil || user == nil || user.ID != 1 {
+		t.Fatalf("RedeemStreamTicket() = %v, %v, want user 1", user, err)
+	}
+	if _, err := s.RedeemStreamTicket(resp.Ticket); err == nil {
+		t.Errorf("RedeemStreamTicket() accepted a ticket twice")
+	}
+	if _, err := s.RedeemStreamTicket("unknown"); err == nil {
+		t.Errorf("RedeemStreamTicket() accepted an unknown ticket")
+	}
+
+	disabled, err := s.IssueStreamTicket(&model.User{ID: 2})
+	if err != nil {
+		t.Fatalf("IssueStreamTicket() error = %v", err)
+	}
+	if _, err := s.RedeemStreamTicket(disabled.Ticket); err == nil {
+		t.Errorf("RedeemStreamTicket() accepted a ticket of a disabled user")
+	}
+}
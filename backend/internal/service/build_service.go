@@ -1,38 +1,193 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"Vortexia/internal/engine"
 	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
 	"Vortexia/internal/repository"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
 )
 
 type buildService struct {
 	buildRepo    repository.BuildRepository
 	pipelineRepo repository.PipelineRepository
+	queue        repository.BuildQueue
+	logs         repository.BuildLogStream
+	logStore     repository.BuildLogStore
+	cancels      repository.BuildCancelSignal
+	engine       *engine.Engine
 }
 
 // NewBuildService 创建构建服务实例
-func NewBuildService(buildRepo repository.BuildRepository, pipelineRepo repository.PipelineRepository) BuildService {
+func NewBuildService(repos *repository.Repositories, engine *engine.Engine) BuildService {
 	return &buildService{
-		buildRepo:    buildRepo,
-		pipelineRepo: pipelineRepo,
+		buildRepo:    repos.Build,
+		pipelineRepo: repos.Pipeline,
+		queue:        repos.Queue,
+		logs:         repos.Logs,
+		logStore:     repos.LogStore,
+		cancels:      repos.Cancels,
+		engine:       engine,
 	}
 }
 
 // Create 创建构建
 func (s *buildService) Create(req *model.TriggerBuildRequest, triggerBy int) (*model.Build, error) {
-	// TODO: 实现构建创建逻辑
-	return nil, nil
+	p, err := s.pipelineRepo.GetByID(req.PipelineID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil || !p.IsActive {
+		return nil, errors.New("流水线不存在")
+	}
+
+	params, err := resolveParameters(p.Config, req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	build := &model.Build{
+		PipelineID: req.PipelineID,
+		Branch:     req.Branch,
+		Commit:     req.Commit,
+		Status:     model.BuildStatusPending,
+		StartedAt:  time.Now(),
+		TriggerBy:  triggerBy,
+		Config:     p.Config,
+		Parameters: params,
+	}
+
+	if err := s.Enqueue(build); err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
+// Rerun 以原构建的流水线、分支、提交和配置快照创建新构建。
+// mode 为 failed 时复用原构建中成功步骤的结果，只执行失败和跳过的步骤
+func (s *buildService) Rerun(id int, mode string, triggerBy int) (*model.Build, error) {
+	orig, err := s.buildRepo.GetByID(id)
+	if err != nil || orig == nil {
+		return nil, err
+	}
+	if !isBuildFinished(orig.Status) {
+		return nil, errors.New("构建尚未结束，无法重新执行")
+	}
+	if mode == "" {
+		mode = model.RerunModeAll
+	}
+	if mode == model.RerunModeFailed && orig.Status == model.BuildStatusSuccess {
+		return nil, errors.New("构建没有失败的步骤")
+	}
+
+	p, err := s.pipelineRepo.GetByID(orig.PipelineID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil || !p.IsActive {
+		return nil, errors.New("流水线不存在")
+	}
+
+	config := orig.Config
+	if config == "" {
+		// 早期的构建没有配置快照
+		config = p.Config
+	}
+
+	build := &model.Build{
+		PipelineID: orig.PipelineID,
+		Branch:     orig.Branch,
+		Tag:        orig.Tag,
+		Commit:     orig.Commit,
+		Status:     model.BuildStatusPending,
+		StartedAt:  time.Now(),
+		TriggerBy:  triggerBy,
+		Config:     config,
+		RerunOf:    &orig.ID,
+		RerunMode:  mode,
+		Parameters: orig.Parameters,
+	}
+
+	if err := s.Enqueue(build); err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
+// resolveParameters 按流水线配置校验触发构建时指定的参数并补全默认值。
+// 配置无效时构建会在执行时失败，此时只拒绝指定了参数的请求
+func resolveParameters(config string, values map[string]interface{}) ([]string, error) {
+	def, err := pipeline.Parse(config)
+	if err != nil {
+		if len(values) > 0 {
+			return nil, errors.New("流水线配置无效，无法指定参数")
+		}
+		return []string{}, nil
+	}
+	return def.ResolveParameters(values)
+}
+
+// Enqueue 保存构建并加入构建队列，由工作池异步执行
+func (s *buildService) Enqueue(build *model.Build) error {
+	build.RunsOn = runsOnOf(build.Config)
+	if build.Parameters == nil {
+		build.Parameters = []string{}
+	}
+	if err := s.buildRepo.Create(build); err != nil {
+		return err
+	}
+
+	if err := s.queue.Enqueue(context.Background(), build.ID); err != nil {
+		_ = s.buildRepo.UpdateStatus(build.ID, model.BuildStatusFailed)
+		return err
+	}
+
+	return nil
 }
 
 // GetByID 根据ID获取构建
 func (s *buildService) GetByID(id int) (*model.Build, error) {
-	return s.buildRepo.GetByID(id)
+	build, err := s.buildRepo.GetByID(id)
+	if err != nil || build == nil {
+		return nil, err
+	}
+	describePending(build)
+	return build, nil
 }
 
 // GetByPipeline 根据流水线获取构建列表
 func (s *buildService) GetByPipeline(pipelineID int, page, pageSize int) (*model.PaginationResponse, error) {
-	// TODO: 实现分页逻辑
-	return nil, nil
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	offset := (page - 1) * pageSize
+	builds, total, err := s.buildRepo.GetByPipeline(pipelineID, offset, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	describePending(builds...)
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	return &model.PaginationResponse{
+		Items:      builds,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}, nil
 }
 
 // UpdateStatus 更新构建状态
@@ -42,22 +197,499 @@ func (s *buildService) UpdateStatus(id int, status string) error {
 
 // List 获取构建列表
 func (s *buildService) List(page, pageSize int) (*model.PaginationResponse, error) {
-	// TODO: 实现分页逻辑
-	return nil, nil
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	offset := (page - 1) * pageSize
+	builds, total, err := s.buildRepo.List(offset, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	describePending(builds...)
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	return &model.PaginationResponse{
+		Items:      builds,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}, nil
 }
 
 // GetSteps 获取构建步骤
 func (s *buildService) GetSteps(buildID int) ([]*model.BuildStep, error) {
-	return s.buildRepo.GetStepsByBuild(buildID)
+	steps, err := s.buildRepo.GetStepsByBuild(buildID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.buildRepo.GetAttemptsByBuild(buildID)
+	if err != nil {
+		return nil, err
+	}
+	byStep := make(map[int]*model.BuildStep, len(steps))
+	for _, step := range steps {
+		byStep[step.ID] = step
+	}
+	for _, a := range attempts {
+		if step := byStep[a.StepID]; step != nil {
+			step.Attempts = append(step.Attempts, a)
+		}
+	}
+
+	return steps, nil
+}
+
+// GetGraph 获取构建的作业依赖图，构建不存在时返回 nil。早期的构建没有作业，返回空图
+func (s *buildService) GetGraph(buildID int) (*model.BuildGraph, error) {
+	build, err := s.buildRepo.GetByID(buildID)
+	if err != nil || build == nil {
+		return nil, err
+	}
+
+	jobs, err := s.buildRepo.GetJobsByBuild(buildID)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := s.GetSteps(buildID)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &model.BuildGraph{
+		BuildID: buildID,
+		Stages:  []string{},
+		Jobs:    []*model.BuildJob{},
+		Edges:   []*model.GraphEdge{},
+	}
+
+	byID := make(map[int]*model.BuildJob, len(jobs))
+	byName := make(map[string]*model.BuildJob, len(jobs))
+	stages := make(map[string]bool)
+	for _, job := range jobs {
+		byID[job.ID] = job
+		byName[job.Name] = job
+		if !stages[job.Stage] {
+			stages[job.Stage] = true
+			graph.Stages = append(graph.Stages, job.Stage)
+		}
+		graph.Jobs = append(graph.Jobs, job)
+	}
+	for _, step := range steps {
+		if step.JobID == nil {
+			continue
+		}
+		if job := byID[*step.JobID]; job != nil {
+			job.Steps = append(job.Steps, step)
+		}
+	}
+	for _, job := range jobs {
+		for _, need := range job.Needs {
+			if dep := byName[need]; dep != nil {
+				graph.Edges = append(graph.Edges, &model.GraphEdge{From: dep.ID, To: job.ID})
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+// Cancel 取消构建。等待中的构建不会再被执行，执行中的构建由执行它的工作进程终止，
+// 远程执行器在下一次心跳时得知构建已取消
+func (s *buildService) Cancel(ctx context.Context, id int, canceledBy int) (*model.Build, error) {
+	build, err := s.buildRepo.GetByID(id)
+	if err != nil || build == nil {
+		return nil, err
+	}
+
+	ok, err := s.buildRepo.Cancel(id, canceledBy)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("构建已结束，无法取消")
+	}
+
+	if build.Status == model.BuildStatusPending {
+		// 构建尚未开始执行，移出待执行列表，由这里通知日志订阅者构建结束。
+		// 移出失败时构建仍会被领取，准备构建时发现已取消后结束
+		if err := s.queue.Remove(ctx, id); err != nil {
+			logger.Warn("Failed to remove canceled build from queue", zap.Int("build_id", id), zap.Error(err))
+		}
+		event := &model.LogEvent{Type: model.LogEventBuildEnd, BuildID: id, Status: model.BuildStatusCanceled, Time: time.Now()}
+		if err := s.logs.Publish(ctx, event); err != nil {
+			logger.Warn("Failed to publish log event", zap.Int("build_id", id), zap.Error(err))
+		}
+	} else if err := s.cancels.Publish(ctx, id); err != nil {
+		// 工作进程在开始下一个步骤前会检查构建状态，信号丢失只会延迟当前步骤的终止
+		logger.Warn("Failed to publish build cancel signal", zap.Int("build_id", id), zap.Error(err))
+	}
+
+	return s.buildRepo.GetByID(id)
+}
+
+// AbortBuild 终止本进程中正在执行的构建
+func (s *buildService) AbortBuild(id int) bool {
+	return s.engine.Abort(id)
 }
 
 // UpdateStepStatus 更新步骤状态
-func (s *buildService) UpdateStepStatus(stepID int, status string, output string) error {
-	return s.buildRepo.UpdateStepStatus(stepID, status, output)
+func (s *buildService) UpdateStepStatus(stepID int, status string) error {
+	return s.buildRepo.UpdateStepStatus(stepID, status)
 }
 
-// ExecuteBuild 执行构建
-func (s *buildService) ExecuteBuild(buildID int) error {
-	// TODO: 实现构建执行逻辑
-	return nil
+// 单次读取日志的默认与最大长度
+const (
+	defaultStepLogBytes = 64 * 1024
+	maxStepLogBytes     = 1024 * 1024
+	defaultStepLogLines = 500
+	maxStepLogLines     = 5000
+)
+
+// getBuildStep 获取属于指定构建的步骤，不存在时返回 nil
+func (s *buildService) getBuildStep(buildID, stepID int) (*model.BuildStep, error) {
+	step, err := s.buildRepo.GetStepByID(stepID)
+	if err != nil || step == nil || step.BuildID != buildID {
+		return nil, err
+	}
+	return step, nil
+}
+
+// logWindow 步骤日志中可读取的范围，指定执行次数时只包含该次执行的输出
+type logWindow struct {
+	offset   int64 // 范围在步骤日志中的起始字节
+	line     int   // 范围在步骤日志中的起始行
+	size     int64 // 范围的字节数
+	lines    int   // 范围的行数，-1表示不限制（执行尚未结束）
+	finished bool  // 范围内的输出不会再增加
+}
+
+// stepLogWindow 计算读取步骤日志的范围，attempt 为0时为整个步骤日志
+func (s *buildService) stepLogWindow(step *model.BuildStep, attempt int) (*logWindow, error) {
+	size, err := s.logStore.Size(step.ID)
+	if err != nil {
+		return nil, err
+	}
+	if attempt == 0 {
+		return &logWindow{size: size, lines: -1, finished: isStepFinished(step.Status)}, nil
+	}
+
+	a, err := s.buildRepo.GetStepAttempt(step.ID, attempt)
+	if err != nil || a == nil {
+		return nil, err
+	}
+	if a.FinishedAt == nil {
+		return &logWindow{offset: a.LogOffset, line: a.LogLine, size: size - a.LogOffset, lines: -1}, nil
+	}
+	return &logWindow{offset: a.LogOffset, line: a.LogLine, size: a.LogBytes, lines: a.LogLines, finished: true}, nil
+}
+
+// GetStepLogBytes 按字节范围读取步骤日志，attempt 大于0时偏移量相对于该次执行的输出
+func (s *buildService) GetStepLogBytes(buildID, stepID, attempt int, offset int64, limit int) (*model.StepLog, error) {
+	step, err := s.getBuildStep(buildID, stepID)
+	if err != nil || step == nil {
+		return nil, err
+	}
+	w, err := s.stepLogWindow(step, attempt)
+	if err != nil || w == nil {
+		return nil, err
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 || limit > maxStepLogBytes {
+		limit = defaultStepLogBytes
+	}
+	if rest := w.size - offset; rest < int64(limit) {
+		limit = int(rest)
+	}
+
+	var data []byte
+	if limit > 0 {
+		data, err = s.logStore.ReadBytes(step.ID, w.offset+offset, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	next := offset + int64(len(data))
+	return &model.StepLog{
+		StepID:     step.ID,
+		Attempt:    attempt,
+		Size:       w.size,
+		Offset:     offset,
+		NextOffset: next,
+		Content:    string(data),
+		Complete:   w.finished && next >= w.size,
+	}, nil
+}
+
+// GetStepLogLines 按行范围读取步骤日志，attempt 大于0时行号相对于该次执行的输出
+func (s *buildService) GetStepLogLines(buildID, stepID, attempt int, from, count int) (*model.StepLog, error) {
+	step, err := s.getBuildStep(buildID, stepID)
+	if err != nil || step == nil {
+		return nil, err
+	}
+	w, err := s.stepLogWindow(step, attempt)
+	if err != nil || w == nil {
+		return nil, err
+	}
+
+	if from < 0 {
+		from = 0
+	}
+	if count < 1 || count > maxStepLogLines {
+		count = defaultStepLogLines
+	}
+	want := count
+	if w.lines >= 0 && w.lines-from < want {
+		want = w.lines - from
+	}
+
+	var lines []string
+	if want > 0 {
+		lines, err = s.logStore.ReadLines(step.ID, w.line+from, want)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &model.StepLog{
+		StepID:   step.ID,
+		Attempt:  attempt,
+		Size:     w.size,
+		Line:     from,
+		NextLine: from + len(lines),
+		Lines:    lines,
+		Complete: w.finished && len(lines) < count,
+	}, nil
+}
+
+// ClaimBuild 为本进程领取一个标签表达式被 labels 满足的构建，没有可执行的构建时返回 nil
+func (s *buildService) ClaimBuild(ctx context.Context, labels []string, visibility time.Duration) (*repository.QueueItem, error) {
+	return claimBuild(ctx, s.queue, s.buildRepo, labels, visibility)
+}
+
+// ExecuteBuild 执行构建，阻塞直到构建结束
+func (s *buildService) ExecuteBuild(ctx context.Context, buildID int) error {
+	return s.engine.Execute(ctx, buildID)
+}
+
+// RequeueLost 将等待执行或执行中、却不在构建队列中的构建重新加入队列，返回数量。
+// 保存构建后进程崩溃或Redis故障导致未能入队，以及Redis数据丢失时，这些构建不会再被执行
+func (s *buildService) RequeueLost(ctx context.Context) (int, error) {
+	ids, err := s.buildRepo.GetUnfinishedIDs()
+	if err != nil {
+		return 0, err
+	}
+	restored, err := s.queue.Restore(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(restored) > 0 {
+		logger.Warn("Requeued builds missing from the build queue", zap.Ints("build_ids", restored))
+	}
+	return len(restored), nil
+}
+
+// 构建已结束但尚未收到结束事件时，等待实时事件的最长时间
+const logDrainTimeout = 5 * time.Second
+
+// WatchLogs 订阅构建日志：先回放序号大于 afterSeq 的历史事件，再推送实时事件，
+// 构建结束事件发送后通道关闭
+func (s *buildService) WatchLogs(ctx context.Context, buildID int, afterSeq int64) (<-chan *model.LogEvent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// 先订阅再读取历史，保证两者之间发布的事件不会丢失
+	live, err := s.logs.Subscribe(ctx, buildID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	build, err := s.buildRepo.GetByID(buildID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if build == nil {
+		cancel()
+		return nil, errors.New("构建不存在")
+	}
+	finished := isBuildFinished(build.Status)
+
+	history, err := s.logs.History(ctx, buildID, afterSeq)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	events := make(chan *model.LogEvent, 64)
+	go func() {
+		defer close(events)
+		defer cancel()
+
+		last := afterSeq
+		// send 按序号去重后发送事件，构建结束或订阅方离开时返回 false
+		send := func(event *model.LogEvent) bool {
+			if event.Seq != 0 {
+				if event.Seq <= last {
+					return true
+				}
+				last = event.Seq
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return false
+			}
+			return event.Type != model.LogEventBuildEnd
+		}
+
+		// Redis中只保留最近的日志事件，请求的起点之后的事件已被丢弃时，从数据库中的步骤输出回放
+		truncated := len(history) > 0 && history[0].Seq > afterSeq+1
+		if finished && (truncated || (len(history) == 0 && afterSeq == 0)) {
+			// Redis中的日志已过期或不完整，构建的输出已全部保存
+			s.replayFromSteps(ctx, build, send)
+			return
+		}
+
+		if truncated {
+			// 回放已保存的输出后从历史的末尾继续推送实时事件，回放期间写入的日志行可能重复
+			last = history[len(history)-1].Seq
+			if !s.replayFromSteps(ctx, build, send) {
+				return
+			}
+		} else {
+			for _, event := range history {
+				if !send(event) {
+					return
+				}
+			}
+		}
+
+		var drain <-chan time.Time
+		if finished {
+			timer := time.NewTimer(logDrainTimeout)
+			defer timer.Stop()
+			drain = timer.C
+		}
+
+		for {
+			select {
+			case event, ok := <-live:
+				if !ok || !send(event) {
+					return
+				}
+			case <-drain:
+				send(&model.LogEvent{
+					Type:    model.LogEventBuildEnd,
+					BuildID: build.ID,
+					Status:  build.Status,
+					Time:    time.Now(),
+				})
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// replayFromSteps 根据数据库中保存的步骤输出生成日志事件。构建尚未结束时只回放已开始的步骤，
+// 不发送未结束步骤的结束事件和构建结束事件。订阅方离开时返回 false
+func (s *buildService) replayFromSteps(ctx context.Context, build *model.Build, send func(*model.LogEvent) bool) bool {
+	finished := isBuildFinished(build.Status)
+	steps, err := s.buildRepo.GetStepsByBuild(build.ID)
+	if err != nil {
+		return true
+	}
+	jobs, err := s.buildRepo.GetJobsByBuild(build.ID)
+	if err != nil {
+		return true
+	}
+	jobNames := make(map[int]string, len(jobs))
+	for _, job := range jobs {
+		jobNames[job.ID] = job.Name
+	}
+
+	for _, step := range steps {
+		if !finished && step.Status == model.StepStatusPending {
+			continue
+		}
+		var jobID int
+		if step.JobID != nil {
+			jobID = *step.JobID
+		}
+		jobName := jobNames[jobID]
+		if !send(&model.LogEvent{Type: model.LogEventStepStart, BuildID: build.ID, JobID: jobID, JobName: jobName, StepID: step.ID, StepName: step.Name, Time: step.StartedAt}) {
+			return false
+		}
+		if !s.replayStepLines(step, func(line string) bool {
+			return send(&model.LogEvent{Type: model.LogEventLog, BuildID: build.ID, StepID: step.ID, Line: line, Time: step.StartedAt})
+		}) {
+			return false
+		}
+		if !finished && !isStepFinished(step.Status) {
+			continue
+		}
+		if !send(&model.LogEvent{Type: model.LogEventStepEnd, BuildID: build.ID, JobID: jobID, JobName: jobName, StepID: step.ID, StepName: step.Name, Status: step.Status, Time: step.StartedAt}) {
+			return false
+		}
+	}
+
+	if finished {
+		send(&model.LogEvent{Type: model.LogEventBuildEnd, BuildID: build.ID, Status: build.Status, Time: time.Now()})
+	}
+	return true
+}
+
+// replayStepLines 按行回放步骤日志，兼容旧版本保存在 output 字段中的输出
+func (s *buildService) replayStepLines(step *model.BuildStep, send func(line string) bool) bool {
+	if step.Output != "" {
+		for _, line := range strings.Split(strings.TrimSuffix(step.Output, "\n"), "\n") {
+			if !send(line) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for from := 0; ; {
+		lines, err := s.logStore.ReadLines(step.ID, from, maxStepLogLines)
+		if err != nil || len(lines) == 0 {
+			return true
+		}
+		for _, line := range lines {
+			if !send(line) {
+				return false
+			}
+		}
+		from += len(lines)
+	}
+}
+
+// isStepFinished 判断步骤是否已处于终态
+func isStepFinished(status string) bool {
+	switch status {
+	case model.StepStatusSuccess, model.StepStatusFailed, model.StepStatusSkipped, model.StepStatusCanceled, model.StepStatusTimedOut:
+		return true
+	}
+	return false
+}
+
+// isBuildFinished 判断构建是否已处于终态
+func isBuildFinished(status string) bool {
+	switch status {
+	case model.BuildStatusSuccess, model.BuildStatusFailed, model.BuildStatusCanceled, model.BuildStatusTimedOut:
+		return true
+	}
+	return false
 }
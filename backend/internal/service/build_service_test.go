@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+)
+
+// fakeCancelBuildRepo 只实现取消构建所需方法的构建仓库
+type fakeCancelBuildRepo struct {
+	fakeBuildRepo
+}
+
+func (r *fakeCancelBuildRepo) Cancel(id int, canceledBy int) (bool, error) {
+	build := r.builds[id]
+	if build == nil || (build.Status != model.BuildStatusPending && build.Status != model.BuildStatusRunning) {
+		return false, nil
+	}
+	copied := *build
+	copied.Status = model.BuildStatusCanceled
+	r.builds[id] = &copied
+	return true, nil
+}
+
+// fakeLogStream 记录发布的日志事件
+type fakeLogStream struct {
+	repository.BuildLogStream
+	events []*model.LogEvent
+}
+
+func (s *fakeLogStream) Publish(ctx context.Context, event *model.LogEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+// fakeCancelSignal 记录发布的取消信号
+type fakeCancelSignal struct {
+	repository.BuildCancelSignal
+	published []int
+}
+
+func (s *fakeCancelSignal) Publish(ctx context.Context, buildID int) error {
+	s.published = append(s.published, buildID)
+	return nil
+}
+
+func TestBuildCancel(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		wantErr     bool
+		wantPending []int // 取消后的待执行列表
+		wantSignals []int
+		wantEvents  int
+	}{
+		// 等待执行的构建移出队列，不会再被领取
+		{name: "pending", status: model.BuildStatusPending, wantPending: []int{2}, wantEvents: 1},
+		// 执行中的构建通过取消信号通知工作进程
+		{name: "running", status: model.BuildStatusRunning, wantPending: []int{1, 2}, wantSignals: []int{1}},
+		{name: "finished", status: model.BuildStatusSuccess, wantErr: true, wantPending: []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &fakeQueue{pending: []int{1, 2}}
+			logs := &fakeLogStream{}
+			cancels := &fakeCancelSignal{}
+			s := &buildService{
+				buildRepo: &fakeCancelBuildRepo{fakeBuildRepo{builds: map[int]*model.Build{
+					1: {ID: 1, Status: tt.status},
+					2: {ID: 2, Status: model.BuildStatusPending},
+				}}},
+				queue:   queue,
+				logs:    logs,
+				cancels: cancels,
+			}
+
+			build, err := s.Cancel(context.Background(), 1, 10)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Cancel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && build.Status != model.BuildStatusCanceled {
+				t.Errorf("build status = %q, want canceled", build.Status)
+			}
+			if !reflect.DeepEqual(queue.pending, tt.wantPending) {
+				t.Errorf("pending = %v, want %v", queue.pending, tt.wantPending)
+			}
+			if !reflect.DeepEqual(cancels.published, tt.wantSignals) {
+				t.Errorf("cancel signals = %v, want %v", cancels.published, tt.wantSignals)
+			}
+			if len(logs.events) != tt.wantEvents {
+				t.Errorf("published %d log events, want %d", len(logs.events), tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/storage"
+)
+
+type cacheService struct {
+	projectRepo repository.ProjectRepository
+	caches      *storage.CacheStore
+}
+
+// NewCacheService 创建依赖缓存服务实例
+func NewCacheService(repos *repository.Repositories, caches *storage.CacheStore) CacheService {
+	return &cacheService{
+		projectRepo: repos.Project,
+		caches:      caches,
+	}
+}
+
+// ListByProject 获取项目的缓存，按最近使用时间倒序，项目不存在或用户不是项目的所有者或管理员时返回 nil
+func (s *cacheService) ListByProject(projectID int, user *model.User) ([]*model.Cache, error) {
+	ok, err := s.canAccess(projectID, user)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return s.caches.List(projectID)
+}
+
+// Delete 删除项目的一个缓存，缓存不存在、不属于该项目或用户不是项目的所有者或管理员时返回 nil
+func (s *cacheService) Delete(ctx context.Context, projectID, cacheID int, user *model.User) (*model.Cache, error) {
+	ok, err := s.canAccess(projectID, user)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	cache, err := s.caches.Get(projectID, cacheID)
+	if err != nil || cache == nil {
+		return nil, err
+	}
+
+	if err := s.caches.Delete(ctx, cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Purge 删除项目的全部缓存，返回删除的缓存，项目不存在或用户不是项目的所有者或管理员时返回 nil
+func (s *cacheService) Purge(ctx context.Context, projectID int, user *model.User) ([]*model.Cache, error) {
+	caches, err := s.ListByProject(projectID, user)
+	if err != nil || caches == nil {
+		return nil, err
+	}
+
+	for _, cache := range caches {
+		if err := s.caches.Delete(ctx, cache); err != nil {
+			return nil, err
+		}
+	}
+	return caches, nil
+}
+
+// canAccess 判断用户能否管理项目的缓存，项目不存在时返回 false
+func (s *cacheService) canAccess(projectID int, user *model.User) (bool, error) {
+	project, err := s.projectRepo.GetByID(projectID)
+	if err != nil {
+		return false, err
+	}
+	return canAccessProject(project, user), nil
+}
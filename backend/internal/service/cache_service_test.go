@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/storage"
+)
+
+type fakeCacheRepo struct {
+	repository.CacheRepository
+	caches  []*model.Cache
+	deleted []int
+}
+
+func (r *fakeCacheRepo) GetInProject(projectID, id int) (*model.Cache, error) {
+	for _, cache := range r.caches {
+		if cache.ID == id && cache.ProjectID == projectID {
+			return cache, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeCacheRepo) GetByProject(projectID int) ([]*model.Cache, error) {
+	result := []*model.Cache{}
+	for _, cache := range r.caches {
+		if cache.ProjectID == projectID {
+			result = append(result, cache)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeCacheRepo) Delete(id int) error {
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+func TestCacheServiceAccess(t *testing.T) {
+	// 项目 3 属于用户 10，项目 4 属于用户 11
+	newService := func(t *testing.T) (*cacheService, *fakeCacheRepo) {
+		objects, err := storage.NewLocal(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewLocal() error = %v", err)
+		}
+		repo := &fakeCacheRepo{caches: []*model.Cache{
+			{ID: 5, ProjectID: 3, Key: "go-mod", StorageKey: "caches/3/a.tar.gz"},
+			{ID: 6, ProjectID: 4, Key: "go-mod", StorageKey: "caches/4/a.tar.gz"},
+		}}
+		return &cacheService{
+			projectRepo: &fakeProjectRepo{projects: map[int]*model.Project{3: {ID: 3, OwnerID: 10}, 4: {ID: 4, OwnerID: 11}}},
+			caches:      storage.NewCacheStore(repo, objects, config.CacheConfig{}),
+		}, repo
+	}
+	ctx := context.Background()
+
+	users := []struct {
+		name string
+		user *model.User
+		want bool
+	}{
+		{name: "owner", user: &model.User{ID: 10, Role: model.RoleUser}, want: true},
+		{name: "admin", user: &model.User{ID: 1, Role: model.RoleAdmin}, want: true},
+		{name: "other user", user: &model.User{ID: 11, Role: model.RoleUser}},
+		{name: "no user"},
+	}
+	for _, u := range users {
+		t.Run(u.name, func(t *testing.T) {
+			s, repo := newService(t)
+			caches, err := s.ListByProject(3, u.user)
+			if err != nil || (caches != nil) != u.want {
+				t.Errorf("ListByProject() = %v, %v, want allowed %v", caches, err, u.want)
+			}
+
+			cache, err := s.Delete(ctx, 3, 5, u.user)
+			if err != nil || (cache != nil) != u.want {
+				t.Errorf("Delete() = %v, %v, want allowed %v", cache, err, u.want)
+			}
+
+			purged, err := s.Purge(ctx, 3, u.user)
+			if err != nil || (purged != nil) != u.want {
+				t.Errorf("Purge() = %v, %v, want allowed %v", purged, err, u.want)
+			}
+			if !u.want && len(repo.deleted) != 0 {
+				t.Errorf("deleted caches %v for a user without access", repo.deleted)
+			}
+		})
+	}
+
+	t.Run("cache of another project", func(t *testing.T) {
+		s, repo := newService(t)
+		// 用户 10 可以管理项目 3，但缓存 6 属于项目 4
+		cache, err := s.Delete(ctx, 3, 6, &model.User{ID: 10, Role: model.RoleUser})
+		if err != nil || cache != nil {
+			t.Errorf("Delete() = %v, %v, want not found", cache, err)
+		}
+		if len(repo.deleted) != 0 {
+			t.Errorf("deleted caches %v of another project", repo.deleted)
+		}
+	})
+}
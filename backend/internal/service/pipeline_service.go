@@ -1,7 +1,11 @@
 package service
 
 import (
+	"errors"
+	"math"
+
 	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
 	"Vortexia/internal/repository"
 )
 
@@ -16,8 +20,23 @@ func NewPipelineService(pipelineRepo repository.PipelineRepository) PipelineServ
 
 // Create 创建流水线
 func (s *pipelineService) Create(req *model.CreatePipelineRequest) (*model.Pipeline, error) {
-	// TODO: 实现流水线创建逻辑
-	return nil, nil
+	// 校验流水线配置，配置无效时返回 pipeline.ValidationErrors
+	if _, err := pipeline.Parse(req.Config); err != nil {
+		return nil, err
+	}
+
+	p := &model.Pipeline{
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+		Config:    req.Config,
+		IsActive:  true,
+	}
+
+	if err := s.pipelineRepo.Create(p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
 }
 
 // GetByID 根据ID获取流水线
@@ -31,8 +50,21 @@ func (s *pipelineService) GetByProject(projectID int) ([]*model.Pipeline, error)
 }
 
 // Update 更新流水线
-func (s *pipelineService) Update(pipeline *model.Pipeline) error {
-	return s.pipelineRepo.Update(pipeline)
+func (s *pipelineService) Update(p *model.Pipeline) error {
+	// 检查流水线是否存在
+	existing, err := s.pipelineRepo.GetByID(p.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return errors.New("流水线不存在")
+	}
+
+	if _, err := pipeline.Parse(p.Config); err != nil {
+		return err
+	}
+
+	return s.pipelineRepo.Update(p)
 }
 
 // Delete 删除流水线
@@ -42,6 +74,26 @@ func (s *pipelineService) Delete(id int) error {
 
 // List 获取流水线列表
 func (s *pipelineService) List(page, pageSize int) (*model.PaginationResponse, error) {
-	// TODO: 实现分页逻辑
-	return nil, nil
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	offset := (page - 1) * pageSize
+	pipelines, total, err := s.pipelineRepo.List(offset, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	return &model.PaginationResponse{
+		Items:      pipelines,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}, nil
 }
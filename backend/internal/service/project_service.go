@@ -100,3 +100,8 @@ func (s *projectService) List(page, pageSize int) (*model.PaginationResponse, er
 		TotalPages: totalPages,
 	}, nil
 }
+
+// canAccessProject 判断用户能否管理项目，只有项目的所有者和管理员可以
+func canAccessProject(project *model.Project, user *model.User) bool {
+	return project != nil && user != nil && (user.Role == model.RoleAdmin || project.OwnerID == user.ID)
+}
@@ -0,0 +1,667 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/engine"
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/storage"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	runnerPollTimeout  = 30 * time.Second // 领取构建的长轮询时长，需小于反向代理的读超时
+	runnerPollInterval = time.Second      // 长轮询期间检查队列的间隔
+)
+
+// ErrJobNotAssigned 构建未分配给发起请求的执行器，例如执行器失联后构建已被重新分配
+var ErrJobNotAssigned = errors.New("构建未分配给该执行器")
+
+type runnerService struct {
+	runnerRepo   repository.RunnerRepository
+	buildRepo    repository.BuildRepository
+	pipelineRepo repository.PipelineRepository
+	queue        repository.BuildQueue
+	engine       *engine.Engine
+	reporter     engine.Reporter
+	artifacts    *storage.ArtifactStore
+	caches       *storage.CacheStore
+	cfg          *config.Config
+}
+
+// NewRunnerService 创建远程执行器服务实例
+func NewRunnerService(repos *repository.Repositories, eng *engine.Engine, cfg *config.Config, artifacts *storage.ArtifactStore, caches *storage.CacheStore) RunnerService {
+	return &runnerService{
+		runnerRepo:   repos.Runner,
+		buildRepo:    repos.Build,
+		pipelineRepo: repos.Pipeline,
+		queue:        repos.Queue,
+		engine:       eng,
+		reporter:     engine.NewReporter(repos, artifacts, caches),
+		artifacts:    artifacts,
+		caches:       caches,
+		cfg:          cfg,
+	}
+}
+
+// visibility 构建租约时长，执行器超过该时长没有心跳即视为失联，其构建被重新投递
+func (s *runnerService) visibility() time.Duration {
+	if s.cfg.Worker.VisibilityTimeout <= 0 {
+		return time.Minute
+	}
+	return time.Duration(s.cfg.Worker.VisibilityTimeout) * time.Second
+}
+
+// List 获取执行器列表
+func (s *runnerService) List(page, pageSize int) (*model.PaginationResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	offset := (page - 1) * pageSize
+	runners, total, err := s.runnerRepo.List(offset, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, runner := range runners {
+		s.setOnline(runner)
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
+
+	return &model.PaginationResponse{
+		Items:      runners,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// SetPaused 暂停或恢复执行器，暂停的执行器执行完当前构建后不再领取新构建
+func (s *runnerService) SetPaused(id int, paused bool) (*model.Runner, error) {
+	runner, err := s.runnerRepo.GetByID(id)
+	if err != nil || runner == nil {
+		return nil, err
+	}
+	if runner.RevokedAt != nil {
+		return nil, errors.New("执行器已被吊销")
+	}
+
+	if err := s.runnerRepo.SetPaused(id, paused); err != nil {
+		return nil, err
+	}
+	return s.getRunner(id)
+}
+
+// Revoke 吊销执行器，令牌立即失效。执行中的构建在租约过期后重新投递给其他执行器
+func (s *runnerService) Revoke(id int) (*model.Runner, error) {
+	runner, err := s.runnerRepo.GetByID(id)
+	if err != nil || runner == nil {
+		return nil, err
+	}
+
+	if err := s.runnerRepo.Revoke(id); err != nil {
+		return nil, err
+	}
+	return s.getRunner(id)
+}
+
+func (s *runnerService) getRunner(id int) (*model.Runner, error) {
+	runner, err := s.runnerRepo.GetByID(id)
+	if err != nil || runner == nil {
+		return nil, err
+	}
+	s.setOnline(runner)
+	return runner, nil
+}
+
+// setOnline 根据最近一次心跳判断执行器是否在线
+func (s *runnerService) setOnline(runner *model.Runner) {
+	runner.Online = runner.RevokedAt == nil && runner.LastSeenAt != nil &&
+		time.Since(*runner.LastSeenAt) < s.visibility()
+}
+
+// Register 使用注册令牌注册执行器，返回执行器令牌
+func (s *runnerService) Register(req *model.RegisterRunnerRequest) (*model.RegisterRunnerResponse, error) {
+	expected := s.cfg.Runner.RegistrationToken
+	if expected == "" {
+		return nil, errors.New("未启用执行器注册")
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(expected)) != 1 {
+		return nil, errors.New("注册令牌无效")
+	}
+
+	token, err := generateRunnerToken()
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := normalizeLabels(req.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := &model.Runner{
+		Name:      strings.TrimSpace(req.Name),
+		Labels:    labels,
+		TokenHash: hashRunnerToken(token),
+		Version:   req.Version,
+	}
+	if err := s.runnerRepo.Create(runner); err != nil {
+		return nil, err
+	}
+	s.setOnline(runner)
+
+	logger.Info("Runner registered", zap.Int("runner_id", runner.ID), zap.String("name", runner.Name))
+	return &model.RegisterRunnerResponse{Runner: runner, Token: token}, nil
+}
+
+// Authenticate 根据执行器令牌获取执行器，令牌无效或已吊销时返回错误
+func (s *runnerService) Authenticate(token string) (*model.Runner, error) {
+	runner, err := s.runnerRepo.GetByTokenHash(hashRunnerToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if runner == nil {
+		return nil, errors.New("执行器令牌无效")
+	}
+	if runner.RevokedAt != nil {
+		return nil, errors.New("执行器已被吊销")
+	}
+	return runner, nil
+}
+
+// Heartbeat 记录执行器在线、更新变化了的标签并为其执行中的构建续租，返回执行器需要终止的构建
+func (s *runnerService) Heartbeat(ctx context.Context, runner *model.Runner, req *model.RunnerHeartbeatRequest) (*model.RunnerHeartbeatResponse, error) {
+	if err := s.runnerRepo.Touch(runner.ID); err != nil {
+		return nil, err
+	}
+
+	if req.Labels != nil {
+		labels, err := normalizeLabels(req.Labels)
+		if err != nil {
+			return nil, err
+		}
+		if !sameLabels(labels, runner.Labels) {
+			if err := s.runnerRepo.SetLabels(runner.ID, labels); err != nil {
+				return nil, err
+			}
+			logger.Info("Runner labels updated", zap.Int("runner_id", runner.ID), zap.Strings("labels", labels))
+		}
+	}
+
+	resp := &model.RunnerHeartbeatResponse{Cancel: []int{}}
+	for _, id := range req.Running {
+		build, err := s.buildRepo.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if build == nil || !assignedTo(build, runner) || build.Status != model.BuildStatusRunning {
+			resp.Cancel = append(resp.Cancel, id)
+			continue
+		}
+		if err := s.queue.Extend(ctx, id, s.visibility()); err != nil {
+			logger.Warn("Failed to extend build lease", zap.Int("build_id", id), zap.Error(err))
+		}
+	}
+	return resp, nil
+}
+
+// RequestJob 为执行器领取一个构建，队列为空时等待至多 runnerPollTimeout，
+// 仍没有构建时返回 nil
+func (s *runnerService) RequestJob(ctx context.Context, runner *model.Runner) (*model.RunnerJob, error) {
+	if err := s.runnerRepo.Touch(runner.ID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, runnerPollTimeout)
+	defer cancel()
+
+	for {
+		if !runner.Paused {
+			job, err := s.claim(runner)
+			if err != nil || job != nil {
+				return job, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(runnerPollInterval):
+		}
+	}
+}
+
+// claim 从构建队列领取构建并为执行器准备好，队列为空时返回 nil
+func (s *runnerService) claim(runner *model.Runner) (*model.RunnerJob, error) {
+	for {
+		item, err := claimBuild(context.Background(), s.queue, s.buildRepo, runner.Labels, s.visibility())
+		if err != nil || item == nil {
+			return nil, err
+		}
+		log := logger.Logger.With(zap.Int("runner_id", runner.ID), zap.Int("build_id", item.BuildID))
+
+		if limit := s.cfg.Worker.MaxDeliveries; limit > 0 && item.Deliveries > limit {
+			// 构建反复中断（例如执行器每次都失联），不再重试
+			log.Error("Build exceeded max deliveries", zap.Int("deliveries", item.Deliveries))
+			if err := s.reporter.FinishBuild(item.BuildID, model.BuildStatusFailed); err != nil {
+				return nil, err
+			}
+			s.ack(log, item.BuildID)
+			continue
+		}
+
+		job, err := s.engine.Prepare(item.BuildID, &runner.ID)
+		if err != nil {
+			// 不确认，租约过期后重新投递
+			return nil, err
+		}
+		if job == nil {
+			// 构建已结束（如在等待期间被取消），无需执行
+			s.ack(log, item.BuildID)
+			continue
+		}
+
+		log.Info("Build assigned to runner", zap.Int("deliveries", item.Deliveries))
+		return job, nil
+	}
+}
+
+func (s *runnerService) ack(log *zap.Logger, buildID int) {
+	if err := s.queue.Ack(context.Background(), buildID); err != nil {
+		log.Error("Failed to ack build", zap.Error(err))
+	}
+}
+
+// GetJobStatus 获取分配给执行器的构建的当前状态
+func (s *runnerService) GetJobStatus(runner *model.Runner, buildID int) (string, error) {
+	build, err := s.assignedBuild(runner, buildID)
+	if err != nil {
+		return "", err
+	}
+	return build.Status, nil
+}
+
+// UpdateCommit 记录构建实际检出的提交
+func (s *runnerService) UpdateCommit(runner *model.Runner, buildID int, commit string) error {
+	if _, err := s.assignedBuild(runner, buildID); err != nil {
+		return err
+	}
+	return s.reporter.UpdateCommit(buildID, commit)
+}
+
+// FinishJob 写入构建的最终状态并将其移出构建队列
+func (s *runnerService) FinishJob(ctx context.Context, runner *model.Runner, buildID int, status string) error {
+	if _, err := s.assignedBuild(runner, buildID); err != nil {
+		return err
+	}
+	if err := s.reporter.FinishBuild(buildID, status); err != nil {
+		return err
+	}
+	return s.queue.Ack(ctx, buildID)
+}
+
+// PublishEvents 发布执行器上报的日志事件，事件只能属于分配给该执行器的构建
+func (s *runnerService) PublishEvents(ctx context.Context, runner *model.Runner, events []*model.LogEvent) error {
+	checked := make(map[int]bool)
+	for _, event := range events {
+		if !checked[event.BuildID] {
+			if _, err := s.assignedBuild(runner, event.BuildID); err != nil {
+				return err
+			}
+			checked[event.BuildID] = true
+		}
+	}
+
+	for _, event := range events {
+		if err := s.reporter.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateBuildJob 更新构建作业的状态
+func (s *runnerService) UpdateBuildJob(runner *model.Runner, jobID int, status string) error {
+	job, err := s.buildRepo.GetJobByID(jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotAssigned
+	}
+	if _, err := s.assignedBuild(runner, job.BuildID); err != nil {
+		return err
+	}
+	return s.reporter.UpdateJobStatus(jobID, status)
+}
+
+// UpdateStep 更新步骤的状态、退出码或复用的原步骤，复用的原步骤须是同一流水线之前的构建中执行成功的步骤
+func (s *runnerService) UpdateStep(runner *model.Runner, stepID int, update *model.RunnerStepUpdate) error {
+	step, err := s.assignedStep(runner, stepID)
+	if err != nil {
+		return err
+	}
+
+	if update.ReusedFrom != nil {
+		if err := s.checkReusable(step, *update.ReusedFrom); err != nil {
+			return err
+		}
+		if err := s.reporter.ReuseStep(stepID, *update.ReusedFrom); err != nil {
+			return err
+		}
+	}
+	if update.ExitCode != nil {
+		if err := s.reporter.SetStepExitCode(stepID, *update.ExitCode); err != nil {
+			return err
+		}
+	}
+	if update.Status != "" {
+		if err := s.reporter.UpdateStepStatus(stepID, update.Status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReusable 检查步骤可以复用 fromStepID 的结果
+func (s *runnerService) checkReusable(step *model.BuildStep, fromStepID int) error {
+	invalid := fmt.Errorf("步骤 %d 不是同一流水线之前的构建中执行成功的步骤，无法复用", fromStepID)
+
+	from, err := s.buildRepo.GetStepByID(fromStepID)
+	if err != nil {
+		return err
+	}
+	if from == nil || from.BuildID >= step.BuildID || from.Status != model.StepStatusSuccess {
+		return invalid
+	}
+
+	build, err := s.buildRepo.GetByID(step.BuildID)
+	if err != nil {
+		return err
+	}
+	fromBuild, err := s.buildRepo.GetByID(from.BuildID)
+	if err != nil {
+		return err
+	}
+	if build == nil || fromBuild == nil || fromBuild.PipelineID != build.PipelineID {
+		return invalid
+	}
+	return nil
+}
+
+// AppendStepLog 追加步骤日志
+func (s *runnerService) AppendStepLog(runner *model.Runner, stepID int, data []byte) error {
+	if _, err := s.assignedStep(runner, stepID); err != nil {
+		return err
+	}
+	return s.reporter.AppendLog(stepID, data)
+}
+
+// CreateStepAttempt 创建步骤执行记录
+func (s *runnerService) CreateStepAttempt(runner *model.Runner, attempt *model.BuildStepAttempt) error {
+	if _, err := s.assignedStep(runner, attempt.StepID); err != nil {
+		return err
+	}
+	return s.reporter.CreateStepAttempt(attempt)
+}
+
+// FinishStepAttempt 写入步骤执行结果
+func (s *runnerService) FinishStepAttempt(runner *model.Runner, attempt *model.BuildStepAttempt) error {
+	if _, err := s.assignedStep(runner, attempt.StepID); err != nil {
+		return err
+	}
+
+	existing, err := s.buildRepo.GetStepAttempt(attempt.StepID, attempt.Attempt)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return errors.New("步骤执行记录不存在")
+	}
+	attempt.ID = existing.ID
+	return s.reporter.FinishStepAttempt(attempt)
+}
+
+// UploadArtifact 保存执行器上传的制品文件，制品所属的作业须属于分配给执行器的构建
+func (s *runnerService) UploadArtifact(ctx context.Context, runner *model.Runner, artifact *model.Artifact, content io.Reader, size int64) error {
+	job, err := s.buildRepo.GetJobByID(artifact.JobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrJobNotAssigned
+	}
+	if _, err := s.assignedBuild(runner, job.BuildID); err != nil {
+		return err
+	}
+
+	if artifact.Path == "" || path.IsAbs(artifact.Path) || path.Clean(artifact.Path) != artifact.Path ||
+		strings.HasPrefix(artifact.Path, "../") {
+		return fmt.Errorf("无效的制品路径 %q", artifact.Path)
+	}
+	if artifact.Mode > 0o777 {
+		return fmt.Errorf("无效的制品权限 %o", artifact.Mode)
+	}
+
+	artifact.BuildID = job.BuildID
+	return s.artifacts.Save(ctx, artifact, content, size)
+}
+
+// DependencyArtifacts 获取作业所在构建中上游作业 upstream 的制品
+func (s *runnerService) DependencyArtifacts(ctx context.Context, runner *model.Runner, jobID int, upstream string) ([]*model.Artifact, error) {
+	if _, _, err := s.assignedJob(runner, jobID); err != nil {
+		return nil, err
+	}
+	return s.reporter.DependencyArtifacts(ctx, jobID, upstream)
+}
+
+// OpenDependencyArtifact 读取作业可以获取的上游作业制品，制品不存在或不是作业所在构建中的制品时返回 nil，
+// 已过期时返回 ErrArtifactExpired
+func (s *runnerService) OpenDependencyArtifact(ctx context.Context, runner *model.Runner, jobID, artifactID int) (*model.Artifact, io.ReadCloser, error) {
+	if _, _, err := s.assignedJob(runner, jobID); err != nil {
+		return nil, nil, err
+	}
+
+	artifact, err := s.artifacts.Get(artifactID)
+	if err != nil || artifact == nil {
+		return nil, nil, err
+	}
+	upstream, err := s.buildRepo.GetJobByID(artifact.JobID)
+	if err != nil || upstream == nil {
+		return nil, nil, err
+	}
+	// 按名称重新查找上游作业的制品，制品须在其中，避免读取其他构建的制品
+	allowed, err := s.reporter.DependencyArtifacts(ctx, jobID, upstream.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := false
+	for _, a := range allowed {
+		found = found || a.ID == artifact.ID
+	}
+	if !found {
+		return nil, nil, nil
+	}
+	if artifact.ExpiresAt != nil && !artifact.ExpiresAt.After(time.Now()) {
+		return nil, nil, ErrArtifactExpired
+	}
+
+	content, err := s.artifacts.Open(ctx, artifact)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return artifact, content, nil
+}
+
+// FindCache 查找作业所属项目的缓存，未命中时返回 nil
+func (s *runnerService) FindCache(runner *model.Runner, jobID int, query *model.RunnerCacheQuery) (*model.Cache, error) {
+	_, projectID, err := s.assignedJob(runner, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return s.caches.Find(projectID, query.Key, query.RestoreKeys)
+}
+
+// OpenCache 读取作业所属项目的缓存，缓存不存在或不属于该项目时返回 nil
+func (s *runnerService) OpenCache(ctx context.Context, runner *model.Runner, jobID, cacheID int) (*model.Cache, io.ReadCloser, error) {
+	_, projectID, err := s.assignedJob(runner, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache, err := s.caches.Get(projectID, cacheID)
+	if err != nil || cache == nil {
+		return nil, nil, err
+	}
+	content, err := s.caches.Open(ctx, cache)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return cache, content, nil
+}
+
+// SaveCache 保存执行器上传的缓存压缩包，缓存属于作业所属的项目
+func (s *runnerService) SaveCache(ctx context.Context, runner *model.Runner, jobID int, cache *model.Cache, content io.Reader, size int64) error {
+	job, projectID, err := s.assignedJob(runner, jobID)
+	if err != nil {
+		return err
+	}
+	if cache.Key == "" || len(cache.Key) > 255 {
+		return fmt.Errorf("无效的缓存键 %q", cache.Key)
+	}
+
+	cache.ProjectID = projectID
+	cache.BuildID = &job.BuildID
+	return s.caches.Save(ctx, cache, content, size)
+}
+
+// assignedJob 获取分配给执行器的构建中的作业及其所属的项目
+func (s *runnerService) assignedJob(runner *model.Runner, jobID int) (*model.BuildJob, int, error) {
+	job, err := s.buildRepo.GetJobByID(jobID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if job == nil {
+		return nil, 0, ErrJobNotAssigned
+	}
+	build, err := s.assignedBuild(runner, job.BuildID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	p, err := s.pipelineRepo.GetByID(build.PipelineID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if p == nil {
+		return nil, 0, fmt.Errorf("pipeline %d of build %d not found", build.PipelineID, build.ID)
+	}
+	return job, p.ProjectID, nil
+}
+
+// assignedBuild 获取分配给执行器的构建
+func (s *runnerService) assignedBuild(runner *model.Runner, buildID int) (*model.Build, error) {
+	build, err := s.buildRepo.GetByID(buildID)
+	if err != nil {
+		return nil, err
+	}
+	if build == nil || !assignedTo(build, runner) {
+		return nil, ErrJobNotAssigned
+	}
+	return build, nil
+}
+
+// assignedStep 获取分配给执行器的构建中的步骤
+func (s *runnerService) assignedStep(runner *model.Runner, stepID int) (*model.BuildStep, error) {
+	step, err := s.buildRepo.GetStepByID(stepID)
+	if err != nil {
+		return nil, err
+	}
+	if step == nil {
+		return nil, ErrJobNotAssigned
+	}
+	if _, err := s.assignedBuild(runner, step.BuildID); err != nil {
+		return nil, err
+	}
+	return step, nil
+}
+
+func assignedTo(build *model.Build, runner *model.Runner) bool {
+	return build.RunnerID != nil && *build.RunnerID == runner.ID
+}
+
+// normalizeLabels 校验标签，去除空白和重复的标签并排序
+func normalizeLabels(labels []string) ([]string, error) {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" || seen[label] {
+			continue
+		}
+		if !pipeline.LabelPattern.MatchString(label) {
+			return nil, fmt.Errorf("无效的标签 %q", label)
+		}
+		seen[label] = true
+		result = append(result, label)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// sameLabels 判断两组已规范化的标签是否相同
+func sameLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// generateRunnerToken 生成执行器令牌
+func generateRunnerToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashRunnerToken 返回令牌的SHA-256，数据库中只保存哈希
+func hashRunnerToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/engine"
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+)
+
+// fakeRunnerRepo 内存中的执行器仓库
+type fakeRunnerRepo struct {
+	repository.RunnerRepository
+	runners map[int]*model.Runner
+}
+
+func (r *fakeRunnerRepo) Create(runner *model.Runner) error {
+	runner.ID = len(r.runners) + 1
+	r.runners[runner.ID] = runner
+	return nil
+}
+
+func (r *fakeRunnerRepo) GetByID(id int) (*model.Runner, error) { return r.runners[id], nil }
+
+func (r *fakeRunnerRepo) GetByTokenHash(tokenHash string) (*model.Runner, error) {
+	for _, runner := range r.runners {
+		if runner.TokenHash == tokenHash {
+			return runner, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRunnerRepo) Touch(id int) error {
+	now := time.Now()
+	r.runners[id].LastSeenAt = &now
+	return nil
+}
+
+// memBuildRepo 内存中的构建仓库，实现领取和执行构建所需的方法
+type memBuildRepo struct {
+	repository.BuildRepository
+	builds map[int]*model.Build
+	jobs   map[int]*model.BuildJob
+	steps  map[int]*model.BuildStep
+}
+
+func newMemBuildRepo(builds ...*model.Build) *memBuildRepo {
+	r := &memBuildRepo{builds: map[int]*model.Build{}, jobs: map[int]*model.BuildJob{}, steps: map[int]*model.BuildStep{}}
+	for _, build := range builds {
+		r.builds[build.ID] = build
+	}
+	return r
+}
+
+func (r *memBuildRepo) GetByID(id int) (*model.Build, error) {
+	if build, ok := r.builds[id]; ok {
+		copied := *build
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *memBuildRepo) UpdateStatus(id int, status string) error {
+	r.builds[id].Status = status
+	return nil
+}
+
+func (r *memBuildRepo) SetRunner(id int, runnerID *int) error {
+	r.builds[id].RunnerID = runnerID
+	return nil
+}
+
+func (r *memBuildRepo) GetRunsOn(ids []int) (map[int]string, error) {
+	result := make(map[int]string, len(ids))
+	for _, id := range ids {
+		if build, ok := r.builds[id]; ok && (build.Status == model.BuildStatusPending || build.Status == model.BuildStatusRunning) {
+			result[id] = build.RunsOn
+		}
+	}
+	return result, nil
+}
+
+func (r *memBuildRepo) CreateJob(job *model.BuildJob) error {
+	job.ID = len(r.jobs) + 1
+	r.jobs[job.ID] = job
+	return nil
+}
+
+func (r *memBuildRepo) GetJobByID(id int) (*model.BuildJob, error) { return r.jobs[id], nil }
+
+func (r *memBuildRepo) CreateStep(step *model.BuildStep) error {
+	if step.ID == 0 {
+		step.ID = 100 + len(r.steps)
+	}
+	r.steps[step.ID] = step
+	return nil
+}
+
+func (r *memBuildRepo) GetStepByID(id int) (*model.BuildStep, error) { return r.steps[id], nil }
+
+func (r *memBuildRepo) UpdateStepStatus(id int, status string) error {
+	r.steps[id].Status = status
+	return nil
+}
+
+func (r *memBuildRepo) SetStepExitCode(id int, exitCode int) error {
+	r.steps[id].ExitCode = &exitCode
+	return nil
+}
+
+func (r *memBuildRepo) ReuseStep(id int, fromStepID int) error {
+	r.steps[id].Status = model.StepStatusSuccess
+	r.steps[id].ReusedFrom = &fromStepID
+	return nil
+}
+
+// noSecretRepo 没有任何密钥的密钥仓库
+type noSecretRepo struct {
+	repository.SecretRepository
+}
+
+func (noSecretRepo) GetForBuild(projectID, pipelineID int) ([]*model.Secret, error) { return nil, nil }
+
+// newTestRunnerService 创建使用内存仓库的执行器服务，流水线 2 属于项目 1
+func newTestRunnerService(builds *memBuildRepo, queue *fakeQueue, cfg *config.Config) *runnerService {
+	repos := &repository.Repositories{
+		Runner: &fakeRunnerRepo{runners: map[int]*model.Runner{}},
+		Build:  builds,
+		Pipeline: &fakePipelineRepo{pipelines: map[int]*model.Pipeline{
+			2: {ID: 2, ProjectID: 1, Name: "ci", Config: "stages:\n  - name: test\n    steps:\n      - {name: unit, run: make test}\n      - {name: lint, run: make lint}\n"},
+			3: {ID: 3, ProjectID: 1, Name: "release"},
+		}},
+		Project: &fakeProjectRepo{projects: map[int]*model.Project{1: {ID: 1, OwnerID: 10}}},
+		Secret:  noSecretRepo{},
+		Queue:   queue,
+		Logs:    &fakeLogStream{},
+	}
+	return &runnerService{
+		runnerRepo:   repos.Runner,
+		buildRepo:    repos.Build,
+		pipelineRepo: repos.Pipeline,
+		queue:        queue,
+		engine:       engine.New(repos, cfg, nil, nil, nil),
+		reporter:     engine.NewReporter(repos, nil, nil),
+		cfg:          cfg,
+	}
+}
+
+func TestRunnerRegisterAndAuthenticate(t *testing.T) {
+	cfg := &config.Config{}
+	s := newTestRunnerService(newMemBuildRepo(), &fakeQueue{}, cfg)
+
+	if _, err := s.Register(&model.RegisterRunnerRequest{Name: "r1", Token: ""}); err == nil {
+		t.Fatal("Register() error = nil, want registration disabled without a registration token")
+	}
+
+	cfg.Runner.RegistrationToken = "registration-token"
+	if _, err := s.Register(&model.RegisterRunnerRequest{Name: "r1", Token: "wrong"}); err == nil {
+		t.Fatal("Register() error = nil, want the wrong registration token rejected")
+	}
+
+	resp, err := s.Register(&model.RegisterRunnerRequest{Name: " r1 ", Token: "registration-token", Labels: []string{"linux", " gpu", "linux"}})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if resp.Token == "" || resp.Runner.TokenHash == resp.Token {
+		t.Errorf("Register() token = %q, want a token stored only as a hash", resp.Token)
+	}
+	if resp.Runner.Name != "r1" || !reflect.DeepEqual(resp.Runner.Labels, []string{"gpu", "linux"}) {
+		t.Errorf("registered runner = %+v", resp.Runner)
+	}
+
+	runner, err := s.Authenticate(resp.Token)
+	if err != nil || runner == nil || runner.ID != resp.Runner.ID {
+		t.Fatalf("Authenticate() = %+v, %v, want the registered runner", runner, err)
+	}
+	if _, err := s.Authenticate("unknown"); err == nil {
+		t.Error("Authenticate() error = nil, want an unknown token rejected")
+	}
+
+	now := time.Now()
+	runner.RevokedAt = &now
+	if _, err := s.Authenticate(resp.Token); err == nil {
+		t.Error("Authenticate() error = nil, want a revoked runner rejected")
+	}
+}
+
+func TestRunnerClaimAndReport(t *testing.T) {
+	builds := newMemBuildRepo(
+		&model.Build{ID: 1, PipelineID: 2, Status: model.BuildStatusCanceled},
+		&model.Build{ID: 2, PipelineID: 2, Status: model.BuildStatusPending, RunsOn: "gpu"},
+		&model.Build{ID: 3, PipelineID: 2, Status: model.BuildStatusPending},
+	)
+	queue := &fakeQueue{pending: []int{1, 2, 3}}
+	s := newTestRunnerService(builds, queue, &config.Config{})
+	runner := &model.Runner{ID: 1, Labels: []string{"linux"}}
+	other := &model.Runner{ID: 2}
+	s.runnerRepo.(*fakeRunnerRepo).runners = map[int]*model.Runner{1: runner, 2: other}
+
+	// 已取消的构建移出队列，等待GPU执行器的构建被跳过
+	job, err := s.RequestJob(context.Background(), runner)
+	if err != nil || job == nil {
+		t.Fatalf("RequestJob() = %+v, %v, want build 3", job, err)
+	}
+	if job.Build.ID != 3 || len(job.Jobs) != 1 || len(job.Jobs[0].Steps) != 2 {
+		t.Fatalf("RequestJob() = build %d with jobs %+v", job.Build.ID, job.Jobs)
+	}
+	if !reflect.DeepEqual(queue.pending, []int{2}) || !reflect.DeepEqual(queue.claimed, []int{3}) {
+		t.Errorf("queue pending = %v, claimed = %v", queue.pending, queue.claimed)
+	}
+	if build := builds.builds[3]; build.Status != model.BuildStatusRunning || !assignedTo(build, runner) {
+		t.Errorf("claimed build = %+v, want running on runner 1", build)
+	}
+	if runner.LastSeenAt == nil {
+		t.Error("RequestJob() did not record the runner as seen")
+	}
+
+	step := job.Jobs[0].Steps[0]
+	exitCode := 0
+	update := &model.RunnerStepUpdate{Status: model.StepStatusSuccess, ExitCode: &exitCode}
+
+	// 其他执行器不能上报不属于自己的构建
+	if err := s.UpdateStep(other, step.ID, update); !errors.Is(err, ErrJobNotAssigned) {
+		t.Errorf("UpdateStep() by other runner error = %v, want ErrJobNotAssigned", err)
+	}
+	if err := s.FinishJob(context.Background(), other, 3, model.BuildStatusSuccess); !errors.Is(err, ErrJobNotAssigned) {
+		t.Errorf("FinishJob() by other runner error = %v, want ErrJobNotAssigned", err)
+	}
+	if err := s.UpdateStep(runner, 999, update); !errors.Is(err, ErrJobNotAssigned) {
+		t.Errorf("UpdateStep() of unknown step error = %v, want ErrJobNotAssigned", err)
+	}
+
+	if err := s.UpdateStep(runner, step.ID, update); err != nil {
+		t.Fatalf("UpdateStep() error = %v", err)
+	}
+	if got := builds.steps[step.ID]; got.Status != model.StepStatusSuccess || got.ExitCode == nil || *got.ExitCode != 0 {
+		t.Errorf("updated step = %+v", got)
+	}
+
+	status, err := s.GetJobStatus(runner, 3)
+	if err != nil || status != model.BuildStatusRunning {
+		t.Errorf("GetJobStatus() = %q, %v, want running", status, err)
+	}
+	if err := s.FinishJob(context.Background(), runner, 3, model.BuildStatusSuccess); err != nil {
+		t.Fatalf("FinishJob() error = %v", err)
+	}
+	if builds.builds[3].Status != model.BuildStatusSuccess || !reflect.DeepEqual(queue.acked, []int{3}) {
+		t.Errorf("finished build status = %q, acked = %v", builds.builds[3].Status, queue.acked)
+	}
+}
+
+func TestRunnerClaimMaxDeliveries(t *testing.T) {
+	builds := newMemBuildRepo(&model.Build{ID: 1, PipelineID: 2, Status: model.BuildStatusRunning})
+	queue := &fakeQueue{pending: []int{1}, deliveries: 4}
+	cfg := &config.Config{}
+	cfg.Worker.MaxDeliveries = 3
+	s := newTestRunnerService(builds, queue, cfg)
+
+	job, err := s.claim(&model.Runner{ID: 1})
+	if err != nil || job != nil {
+		t.Fatalf("claim() = %+v, %v, want nil", job, err)
+	}
+	// 反复中断的构建不再重试
+	if builds.builds[1].Status != model.BuildStatusFailed || !reflect.DeepEqual(queue.acked, []int{1}) {
+		t.Errorf("build status = %q, acked = %v, want failed and acked", builds.builds[1].Status, queue.acked)
+	}
+}
+
+func TestRunnerUpdateStepReusedFrom(t *testing.T) {
+	runner := &model.Runner{ID: 1}
+	builds := newMemBuildRepo(
+		&model.Build{ID: 1, PipelineID: 2, Status: model.BuildStatusFailed},
+		&model.Build{ID: 2, PipelineID: 3, Status: model.BuildStatusSuccess},
+		&model.Build{ID: 3, PipelineID: 2, Status: model.BuildStatusRunning, RunnerID: &runner.ID},
+		&model.Build{ID: 4, PipelineID: 2, Status: model.BuildStatusPending},
+	)
+	for _, step := range []*model.BuildStep{
+		{ID: 10, BuildID: 1, Status: model.StepStatusSuccess},
+		{ID: 11, BuildID: 1, Status: model.StepStatusFailed},
+		{ID: 20, BuildID: 2, Status: model.StepStatusSuccess},
+		{ID: 30, BuildID: 3, Status: model.StepStatusPending},
+		{ID: 31, BuildID: 3, Status: model.StepStatusSuccess},
+		{ID: 40, BuildID: 4, Status: model.StepStatusSuccess},
+	} {
+		builds.CreateStep(step)
+	}
+	s := newTestRunnerService(builds, &fakeQueue{}, &config.Config{})
+
+	tests := []struct {
+		name    string
+		from    int
+		wantErr bool
+	}{
+		{name: "earlier build of the same pipeline", from: 10},
+		{name: "failed step", from: 11, wantErr: true},
+		{name: "other pipeline", from: 20, wantErr: true},
+		{name: "same build", from: 31, wantErr: true},
+		{name: "later build", from: 40, wantErr: true},
+		{name: "unknown step", from: 99, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builds.steps[30].Status = model.StepStatusPending
+			builds.steps[30].ReusedFrom = nil
+
+			err := s.UpdateStep(runner, 30, &model.RunnerStepUpdate{ReusedFrom: &tt.from})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateStep() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := builds.steps[30]; tt.wantErr != (got.ReusedFrom == nil) {
+				t.Errorf("step reused from = %v, want reused only when valid", got.ReusedFrom)
+			}
+		})
+	}
+}
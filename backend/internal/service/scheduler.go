@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+	"Vortexia/internal/repository"
+)
+
+// schedulerPageSize 领取时每次从队列读取的待执行构建数
+const schedulerPageSize = 50
+
+// schedulerScanLimit 每次领取最多检查的待执行构建数，避免等待其他执行器的构建堆积时每次领取都遍历整个队列。
+// 排在更后面的构建在前面的构建被领取后才会被检查
+const schedulerScanLimit = 10 * schedulerPageSize
+
+// claimBuild 按入队顺序领取第一个标签表达式被 labels 满足的构建，没有可执行的构建时返回 nil。
+// 逐页检查待执行列表最早的 schedulerScanLimit 个构建，等待其他执行器的构建不会阻塞排在后面的构建；
+// 已删除或已结束（如等待期间被取消）的构建直接移出队列
+func claimBuild(ctx context.Context, queue repository.BuildQueue, buildRepo repository.BuildRepository, labels []string, visibility time.Duration) (*repository.QueueItem, error) {
+	for offset := 0; offset < schedulerScanLimit; {
+		ids, err := queue.Pending(ctx, offset, schedulerPageSize)
+		if err != nil || len(ids) == 0 {
+			return nil, err
+		}
+
+		runsOn, err := buildRepo.GetRunsOn(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		// 移出列表的构建使后面的构建前移，下一页的位置相应减少
+		removed := 0
+		for _, id := range ids {
+			expr, ok := runsOn[id]
+			if !ok {
+				if err := queue.Remove(ctx, id); err != nil {
+					return nil, err
+				}
+				removed++
+				continue
+			}
+			if !matchLabels(expr, labels) {
+				continue
+			}
+
+			item, err := queue.Claim(ctx, id, visibility)
+			if err != nil {
+				return nil, err
+			}
+			if item != nil {
+				return item, nil
+			}
+			// 已被其他执行器领取
+			removed++
+		}
+
+		if len(ids) < schedulerPageSize {
+			return nil, nil
+		}
+		offset += len(ids) - removed
+	}
+	return nil, nil
+}
+
+// matchLabels 判断标签是否满足构建的标签表达式
+func matchLabels(expr string, labels []string) bool {
+	if expr == "" {
+		return true
+	}
+	selector, err := pipeline.ParseLabelSelector(expr)
+	if err != nil {
+		// 表达式来自已校验的配置，不会解析失败；万一失败交给任意执行器，避免构建永远等待
+		return true
+	}
+	return selector.Matches(labels)
+}
+
+// runsOnOf 返回流水线配置中的标签表达式，配置无效时返回空字符串，由准备构建时将构建标记为失败
+func runsOnOf(config string) string {
+	def, err := pipeline.Parse(config)
+	if err != nil {
+		return ""
+	}
+	return def.RunsOn.String()
+}
+
+// describePending 为等待指定执行器的构建填写等待原因
+func describePending(builds ...*model.Build) {
+	for _, build := range builds {
+		if build.Status == model.BuildStatusPending && build.RunsOn != "" {
+			build.PendingReason = fmt.Sprintf("等待匹配 %s 的执行器", build.RunsOn)
+		}
+	}
+}
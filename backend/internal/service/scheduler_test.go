@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+)
+
+// fakeQueue 内存中的构建队列，pending 按入队顺序排列
+type fakeQueue struct {
+	pending    []int
+	claimed    []int
+	acked      []int
+	pages      int
+	deliveries int // 领取时返回的投递次数，0 表示首次投递
+}
+
+func (q *fakeQueue) Enqueue(ctx context.Context, buildID int) error {
+	q.pending = append(q.pending, buildID)
+	return nil
+}
+
+func (q *fakeQueue) Pending(ctx context.Context, offset, limit int) ([]int, error) {
+	q.pages++
+	if offset >= len(q.pending) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(q.pending) {
+		end = len(q.pending)
+	}
+	return append([]int(nil), q.pending[offset:end]...), nil
+}
+
+func (q *fakeQueue) Claim(ctx context.Context, buildID int, visibility time.Duration) (*repository.QueueItem, error) {
+	for i, id := range q.pending {
+		if id == buildID {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			q.claimed = append(q.claimed, buildID)
+			deliveries := q.deliveries
+			if deliveries == 0 {
+				deliveries = 1
+			}
+			return &repository.QueueItem{BuildID: buildID, Deliveries: deliveries}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (q *fakeQueue) Remove(ctx context.Context, buildID int) error {
+	for i, id := range q.pending {
+		if id == buildID {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (q *fakeQueue) Extend(ctx context.Context, buildID int, visibility time.Duration) error {
+	return nil
+}
+
+func (q *fakeQueue) Ack(ctx context.Context, buildID int) error {
+	q.acked = append(q.acked, buildID)
+	return nil
+}
+
+func (q *fakeQueue) RequeueExpired(ctx context.Context) (int, error) { return 0, nil }
+
+func (q *fakeQueue) Restore(ctx context.Context, ids []int) ([]int, error) { return nil, nil }
+
+// fakeRunsOnRepo 只实现 GetRunsOn 的构建仓库
+type fakeRunsOnRepo struct {
+	repository.BuildRepository
+	runsOn map[int]string
+}
+
+func (r *fakeRunsOnRepo) GetRunsOn(ids []int) (map[int]string, error) {
+	result := make(map[int]string, len(ids))
+	for _, id := range ids {
+		if expr, ok := r.runsOn[id]; ok {
+			result[id] = expr
+		}
+	}
+	return result, nil
+}
+
+func TestClaimBuild(t *testing.T) {
+	// 前面排满等待GPU执行器的构建，最后是任意执行器都可以执行的构建
+	queue := &fakeQueue{}
+	repo := &fakeRunsOnRepo{runsOn: map[int]string{}}
+	for id := 1; id <= 3*schedulerPageSize; id++ {
+		queue.pending = append(queue.pending, id)
+		repo.runsOn[id] = "gpu"
+	}
+	last := 3*schedulerPageSize + 1
+	queue.pending = append(queue.pending, last)
+	repo.runsOn[last] = "linux || windows"
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   int
+	}{
+		{name: "skips builds waiting for other runners", labels: []string{"linux"}, want: last},
+		{name: "claims oldest matching build", labels: []string{"gpu", "linux"}, want: 1},
+		{name: "nothing matches", labels: []string{"darwin"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQueue{pending: append([]int(nil), queue.pending...)}
+			item, err := claimBuild(context.Background(), q, repo, tt.labels, time.Minute)
+			if err != nil {
+				t.Fatalf("claimBuild() error = %v", err)
+			}
+
+			got := 0
+			if item != nil {
+				got = item.BuildID
+			}
+			if got != tt.want {
+				t.Errorf("claimBuild() = build %d, want %d", got, tt.want)
+			}
+			if tt.want == 0 && q.pages != 4 {
+				t.Errorf("scanned %d pages, want the whole queue in 4 pages", q.pages)
+			}
+		})
+	}
+}
+
+func TestClaimBuildRemovesStaleBuilds(t *testing.T) {
+	// 第一页全部是已删除或已结束的构建，移出后下一页从列表开头读取
+	q := &fakeQueue{}
+	repo := &fakeRunsOnRepo{runsOn: map[int]string{}}
+	for id := 1; id <= schedulerPageSize; id++ {
+		q.pending = append(q.pending, id)
+	}
+	q.pending = append(q.pending, 100, 101)
+	repo.runsOn[100] = "gpu"
+	repo.runsOn[101] = ""
+
+	item, err := claimBuild(context.Background(), q, repo, nil, time.Minute)
+	if err != nil || item == nil || item.BuildID != 101 {
+		t.Fatalf("claimBuild() = %+v, %v, want build 101", item, err)
+	}
+	if !reflect.DeepEqual(q.pending, []int{100}) {
+		t.Errorf("pending = %v, want stale builds removed", q.pending)
+	}
+}
+
+func TestClaimBuildScanLimit(t *testing.T) {
+	// 排在 schedulerScanLimit 之后的构建本次不检查
+	q := &fakeQueue{}
+	repo := &fakeRunsOnRepo{runsOn: map[int]string{}}
+	for id := 1; id <= schedulerScanLimit+1; id++ {
+		q.pending = append(q.pending, id)
+		repo.runsOn[id] = "gpu"
+	}
+	repo.runsOn[schedulerScanLimit+1] = ""
+
+	item, err := claimBuild(context.Background(), q, repo, []string{"linux"}, time.Minute)
+	if err != nil || item != nil {
+		t.Fatalf("claimBuild() = %+v, %v, want nil", item, err)
+	}
+	if want := schedulerScanLimit / schedulerPageSize; q.pages != want {
+		t.Errorf("scanned %d pages, want %d", q.pages, want)
+	}
+
+	// 前面的构建被领取后，后面的构建进入检查范围
+	q.pending = q.pending[1:]
+	item, err = claimBuild(context.Background(), q, repo, []string{"linux"}, time.Minute)
+	if err != nil || item == nil || item.BuildID != schedulerScanLimit+1 {
+		t.Fatalf("claimBuild() = %+v, %v, want build %d", item, err, schedulerScanLimit+1)
+	}
+}
+
+func TestDescribePending(t *testing.T) {
+	builds := []*model.Build{
+		{ID: 1, Status: model.BuildStatusPending, RunsOn: "gpu"},
+		{ID: 2, Status: model.BuildStatusPending},
+		{ID: 3, Status: model.BuildStatusRunning, RunsOn: "gpu"},
+	}
+	describePending(builds...)
+
+	want := []string{"等待匹配 gpu 的执行器", "", ""}
+	for i, build := range builds {
+		if build.PendingReason != want[i] {
+			t.Errorf("build %d pending reason = %q, want %q", build.ID, build.PendingReason, want[i])
+		}
+	}
+}
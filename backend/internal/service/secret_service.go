@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/secrets"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// secretNamePattern 密钥以同名环境变量注入步骤，名称需为有效的环境变量名
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedSecretPrefixes 引擎设置的环境变量前缀，密钥不能使用
+var reservedSecretPrefixes = []string{"VORTEXIA", "MATRIX_", "PARAM_"}
+
+type secretService struct {
+	secretRepo   repository.SecretRepository
+	projectRepo  repository.ProjectRepository
+	pipelineRepo repository.PipelineRepository
+	keyring      *secrets.Keyring
+}
+
+// NewSecretService 创建密钥服务实例
+func NewSecretService(repos *repository.Repositories, keyring *secrets.Keyring) SecretService {
+	return &secretService{
+		secretRepo:   repos.Secret,
+		projectRepo:  repos.Project,
+		pipelineRepo: repos.Pipeline,
+		keyring:      keyring,
+	}
+}
+
+// ListByProject 获取项目的密钥，项目不存在或用户不是项目的所有者或管理员时返回 nil
+func (s *secretService) ListByProject(projectID int, user *model.User) ([]*model.Secret, error) {
+	ok, err := s.canAccess(projectID, user)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return s.secretRepo.GetByProject(projectID)
+}
+
+// ListByPipeline 获取流水线的密钥，流水线不存在或用户不是所属项目的所有者或管理员时返回 nil
+func (s *secretService) ListByPipeline(pipelineID int, user *model.User) ([]*model.Secret, error) {
+	p, err := s.accessiblePipeline(pipelineID, user)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return s.secretRepo.GetByPipeline(pipelineID)
+}
+
+// CreateForProject 创建项目的密钥，项目不存在或用户不是项目的所有者或管理员时返回 nil
+func (s *secretService) CreateForProject(projectID int, req *model.CreateSecretRequest, user *model.User) (*model.Secret, error) {
+	ok, err := s.canAccess(projectID, user)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	existing, err := s.secretRepo.GetByProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	return s.create(&model.Secret{ProjectID: projectID, Name: req.Name, CreatedBy: user.ID}, req.Value, existing)
+}
+
+// CreateForPipeline 创建流水线的密钥，流水线不存在或用户不是所属项目的所有者或管理员时返回 nil
+func (s *secretService) CreateForPipeline(pipelineID int, req *model.CreateSecretRequest, user *model.User) (*model.Secret, error) {
+	p, err := s.accessiblePipeline(pipelineID, user)
+	if err != nil || p == nil {
+		return nil, err
+	}
+
+	existing, err := s.secretRepo.GetByPipeline(pipelineID)
+	if err != nil {
+		return nil, err
+	}
+	secret := &model.Secret{ProjectID: p.ProjectID, PipelineID: &p.ID, Name: req.Name, CreatedBy: user.ID}
+	return s.create(secret, req.Value, existing)
+}
+
+// create 校验名称并加密保存密钥，existing 为同一作用域内已有的密钥
+func (s *secretService) create(secret *model.Secret, value string, existing []*model.Secret) (*model.Secret, error) {
+	if err := validateSecretName(secret.Name); err != nil {
+		return nil, err
+	}
+	for _, other := range existing {
+		if other.Name == secret.Name {
+			return nil, fmt.Errorf("密钥 %s 已存在", secret.Name)
+		}
+	}
+
+	if err := s.seal(secret, value); err != nil {
+		return nil, err
+	}
+	if err := s.secretRepo.Create(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Update 修改密钥的值，密钥不存在或用户不是所属项目的所有者或管理员时返回 nil
+func (s *secretService) Update(id int, req *model.UpdateSecretRequest, user *model.User) (*model.Secret, error) {
+	secret, err := s.accessibleSecret(id, user)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+
+	if err := s.seal(secret, req.Value); err != nil {
+		return nil, err
+	}
+	if err := s.secretRepo.UpdateValue(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Delete 删除密钥，返回被删除的密钥，密钥不存在或用户不是所属项目的所有者或管理员时返回 nil
+func (s *secretService) Delete(id int, user *model.User) (*model.Secret, error) {
+	secret, err := s.accessibleSecret(id, user)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+
+	if err := s.secretRepo.Delete(id); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// RotateKeys 用当前主密钥重新加密其他主密钥加密的密钥，返回重新加密的数量。
+// 加密所用主密钥已不在配置中的密钥无法解密，保持原样并记录日志
+func (s *secretService) RotateKeys() (int, error) {
+	primary := s.keyring.Primary()
+	if primary == "" {
+		return 0, nil
+	}
+
+	stale, err := s.secretRepo.GetNotEncryptedWith(primary)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, secret := range stale {
+		value, err := s.keyring.Open(secret)
+		if err != nil {
+			logger.Warn("Cannot rotate secret", zap.Int("secret_id", secret.ID), zap.Error(err))
+			continue
+		}
+		if err := s.keyring.Seal(secret, value); err != nil {
+			return rotated, err
+		}
+		if err := s.secretRepo.UpdateValue(secret); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// canAccess 判断用户能否管理项目的密钥，项目不存在时返回 false
+func (s *secretService) canAccess(projectID int, user *model.User) (bool, error) {
+	project, err := s.projectRepo.GetByID(projectID)
+	if err != nil {
+		return false, err
+	}
+	return canAccessProject(project, user), nil
+}
+
+// accessiblePipeline 获取用户可以管理密钥的流水线，流水线不存在或无权访问时返回 nil
+func (s *secretService) accessiblePipeline(pipelineID int, user *model.User) (*model.Pipeline, error) {
+	p, err := s.pipelineRepo.GetByID(pipelineID)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	ok, err := s.canAccess(p.ProjectID, user)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return p, nil
+}
+
+// accessibleSecret 获取用户可以管理的密钥，密钥不存在或无权访问时返回 nil。
+// 流水线的密钥按流水线当前所属的项目判断
+func (s *secretService) accessibleSecret(id int, user *model.User) (*model.Secret, error) {
+	secret, err := s.secretRepo.GetByID(id)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+
+	projectID := secret.ProjectID
+	if secret.PipelineID != nil {
+		p, err := s.pipelineRepo.GetByID(*secret.PipelineID)
+		if err != nil || p == nil {
+			return nil, err
+		}
+		projectID = p.ProjectID
+	}
+	ok, err := s.canAccess(projectID, user)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (s *secretService) seal(secret *model.Secret, value string) error {
+	if err := s.keyring.Seal(secret, value); err != nil {
+		if errors.Is(err, secrets.ErrNoMasterKey) {
+			return errors.New("服务端未配置密钥加密主密钥，无法保存密钥")
+		}
+		return err
+	}
+	return nil
+}
+
+func validateSecretName(name string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("无效的密钥名称 %q，只能包含字母、数字和下划线，且不能以数字开头", name)
+	}
+	upper := strings.ToUpper(name)
+	if upper == "CI" {
+		return fmt.Errorf("密钥名称 %s 为保留的环境变量名", name)
+	}
+	for _, prefix := range reservedSecretPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return fmt.Errorf("密钥名称不能以保留的前缀 %s 开头", prefix)
+		}
+	}
+	return nil
+}
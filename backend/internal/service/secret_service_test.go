@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/secrets"
+)
+
+type fakeSecretRepo struct {
+	repository.SecretRepository
+	secrets map[int]*model.Secret
+	writes  int
+}
+
+func (r *fakeSecretRepo) GetByID(id int) (*model.Secret, error) { return r.secrets[id], nil }
+
+func (r *fakeSecretRepo) GetByProject(projectID int) ([]*model.Secret, error) {
+	result := []*model.Secret{}
+	for _, secret := range r.secrets {
+		if secret.ProjectID == projectID && secret.PipelineID == nil {
+			result = append(result, secret)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeSecretRepo) GetByPipeline(pipelineID int) ([]*model.Secret, error) {
+	result := []*model.Secret{}
+	for _, secret := range r.secrets {
+		if secret.PipelineID != nil && *secret.PipelineID == pipelineID {
+			result = append(result, secret)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeSecretRepo) Create(secret *model.Secret) error {
+	r.writes++
+	return nil
+}
+
+func (r *fakeSecretRepo) UpdateValue(secret *model.Secret) error {
+	r.writes++
+	return nil
+}
+
+func (r *fakeSecretRepo) Delete(id int) error {
+	r.writes++
+	return nil
+}
+
+func TestSecretServiceAccess(t *testing.T) {
+	keyring, err := secrets.NewKeyring([]config.MasterKey{{ID: "k1", Key: bytes.Repeat([]byte{1}, 32)}})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	// 项目 3 属于用户 10，流水线 2 属于项目 3；密钥 5 是项目的密钥，密钥 6 是流水线的密钥
+	pipelineID := 2
+	newService := func() (*secretService, *fakeSecretRepo) {
+		repo := &fakeSecretRepo{secrets: map[int]*model.Secret{
+			5: {ID: 5, ProjectID: 3, Name: "TOKEN"},
+			6: {ID: 6, ProjectID: 3, PipelineID: &pipelineID, Name: "DEPLOY_KEY"},
+		}}
+		return &secretService{
+			secretRepo:   repo,
+			projectRepo:  &fakeProjectRepo{projects: map[int]*model.Project{3: {ID: 3, OwnerID: 10}}},
+			pipelineRepo: &fakePipelineRepo{pipelines: map[int]*model.Pipeline{2: {ID: 2, ProjectID: 3}}},
+			keyring:      keyring,
+		}, repo
+	}
+
+	users := []struct {
+		name string
+		user *model.User
+		want bool
+	}{
+		{name: "owner", user: &model.User{ID: 10, Role: model.RoleUser}, want: true},
+		{name: "admin", user: &model.User{ID: 1, Role: model.RoleAdmin}, want: true},
+		{name: "other user", user: &model.User{ID: 11, Role: model.RoleUser}},
+		{name: "no user"},
+	}
+	req := &model.CreateSecretRequest{Name: "NEW_SECRET", Value: "value"}
+	ops := []struct {
+		name string
+		call func(s *secretService, user *model.User) (bool, error)
+	}{
+		{name: "ListByProject", call: func(s *secretService, user *model.User) (bool, error) {
+			got, err := s.ListByProject(3, user)
+			return got != nil, err
+		}},
+		{name: "ListByPipeline", call: func(s *secretService, user *model.User) (bool, error) {
+			got, err := s.ListByPipeline(2, user)
+			return got != nil, err
+		}},
+		{name: "CreateForProject", call: func(s *secretService, user *model.User) (bool, error) {
+			got, err := s.CreateForProject(3, req, user)
+			return got != nil, err
+		}},
+		{name: "CreateForPipeline", call: func(s *secretService, user *model.User) (bool, error) {
+			got, err := s.CreateForPipeline(2, req, user)
+			return got != nil, err
+		}},
+		{name: "Update project secret", call: func(s *secretService, user *model.User) (bool, error) {
+			got, err := s.Update(5, &model.UpdateSecretRequest{Value: "new"}, user)
+			return got != nil, err
+		}},
+		{name: "Update pipeline secret", call: func(s *secretService, user *model.User) (bool, error) {
+			got, err := s.Update(6, &model.UpdateSecretRequest{Value: "new"}, user)
+			return got != nil, err
+		}},
+		{name: "Delete", call: func(s *secretService, user *model.User) (bool, error) {
+			got, err := s.Delete(6, user)
+			return got != nil, err
+		}},
+	}
+
+	for _, op := range ops {
+		for _, u := range users {
+			t.Run(op.name+"/"+u.name, func(t *testing.T) {
+				s, repo := newService()
+				got, err := op.call(s, u.user)
+				if err != nil {
+					t.Fatalf("%s() error = %v", op.name, err)
+				}
+				if got != u.want {
+					t.Errorf("%s() allowed = %v, want %v", op.name, got, u.want)
+				}
+				// 无权访问时与项目不存在的结果相同，不修改任何密钥
+				if !u.want && repo.writes != 0 {
+					t.Errorf("%s() wrote %d secrets for a user without access", op.name, repo.writes)
+				}
+			})
+		}
+	}
+}
@@ -1,8 +1,17 @@
 package service
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/engine"
 	"Vortexia/internal/model"
 	"Vortexia/internal/repository"
+	"Vortexia/internal/secrets"
+	"Vortexia/internal/storage"
 )
 
 // Services 包含所有服务接口
@@ -12,16 +21,31 @@ type Services struct {
 	Project  ProjectService
 	Pipeline PipelineService
 	Build    BuildService
+	Runner   RunnerService
+	Secret   SecretService
+	Artifact ArtifactService
+	Cache    CacheService
+	Webhook  WebhookService
 }
 
 // NewServices 创建服务集合
-func NewServices(repos *repository.Repositories) *Services {
+func NewServices(repos *repository.Repositories, cfg *config.Config, keyring *secrets.Keyring, artifacts *storage.ArtifactStore, caches *storage.CacheStore) *Services {
+	// 服务端执行构建与为远程执行器准备构建共用同一个引擎
+	eng := engine.New(repos, cfg, keyring, artifacts, caches)
+
+	builds := NewBuildService(repos, eng)
+
 	return &Services{
-		Auth:     NewAuthService(repos.User),
+		Auth:     NewAuthService(repos.User, repos.Tickets),
 		User:     NewUserService(repos.User),
 		Project:  NewProjectService(repos.Project),
 		Pipeline: NewPipelineService(repos.Pipeline),
-		Build:    NewBuildService(repos.Build, repos.Pipeline),
+		Build:    builds,
+		Runner:   NewRunnerService(repos, eng, cfg, artifacts, caches),
+		Secret:   NewSecretService(repos, keyring),
+		Artifact: NewArtifactService(repos, artifacts),
+		Cache:    NewCacheService(repos, caches),
+		Webhook:  NewWebhookService(repos, builds, keyring),
 	}
 }
 
@@ -30,6 +54,8 @@ type AuthService interface {
 	Login(username, password string) (*model.LoginResponse, error)
 	ValidateToken(token string) (*model.User, error)
 	GenerateToken(user *model.User) (string, error)
+	IssueStreamTicket(user *model.User) (*model.StreamTicketResponse, error)
+	RedeemStreamTicket(ticket string) (*model.User, error)
 }
 
 // UserService 用户服务接口
@@ -65,13 +91,88 @@ type PipelineService interface {
 // BuildService 构建服务接口
 type BuildService interface {
 	Create(req *model.TriggerBuildRequest, triggerBy int) (*model.Build, error)
+	Enqueue(build *model.Build) error
 	GetByID(id int) (*model.Build, error)
 	GetByPipeline(pipelineID int, page, pageSize int) (*model.PaginationResponse, error)
 	UpdateStatus(id int, status string) error
+	Cancel(ctx context.Context, id int, canceledBy int) (*model.Build, error)
+	Rerun(id int, mode string, triggerBy int) (*model.Build, error)
+	AbortBuild(id int) bool
 	List(page, pageSize int) (*model.PaginationResponse, error)
 
 	// 构建步骤相关
 	GetSteps(buildID int) ([]*model.BuildStep, error)
-	UpdateStepStatus(stepID int, status string, output string) error
-	ExecuteBuild(buildID int) error
+	GetGraph(buildID int) (*model.BuildGraph, error)
+	UpdateStepStatus(stepID int, status string) error
+	GetStepLogBytes(buildID, stepID, attempt int, offset int64, limit int) (*model.StepLog, error)
+	GetStepLogLines(buildID, stepID, attempt int, from, count int) (*model.StepLog, error)
+	ClaimBuild(ctx context.Context, labels []string, visibility time.Duration) (*repository.QueueItem, error)
+	ExecuteBuild(ctx context.Context, buildID int) error
+	RequeueLost(ctx context.Context) (int, error)
+
+	// 构建日志相关
+	WatchLogs(ctx context.Context, buildID int, afterSeq int64) (<-chan *model.LogEvent, error)
+}
+
+// RunnerService 远程执行器服务接口
+type RunnerService interface {
+	// 执行器管理相关
+	List(page, pageSize int) (*model.PaginationResponse, error)
+	SetPaused(id int, paused bool) (*model.Runner, error)
+	Revoke(id int) (*model.Runner, error)
+
+	// 执行器API相关，除注册外都需要执行器令牌
+	Register(req *model.RegisterRunnerRequest) (*model.RegisterRunnerResponse, error)
+	Authenticate(token string) (*model.Runner, error)
+	Heartbeat(ctx context.Context, runner *model.Runner, req *model.RunnerHeartbeatRequest) (*model.RunnerHeartbeatResponse, error)
+	RequestJob(ctx context.Context, runner *model.Runner) (*model.RunnerJob, error)
+	GetJobStatus(runner *model.Runner, buildID int) (string, error)
+	UpdateCommit(runner *model.Runner, buildID int, commit string) error
+	FinishJob(ctx context.Context, runner *model.Runner, buildID int, status string) error
+	PublishEvents(ctx context.Context, runner *model.Runner, events []*model.LogEvent) error
+	UpdateBuildJob(runner *model.Runner, jobID int, status string) error
+	UpdateStep(runner *model.Runner, stepID int, update *model.RunnerStepUpdate) error
+	AppendStepLog(runner *model.Runner, stepID int, data []byte) error
+	CreateStepAttempt(runner *model.Runner, attempt *model.BuildStepAttempt) error
+	FinishStepAttempt(runner *model.Runner, attempt *model.BuildStepAttempt) error
+	UploadArtifact(ctx context.Context, runner *model.Runner, artifact *model.Artifact, content io.Reader, size int64) error
+	DependencyArtifacts(ctx context.Context, runner *model.Runner, jobID int, upstream string) ([]*model.Artifact, error)
+	OpenDependencyArtifact(ctx context.Context, runner *model.Runner, jobID, artifactID int) (*model.Artifact, io.ReadCloser, error)
+	FindCache(runner *model.Runner, jobID int, query *model.RunnerCacheQuery) (*model.Cache, error)
+	OpenCache(ctx context.Context, runner *model.Runner, jobID, cacheID int) (*model.Cache, io.ReadCloser, error)
+	SaveCache(ctx context.Context, runner *model.Runner, jobID int, cache *model.Cache, content io.Reader, size int64) error
+}
+
+// SecretService 密钥服务接口，密钥的值只能写入，接口不返回
+type SecretService interface {
+	ListByProject(projectID int, user *model.User) ([]*model.Secret, error)
+	ListByPipeline(pipelineID int, user *model.User) ([]*model.Secret, error)
+	CreateForProject(projectID int, req *model.CreateSecretRequest, user *model.User) (*model.Secret, error)
+	CreateForPipeline(pipelineID int, req *model.CreateSecretRequest, user *model.User) (*model.Secret, error)
+	Update(id int, req *model.UpdateSecretRequest, user *model.User) (*model.Secret, error)
+	Delete(id int, user *model.User) (*model.Secret, error)
+	RotateKeys() (int, error)
+}
+
+// ArtifactService 构建制品服务接口
+type ArtifactService interface {
+	ListByBuild(buildID int, user *model.User) ([]*model.Artifact, error)
+	Open(ctx context.Context, buildID, artifactID int, user *model.User) (*model.Artifact, io.ReadCloser, error)
+	DeleteExpired(ctx context.Context) (int, error)
+}
+
+// CacheService 项目依赖缓存服务接口
+type CacheService interface {
+	ListByProject(projectID int, user *model.User) ([]*model.Cache, error)
+	Delete(ctx context.Context, projectID, cacheID int, user *model.User) (*model.Cache, error)
+	Purge(ctx context.Context, projectID int, user *model.User) ([]*model.Cache, error)
+}
+
+// WebhookService 项目 webhook 服务接口，代码托管平台推送分支或标签时按流水线的触发规则创建构建
+type WebhookService interface {
+	Get(projectID int, user *model.User) (*model.ProjectWebhook, error)
+	Rotate(projectID int, user *model.User) (*model.RotateWebhookResponse, error)
+	Delete(projectID int, user *model.User) (*model.ProjectWebhook, error)
+	RotateKeys() (int, error)
+	Receive(provider string, projectID int, header http.Header, body []byte) (*model.WebhookResult, error)
 }
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"Vortexia/internal/model"
+	"Vortexia/internal/pipeline"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/secrets"
+	"Vortexia/internal/webhook"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// webhookReplayWindow 重复投递的判断时长，此时长内相同的投递只处理一次，用于忽略代码托管平台的重试和重放的请求。
+// 超过后手动重新投递（如 GitHub 的 Redeliver、GitLab 的 Test）会再次触发构建
+const webhookReplayWindow = 10 * time.Minute
+
+// maxDeliveryIDLength 记录的投递ID的最大长度，超出部分截断
+const maxDeliveryIDLength = 90
+
+type webhookService struct {
+	webhookRepo  repository.WebhookRepository
+	projectRepo  repository.ProjectRepository
+	pipelineRepo repository.PipelineRepository
+	builds       BuildService
+	keyring      *secrets.Keyring
+}
+
+// NewWebhookService 创建 webhook 服务实例
+func NewWebhookService(repos *repository.Repositories, builds BuildService, keyring *secrets.Keyring) WebhookService {
+	return &webhookService{
+		webhookRepo:  repos.Webhook,
+		projectRepo:  repos.Project,
+		pipelineRepo: repos.Pipeline,
+		builds:       builds,
+		keyring:      keyring,
+	}
+}
+
+// Get 获取项目的 webhook，不包含密钥。项目不存在、用户不是项目的所有者或管理员、
+// 未生成 webhook 密钥时返回 nil
+func (s *webhookService) Get(projectID int, user *model.User) (*model.ProjectWebhook, error) {
+	project, err := s.projectRepo.GetByID(projectID)
+	if err != nil || !canAccessProject(project, user) {
+		return nil, err
+	}
+
+	hook, err := s.webhookRepo.GetByProject(projectID)
+	if err != nil || hook == nil {
+		return nil, err
+	}
+	hook.URLs = webhookURLs(projectID)
+	return hook, nil
+}
+
+// Rotate 为项目生成新的 webhook 密钥，替换已有的密钥，项目不存在或用户不是项目的所有者或管理员时返回 nil
+func (s *webhookService) Rotate(projectID int, user *model.User) (*model.RotateWebhookResponse, error) {
+	project, err := s.projectRepo.GetByID(projectID)
+	if err != nil || !canAccessProject(project, user) {
+		return nil, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	hook := &model.ProjectWebhook{ProjectID: projectID, CreatedBy: user.ID}
+	if err := s.keyring.SealWebhook(hook, secret); err != nil {
+		if errors.Is(err, secrets.ErrNoMasterKey) {
+			return nil, errors.New("服务端未配置密钥加密主密钥，无法保存 webhook 密钥")
+		}
+		return nil, err
+	}
+	if err := s.webhookRepo.Save(hook); err != nil {
+		return nil, err
+	}
+
+	hook.URLs = webhookURLs(projectID)
+	return &model.RotateWebhookResponse{Webhook: hook, Secret: secret}, nil
+}
+
+// Delete 删除项目的 webhook，之后的推送事件不再触发构建。项目不存在、用户不是项目的所有者或管理员、
+// 未生成 webhook 密钥时返回 nil
+func (s *webhookService) Delete(projectID int, user *model.User) (*model.ProjectWebhook, error) {
+	project, err := s.projectRepo.GetByID(projectID)
+	if err != nil || !canAccessProject(project, user) {
+		return nil, err
+	}
+
+	hook, err := s.webhookRepo.GetByProject(projectID)
+	if err != nil || hook == nil {
+		return nil, err
+	}
+	if err := s.webhookRepo.Delete(projectID); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// RotateKeys 用当前主密钥重新加密其他主密钥加密的 webhook 密钥，返回重新加密的数量。
+// 加密所用主密钥已不在配置中的密钥无法解密，保持原样并记录日志
+func (s *webhookService) RotateKeys() (int, error) {
+	primary := s.keyring.Primary()
+	if primary == "" {
+		return 0, nil
+	}
+
+	stale, err := s.webhookRepo.GetNotEncryptedWith(primary)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, hook := range stale {
+		secret, err := s.keyring.OpenWebhook(hook)
+		if err != nil {
+			logger.Warn("Cannot rotate webhook secret", zap.Int("project_id", hook.ProjectID), zap.Error(err))
+			continue
+		}
+		if err := s.keyring.SealWebhook(hook, secret); err != nil {
+			return rotated, err
+		}
+		if err := s.webhookRepo.UpdateSecret(hook); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// Receive 校验并处理代码托管平台的推送事件，为触发规则与推送的分支或标签匹配的流水线创建构建。
+// webhookReplayWindow 内重复的投递（投递ID或请求体相同）被忽略，不会重复创建构建。
+// 创建构建失败时，只有尚未创建任何构建的投递可以被重新投递处理。
+// 项目不存在、已停用或未生成 webhook 密钥时返回 nil；平台不支持时返回 webhook.ErrUnknownProvider，
+// 签名无效时返回 webhook.ErrInvalidSignature，请求体格式错误时返回 webhook.ErrInvalidPayload
+func (s *webhookService) Receive(provider string, projectID int, header http.Header, body []byte) (*model.WebhookResult, error) {
+	if !isWebhookProvider(provider) {
+		return nil, webhook.ErrUnknownProvider
+	}
+
+	project, err := s.projectRepo.GetByID(projectID)
+	if err != nil || project == nil || !project.IsActive {
+		return nil, err
+	}
+	hook, err := s.webhookRepo.GetByProject(projectID)
+	if err != nil || hook == nil {
+		return nil, err
+	}
+
+	secret, err := s.keyring.OpenWebhook(hook)
+	if err != nil {
+		return nil, err
+	}
+	if err := webhook.Verify(provider, header, body, secret); err != nil {
+		return nil, err
+	}
+
+	event, err := webhook.Parse(provider, header, body)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.WebhookResult{
+		Event:   event.Type,
+		Branch:  event.Branch,
+		Tag:     event.Tag,
+		Commit:  event.Commit,
+		Ignored: event.Ignored,
+		Builds:  []*model.Build{},
+	}
+	if event.Type == "" {
+		return result, nil
+	}
+
+	keys := deliveryKeys(provider, header, body)
+	fresh, err := s.recordDelivery(projectID, provider, keys)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		result.Ignored = fmt.Sprintf("重复的投递，%d分钟内已处理过相同的事件", int(webhookReplayWindow.Minutes()))
+		return result, nil
+	}
+
+	if err := s.trigger(project, event, result); err != nil {
+		// 已创建的构建不会撤销，保留投递记录，避免重新投递时再次为这些流水线创建构建
+		if len(result.Builds) == 0 {
+			s.forgetDelivery(projectID, provider, keys)
+		} else {
+			logger.Warn("Webhook triggered builds partially",
+				zap.Int("project_id", projectID),
+				zap.String("provider", provider),
+				zap.Int("builds", len(result.Builds)),
+				zap.Error(err))
+		}
+		return nil, err
+	}
+
+	if len(result.Builds) > 0 {
+		logger.Info("Webhook triggered builds",
+			zap.Int("project_id", projectID),
+			zap.String("provider", provider),
+			zap.String("event", event.Type),
+			zap.String("commit", event.Commit),
+			zap.Int("builds", len(result.Builds)))
+	}
+	return result, nil
+}
+
+// trigger 为触发规则匹配的流水线创建构建，写入 result
+func (s *webhookService) trigger(project *model.Project, event *webhook.Event, result *model.WebhookResult) error {
+	pipelines, err := s.pipelineRepo.GetByProject(project.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range pipelines {
+		if !p.IsActive {
+			continue
+		}
+
+		params, reason := matchPipeline(p, event, project.Branch)
+		if reason != "" {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("流水线 %s：%s", p.Name, reason))
+			continue
+		}
+
+		build := &model.Build{
+			PipelineID: p.ID,
+			Branch:     event.Branch,
+			Tag:        event.Tag,
+			Commit:     event.Commit,
+			Event:      event.Type,
+			Status:     model.BuildStatusPending,
+			StartedAt:  time.Now(),
+			TriggerBy:  project.OwnerID,
+			Config:     p.Config,
+			Parameters: params,
+		}
+		if err := s.builds.Enqueue(build); err != nil {
+			return err
+		}
+		result.Builds = append(result.Builds, build)
+	}
+	return nil
+}
+
+// recordDelivery 记录投递的全部去重键，任一键在 webhookReplayWindow 内已记录过时撤销本次记录并返回 false。
+// 顺便删除超过判断时长的记录
+func (s *webhookService) recordDelivery(projectID int, provider string, keys []string) (bool, error) {
+	since := time.Now().Add(-webhookReplayWindow)
+	if err := s.webhookRepo.DeleteDeliveriesBefore(since); err != nil {
+		return false, err
+	}
+
+	for i, key := range keys {
+		fresh, err := s.webhookRepo.RecordDelivery(projectID, provider, key, since)
+		if err != nil {
+			s.forgetDelivery(projectID, provider, keys[:i])
+			return false, err
+		}
+		if !fresh {
+			s.forgetDelivery(projectID, provider, keys[:i])
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// forgetDelivery 删除投递的去重键，处理失败后代码托管平台重新投递时可以再次处理
+func (s *webhookService) forgetDelivery(projectID int, provider string, keys []string) {
+	for _, key := range keys {
+		if err := s.webhookRepo.ForgetDelivery(projectID, provider, key); err != nil {
+			logger.Warn("Failed to forget webhook delivery", zap.Int("project_id", projectID), zap.Error(err))
+		}
+	}
+}
+
+// deliveryKeys 返回投递的去重键：平台生成的投递ID和请求体的SHA-256。
+// 投递ID不在签名范围内，重放时可以被修改，因此同时按签名覆盖的请求体去重
+func deliveryKeys(provider string, header http.Header, body []byte) []string {
+	sum := sha256.Sum256(body)
+	keys := []string{"sha256:" + hex.EncodeToString(sum[:])}
+	if id := webhook.DeliveryID(provider, header); id != "" {
+		if len(id) > maxDeliveryIDLength {
+			id = id[:maxDeliveryIDLength]
+		}
+		keys = append(keys, "id:"+id)
+	}
+	return keys
+}
+
+// matchPipeline 按流水线的触发规则匹配推送事件，返回构建使用的参数默认值；
+// 不触发构建时返回原因
+func matchPipeline(p *model.Pipeline, event *webhook.Event, defaultBranch string) ([]string, string) {
+	def, err := pipeline.Parse(p.Config)
+	if err != nil {
+		return nil, "流水线配置无效"
+	}
+
+	switch event.Type {
+	case model.BuildEventPush:
+		if !def.Triggers.MatchBranch(event.Branch, defaultBranch) {
+			return nil, fmt.Sprintf("分支 %s 不满足触发规则", event.Branch)
+		}
+	case model.BuildEventTag:
+		if !def.Triggers.MatchTag(event.Tag) {
+			return nil, fmt.Sprintf("标签 %s 不满足触发规则", event.Tag)
+		}
+	}
+
+	params, err := def.ResolveParameters(nil)
+	if err != nil {
+		return nil, "推送触发的构建只能使用参数的默认值，" + err.Error()
+	}
+	return params, ""
+}
+
+// webhookURLs 返回各代码托管平台填写的 webhook 地址路径
+func webhookURLs(projectID int) map[string]string {
+	urls := make(map[string]string, len(webhook.Providers))
+	for _, provider := range webhook.Providers {
+		urls[provider] = fmt.Sprintf("/api/v1/hooks/%s/%d", provider, projectID)
+	}
+	return urls
+}
+
+func isWebhookProvider(provider string) bool {
+	for _, p := range webhook.Providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// generateWebhookSecret 生成 webhook 密钥
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
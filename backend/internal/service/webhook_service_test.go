@@ -0,0 +1,311 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/secrets"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// fakeWebhookRepo 内存中的 webhook 仓库，投递记录按 项目/平台/去重键 保存接收时间
+type fakeWebhookRepo struct {
+	repository.WebhookRepository
+	hook       *model.ProjectWebhook
+	deliveries map[string]time.Time
+}
+
+func (r *fakeWebhookRepo) GetByProject(projectID int) (*model.ProjectWebhook, error) {
+	if r.hook == nil || r.hook.ProjectID != projectID {
+		return nil, nil
+	}
+	return r.hook, nil
+}
+
+func (r *fakeWebhookRepo) Save(webhook *model.ProjectWebhook) error {
+	r.hook = webhook
+	return nil
+}
+
+func (r *fakeWebhookRepo) Delete(projectID int) error {
+	if r.hook != nil && r.hook.ProjectID == projectID {
+		r.hook = nil
+	}
+	return nil
+}
+
+func (r *fakeWebhookRepo) RecordDelivery(projectID int, provider, deliveryID string, since time.Time) (bool, error) {
+	key := fmt.Sprintf("%d/%s/%s", projectID, provider, deliveryID)
+	if at, ok := r.deliveries[key]; ok && !at.Before(since) {
+		return false, nil
+	}
+	r.deliveries[key] = time.Now()
+	return true, nil
+}
+
+func (r *fakeWebhookRepo) ForgetDelivery(projectID int, provider, deliveryID string) error {
+	delete(r.deliveries, fmt.Sprintf("%d/%s/%s", projectID, provider, deliveryID))
+	return nil
+}
+
+func (r *fakeWebhookRepo) DeleteDeliveriesBefore(t time.Time) error {
+	for key, at := range r.deliveries {
+		if at.Before(t) {
+			delete(r.deliveries, key)
+		}
+	}
+	return nil
+}
+
+// age 将全部投递记录提前 d，模拟经过了这段时间
+func (r *fakeWebhookRepo) age(d time.Duration) {
+	for key, at := range r.deliveries {
+		r.deliveries[key] = at.Add(-d)
+	}
+}
+
+func (r *fakePipelineRepo) GetByProject(projectID int) ([]*model.Pipeline, error) {
+	var result []*model.Pipeline
+	for _, p := range r.pipelines {
+		if p.ProjectID == projectID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// fakeBuildService 只实现 Enqueue 的构建服务
+type fakeBuildService struct {
+	BuildService
+	enqueued []*model.Build
+	err      error // 不为空时，已入队的构建达到 limit 后入队返回该错误
+	limit    int
+}
+
+func (s *fakeBuildService) Enqueue(build *model.Build) error {
+	if s.err != nil && len(s.enqueued) >= s.limit {
+		return s.err
+	}
+	build.ID = len(s.enqueued) + 1
+	s.enqueued = append(s.enqueued, build)
+	return nil
+}
+
+func TestWebhookReceiveReplayWindow(t *testing.T) {
+	const secret = "0123456789abcdef"
+	keyring, err := secrets.NewKeyring([]config.MasterKey{{ID: "k1", Key: make([]byte, 32)}})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	hook := &model.ProjectWebhook{ProjectID: 3}
+	if err := keyring.SealWebhook(hook, secret); err != nil {
+		t.Fatalf("SealWebhook() error = %v", err)
+	}
+
+	repo := &fakeWebhookRepo{hook: hook, deliveries: make(map[string]time.Time)}
+	builds := &fakeBuildService{}
+	s := &webhookService{
+		webhookRepo: repo,
+		projectRepo: &fakeProjectRepo{projects: map[int]*model.Project{
+			3: {ID: 3, OwnerID: 10, Branch: "main", IsActive: true},
+		}},
+		pipelineRepo: &fakePipelineRepo{pipelines: map[int]*model.Pipeline{
+			2: {ID: 2, ProjectID: 3, Name: "ci", IsActive: true, Config: "name: ci\nimage: alpine\nstages:\n  - name: test\n    steps:\n      - name: unit\n        run: true\n"},
+		}},
+		builds:  builds,
+		keyring: keyring,
+	}
+
+	body := []byte(`{"ref":"refs/heads/main","after":"1481a2de7b2a7d02428ad93446ab166be7793fbb"}`)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	header := http.Header{
+		"X-Github-Event":      {"push"},
+		"X-Github-Delivery":   {"72d3162e-cc78-11e3-81ab-4c9367dc0958"},
+		"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(mac.Sum(nil))},
+	}
+	// 其他投递ID、相同请求体的投递，如手动重新发送的测试事件
+	other := header.Clone()
+	other.Set("X-Github-Delivery", "9b0c4a2e-cc78-11e3-81ab-4c9367dc0958")
+
+	steps := []struct {
+		name       string
+		age        time.Duration
+		header     http.Header
+		wantBuilds int
+	}{
+		{name: "first delivery", header: header, wantBuilds: 1},
+		{name: "retry within the window", age: time.Minute, header: header},
+		{name: "same body within the window", header: other},
+		{name: "redelivery after the window", age: webhookReplayWindow, header: header, wantBuilds: 1},
+		{name: "same body after the window", age: webhookReplayWindow + time.Second, header: other, wantBuilds: 1},
+	}
+
+	for _, step := range steps {
+		repo.age(step.age)
+		result, err := s.Receive("github", 3, step.header, body)
+		if err != nil {
+			t.Fatalf("%s: Receive() error = %v", step.name, err)
+		}
+		if len(result.Builds) != step.wantBuilds {
+			t.Errorf("%s: created %d builds, want %d (ignored: %q)", step.name, len(result.Builds), step.wantBuilds, result.Ignored)
+		}
+		if step.wantBuilds == 0 && result.Ignored == "" {
+			t.Errorf("%s: duplicate delivery not reported as ignored", step.name)
+		}
+	}
+	if len(builds.enqueued) != 3 {
+		t.Errorf("enqueued %d builds, want 3", len(builds.enqueued))
+	}
+}
+
+func TestWebhookAccess(t *testing.T) {
+	keyring, err := secrets.NewKeyring([]config.MasterKey{{ID: "k1", Key: make([]byte, 32)}})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	projects := &fakeProjectRepo{projects: map[int]*model.Project{3: {ID: 3, OwnerID: 10, IsActive: true}}}
+
+	tests := []struct {
+		name string
+		user *model.User
+		want bool
+	}{
+		{name: "owner", user: &model.User{ID: 10, Role: model.RoleUser}, want: true},
+		{name: "admin", user: &model.User{ID: 1, Role: model.RoleAdmin}, want: true},
+		{name: "other user", user: &model.User{ID: 11, Role: model.RoleUser}},
+		{name: "no user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 其他用户的请求与项目不存在的结果相同，密钥不被读取、替换或删除
+			existing := &model.ProjectWebhook{ProjectID: 3, CreatedBy: 10}
+			if err := keyring.SealWebhook(existing, "original secret"); err != nil {
+				t.Fatalf("SealWebhook() error = %v", err)
+			}
+			repo := &fakeWebhookRepo{hook: existing}
+			s := &webhookService{webhookRepo: repo, projectRepo: projects, keyring: keyring}
+
+			hook, err := s.Get(3, tt.user)
+			if err != nil || (hook != nil) != tt.want {
+				t.Fatalf("Get() = %v, %v, want allowed %v", hook, err, tt.want)
+			}
+			if hook != nil {
+				data, _ := json.Marshal(hook)
+				if strings.Contains(string(data), "secret") || strings.Contains(string(data), "key_id") {
+					t.Errorf("Get() response %s exposes the secret", data)
+				}
+			}
+
+			resp, err := s.Rotate(3, tt.user)
+			if err != nil || (resp != nil) != tt.want {
+				t.Fatalf("Rotate() = %v, %v, want allowed %v", resp, err, tt.want)
+			}
+			if resp != nil && repo.hook.CreatedBy != tt.user.ID {
+				t.Errorf("rotated webhook created_by = %d, want %d", repo.hook.CreatedBy, tt.user.ID)
+			}
+			if resp == nil && repo.hook != existing {
+				t.Errorf("Rotate() replaced the webhook without permission")
+			}
+
+			deleted, err := s.Delete(3, tt.user)
+			if err != nil || (deleted != nil) != tt.want {
+				t.Fatalf("Delete() = %v, %v, want allowed %v", deleted, err, tt.want)
+			}
+			if (repo.hook == nil) != tt.want {
+				t.Errorf("webhook deleted = %v, want %v", repo.hook == nil, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookReceiveEnqueueFailure(t *testing.T) {
+	const secret = "0123456789abcdef"
+	keyring, err := secrets.NewKeyring([]config.MasterKey{{ID: "k1", Key: make([]byte, 32)}})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	const pipelineConfig = "name: ci\nimage: alpine\nstages:\n  - name: test\n    steps:\n      - name: unit\n        run: true\n"
+	body := []byte(`{"ref":"refs/heads/main","after":"1481a2de7b2a7d02428ad93446ab166be7793fbb"}`)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	header := http.Header{
+		"X-Github-Event":      {"push"},
+		"X-Github-Delivery":   {"72d3162e-cc78-11e3-81ab-4c9367dc0958"},
+		"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(mac.Sum(nil))},
+	}
+
+	tests := []struct {
+		name        string
+		limit       int // 第一次投递时可以入队的构建数量
+		wantRetried int // 重新投递创建的构建数量
+	}{
+		// 没有创建构建，重新投递时再次处理
+		{name: "nothing enqueued", limit: 0, wantRetried: 2},
+		// 已创建的构建不会被重新投递再次创建
+		{name: "partially enqueued", limit: 1, wantRetried: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &model.ProjectWebhook{ProjectID: 3}
+			if err := keyring.SealWebhook(hook, secret); err != nil {
+				t.Fatalf("SealWebhook() error = %v", err)
+			}
+			builds := &fakeBuildService{err: errors.New("queue unavailable"), limit: tt.limit}
+			s := &webhookService{
+				webhookRepo: &fakeWebhookRepo{hook: hook, deliveries: make(map[string]time.Time)},
+				projectRepo: &fakeProjectRepo{projects: map[int]*model.Project{
+					3: {ID: 3, OwnerID: 10, Branch: "main", IsActive: true},
+				}},
+				pipelineRepo: &fakePipelineRepo{pipelines: map[int]*model.Pipeline{
+					1: {ID: 1, ProjectID: 3, Name: "ci", IsActive: true, Config: pipelineConfig},
+					2: {ID: 2, ProjectID: 3, Name: "lint", IsActive: true, Config: pipelineConfig},
+				}},
+				builds:  builds,
+				keyring: keyring,
+			}
+
+			if _, err := s.Receive("github", 3, header, body); err == nil {
+				t.Fatal("Receive() error = nil, want the enqueue error")
+			}
+			created := len(builds.enqueued)
+
+			// 代码托管平台重新投递时队列已恢复
+			builds.err = nil
+			result, err := s.Receive("github", 3, header, body)
+			if err != nil {
+				t.Fatalf("retry: Receive() error = %v", err)
+			}
+			if len(result.Builds) != tt.wantRetried {
+				t.Errorf("retry created %d builds, want %d (ignored: %q)", len(result.Builds), tt.wantRetried, result.Ignored)
+			}
+			if tt.wantRetried == 0 && result.Ignored == "" {
+				t.Error("retry not reported as ignored")
+			}
+			if got := len(builds.enqueued) - created; got != tt.wantRetried {
+				t.Errorf("enqueued %d builds on retry, want %d", got, tt.wantRetried)
+			}
+		})
+	}
+}
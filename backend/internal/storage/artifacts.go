@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// expiredBatch 每次删除过期制品时读取的记录数
+const expiredBatch = 100
+
+// defaultArtifactMode 未记录权限的制品文件的权限
+const defaultArtifactMode = 0o644
+
+// ArtifactStore 构建制品的存储，文件内容保存在对象存储中，记录保存在数据库中
+type ArtifactStore struct {
+	repo    repository.ArtifactRepository
+	storage Storage
+	cfg     config.ArtifactsConfig
+}
+
+// NewArtifactStore 创建制品存储
+func NewArtifactStore(repo repository.ArtifactRepository, storage Storage, cfg config.ArtifactsConfig) *ArtifactStore {
+	return &ArtifactStore{repo: repo, storage: storage, cfg: cfg}
+}
+
+// MaxSize 单个制品文件的大小上限
+func (s *ArtifactStore) MaxSize() int64 {
+	return int64(s.cfg.MaxSizeMB) * 1024 * 1024
+}
+
+// Save 保存制品文件并创建记录，写入制品的大小和SHA-256。
+// 未指定过期时间时按 ARTIFACTS_EXPIRE_DAYS 设置，未指定权限时为 0644
+func (s *ArtifactStore) Save(ctx context.Context, artifact *model.Artifact, r io.Reader, size int64) error {
+	if max := s.MaxSize(); max > 0 && size > max {
+		return fmt.Errorf("制品 %s 的大小 %d 字节超过上限 %d 字节", artifact.Path, size, max)
+	}
+	if artifact.Mode == 0 {
+		artifact.Mode = defaultArtifactMode
+	}
+	if artifact.ExpiresAt == nil && s.cfg.ExpireDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, s.cfg.ExpireDays)
+		artifact.ExpiresAt = &expiresAt
+	}
+
+	artifact.StorageKey = fmt.Sprintf("artifacts/%d/%d/%s", artifact.BuildID, artifact.JobID, artifact.Path)
+	hash := sha256.New()
+	if err := s.storage.Put(ctx, artifact.StorageKey, io.TeeReader(r, hash), size); err != nil {
+		return err
+	}
+	artifact.Size = size
+	artifact.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if err := s.repo.Create(artifact); err != nil {
+		s.deleteObject(artifact)
+		return err
+	}
+	return nil
+}
+
+// Get 根据ID获取制品
+func (s *ArtifactStore) Get(id int) (*model.Artifact, error) {
+	return s.repo.GetByID(id)
+}
+
+// ListByJob 获取作业的制品，按路径排序
+func (s *ArtifactStore) ListByJob(jobID int) ([]*model.Artifact, error) {
+	return s.repo.GetByJob(jobID)
+}
+
+// Open 读取制品文件
+func (s *ArtifactStore) Open(ctx context.Context, artifact *model.Artifact) (io.ReadCloser, error) {
+	return s.storage.Get(ctx, artifact.StorageKey)
+}
+
+// DeleteByBuild 删除构建的全部制品
+func (s *ArtifactStore) DeleteByBuild(ctx context.Context, buildID int) error {
+	artifacts, err := s.repo.GetByBuild(buildID)
+	if err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		if err := s.delete(ctx, artifact); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExpired 删除已过期的制品，返回删除的数量
+func (s *ArtifactStore) DeleteExpired(ctx context.Context) (int, error) {
+	deleted := 0
+	for {
+		artifacts, err := s.repo.GetExpired(time.Now(), expiredBatch)
+		if err != nil {
+			return deleted, err
+		}
+		for _, artifact := range artifacts {
+			if err := s.delete(ctx, artifact); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if len(artifacts) < expiredBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// delete 先删除文件再删除记录，删除文件失败时保留记录以便下次重试
+func (s *ArtifactStore) delete(ctx context.Context, artifact *model.Artifact) error {
+	if err := s.storage.Delete(ctx, artifact.StorageKey); err != nil {
+		return err
+	}
+	return s.repo.Delete(artifact.ID)
+}
+
+// deleteObject 删除未能创建记录的制品文件，失败时只记录日志
+func (s *ArtifactStore) deleteObject(artifact *model.Artifact) {
+	if err := s.storage.Delete(context.Background(), artifact.StorageKey); err != nil {
+		logger.Warn("Failed to delete orphaned artifact object",
+			zap.String("key", artifact.StorageKey),
+			zap.Error(err),
+		)
+	}
+}
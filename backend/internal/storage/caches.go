@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// CacheStore 项目依赖缓存的存储，压缩包保存在对象存储中，记录保存在数据库中。
+// 每个项目的缓存总大小超过 CACHE_MAX_SIZE_MB 时删除最久未使用的缓存
+type CacheStore struct {
+	repo    repository.CacheRepository
+	storage Storage
+	cfg     config.CacheConfig
+}
+
+// NewCacheStore 创建缓存存储
+func NewCacheStore(repo repository.CacheRepository, storage Storage, cfg config.CacheConfig) *CacheStore {
+	return &CacheStore{repo: repo, storage: storage, cfg: cfg}
+}
+
+// MaxSize 每个项目的缓存总大小上限，0表示不限制
+func (s *CacheStore) MaxSize() int64 {
+	return int64(s.cfg.MaxSizeMB) * 1024 * 1024
+}
+
+// Find 查找项目的缓存：先按 key 精确匹配，未命中时依次按 restoreKeys 前缀匹配最近保存的缓存。
+// 命中时更新缓存的最近使用时间，未命中时返回 nil
+func (s *CacheStore) Find(projectID int, key string, restoreKeys []string) (*model.Cache, error) {
+	cache, err := s.repo.GetByKey(projectID, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, prefix := range restoreKeys {
+		if cache != nil {
+			break
+		}
+		if cache, err = s.repo.GetLatestByPrefix(projectID, prefix); err != nil {
+			return nil, err
+		}
+	}
+	if cache == nil {
+		return nil, nil
+	}
+
+	if err := s.repo.Touch(cache.ID); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Open 读取缓存的压缩包。对象已不存在时删除缓存记录并返回 ErrNotFound
+func (s *CacheStore) Open(ctx context.Context, cache *model.Cache) (io.ReadCloser, error) {
+	r, err := s.storage.Get(ctx, cache.StorageKey)
+	if errors.Is(err, ErrNotFound) {
+		if err := s.repo.Delete(cache.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+// Save 保存缓存的压缩包并创建记录，写入缓存的大小和SHA-256，之后按大小上限淘汰最久未使用的缓存
+func (s *CacheStore) Save(ctx context.Context, cache *model.Cache, r io.Reader, size int64) error {
+	if max := s.MaxSize(); max > 0 && size > max {
+		return fmt.Errorf("缓存 %s 的大小 %d 字节超过项目的缓存上限 %d 字节", cache.Key, size, max)
+	}
+
+	// 缓存键可以包含任意字符，对象的键使用其摘要
+	sum := sha256.Sum256([]byte(cache.Key))
+	cache.StorageKey = fmt.Sprintf("caches/%d/%s.tar.gz", cache.ProjectID, hex.EncodeToString(sum[:]))
+	hash := sha256.New()
+	if err := s.storage.Put(ctx, cache.StorageKey, io.TeeReader(r, hash), size); err != nil {
+		return err
+	}
+	cache.Size = size
+	cache.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if err := s.repo.Save(cache); err != nil {
+		s.deleteObject(cache)
+		return err
+	}
+	return s.evict(ctx, cache.ProjectID)
+}
+
+// List 获取项目的缓存，按最近使用时间倒序
+func (s *CacheStore) List(projectID int) ([]*model.Cache, error) {
+	return s.repo.GetByProject(projectID)
+}
+
+// Get 根据ID获取项目的缓存，缓存不存在或不属于该项目时返回 nil
+func (s *CacheStore) Get(projectID, id int) (*model.Cache, error) {
+	return s.repo.GetInProject(projectID, id)
+}
+
+// Delete 删除缓存
+func (s *CacheStore) Delete(ctx context.Context, cache *model.Cache) error {
+	if err := s.storage.Delete(ctx, cache.StorageKey); err != nil {
+		return err
+	}
+	return s.repo.Delete(cache.ID)
+}
+
+// Purge 删除项目的全部缓存，返回删除的数量
+func (s *CacheStore) Purge(ctx context.Context, projectID int) (int, error) {
+	caches, err := s.repo.GetByProject(projectID)
+	if err != nil {
+		return 0, err
+	}
+	for i, cache := range caches {
+		if err := s.Delete(ctx, cache); err != nil {
+			return i, err
+		}
+	}
+	return len(caches), nil
+}
+
+// evict 项目的缓存总大小超过上限时删除最久未使用的缓存
+func (s *CacheStore) evict(ctx context.Context, projectID int) error {
+	max := s.MaxSize()
+	if max <= 0 {
+		return nil
+	}
+
+	caches, err := s.repo.GetByProject(projectID)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, cache := range caches {
+		total += cache.Size
+	}
+	// caches 按最近使用时间倒序，从最久未使用的开始删除
+	for i := len(caches) - 1; i >= 0 && total > max; i-- {
+		cache := caches[i]
+		if err := s.Delete(ctx, cache); err != nil {
+			return err
+		}
+		total -= cache.Size
+		logger.Info("Evicted least recently used cache",
+			zap.Int("project_id", projectID),
+			zap.String("key", cache.Key),
+			zap.Int64("size", cache.Size),
+		)
+	}
+	return nil
+}
+
+// deleteObject 删除未能创建记录的压缩包，失败时只记录日志
+func (s *CacheStore) deleteObject(cache *model.Cache) {
+	if err := s.storage.Delete(context.Background(), cache.StorageKey); err != nil {
+		logger.Warn("Failed to delete orphaned cache object",
+			zap.String("key", cache.StorageKey),
+			zap.Error(err),
+		)
+	}
+}
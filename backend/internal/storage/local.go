@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localStorage 将对象保存为本机目录中的文件，多个服务端进程需共享同一目录
+type localStorage struct {
+	root string
+}
+
+// NewLocal 创建保存在 root 目录中的对象存储
+func NewLocal(root string) (Storage, error) {
+	if root == "" {
+		return nil, errors.New("local storage root is not configured")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage root: %w", err)
+	}
+	return &localStorage{root: root}, nil
+}
+
+// Put 先写入临时文件再重命名，读取方不会读到写了一半的对象
+func (s *localStorage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create object file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, &contextReader{ctx: ctx, r: io.LimitReader(r, size)})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if n != size {
+		return fmt.Errorf("failed to write object: got %d of %d bytes", n, size)
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("failed to save object: %w", err)
+	}
+	return nil
+}
+
+// Get 读取对象
+func (s *localStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	return f, nil
+}
+
+// Delete 删除对象
+func (s *localStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// file 返回对象的文件路径，拒绝指向根目录之外的键
+func (s *localStorage) file(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+// contextReader 在 ctx 结束后停止读取，用于中断大文件的写入
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocal(root)
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	ctx := context.Background()
+	key := "artifacts/1/2/dist/app.txt"
+
+	if err := s.Put(ctx, key, strings.NewReader("hello world"), 5); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "artifacts", "1", "2", "dist", "app.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("object file = %q, %v, want %q", data, err, "hello")
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	body, _ := io.ReadAll(r)
+	r.Close()
+	if string(body) != "hello" {
+		t.Errorf("Get() = %q, want %q", body, "hello")
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete() of a missing object error = %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalShortWrite(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocal(root)
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+
+	err = s.Put(context.Background(), "short", strings.NewReader("abc"), 10)
+	if err == nil || !strings.Contains(err.Error(), "got 3 of 10 bytes") {
+		t.Errorf("Put() error = %v, want a short write error", err)
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 0 {
+		t.Errorf("root contains %d entries after a failed write, want none", len(entries))
+	}
+}
+
+func TestLocalRejectsTraversal(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "objects")
+	s, err := NewLocal(root)
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	ctx := context.Background()
+
+	keys := []string{
+		"",
+		"../escape",
+		"artifacts/../../escape",
+		"/etc/passwd",
+		"artifacts/./x",
+		"artifacts//x",
+		"artifacts/x/",
+		`..\escape`,
+		"artifacts/..",
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			if err := s.Put(ctx, key, strings.NewReader("x"), 1); err == nil || !strings.Contains(err.Error(), "invalid object key") {
+				t.Errorf("Put(%q) error = %v, want invalid object key", key, err)
+			}
+			if _, err := s.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(%q) error = %v, want invalid object key", key, err)
+			}
+			if err := s.Delete(ctx, key); err == nil {
+				t.Errorf("Delete(%q) accepted the key", key)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(parent, "escape")); !os.IsNotExist(err) {
+		t.Errorf("object written outside the root: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"Vortexia/internal/config"
+)
+
+// fakeS3 内存中的S3兼容服务，以请求路径为对象的键，同时记录收到的请求
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	requests []*http.Request
+	failPut  bool
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte)}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/") {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	key := r.Host + r.URL.EscapedPath()
+	switch r.Method {
+	case http.MethodPut:
+		if f.failPut {
+			http.Error(w, "<Error><Code>InternalError</Code></Error>", http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+	case http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) last() *http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+// newTestS3 创建连接到 fake 的S3存储。虚拟主机形式的地址 bucket.host 无法解析，
+// 改为直接连接到测试服务
+func newTestS3(t *testing.T, f *fakeS3, pathStyle bool) *s3Storage {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	s, err := NewS3(config.S3Config{
+		Endpoint:  srv.URL + "/",
+		Bucket:    "ci-bucket",
+		AccessKey: "AK",
+		SecretKey: "SK",
+		PathStyle: pathStyle,
+	})
+	if err != nil {
+		t.Fatalf("NewS3() error = %v", err)
+	}
+	s3 := s.(*s3Storage)
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	s3.http = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	return s3
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		pathStyle bool
+		key       string
+		wantHost  string
+		wantPath  string
+	}{
+		{name: "path style", pathStyle: true, key: "artifacts/1/2/dist/app.tar.gz", wantPath: "/ci-bucket/artifacts/1/2/dist/app.tar.gz"},
+		{name: "virtual hosted", key: "artifacts/1/2/dist/app.tar.gz", wantHost: "ci-bucket.", wantPath: "/artifacts/1/2/dist/app.tar.gz"},
+		{name: "escaped key", pathStyle: true, key: "artifacts/1/2/my report+1.txt", wantPath: "/ci-bucket/artifacts/1/2/my%20report%2B1.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeS3()
+			s := newTestS3(t, f, tt.pathStyle)
+			ctx := context.Background()
+
+			if err := s.Put(ctx, tt.key, strings.NewReader("hello world"), 5); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			put := f.last()
+			if put.ContentLength != 5 {
+				t.Errorf("Content-Length = %d, want 5", put.ContentLength)
+			}
+			if got := put.Header.Get("X-Amz-Content-Sha256"); got != s3UnsignedPayload {
+				t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, s3UnsignedPayload)
+			}
+			auth := put.Header.Get("Authorization")
+			if !strings.Contains(auth, "/us-east-1/s3/aws4_request") ||
+				!strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") {
+				t.Errorf("Authorization = %q", auth)
+			}
+			if !strings.HasPrefix(put.Host, tt.wantHost) {
+				t.Errorf("Host = %q, want prefix %q", put.Host, tt.wantHost)
+			}
+			if got := put.URL.EscapedPath(); got != tt.wantPath {
+				t.Errorf("path = %q, want %q", got, tt.wantPath)
+			}
+
+			r, err := s.Get(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			body, _ := io.ReadAll(r)
+			r.Close()
+			if string(body) != "hello" {
+				t.Errorf("Get() = %q, want %q", body, "hello")
+			}
+
+			if err := s.Delete(ctx, tt.key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if f.last().Method != http.MethodDelete {
+				t.Errorf("last request = %s, want DELETE", f.last().Method)
+			}
+			if _, err := s.Get(ctx, tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3Errors(t *testing.T) {
+	f := newFakeS3()
+	s := newTestS3(t, f, true)
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "missing"); err != nil {
+		t.Errorf("Delete() of a missing object error = %v", err)
+	}
+
+	if err := s.Put(ctx, "empty", strings.NewReader(""), 0); err != nil {
+		t.Errorf("Put() of an empty object error = %v", err)
+	}
+	if got := f.last().TransferEncoding; len(got) != 0 {
+		t.Errorf("empty object sent with Transfer-Encoding %v", got)
+	}
+
+	f.failPut = true
+	err := s.Put(ctx, "fail", strings.NewReader("x"), 1)
+	if err == nil || !strings.Contains(err.Error(), "returned 500") || !strings.Contains(err.Error(), "InternalError") {
+		t.Errorf("Put() error = %v, want the 500 response", err)
+	}
+
+	s.cfg.AccessKey = "other"
+	if _, err := s.Get(ctx, "empty"); err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Get() with wrong credentials error = %v, want 403", err)
+	}
+}
+
+func TestNewS3Config(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.S3Config
+		wantErr string
+	}{
+		{name: "missing bucket", cfg: config.S3Config{Endpoint: "http://s3", AccessKey: "a", SecretKey: "s"}, wantErr: "endpoint and a bucket"},
+		{name: "missing credentials", cfg: config.S3Config{Endpoint: "http://s3", Bucket: "b"}, wantErr: "access key"},
+		{name: "invalid scheme", cfg: config.S3Config{Endpoint: "ftp://s3", Bucket: "b", AccessKey: "a", SecretKey: "s"}, wantErr: "invalid s3 endpoint"},
+		{name: "valid", cfg: config.S3Config{Endpoint: "https://s3.example.com", Bucket: "b", AccessKey: "a", SecretKey: "s"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewS3(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewS3() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewS3() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3EscapePath(t *testing.T) {
+	got := s3EscapePath("/a b/c+d/é~_.-")
+	if want := "/a%20b/c%2Bd/%C3%A9~_.-"; got != want {
+		t.Errorf("s3EscapePath() = %q, want %q", got, want)
+	}
+	if _, err := url.Parse("http://x" + got); err != nil {
+		t.Errorf("escaped path is not a valid URL: %v", err)
+	}
+}
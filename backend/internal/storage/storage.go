@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"Vortexia/internal/config"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("object not found")
+
+// Storage 对象存储接口，对象以 / 分隔的键标识
+type Storage interface {
+	// Put 写入对象，size 为内容的字节数，内容少于 size 时返回错误
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get 读取对象，对象不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+}
+
+// New 按配置创建对象存储
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Type {
+	case "", "local":
+		return NewLocal(cfg.LocalRoot)
+	case "s3":
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Type)
+	}
+}
@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"Vortexia/internal/model"
+)
+
+// 支持的代码托管平台
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// Providers 支持的代码托管平台，按 webhook 地址中的名称排列
+var Providers = []string{ProviderGitHub, ProviderGitLab, ProviderGitea}
+
+// MaxPayloadSize 推送事件请求体的大小上限，与 GitHub 的上限一致
+const MaxPayloadSize = 25 << 20
+
+var (
+	// ErrUnknownProvider 不支持的代码托管平台
+	ErrUnknownProvider = errors.New("不支持的代码托管平台")
+	// ErrInvalidSignature 请求缺少签名或签名与密钥不匹配
+	ErrInvalidSignature = errors.New("webhook 签名无效")
+	// ErrInvalidPayload 推送事件的请求体格式错误
+	ErrInvalidPayload = errors.New("无效的推送事件")
+)
+
+// 删除分支或标签时推送事件中的新提交
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+// commitPattern 完整的提交SHA
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Event 从推送事件中解析出的分支或标签的更新
+type Event struct {
+	Type    string // model.BuildEventPush 或 model.BuildEventTag，为空时事件被忽略
+	Branch  string // 推送的分支
+	Tag     string // 推送的标签
+	Commit  string // 分支或标签指向的提交
+	Ignored string // 事件被忽略的原因
+}
+
+// pushPayload 三个平台推送事件请求体中用到的字段，GitHub 和 Gitea 的格式相同
+type pushPayload struct {
+	Ref         string  `json:"ref"`
+	After       string  `json:"after"`
+	Deleted     bool    `json:"deleted"`      // GitHub
+	CheckoutSHA *string `json:"checkout_sha"` // GitLab，附注标签为标签指向的提交，删除时为 null
+	HeadCommit  *struct {
+		ID string `json:"id"`
+	} `json:"head_commit"` // GitHub、Gitea，附注标签的 after 是标签对象，这里是标签指向的提交
+}
+
+// DeliveryID 返回请求头中代码托管平台为每次投递生成的唯一ID，重新投递时不变，没有时返回空字符串
+func DeliveryID(provider string, header http.Header) string {
+	switch provider {
+	case ProviderGitHub:
+		return header.Get("X-GitHub-Delivery")
+	case ProviderGitLab:
+		return header.Get("X-Gitlab-Event-UUID")
+	case ProviderGitea:
+		return header.Get("X-Gitea-Delivery")
+	default:
+		return ""
+	}
+}
+
+// Verify 校验请求的签名：GitHub 和 Gitea 以密钥对请求体计算 HMAC-SHA256，
+// GitLab 在请求头中直接携带密钥
+func Verify(provider string, header http.Header, body []byte, secret string) error {
+	switch provider {
+	case ProviderGitHub:
+		signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+		return verifyHMAC(signature, body, secret)
+	case ProviderGitea:
+		return verifyHMAC(header.Get("X-Gitea-Signature"), body, secret)
+	case ProviderGitLab:
+		token := header.Get("X-Gitlab-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return ErrInvalidSignature
+		}
+		return nil
+	default:
+		return ErrUnknownProvider
+	}
+}
+
+// verifyHMAC 比较十六进制的签名与请求体的 HMAC-SHA256
+func verifyHMAC(signature string, body []byte, secret string) error {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) != sha256.Size {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Parse 解析推送事件。不是推送事件、删除了分支或标签时返回 Type 为空的事件并说明原因，
+// 请求体格式错误时返回 ErrInvalidPayload
+func Parse(provider string, header http.Header, body []byte) (*Event, error) {
+	var name string
+	switch provider {
+	case ProviderGitHub:
+		name = header.Get("X-GitHub-Event")
+		if name != "push" {
+			return ignored("不是推送事件: %s", name), nil
+		}
+	case ProviderGitLab:
+		name = header.Get("X-Gitlab-Event")
+		if name != "Push Hook" && name != "Tag Push Hook" {
+			return ignored("不是推送事件: %s", name), nil
+		}
+	case ProviderGitea:
+		name = header.Get("X-Gitea-Event")
+		if name != "push" {
+			return ignored("不是推送事件: %s", name), nil
+		}
+	default:
+		return nil, ErrUnknownProvider
+	}
+
+	var payload pushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return payload.event()
+}
+
+// event 按推送的引用和提交返回事件
+func (p *pushPayload) event() (*Event, error) {
+	event := &Event{}
+	if branch, ok := strings.CutPrefix(p.Ref, "refs/heads/"); ok {
+		event.Type, event.Branch = model.BuildEventPush, branch
+	} else if tag, ok := strings.CutPrefix(p.Ref, "refs/tags/"); ok {
+		event.Type, event.Tag = model.BuildEventTag, tag
+	} else {
+		return ignored("不支持的引用 %q", p.Ref), nil
+	}
+	if name := event.Branch + event.Tag; name == "" || strings.HasPrefix(name, "-") {
+		return nil, fmt.Errorf("%w: 无效的引用 %q", ErrInvalidPayload, p.Ref)
+	}
+
+	if p.Deleted || p.After == zeroCommit {
+		return ignored("删除了 %s", p.Ref), nil
+	}
+
+	event.Commit = p.After
+	switch {
+	case p.CheckoutSHA != nil && *p.CheckoutSHA != "":
+		event.Commit = *p.CheckoutSHA
+	case p.HeadCommit != nil && p.HeadCommit.ID != "":
+		event.Commit = p.HeadCommit.ID
+	}
+	if !commitPattern.MatchString(event.Commit) {
+		return nil, fmt.Errorf("%w: 无效的提交 %q", ErrInvalidPayload, event.Commit)
+	}
+	return event, nil
+}
+
+func ignored(format string, args ...interface{}) *Event {
+	return &Event{Ignored: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,396 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Vortexia/internal/model"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// fixtures testdata 中的推送事件请求体及代码托管平台发送时带的请求头
+var fixtures = map[string]http.Header{
+	"github_push.json": {
+		"X-Github-Event":    {"push"},
+		"X-Github-Delivery": {"72d3162e-cc78-11e3-81ab-4c9367dc0958"},
+	},
+	"github_tag.json": {
+		"X-Github-Event":    {"push"},
+		"X-Github-Delivery": {"8a1f0c2e-cc78-11e3-81ab-4c9367dc0958"},
+	},
+	"gitlab_push.json": {
+		"X-Gitlab-Event":      {"Push Hook"},
+		"X-Gitlab-Event-Uuid": {"13792a34-cac6-4fda-95a8-c58e00a3954e"},
+	},
+	"gitlab_tag.json": {
+		"X-Gitlab-Event":      {"Tag Push Hook"},
+		"X-Gitlab-Event-Uuid": {"4f0c7a43-1d2b-4c8e-9b5a-6e3d2f1a0b9c"},
+	},
+	"gitea_push.json": {
+		"X-Gitea-Event":    {"push"},
+		"X-Gitea-Delivery": {"f6266f16-1bf3-46a5-9ea4-602e06ead473"},
+	},
+	"gitea_tag.json": {
+		"X-Gitea-Event":    {"push"},
+		"X-Gitea-Delivery": {"0b8e2c6d-5a3f-4e1b-8c7d-9f2a1e3b4c5d"},
+	},
+}
+
+// loadFixture 读取推送事件请求体，返回按平台签名后的请求头
+func loadFixture(t *testing.T, name string) (string, http.Header, []byte) {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	provider, _, _ := strings.Cut(name, "_")
+	header := fixtures[name].Clone()
+	sign(provider, header, body, testSecret)
+	return provider, header, body
+}
+
+// sign 按平台的方式为请求签名
+func sign(provider string, header http.Header, body []byte, secret string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+	switch provider {
+	case ProviderGitHub:
+		header.Set("X-Hub-Signature-256", "sha256="+signature)
+	case ProviderGitea:
+		header.Set("X-Gitea-Signature", signature)
+	case ProviderGitLab:
+		header.Set("X-Gitlab-Token", secret)
+	}
+}
+
+func TestParseFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Event
+	}{
+		{
+			fixture: "github_push.json",
+			want:    Event{Type: model.BuildEventPush, Branch: "main", Commit: "1481a2de7b2a7d02428ad93446ab166be7793fbb"},
+		},
+		{
+			// 附注标签的 after 是标签对象，构建检出 head_commit 中标签指向的提交
+			fixture: "github_tag.json",
+			want:    Event{Type: model.BuildEventTag, Tag: "v1.2.0", Commit: "1481a2de7b2a7d02428ad93446ab166be7793fbb"},
+		},
+		{
+			fixture: "gitlab_push.json",
+			want:    Event{Type: model.BuildEventPush, Branch: "feature/login", Commit: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"},
+		},
+		{
+			fixture: "gitlab_tag.json",
+			want:    Event{Type: model.BuildEventTag, Tag: "v1.0.0", Commit: "5937ac0a7beb003549fc5fd26fc247adbce4a52e"},
+		},
+		{
+			fixture: "gitea_push.json",
+			want:    Event{Type: model.BuildEventPush, Branch: "develop", Commit: "bffeb74224043ba2feb48d137756c8a9331c449a"},
+		},
+		{
+			fixture: "gitea_tag.json",
+			want:    Event{Type: model.BuildEventTag, Tag: "v0.3.1", Commit: "bffeb74224043ba2feb48d137756c8a9331c449a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			provider, header, body := loadFixture(t, tt.fixture)
+			if err := Verify(provider, header, body, testSecret); err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			event, err := Parse(provider, header, body)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if *event != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", *event, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		modify  func(header http.Header, body []byte) []byte
+		wantErr error
+	}{
+		{name: "github valid", fixture: "github_push.json"},
+		{name: "gitea valid", fixture: "gitea_push.json"},
+		{name: "gitlab valid", fixture: "gitlab_push.json"},
+		{
+			name:    "github wrong secret",
+			fixture: "github_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				sign(ProviderGitHub, header, body, "other secret")
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "github tampered body",
+			fixture: "github_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				return []byte(strings.Replace(string(body), "refs/heads/main", "refs/heads/prod", 1))
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "github missing header",
+			fixture: "github_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				header.Del("X-Hub-Signature-256")
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "github sha1 signature",
+			fixture: "github_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				header.Set("X-Hub-Signature-256", strings.Replace(header.Get("X-Hub-Signature-256"), "sha256=", "sha1=", 1))
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "gitea wrong secret",
+			fixture: "gitea_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				sign(ProviderGitea, header, body, "other secret")
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "gitea malformed signature",
+			fixture: "gitea_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				header.Set("X-Gitea-Signature", "not-hex")
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "gitea missing header",
+			fixture: "gitea_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				header.Del("X-Gitea-Signature")
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "gitlab wrong token",
+			fixture: "gitlab_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				header.Set("X-Gitlab-Token", testSecret[:len(testSecret)-1])
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "gitlab missing token",
+			fixture: "gitlab_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				header.Del("X-Gitlab-Token")
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			// GitLab 不对请求体签名，令牌正确即通过
+			name:    "gitlab token ignores body",
+			fixture: "gitlab_push.json",
+			modify: func(header http.Header, body []byte) []byte {
+				return []byte(`{"ref":"refs/heads/other"}`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, header, body := loadFixture(t, tt.fixture)
+			if tt.modify != nil {
+				body = tt.modify(header, body)
+			}
+			if err := Verify(provider, header, body, testSecret); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("unknown provider", func(t *testing.T) {
+		if err := Verify("bitbucket", http.Header{}, nil, testSecret); !errors.Is(err, ErrUnknownProvider) {
+			t.Errorf("Verify() error = %v, want ErrUnknownProvider", err)
+		}
+	})
+	t.Run("gitlab empty secret", func(t *testing.T) {
+		if err := Verify(ProviderGitLab, http.Header{}, nil, ""); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("Verify() error = %v, want ErrInvalidSignature", err)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	const commit = "1481a2de7b2a7d02428ad93446ab166be7793fbb"
+	github := http.Header{"X-Github-Event": {"push"}}
+
+	tests := []struct {
+		name        string
+		provider    string
+		header      http.Header
+		body        string
+		want        Event
+		wantIgnored string
+		wantErr     error
+	}{
+		{
+			name:     "nested branch",
+			provider: ProviderGitHub,
+			header:   github,
+			body:     `{"ref":"refs/heads/release/1.2","after":"` + commit + `"}`,
+			want:     Event{Type: model.BuildEventPush, Branch: "release/1.2", Commit: commit},
+		},
+		{
+			name:        "github branch deletion",
+			provider:    ProviderGitHub,
+			header:      github,
+			body:        `{"ref":"refs/heads/main","after":"0000000000000000000000000000000000000000","deleted":true,"head_commit":null}`,
+			wantIgnored: "删除了 refs/heads/main",
+		},
+		{
+			name:        "gitlab branch deletion",
+			provider:    ProviderGitLab,
+			header:      http.Header{"X-Gitlab-Event": {"Push Hook"}},
+			body:        `{"ref":"refs/heads/feature","after":"0000000000000000000000000000000000000000","checkout_sha":null}`,
+			wantIgnored: "删除了 refs/heads/feature",
+		},
+		{
+			name:        "gitea tag deletion",
+			provider:    ProviderGitea,
+			header:      http.Header{"X-Gitea-Event": {"push"}},
+			body:        `{"ref":"refs/tags/v1","after":"0000000000000000000000000000000000000000"}`,
+			wantIgnored: "删除了 refs/tags/v1",
+		},
+		{
+			name:        "github ping",
+			provider:    ProviderGitHub,
+			header:      http.Header{"X-Github-Event": {"ping"}},
+			body:        `{"zen":"Keep it logically awesome."}`,
+			wantIgnored: "不是推送事件: ping",
+		},
+		{
+			name:        "gitlab merge request",
+			provider:    ProviderGitLab,
+			header:      http.Header{"X-Gitlab-Event": {"Merge Request Hook"}},
+			body:        `{}`,
+			wantIgnored: "不是推送事件: Merge Request Hook",
+		},
+		{
+			name:        "unsupported ref",
+			provider:    ProviderGitHub,
+			header:      github,
+			body:        `{"ref":"refs/notes/commits","after":"` + commit + `"}`,
+			wantIgnored: `不支持的引用 "refs/notes/commits"`,
+		},
+		{
+			name:     "invalid json",
+			provider: ProviderGitHub,
+			header:   github,
+			body:     `{"ref":`,
+			wantErr:  ErrInvalidPayload,
+		},
+		{
+			name:     "empty branch",
+			provider: ProviderGitHub,
+			header:   github,
+			body:     `{"ref":"refs/heads/","after":"` + commit + `"}`,
+			wantErr:  ErrInvalidPayload,
+		},
+		{
+			name:     "option-like branch",
+			provider: ProviderGitHub,
+			header:   github,
+			body:     `{"ref":"refs/heads/--upload-pack=evil","after":"` + commit + `"}`,
+			wantErr:  ErrInvalidPayload,
+		},
+		{
+			name:     "short commit",
+			provider: ProviderGitHub,
+			header:   github,
+			body:     `{"ref":"refs/heads/main","after":"1481a2d"}`,
+			wantErr:  ErrInvalidPayload,
+		},
+		{
+			name:     "unknown provider",
+			provider: "bitbucket",
+			header:   http.Header{},
+			body:     `{}`,
+			wantErr:  ErrUnknownProvider,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := Parse(tt.provider, tt.header, []byte(tt.body))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			want := tt.want
+			if tt.wantIgnored != "" {
+				want = Event{Ignored: tt.wantIgnored}
+			}
+			if *event != want {
+				t.Errorf("Parse() = %+v, want %+v", *event, want)
+			}
+		})
+	}
+}
+
+func TestDeliveryID(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		{fixture: "github_push.json", want: "72d3162e-cc78-11e3-81ab-4c9367dc0958"},
+		{fixture: "gitlab_push.json", want: "13792a34-cac6-4fda-95a8-c58e00a3954e"},
+		{fixture: "gitea_push.json", want: "f6266f16-1bf3-46a5-9ea4-602e06ead473"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			provider, header, _ := loadFixture(t, tt.fixture)
+			if got := DeliveryID(provider, header); got != tt.want {
+				t.Errorf("DeliveryID() = %q, want %q", got, tt.want)
+			}
+			if got := DeliveryID(provider, http.Header{}); got != "" {
+				t.Errorf("DeliveryID() without header = %q, want empty", got)
+			}
+		})
+	}
+
+	if got := DeliveryID("bitbucket", http.Header{"X-Request-Uuid": {"x"}}); got != "" {
+		t.Errorf("DeliveryID() for unknown provider = %q, want empty", got)
+	}
+}
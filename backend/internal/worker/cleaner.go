@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/service"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// defaultCleanupInterval 未配置删除过期制品的间隔时的默认值
+const defaultCleanupInterval = time.Hour
+
+// ArtifactCleaner 定期删除过期的制品。多个服务端进程同时删除同一制品不会出错
+type ArtifactCleaner struct {
+	artifactService service.ArtifactService
+	interval        time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewArtifactCleaner 创建过期制品清理器
+func NewArtifactCleaner(artifactService service.ArtifactService, cfg config.ArtifactsConfig) *ArtifactCleaner {
+	interval := time.Duration(cfg.CleanupInterval) * time.Minute
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+	return &ArtifactCleaner{
+		artifactService: artifactService,
+		interval:        interval,
+		stop:            make(chan struct{}),
+	}
+}
+
+// Start 启动清理协程，启动时先清理一次
+func (c *ArtifactCleaner) Start() {
+	c.wg.Add(1)
+	go c.loop()
+}
+
+// Stop 停止清理协程，等待正在进行的清理结束
+func (c *ArtifactCleaner) Stop() {
+	close(c.stop)
+	c.wg.Wait()
+}
+
+func (c *ArtifactCleaner) loop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.clean()
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *ArtifactCleaner) clean() {
+	n, err := c.artifactService.DeleteExpired(context.Background())
+	if err != nil {
+		logger.Error("Failed to delete expired artifacts", zap.Int("deleted", n), zap.Error(err))
+		return
+	}
+	if n > 0 {
+		logger.Info("Deleted expired artifacts", zap.Int("count", n))
+	}
+}
@@ -0,0 +1,233 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"Vortexia/internal/config"
+	"Vortexia/internal/model"
+	"Vortexia/internal/repository"
+	"Vortexia/internal/service"
+	"Vortexia/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	pollInterval             = time.Second      // 队列为空时的轮询间隔
+	defaultVisibilityTimeout = 60 * time.Second // 未配置租约时长时的默认值
+)
+
+// Pool 构建工作池，从构建队列领取构建并执行
+type Pool struct {
+	queue        repository.BuildQueue
+	cancels      repository.BuildCancelSignal
+	buildService service.BuildService
+	cfg          config.WorkerConfig
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPool 创建构建工作池
+func NewPool(queue repository.BuildQueue, cancels repository.BuildCancelSignal, buildService service.BuildService, cfg config.WorkerConfig) *Pool {
+	return &Pool{
+		queue:        queue,
+		cancels:      cancels,
+		buildService: buildService,
+		cfg:          cfg,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start 启动工作协程、租约回收协程和取消信号监听协程。
+// 不在本进程执行构建时仍需回收失联的远程执行器的构建。
+// 启动前先找回未能入队或队列数据丢失的构建
+func (p *Pool) Start() {
+	if _, err := p.buildService.RequeueLost(context.Background()); err != nil {
+		logger.Error("Failed to requeue builds missing from the build queue", zap.Error(err))
+	}
+
+	p.wg.Add(1)
+	go p.reap()
+
+	if p.cfg.Concurrency <= 0 {
+		logger.Info("Build workers disabled")
+		return
+	}
+
+	p.wg.Add(1)
+	go p.watchCancels()
+
+	for i := 0; i < p.cfg.Concurrency; i++ {
+		p.wg.Add(1)
+		go p.work(i)
+	}
+
+	logger.Info("Build workers started", zap.Int("concurrency", p.cfg.Concurrency))
+}
+
+// Stop 停止领取新构建并等待执行中的构建结束。
+// ctx 到期时直接返回，未完成的构建在租约过期后由其他进程重新执行。
+func (p *Pool) Stop(ctx context.Context) error {
+	close(p.stop)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) visibility() time.Duration {
+	if p.cfg.VisibilityTimeout <= 0 {
+		return defaultVisibilityTimeout
+	}
+	return time.Duration(p.cfg.VisibilityTimeout) * time.Second
+}
+
+// work 工作协程主循环
+func (p *Pool) work(id int) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		item, err := p.buildService.ClaimBuild(context.Background(), p.cfg.Labels, p.visibility())
+		if err != nil {
+			logger.Error("Failed to dequeue build", zap.Int("worker", id), zap.Error(err))
+		}
+		if item == nil {
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		p.process(id, item)
+	}
+}
+
+// process 执行领取到的构建，执行期间定期续租
+func (p *Pool) process(id int, item *repository.QueueItem) {
+	ctx := context.Background()
+	log := logger.Logger.With(zap.Int("worker", id), zap.Int("build_id", item.BuildID))
+
+	if p.cfg.MaxDeliveries > 0 && item.Deliveries > p.cfg.MaxDeliveries {
+		// 构建反复中断（例如每次都导致进程崩溃），不再重试
+		log.Error("Build exceeded max deliveries", zap.Int("deliveries", item.Deliveries))
+		if err := p.buildService.UpdateStatus(item.BuildID, model.BuildStatusFailed); err != nil {
+			log.Error("Failed to mark build as failed", zap.Error(err))
+			return
+		}
+		p.ack(log, item.BuildID)
+		return
+	}
+
+	done := make(chan struct{})
+	go p.keepAlive(log, item.BuildID, done)
+
+	log.Info("Executing build", zap.Int("deliveries", item.Deliveries))
+	err := p.buildService.ExecuteBuild(ctx, item.BuildID)
+	close(done)
+
+	if err != nil {
+		// 引擎故障，不确认，租约过期后重新投递
+		log.Error("Build execution failed", zap.Error(err))
+		return
+	}
+
+	p.ack(log, item.BuildID)
+}
+
+// keepAlive 在构建执行期间按租约时长的三分之一周期续租
+func (p *Pool) keepAlive(log *zap.Logger, buildID int, done <-chan struct{}) {
+	ticker := time.NewTicker(p.visibility() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := p.queue.Extend(context.Background(), buildID, p.visibility()); err != nil {
+				log.Warn("Failed to extend build lease", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (p *Pool) ack(log *zap.Logger, buildID int) {
+	if err := p.queue.Ack(context.Background(), buildID); err != nil {
+		log.Error("Failed to ack build", zap.Error(err))
+	}
+}
+
+// reap 定期将租约过期的构建放回队列
+func (p *Pool) reap() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.visibility() / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			n, err := p.queue.RequeueExpired(context.Background())
+			if err != nil {
+				logger.Error("Failed to requeue expired builds", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				logger.Warn("Requeued builds with expired lease", zap.Int("count", n))
+			}
+		}
+	}
+}
+
+// watchCancels 监听构建取消信号，终止本进程中正在执行的对应构建。
+// 订阅断开后重新订阅，期间丢失的信号由引擎在步骤之间检查构建状态兜底。
+func (p *Pool) watchCancels() {
+	defer p.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-p.stop
+		cancel()
+	}()
+
+	for ctx.Err() == nil {
+		ids, err := p.cancels.Subscribe(ctx)
+		if err != nil {
+			logger.Error("Failed to subscribe build cancel signal", zap.Error(err))
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		for id := range ids {
+			if p.buildService.AbortBuild(id) {
+				logger.Info("Aborting canceled build", zap.Int("build_id", id))
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+-- +goose Up
+-- 构建日志分块表，步骤输出以追加方式写入
+CREATE TABLE build_log_chunks (
+    id BIGSERIAL PRIMARY KEY,
+    step_id INTEGER NOT NULL REFERENCES build_steps(id) ON DELETE CASCADE,
+    chunk_index INTEGER NOT NULL,
+    byte_offset BIGINT NOT NULL, -- 分块在日志中的起始字节
+    line_offset INTEGER NOT NULL, -- 分块之前的完整行数
+    line_count INTEGER NOT NULL, -- 分块中的换行符数量
+    data BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (step_id, chunk_index)
+);
+
+CREATE INDEX idx_build_log_chunks_step_offset ON build_log_chunks(step_id, byte_offset);
+CREATE INDEX idx_build_log_chunks_step_line ON build_log_chunks(step_id, line_offset);
+
+-- +goose Down
+DROP TABLE IF EXISTS build_log_chunks;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- 记录取消构建的用户和时间
+ALTER TABLE builds ADD COLUMN canceled_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE builds ADD COLUMN canceled_at TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE builds DROP COLUMN IF EXISTS canceled_at;
+ALTER TABLE builds DROP COLUMN IF EXISTS canceled_by;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- 步骤的每次执行记录，步骤重试时一个步骤对应多条记录
+CREATE TABLE build_step_attempts (
+    id SERIAL PRIMARY KEY,
+    step_id INTEGER NOT NULL REFERENCES build_steps(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL, -- 第几次执行，从1开始
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    exit_code INTEGER,
+    log_offset BIGINT NOT NULL DEFAULT 0, -- 本次执行的输出在步骤日志中的起始字节
+    log_bytes BIGINT NOT NULL DEFAULT 0,
+    log_line INTEGER NOT NULL DEFAULT 0, -- 本次执行的输出在步骤日志中的起始行
+    log_lines INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE,
+    duration INTEGER, -- 执行持续时间（秒）
+    UNIQUE (step_id, attempt)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS build_step_attempts;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- 构建创建时保存流水线配置快照，重新执行时使用相同的配置
+ALTER TABLE builds ADD COLUMN config TEXT;
+ALTER TABLE builds ADD COLUMN rerun_of INTEGER REFERENCES builds(id) ON DELETE SET NULL;
+ALTER TABLE builds ADD COLUMN rerun_mode VARCHAR(20); -- all: 全部重新执行，failed: 只执行失败和跳过的步骤
+ALTER TABLE build_steps ADD COLUMN reused_from INTEGER REFERENCES build_steps(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_builds_rerun_of ON builds(rerun_of);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_builds_rerun_of;
+ALTER TABLE build_steps DROP COLUMN IF EXISTS reused_from;
+ALTER TABLE builds DROP COLUMN IF EXISTS rerun_mode;
+ALTER TABLE builds DROP COLUMN IF EXISTS rerun_of;
+ALTER TABLE builds DROP COLUMN IF EXISTS config;
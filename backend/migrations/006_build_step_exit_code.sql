@@ -0,0 +1,6 @@
+-- +goose Up
+-- 步骤最后一次执行的命令退出码
+ALTER TABLE build_steps ADD COLUMN exit_code INTEGER;
+
+-- +goose Down
+ALTER TABLE build_steps DROP COLUMN IF EXISTS exit_code;
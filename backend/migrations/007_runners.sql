@@ -0,0 +1,23 @@
+-- +goose Up
+-- 远程执行器，令牌只保存SHA-256
+CREATE TABLE runners (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    labels TEXT[] NOT NULL DEFAULT '{}',
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    version VARCHAR(50) NOT NULL DEFAULT '',
+    paused BOOLEAN NOT NULL DEFAULT false,
+    last_seen_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- 执行构建的远程执行器，在服务端执行的构建为空
+ALTER TABLE builds ADD COLUMN runner_id INTEGER REFERENCES runners(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_builds_runner ON builds(runner_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_builds_runner;
+ALTER TABLE builds DROP COLUMN IF EXISTS runner_id;
+DROP TABLE IF EXISTS runners;
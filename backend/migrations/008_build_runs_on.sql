@@ -0,0 +1,6 @@
+-- +goose Up
+-- 构建创建时流水线 runs_on 的标签表达式，为空表示任意执行器都可以执行
+ALTER TABLE builds ADD COLUMN runs_on TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE builds DROP COLUMN IF EXISTS runs_on;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- 构建的作业，作业之间按 needs 依赖关系组成有向无环图
+CREATE TABLE build_jobs (
+    id SERIAL PRIMARY KEY,
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    stage VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    needs TEXT[] NOT NULL DEFAULT '{}', -- 依赖的作业名称
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    duration INTEGER, -- 执行持续时间（秒）
+    job_order INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (build_id, name)
+);
+
+-- 早期构建的步骤不属于任何作业
+ALTER TABLE build_steps ADD COLUMN job_id INTEGER REFERENCES build_jobs(id) ON DELETE CASCADE;
+CREATE INDEX idx_build_steps_job_id ON build_steps(job_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_build_steps_job_id;
+ALTER TABLE build_steps DROP COLUMN IF EXISTS job_id;
+DROP TABLE IF EXISTS build_jobs;
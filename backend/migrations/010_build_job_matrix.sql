@@ -0,0 +1,8 @@
+-- +goose Up
+-- 按矩阵展开的作业记录展开前的作业名称和矩阵变量的取值（name=value）
+ALTER TABLE build_jobs ADD COLUMN matrix_of VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE build_jobs ADD COLUMN matrix TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE build_jobs DROP COLUMN IF EXISTS matrix;
+ALTER TABLE build_jobs DROP COLUMN IF EXISTS matrix_of;
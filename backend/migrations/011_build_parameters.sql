@@ -0,0 +1,6 @@
+-- +goose Up
+-- 触发构建时指定的参数（name=value），按流水线中声明的顺序保存
+ALTER TABLE builds ADD COLUMN parameters TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE builds DROP COLUMN IF EXISTS parameters;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- 项目和流水线的密钥，值以 AES-256-GCM 加密保存，key_id 为加密使用的主密钥
+CREATE TABLE secrets (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    pipeline_id INTEGER REFERENCES pipelines(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    key_id VARCHAR(50) NOT NULL,
+    value BYTEA NOT NULL,
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- 同一作用域内密钥名称唯一，pipeline_id 为空的是项目的密钥
+CREATE UNIQUE INDEX idx_secrets_project_name ON secrets(project_id, name) WHERE pipeline_id IS NULL;
+CREATE UNIQUE INDEX idx_secrets_pipeline_name ON secrets(pipeline_id, name) WHERE pipeline_id IS NOT NULL;
+CREATE INDEX idx_secrets_key_id ON secrets(key_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS secrets;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- 构建制品，文件内容保存在制品存储中，storage_key 为对象在存储中的键
+CREATE TABLE artifacts (
+    id SERIAL PRIMARY KEY,
+    build_id INTEGER NOT NULL REFERENCES builds(id) ON DELETE CASCADE,
+    job_id INTEGER NOT NULL REFERENCES build_jobs(id) ON DELETE CASCADE,
+    path VARCHAR(1000) NOT NULL,
+    size BIGINT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    storage_key VARCHAR(1200) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_artifacts_job_path ON artifacts(job_id, path);
+CREATE INDEX idx_artifacts_build_id ON artifacts(build_id);
+CREATE INDEX idx_artifacts_expires_at ON artifacts(expires_at) WHERE expires_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS artifacts;
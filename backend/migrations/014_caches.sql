@@ -0,0 +1,19 @@
+-- +goose Up
+-- 项目的依赖缓存，压缩包保存在制品存储中，同一项目中缓存键唯一
+CREATE TABLE caches (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    storage_key VARCHAR(300) NOT NULL,
+    build_id INTEGER REFERENCES builds(id) ON DELETE SET NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_caches_project_key ON caches(project_id, key);
+CREATE INDEX idx_caches_project_last_used ON caches(project_id, last_used_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS caches;
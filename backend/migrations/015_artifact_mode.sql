@@ -0,0 +1,6 @@
+-- +goose Up
+-- 制品文件的权限位，下载到后续作业的工作目录时保留（如可执行文件），默认 0644
+ALTER TABLE artifacts ADD COLUMN mode INTEGER NOT NULL DEFAULT 420;
+
+-- +goose Down
+ALTER TABLE artifacts DROP COLUMN IF EXISTS mode;
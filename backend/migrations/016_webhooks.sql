@@ -0,0 +1,20 @@
+-- +goose Up
+-- 项目的 webhook 密钥，以 AES-256-GCM 加密保存，key_id 为加密使用的主密钥
+CREATE TABLE project_webhooks (
+    project_id INTEGER PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    key_id VARCHAR(50) NOT NULL,
+    secret BYTEA NOT NULL,
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_project_webhooks_key_id ON project_webhooks(key_id);
+
+-- 推送事件触发的构建记录事件类型（push 或 tag）和推送的标签，通过API触发的构建为空
+ALTER TABLE builds ADD COLUMN event VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE builds ADD COLUMN tag VARCHAR(255) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE builds DROP COLUMN IF EXISTS tag;
+ALTER TABLE builds DROP COLUMN IF EXISTS event;
+DROP TABLE IF EXISTS project_webhooks;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- 已处理的 webhook 投递，按代码托管平台的投递ID去重，防止重放签名有效的请求重复创建构建
+CREATE TABLE webhook_deliveries (
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    delivery_id VARCHAR(100) NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, provider, delivery_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_received_at ON webhook_deliveries(received_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;